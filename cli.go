@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gitlab.dev.ict/golang/go-ai/config"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
+)
+
+const (
+	cmdKbExport = "kb-export"
+	cmdKbImport = "kb-import"
+)
+
+// commandArgs returns CLI subcommand with its args (positional args after app flags), env file argument is skipped.
+func commandArgs() []string {
+	args := flag.Args()
+	if len(args) > 0 && strings.HasSuffix(args[0], ".env") {
+		args = args[1:]
+	}
+	return args
+}
+
+// runCommand runs CLI subcommand and returns exit code:
+//
+//	go-ai [app flags] kb-export [-out kb.jsonl] [-vectors] [-page 200]
+//	go-ai [app flags] kb-import -in kb.jsonl [-class KnowledgeBaseProd] [-reembed] [-batch 100]
+func runCommand(cfg *config.Config, args []string) int {
+	var run func(ctx context.Context, store w.VectorStore, args []string) error
+	switch args[0] {
+	case cmdKbExport:
+		run = kbExport
+	case cmdKbImport:
+		run = kbImport
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s (available: %s, %s)\n", args[0], cmdKbExport, cmdKbImport)
+		return 2
+	}
+
+	if err := cfg.InitVectorStore(); err != nil {
+		fmt.Fprintf(os.Stderr, "init vector store: %v\n", err)
+		return 1
+	}
+	defer cfg.Close()
+	if err := run(context.Background(), cfg.Store, args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func kbExport(ctx context.Context, store w.VectorStore, args []string) error {
+	fs := flag.NewFlagSet(cmdKbExport, flag.ContinueOnError)
+	out := fs.String("out", fmt.Sprintf("kb_%s.jsonl", time.Now().Format("2006-01-02_15-04-05")), "output JSONL file")
+	vectors := fs.Bool("vectors", false, "export vectors")
+	page := fs.Int("page", w.DefaultDumpPageSize, "objects per store request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	d, ok := store.(w.Dumper)
+	if !ok {
+		return fmt.Errorf("store %T does not support export", store)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	count, err := w.Export(ctx, d, f, w.DumpOptions{PageSize: *page, WithVector: *vectors})
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d objects to %s\n", count, *out)
+	return nil
+}
+
+func kbImport(ctx context.Context, store w.VectorStore, args []string) error {
+	fs := flag.NewFlagSet(cmdKbImport, flag.ContinueOnError)
+	in := fs.String("in", "", "input JSONL file (- for stdin)")
+	class := fs.String("class", "", "target Weaviate class, created if absent (default - class of the store)")
+	reEmbed := fs.Bool("reembed", false, "ignore vectors of the dump and vectorize objects again")
+	batch := fs.Int("batch", w.DefaultBatchSize, "objects per batch request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" {
+		return fmt.Errorf("-in is required")
+	}
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	target, err := w.ImportTarget(ctx, store, *class)
+	if err != nil {
+		return err
+	}
+	res, err := w.Import(ctx, target, r, w.ImportOptions{BatchSize: *batch, ReEmbed: *reEmbed})
+	fmt.Fprintln(os.Stderr, res)
+	for _, failed := range res.Failed() {
+		fmt.Fprintf(os.Stderr, "failed: id=%s title=%s#%d: %s\n", failed.ID, failed.Title, failed.ChunkNo, failed.Error)
+	}
+	return err
+}
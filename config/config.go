@@ -1,13 +1,17 @@
 package config
 
 import (
+	"context"
 	_ "embed"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"text/template"
+	"time"
 
 	"github.com/artyom/autoflags"
 	"github.com/caarlos0/env/v6"
@@ -20,6 +24,7 @@ import (
 	"gitlab.dev.ict/golang/go-ai/handlers"
 	"gitlab.dev.ict/golang/go-ai/helpers"
 	"gitlab.dev.ict/golang/go-ai/logic/ailogic"
+	"gitlab.dev.ict/golang/go-ai/logic/ailogic/callbackhandlers"
 	"gitlab.dev.ict/golang/go-ai/logic/ailogic/llm"
 	"gitlab.dev.ict/golang/go-ai/logic/biz"
 	dbi "gitlab.dev.ict/golang/go-ai/logic/db_info_collector"
@@ -38,6 +43,13 @@ import (
 
 const (
 	DefPathToDocx = "./assets/voip_ritm_docs"
+
+	VectorStoreWeaviate = "weaviate"
+	VectorStoreMemory   = "memory"
+	EmbedModelNone      = "none"
+	RerankerLLM         = "llm"
+	RerankerLexical     = "lexical"
+	RerankerNone        = "none"
 )
 
 type Config struct {
@@ -57,7 +69,34 @@ type Config struct {
 	ISV               bool                  `json:"isv" flag:"isv,skip server certificate verification"`
 	SslPath           string                `json:"sslPath" default:"/etc/ssl/certs/ca-certificates.crt" env:"GO_AI_SSL_PATH" flag:"ssl,SSL cert path"`
 	LLMModel          string                `json:"llmModel" default:"gpt-4" env:"GO_AI_DEF_LLM_MODEL" flag:"lm,default LLM model"`
+	PathLLMProfiles   string                `json:"pathLlmProfiles" default:"assets/llm_profiles.yml" env:"GO_AI_LLM_PROFILES" flag:"llm-profiles,YAML file of LLM profiles (provider, base URL, model, parameters) and profiles of chains"`
+	LLMChains         string                `json:"llmChains" env:"GO_AI_LLM_CHAINS" flag:"llm-chains,override profiles of chains: chain=profile[,chain=profile] (e.g. agent_first=local)"`
 	IsNeedPopulateVDB bool                  `json:"isNeedPopulateVDB" default:"false" flag:"vdb,initiate vector database population"`
+	ChunkTokens       int                   `json:"chunkTokens" default:"800" env:"GO_AI_CHUNK_TOKENS" flag:"chunk-tokens,target size of document chunk in tokens"`
+	ChunkOverlap      int                   `json:"chunkOverlap" default:"100" env:"GO_AI_CHUNK_OVERLAP" flag:"chunk-overlap,overlap between document chunks in tokens"`
+	IngestLedgerPath  string                `json:"ingestLedgerPath" default:"./data/ingest.db" env:"GO_AI_INGEST_LEDGER" flag:"ingest-ledger,sqlite file with ledger of ingested documents"`
+	IngestWorkers     int                   `json:"ingestWorkers" default:"2" env:"GO_AI_INGEST_WORKERS" flag:"ingest-workers,count of background ingestion workers"`
+	IsIngestEnrich    bool                  `json:"isIngestEnrich" env:"GO_AI_INGEST_ENRICH" flag:"ingest-enrich,generate summary, keywords, language and category of ingested chunks with LLM"`
+	PathEnrichPrompt  string                `json:"pathEnrichPrompt" default:"assets/prompt_templates/prompt_sys_summarize.tmpl" env:"GO_AI_ENRICH_PROMPT"`
+	PathRunbooks      string                `json:"pathRunbooks" env:"GO_AI_RUNBOOKS" flag:"runbooks,comma separated directories/files of Markdown and TXT runbooks"`
+	BundleMaxMB       int                   `json:"bundleMaxMb" default:"100" env:"GO_AI_BUNDLE_MAX_MB" flag:"bundle-max-mb,max size of uploaded ZIP bundle (and of the request body) in MB"`
+	BundleMaxEntries  int                   `json:"bundleMaxEntries" default:"200" env:"GO_AI_BUNDLE_MAX_ENTRIES" flag:"bundle-max-entries,max count of files in uploaded ZIP bundle"`
+	IsConfSyncOFF     bool                  `json:"isConfSyncOff" env:"GO_AI_CONF_SYNC_OFF" flag:"conf-sync-off,disable incremental sync of Confluence (page tree is crawled by goconfluence)"`
+	ConfluenceToken   string                `json:"-" env:"CONFLUENCE_TOKEN"`
+	IsCrawlOFF        bool                  `json:"isCrawlOff" env:"GO_AI_CRAWL_OFF" flag:"crawl-off,disable crawling of lifecell.ua (only fixed list of pages is scraped)"`
+	CrawlDepth        int                   `json:"crawlDepth" default:"2" env:"GO_AI_CRAWL_DEPTH" flag:"crawl-depth,depth of links followed by lifecell.ua crawler"`
+	CrawlMaxPages     int                   `json:"crawlMaxPages" default:"300" env:"GO_AI_CRAWL_MAX_PAGES" flag:"crawl-max-pages,max count of pages requested by lifecell.ua crawler"`
+	CrawlDelayMs      int                   `json:"crawlDelayMs" default:"1000" env:"GO_AI_CRAWL_DELAY_MS" flag:"crawl-delay-ms,politeness delay between crawler requests in milliseconds"`
+	VectorStore       string                `json:"vectorStore" default:"weaviate" env:"GO_AI_VECTOR_STORE" flag:"vector-store,vector store implementation (weaviate,memory)"`
+	VectorClass       string                `json:"vectorClass" default:"KnowledgeBase" env:"GO_AI_VECTOR_CLASS" flag:"vector-class,Weaviate class of knowledge base (use separate class per embedding model)"`
+	EmbedModel        string                `json:"embedModel" default:"none" env:"GO_AI_EMBED_MODEL" flag:"embed-model,client-side embedding model (none - vectorizer of the store, OpenAI model e.g. text-embedding-3-large, hash[-dims] - local), set it with a new VectorClass"`
+	Reranker          string                `json:"reranker" default:"llm" env:"GO_AI_RERANKER" flag:"reranker,reranker of retrieved documents (llm,lexical,none)"`
+	IsQueryRewriteOFF bool                  `json:"isQueryRewriteOff" env:"GO_AI_QUERY_REWRITE_OFF" flag:"query-rewrite-off,disable multi-query retrieval (UA/EN, keywords, HyDE variants of the query)"`
+	RerankCandidates  int                   `json:"rerankCandidates" default:"20" env:"GO_AI_RERANK_CANDIDATES" flag:"rerank-candidates,count of candidates fetched from vector store for reranking"`
+	RetrieveTopK      int                   `json:"retrieveTopK" default:"4" env:"GO_AI_RETRIEVE_TOP_K" flag:"retrieve-top-k,max count of documents passed to agent"`
+	RerankMinScore    float64               `json:"rerankMinScore" default:"0.2" env:"GO_AI_RERANK_MIN_SCORE" flag:"rerank-min-score,min rerank score (0..1) of documents passed to agent"`
+	RetrieveTokens    int                   `json:"retrieveTokens" default:"3000" env:"GO_AI_RETRIEVE_TOKENS" flag:"retrieve-tokens,token budget of documents passed to agent"`
+	VectorStorePath   string                `json:"vectorStorePath" default:"./data/vectors.json" env:"GO_AI_VECTOR_STORE_PATH" flag:"vector-store-path,file of in-memory vector store (empty - not persisted)"`
 	DBTmCimURL        string                `json:"dbURLTmCim" default:"" env:"DB_URL_TM_CIM" flag:"db-tm-cim,Oracle DB URL"`
 	IsAiDbOFF         bool                  `json:"isAiDbOff" env:"IS_AI_DB_OFF" flag:"aidboff,disable AI DB"`
 	IsForceInitRoles  bool                  `json:"isForceInitRoles" env:"IS_FORCE_INIT_ROLES" flag:"force-init-roles,force roles init"`
@@ -71,7 +110,8 @@ type Config struct {
 
 	// Runtime components
 	Log                   *gologgers.Logger
-	LLM                   llms.Model
+	LLM                   llms.Model    // LLM of profile "default"
+	Models                *llm.Registry // LLM profiles of chains and AI agents
 	AI                    *goopenai.Client
 	DbTmCim               dbi.DBSchemaInfoProvider
 	SqliteChatsDB         *gorm.DB
@@ -82,7 +122,9 @@ type Config struct {
 	docx                  *services.DocxPprocessor      // Processor for DOCX files.
 	scrapperWebLifecellUA *services.WebPagesProcessor   // Web pages processor for Lifecell UA.
 	scrapperWebOther      *services.WebPagesProcessor   // Web pages processor for other sources.
-	Kb                    *w.KnowledgeBase
+	Kb                    *w.KnowledgeBase              // nil if vector store is not Weaviate
+	Store                 w.VectorStore
+	Embedder              llm.Embedder // nil if EmbedModel is none
 	Rag                   *services.RAGService
 	UserStorage           us.UserStorage
 	PermissionsConfig     *models.PermissionsConfig
@@ -133,6 +175,14 @@ func (c *Config) validate() error {
 		return fmt.Errorf("port must be specified")
 	}
 
+	if c.VectorStore != VectorStoreWeaviate && c.VectorStore != VectorStoreMemory {
+		return fmt.Errorf("unknown vector store: %s", c.VectorStore)
+	}
+
+	if c.Reranker != RerankerLLM && c.Reranker != RerankerLexical && c.Reranker != RerankerNone {
+		return fmt.Errorf("unknown reranker: %s", c.Reranker)
+	}
+
 	if c.WithSSL && c.DirAppSSL == "" {
 		return fmt.Errorf("SSL directory must be specified when SSL is enabled")
 	}
@@ -165,7 +215,9 @@ func (c *Config) IsProduction() bool {
 
 func (c *Config) initialize() error {
 	// Initialize AI and LLM services
-	c.LLM = llm.OpenAI(c.LLMModel, c.Log, c.GetHTTPClient())
+	if err := c.initModels(); err != nil {
+		return err
+	}
 	c.AI = goopenai.New().
 		WithProxy(true, "").
 		WithLogger(gologgers.New(
@@ -203,17 +255,15 @@ func (c *Config) initialize() error {
 	c.WsGetter = biz.NewWSGetter(c.Log, c.Cimws, c.Omws)
 
 	// Initialize vector database and knowledge base
-	c.WvCfg.Log = c.Log
-	c.Kb = w.NewKnowledgeBase(
-		w.NewWVClient(c.WvCfg),
-		gologgers.New(
-			gologgers.WithChannel("VECTOR"),
-			gologgers.WithLevel(c.LogOptions.LogLevel),
-			gologgers.WithOC(c.LogOptions.IsOutConsole),
-		),
-		w.DefaultClassKB,
-		"assets/knowledge_base.json",
-	)
+	if err := c.initVectorStore(); err != nil {
+		return err
+	}
+	// CLI commands skip the check, so the class can be exported for migration
+	if c.Kb != nil {
+		if err := c.Kb.CheckClassConfig(context.Background()); err != nil {
+			return fmt.Errorf("vector class %s must be migrated (kb-export, kb-import -class <new class>, GO_AI_VECTOR_CLASS=<new class>): %w", c.VectorClass, err)
+		}
+	}
 
 	// Initialize content processors
 	processorConfluence := services.NewConfluenceProcessor(c.Log, true).
@@ -222,22 +272,91 @@ func (c *Config) initialize() error {
 		Debug(c.IsDebug).
 		EBT(services.ExpBV)
 
+	crawlOpts := services.CrawlOptions{
+		MaxDepth:     c.CrawlDepth,
+		MaxPages:     c.CrawlMaxPages,
+		PathPrefixes: services.Prefixes_LifecellUA,
+		UseSitemap:   true,
+		Delay:        time.Duration(c.CrawlDelayMs) * time.Millisecond,
+	}
 	processorScrapperWebLifecellUA := services.NewWPPLifecellUA(c.Log).
 		WithWebURLs(services.Links_LifecellUA)
+	if !c.IsCrawlOFF {
+		processorScrapperWebLifecellUA.WithCrawl(crawlOpts)
+	}
 
 	processorScrapperWebOther := services.NewWPPOther(c.Log)
-	processorDocx := services.NewDocxPprocessor(c.Log, gonet.NewRestyClient(c.Log)).
+	// tables are rendered as markdown, so chunker keeps them whole
+	processorDocx := services.NewDocxPprocessor(c.Log, gonet.NewRestyClient(c.Log)).WithTableStyle(services.TblStyleMD).
 		WithFilePaths(lo.Must(filepath.Glob(filepath.Join(utils.ExpandPath(DefPathToDocx), "*.docx")))...)
+	processorPDF := services.NewPDFPprocessor(c.Log, gonet.NewRestyClient(c.Log)).
+		WithFilePaths(lo.Must(filepath.Glob(filepath.Join(utils.ExpandPath(DefPathToDocx), "*.pdf")))...)
+	processorSheet := services.NewSheetPprocessor(c.Log).
+		WithFilePaths(append(lo.Must(filepath.Glob(filepath.Join(utils.ExpandPath(DefPathToDocx), "*.xlsx"))), lo.Must(filepath.Glob(filepath.Join(utils.ExpandPath(DefPathToDocx), "*.csv")))...)...)
+	runbooks := lo.Compact(lo.Map(strings.Split(c.PathRunbooks, ","), func(p string, _ int) string { return strings.TrimSpace(p) }))
+	processorMarkdown := services.NewMarkdownPprocessor(c.Log).
+		WithPaths(lo.Map(runbooks, func(p string, _ int) string { return utils.ExpandPath(p) })...)
 
 	// Initialize RAG service
-	c.Rag = services.NewRAGService(c.Log, c.Kb, c.AI).
+	c.Rag = services.NewRAGService(c.Log, c.Store, c.AI).
 		SetWS(c.WsGetter).
 		LLM(c.LLM).
+		WithModels(c.Models).
 		DBCim(c.DbTmCim).
 		AppendLogic(processorConfluence).
 		AppendLogic(processorDocx).
+		AppendLogic(processorPDF).
+		AppendLogic(processorSheet).
+		AppendLogic(processorMarkdown).
 		AppendLogic(processorScrapperWebLifecellUA).
-		AppendLogic(processorScrapperWebOther)
+		AppendLogic(processorScrapperWebOther).
+		WithRetriever(c.newRetriever())
+	bundleLimits := services.BundleLimits{MaxSize: int64(c.BundleMaxMB) << 20, MaxEntries: c.BundleMaxEntries}
+	// uploads, URLs and attachments are processed by new processors, web pages are not crawled unless the job asks for it.
+	// Bundles are uploaded only, so bundle processor is not a data source of full runs
+	c.Rag.Files().
+		Register(models.LogicTypeDocx, []string{".docx"}, services.MimeDocx).
+		Register(models.LogicTypePDF, []string{".pdf"}, services.MimePDF).
+		Register(models.LogicTypeSheet, []string{".xlsx", ".csv"}, services.MimeXLSX, services.MimeCSV).
+		Register(models.LogicTypeMarkdown, services.MarkdownExts, services.MimeMarkdown).
+		Register(models.LogicTypeWebOther, []string{".html", ".htm"}, helpers.MT_HTML).
+		Register(models.LogicTypeBundle, []string{".zip"}, services.MimeZip).
+		RegisterFactory(models.LogicTypeDocx, func() models.Logic {
+			return services.NewDocxPprocessor(c.Log, gonet.NewRestyClient(c.Log)).WithTableStyle(services.TblStyleMD)
+		}).
+		RegisterFactory(models.LogicTypePDF, func() models.Logic { return services.NewPDFPprocessor(c.Log, gonet.NewRestyClient(c.Log)) }).
+		RegisterFactory(models.LogicTypeSheet, func() models.Logic { return services.NewSheetPprocessor(c.Log) }).
+		RegisterFactory(models.LogicTypeMarkdown, func() models.Logic { return services.NewMarkdownPprocessor(c.Log) }).
+		RegisterFactory(models.LogicTypeWebLifecell, func() models.Logic { return services.NewWPPLifecellUA(c.Log) }).
+		RegisterFactory(models.LogicTypeWebOther, func() models.Logic { return services.NewWPPOther(c.Log) }).
+		RegisterFactory(models.LogicTypeBundle, func() models.Logic {
+			return services.NewBundlePprocessor(c.Log, c.Rag.FileLogic).WithLimits(bundleLimits)
+		})
+
+	if ledger, err := services.NewLedgerSqlite(c.IngestLedgerPath); err != nil {
+		c.Log.Errorf("Ingestion ledger[%s] init failed, incremental ingestion is disabled, documents are overwritten on every run: %v", c.IngestLedgerPath, err)
+	} else {
+		c.Rag.WithLedger(ledger)
+		if c.IsIngestEnrich {
+			c.initEnricher(ledger.DB())
+		}
+		if c.IsConfSyncOFF {
+			c.Log.Info("Confluence incremental sync is disabled")
+		} else if state, err := services.NewConfStateGorm(ledger.DB()); err != nil {
+			c.Log.Errorf("Confluence sync state init failed, full crawl is used: %v", err)
+		} else {
+			processorConfluence.
+				WithSync(services.NewConfluenceClient(gonet.NewRestyClient(c.Log), services.URL_CE, c.ConfluenceToken), state).
+				WithAttachments(c.Rag.FileLogic)
+		}
+		if jobs, err := services.NewJobQueue(c.Log, ledger.DB(), c.Rag); err != nil {
+			c.Log.Errorf("Ingestion job queue init failed: %v", err)
+		} else if err = jobs.WithWorkers(c.IngestWorkers).WithCrawl(crawlOpts).Start(context.Background()); err != nil {
+			c.Log.Errorf("Ingestion job queue start failed: %v", err)
+		} else {
+			c.Rag.WithJobQueue(jobs)
+		}
+	}
 
 	// Initialize user storage and authentication
 	c.UserStorage = lo.Must(repos.NewUserStoragePG(
@@ -269,7 +388,177 @@ func (c *Config) initialize() error {
 	}
 
 	c.Log.Debugf("--- PermissionsConfig:\n%s", c.PermissionsConfig.String())
-	c.Log.Infof("Total items in vector db => %d", c.Kb.TotalItems())
+	if total, err := c.Store.Count(context.Background()); err != nil {
+		c.Log.Errorf("Count items in vector db failed: %v", err)
+	} else {
+		c.Log.Infof("Total items in vector db => %d", total)
+	}
+
+	if c.IsNeedPopulateVDB {
+		go func() {
+			for _, diff := range c.Rag.RunLogicsForDataSources(utils.GenerateCtxWithRid()) {
+				c.Log.Infof("Vector db population: %s", diff)
+			}
+		}()
+	}
 
 	return nil
 }
+
+// Close saves pending changes of the memory vector store, it is called on shutdown.
+func (c *Config) Close() {
+	if store, ok := c.Store.(*w.MemoryStore); ok {
+		if err := store.Flush(context.Background()); err != nil {
+			c.Log.Errorf("Memory vector store flush failed: %v", err)
+		}
+	}
+}
+
+// InitVectorStore initializes only the vector store (and logger if it is not set), it is used by CLI commands.
+func (c *Config) InitVectorStore() error {
+	if c.Log == nil {
+		c.Log = gologgers.New(
+			gologgers.WithChannel("CLI"),
+			gologgers.WithLevel(c.LogOptions.LogLevel),
+			gologgers.WithOC(c.LogOptions.IsOutConsole),
+		)
+	}
+	return c.initVectorStore()
+}
+
+// initVectorStore creates vector store selected by VectorStore: Weaviate knowledge base or in-process memory store
+func (c *Config) initVectorStore() error {
+	log := gologgers.New(
+		gologgers.WithChannel("VECTOR"),
+		gologgers.WithLevel(c.LogOptions.LogLevel),
+		gologgers.WithOC(c.LogOptions.IsOutConsole),
+	)
+	chunker := w.NewHeadingChunker(c.ChunkTokens, c.ChunkOverlap)
+
+	if c.EmbedModel != EmbedModelNone && c.EmbedModel != "" {
+		emb, err := llm.NewEmbedder(c.EmbedModel, log, c.GetHTTPClient())
+		if err != nil {
+			return fmt.Errorf("create embedder[%s]: %w", c.EmbedModel, err)
+		}
+		c.Embedder = emb
+	}
+
+	if c.VectorStore == VectorStoreMemory {
+		store, err := w.OpenMemoryStore(log, c.VectorStorePath)
+		if err != nil {
+			return fmt.Errorf("open memory vector store[%s]: %w", c.VectorStorePath, err)
+		}
+		if c.Embedder != nil {
+			store.WithEmbedder(c.Embedder)
+		}
+		c.Store = store.WithChunker(chunker).AutoFlush(context.Background(), w.DefaultMemFlushInterval)
+		return nil
+	}
+
+	c.WvCfg.Log = c.Log
+	c.Kb = w.NewKnowledgeBase(w.NewWVClient(c.WvCfg), log, c.VectorClass, "assets/knowledge_base.json").WithChunker(chunker)
+	if c.Embedder != nil {
+		c.Kb.WithEmbedder(c.Embedder)
+	}
+	c.Store = c.Kb
+	return nil
+}
+
+// initModels loads LLM profiles from PathLLMProfiles (built-in profiles are used if there is no file),
+// profiles of chains can be overridden by LLMChains. Model of profile "default" is LLMModel if it is not set in the file.
+func (c *Config) initModels() error {
+	cfg := llm.DefaultRegistryConfig(c.LLMModel)
+	if data, err := os.ReadFile(c.PathLLMProfiles); err != nil {
+		c.Log.Warnf("LLM profiles[%s] not loaded, built-in profiles are used: %v", c.PathLLMProfiles, err)
+	} else if cfg, err = llm.LoadRegistryConfig(data); err != nil {
+		return fmt.Errorf("failed to load LLM profiles[%s]: %w", c.PathLLMProfiles, err)
+	} else if p := cfg.Profiles[llm.ProfileDefault]; p != nil && p.Model == "" {
+		p.Model = c.LLMModel
+	}
+
+	noProxy := sync.OnceValue(func() *http.Client { return helpers.HttpClient(c.Log, c.HttpClientTO, c.SslPath, c.ISV, false) })
+	httpClient := func(withProxy bool) *http.Client { return lo.TernaryF(withProxy, c.GetHTTPClient, noProxy) }
+
+	var err error
+	if c.Models, err = llm.NewRegistry(c.Log, httpClient, cfg.WithChains(c.LLMChains)); err != nil {
+		return fmt.Errorf("invalid LLM profiles: %w", err)
+	}
+	c.Models.WithUsage(callbackhandlers.NewUsageHandler(c.Log, callbackhandlers.UsageRecorderFunc(c.recordUsage), ailogic.GetLCCFromCtx))
+	if c.LLM, err = c.Models.Model(llm.ProfileDefault); err != nil {
+		return fmt.Errorf("create default LLM: %w", err)
+	}
+	c.Log.Infof("LLM profiles:\n%s", c.Models)
+	return nil
+}
+
+// recordUsage saves usage of LLM call to user storage, calls made before user storage is initialized are only logged
+func (c *Config) recordUsage(ctx context.Context, u callbackhandlers.Usage) error {
+	if c.UserStorage == nil {
+		c.Log.RecWithCtx(ctx).Infof("LLM usage is not recorded: %+v", u)
+		return nil
+	}
+	return c.UserStorage.UsageService().Record(ctx, &us.LLMUsage{
+		Login:            u.Login,
+		ChatUUID:         u.ChatUUID,
+		Chain:            u.Chain,
+		Profile:          u.Profile,
+		Model:            u.Model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		LatencyMs:        u.Latency.Milliseconds(),
+		Cost:             u.Cost,
+	})
+}
+
+// initEnricher enables LLM enrichment of ingested chunks, enrichments are cached in the ledger DB by content hash
+func (c *Config) initEnricher(db *gorm.DB) {
+	tmpl, err := template.ParseFiles(c.PathEnrichPrompt)
+	var sb strings.Builder
+	if err == nil {
+		err = tmpl.ExecuteTemplate(&sb, llm.EnrichPromptName, nil)
+	}
+	if err != nil {
+		c.Log.Errorf("Enrichment prompt[%s] load failed, enrichment is disabled: %v", c.PathEnrichPrompt, err)
+		return
+	}
+	cache, err := services.NewEnrichCacheGorm(db)
+	if err != nil {
+		c.Log.Errorf("Enrichment cache init failed, enrichment is disabled: %v", err)
+		return
+	}
+	model, callOpts, err := c.Models.ForChain(llm.ChainEnricher, nil)
+	if err != nil {
+		c.Log.Errorf("Enrichment LLM init failed, enrichment is disabled: %v", err)
+		return
+	}
+	enricher := llm.NewLLMEnricher(model, strings.TrimSpace(sb.String()), callOpts...).
+		WithCategories(models.Categories...)
+	c.Rag.Ingestor().WithEnricher(enricher, cache)
+}
+
+// newRetriever creates retrieval pipeline over the vector store with reranker selected by Reranker
+func (c *Config) newRetriever() *w.Retriever {
+	r := w.NewRetriever(c.Log, c.Store).
+		WithCandidates(c.RerankCandidates).
+		WithTopK(c.RetrieveTopK).
+		WithMinScore(c.RerankMinScore).
+		WithTokenBudget(c.RetrieveTokens)
+	if c.Reranker == RerankerNone {
+		r.WithReranker(nil)
+	}
+	if c.Reranker != RerankerLLM && c.IsQueryRewriteOFF {
+		return r
+	}
+	model, callOpts, err := c.Models.ForChain(llm.ChainRetriever, nil)
+	if err != nil {
+		c.Log.Errorf("Retriever LLM init failed, lexical reranker is used and queries are not rewritten: %v", err)
+		return r
+	}
+	if c.Reranker == RerankerLLM {
+		r.WithReranker(llm.NewLLMReranker(model, callOpts...))
+	}
+	if !c.IsQueryRewriteOFF {
+		r.WithRewriter(llm.NewLLMQueryRewriter(model, callOpts...))
+	}
+	return r
+}
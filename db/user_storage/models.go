@@ -45,6 +45,7 @@ import (
 
 	"github.com/samber/lo"
 	"gitlab.dev.ict/golang/go-ai/helpers"
+	"gitlab.dev.ict/golang/go-ai/helpers/tools"
 	"gitlab.dev.ict/golang/libs/gologgers"
 	"gitlab.dev.ict/golang/libs/goopenai"
 	"gitlab.dev.ict/golang/libs/utils"
@@ -54,6 +55,9 @@ import (
 	"gitlab.dev.ict/golang/go-ai/db"
 )
 
+// MsgFieldCitations - field of the assistant message in Chat JSON with sources used for the answer
+const MsgFieldCitations = "citations"
+
 // User represents a user in the system. The model includes fields for user status, login information, and relationships to other models.
 type User struct {
 	gorm.Model
@@ -260,6 +264,28 @@ func (c *Chat) WithChainName(chainName string) *Chat {
 	return c
 }
 
+// WithLastMessageCitations stores citations (sources of the answer) in the last message of chat JSON.
+func (c *Chat) WithLastMessageCitations(citations any) *Chat {
+	var data map[string]any
+	if err := json.Unmarshal(c.Chat, &data); err != nil {
+		return c
+	}
+	if msgs, ok := data["messages"].([]any); ok && len(msgs) > 0 {
+		if last, ok := msgs[len(msgs)-1].(map[string]any); ok {
+			last[MsgFieldCitations] = citations
+			c.Chat = lo.Must(json.Marshal(data))
+		}
+	}
+	return c
+}
+
+// MessageWithCitations converts chat message to JSON with citations, ready for AddMessageToChat.
+func MessageWithCitations(msg any, citations any) json.RawMessage {
+	js := string(lo.Must(json.Marshal(msg)))
+	tools.AddFieldToJson(&js, MsgFieldCitations, citations)
+	return json.RawMessage(js)
+}
+
 func NewChat(userID uint, chat *goopenai.Chat) *Chat {
 	chatData, _ := json.Marshal(chat)
 	newChat := &Chat{
@@ -420,6 +446,10 @@ func AutoMigrate(db *gorm.DB) error {
 		&GroupRole{},
 		&UserGroup{},
 		&Chat{},
+		&LLMUsage{},
+		&Quota{},
+		&AIRequest{},
+		&QuotaReset{},
 	)
 }
 
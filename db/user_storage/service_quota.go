@@ -0,0 +1,339 @@
+package userstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitlab.dev.ict/golang/libs/gologgers"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQuotaExceeded is returned when request of the user is rejected as the quota is used up
+var ErrQuotaExceeded = errors.New("AI quota is exceeded")
+
+// QuotaNearRatio is part of the limit after which user is notified that the quota is nearly used
+const QuotaNearRatio = 0.8
+
+// Names of quota limits
+const (
+	QuotaRequestsPerHour = "requests_per_hour"
+	QuotaTokensPerDay    = "tokens_per_day"
+	QuotaMonthlyCost     = "monthly_cost"
+	QuotaGroupBudget     = "group_budget"
+)
+
+// Subjects of quota reset
+const (
+	QuotaSubjectUser  = "user"
+	QuotaSubjectGroup = "group"
+)
+
+// Quota limits AI usage of every user with the role or in the group, 0 - not limited.
+// User with several roles/groups gets the most generous limit of them, so 0 in any of them is not limited.
+type Quota struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	RoleID          *uint     `gorm:"uniqueIndex" json:"roleId,omitempty"`
+	GroupID         *uint     `gorm:"uniqueIndex" json:"groupId,omitempty"`
+	RequestsPerHour int       `json:"requestsPerHour"`
+	TokensPerDay    int64     `json:"tokensPerDay"`
+	MonthlyCost     float64   `json:"monthlyCost"` // USD per user
+	GroupBudget     float64   `json:"groupBudget"` // USD per month for all members of the group together (group quota only)
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// AIRequest is user request to AI chain admitted by quota check
+type AIRequest struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+	Login     string    `gorm:"type:varchar(100);index"`
+	Chain     string    `gorm:"type:varchar(100)"`
+}
+
+// QuotaReset is the moment since which usage of user/group is counted, set by admin
+type QuotaReset struct {
+	Subject string    `gorm:"type:varchar(150);primaryKey"` // user:<login> or group:<name>
+	ResetAt time.Time `gorm:"not null"`
+}
+
+// QuotaUsage is usage of one limit
+type QuotaUsage struct {
+	Name  string  `json:"name"`
+	Group string  `json:"group,omitempty"` // group of group_budget
+	Used  float64 `json:"used"`
+	Limit float64 `json:"limit"`
+}
+
+func (u QuotaUsage) String() string {
+	name := strings.ReplaceAll(u.Name, "_", " ")
+	if u.Group != "" {
+		name += " of " + u.Group
+	}
+	if u.Name == QuotaMonthlyCost || u.Name == QuotaGroupBudget {
+		return fmt.Sprintf("%s $%.2f/$%.2f", name, u.Used, u.Limit)
+	}
+	return fmt.Sprintf("%s %.0f/%.0f", name, u.Used, u.Limit)
+}
+
+// QuotaStatus is usage of the limits of the user, limits which are not set are not listed
+type QuotaStatus struct {
+	Login string       `json:"login"`
+	Usage []QuotaUsage `json:"usage"`
+
+	recorded bool // the request is recorded and counted in Usage (see QuotaService.Admit)
+}
+
+// Exceeded returns limits which are used up
+func (s *QuotaStatus) Exceeded() (res []QuotaUsage) {
+	for _, u := range s.Usage {
+		if u.Used >= u.Limit && !s.isLast(u) {
+			res = append(res, u)
+		}
+	}
+	return
+}
+
+// Last returns limits which are used up by the recorded request, i.e. it is the last request allowed by them
+func (s *QuotaStatus) Last() (res []QuotaUsage) {
+	for _, u := range s.Usage {
+		if s.isLast(u) {
+			res = append(res, u)
+		}
+	}
+	return
+}
+
+func (s *QuotaStatus) isLast(u QuotaUsage) bool {
+	return s.recorded && u.Name == QuotaRequestsPerHour && u.Used == u.Limit
+}
+
+// Near returns limits which are used by QuotaNearRatio or more, but not up
+func (s *QuotaStatus) Near() (res []QuotaUsage) {
+	for _, u := range s.Usage {
+		if u.Used < u.Limit && u.Used >= u.Limit*QuotaNearRatio {
+			res = append(res, u)
+		}
+	}
+	return
+}
+
+// Message describes exceeded or nearly used limits for the user, empty if there are none
+func (s *QuotaStatus) Message() string {
+	join := func(us []QuotaUsage) string {
+		var sb strings.Builder
+		for i, u := range us {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(u.String())
+		}
+		return sb.String()
+	}
+	if ex := s.Exceeded(); len(ex) > 0 {
+		return "AI quota is used up: " + join(ex)
+	}
+	if last := s.Last(); len(last) > 0 {
+		return "Last AI request allowed by quota: " + join(last)
+	}
+	if near := s.Near(); len(near) > 0 {
+		return "AI quota is nearly used: " + join(near)
+	}
+	return ""
+}
+
+// QuotaService handles quotas of AI usage
+type QuotaService struct {
+	db  *gorm.DB
+	log *gologgers.Logger
+}
+
+func NewQuotaService(db *gorm.DB, logger *gologgers.Logger) *QuotaService {
+	return &QuotaService{
+		db:  db,
+		log: logger,
+	}
+}
+
+// GetQuotas retrieves quotas of all roles and groups
+func (s *QuotaService) GetQuotas(ctx context.Context) ([]Quota, error) {
+	var quotas []Quota
+	err := s.db.WithContext(createCtx(ctx, s.log)).Order("id").Find(&quotas).Error
+	return quotas, err
+}
+
+// SetRoleQuota creates or replaces quota of the role
+func (s *QuotaService) SetRoleQuota(ctx context.Context, roleCode string, q *Quota) error {
+	var role Role
+	if err := s.db.WithContext(createCtx(ctx, s.log)).Where("code = ?", strings.ToUpper(roleCode)).First(&role).Error; err != nil {
+		return fmt.Errorf("role %s not found: %w", roleCode, err)
+	}
+	q.ID, q.RoleID, q.GroupID, q.GroupBudget = 0, &role.ID, nil, 0
+	return s.upsert(ctx, "role_id", q)
+}
+
+// SetGroupQuota creates or replaces quota of the group
+func (s *QuotaService) SetGroupQuota(ctx context.Context, groupName string, q *Quota) error {
+	var group Group
+	if err := s.db.WithContext(createCtx(ctx, s.log)).Where("name = ?", groupName).First(&group).Error; err != nil {
+		return fmt.Errorf("group %s not found: %w", groupName, err)
+	}
+	q.ID, q.RoleID, q.GroupID = 0, nil, &group.ID
+	return s.upsert(ctx, "group_id", q)
+}
+
+func (s *QuotaService) upsert(ctx context.Context, key string, q *Quota) error {
+	return s.db.WithContext(createCtx(ctx, s.log)).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: key}},
+			DoUpdates: clause.AssignmentColumns([]string{"requests_per_hour", "tokens_per_day", "monthly_cost", "group_budget", "updated_at"}),
+		}).
+		Create(q).Error
+}
+
+// Reset restarts counting of usage of the user or the group (QuotaSubjectUser/QuotaSubjectGroup), usage records are kept
+func (s *QuotaService) Reset(ctx context.Context, subject, name string) error {
+	if subject != QuotaSubjectUser && subject != QuotaSubjectGroup {
+		return fmt.Errorf("unknown quota subject %q", subject)
+	}
+	return s.db.WithContext(createCtx(ctx, s.log)).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(&QuotaReset{Subject: subject + ":" + name, ResetAt: time.Now()}).Error
+}
+
+// Admit checks quota of the user and records the request to AI chain, ErrQuotaExceeded is returned if quota is used up
+// and the request is not recorded. Concurrent requests of the user are admitted one after another: the request is saved
+// first and the user is locked (write lock of the database in SQLite, row lock in PostgreSQL) until it is counted.
+func (s *QuotaService) Admit(ctx context.Context, login, chain string) (status *QuotaStatus, err error) {
+	err = s.db.WithContext(createCtx(ctx, s.log)).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&AIRequest{Login: login, Chain: chain}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("username = ?", login).Find(&User{}).Error; err != nil {
+			return err
+		}
+		if status, err = s.status(tx, login); err != nil {
+			return err
+		}
+		status.recorded = true
+		if len(status.Exceeded()) == 0 {
+			return nil
+		}
+		// rejected request is rolled back
+		for i, u := range status.Usage {
+			if u.Name == QuotaRequestsPerHour {
+				status.Usage[i].Used--
+			}
+		}
+		status.recorded = false
+		return ErrQuotaExceeded
+	})
+	return status, err
+}
+
+// Status returns usage of the limits of the user: requests in the last hour, tokens since start of the day,
+// cost since start of the month (per user and per group with budget)
+func (s *QuotaService) Status(ctx context.Context, login string) (*QuotaStatus, error) {
+	return s.status(s.db.WithContext(createCtx(ctx, s.log)), login)
+}
+
+func (s *QuotaService) status(tx *gorm.DB, login string) (*QuotaStatus, error) {
+	var quotas []Quota
+	err := tx.Where("role_id IN (?) OR role_id IN (?) OR group_id IN (?)",
+		tx.Table("user_roles").Select("user_roles.role_id").
+			Joins("JOIN users ON users.id = user_roles.user_id").Where("users.username = ?", login),
+		tx.Table("group_roles").Select("group_roles.role_id").
+			Joins("JOIN user_groups ON user_groups.group_id = group_roles.group_id").
+			Joins("JOIN users ON users.id = user_groups.user_id").Where("users.username = ?", login),
+		tx.Table("user_groups").Select("user_groups.group_id").
+			Joins("JOIN users ON users.id = user_groups.user_id").Where("users.username = ?", login),
+	).Find(&quotas).Error
+	if err != nil {
+		return nil, fmt.Errorf("get quotas of %s: %w", login, err)
+	}
+
+	limit := Quota{
+		RequestsPerHour: generous(quotas, func(q Quota) int { return q.RequestsPerHour }),
+		TokensPerDay:    generous(quotas, func(q Quota) int64 { return q.TokensPerDay }),
+		MonthlyCost:     generous(quotas, func(q Quota) float64 { return q.MonthlyCost }),
+	}
+
+	status := &QuotaStatus{Login: login}
+	now := time.Now()
+	userReset := s.resetAt(tx, QuotaSubjectUser, login)
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	if limit.RequestsPerHour > 0 {
+		var used int64
+		if err = tx.Model(&AIRequest{}).Where("login = ? AND created_at >= ?", login, later(now.Add(-time.Hour), userReset)).Count(&used).Error; err != nil {
+			return nil, err
+		}
+		status.Usage = append(status.Usage, QuotaUsage{Name: QuotaRequestsPerHour, Used: float64(used), Limit: float64(limit.RequestsPerHour)})
+	}
+	if limit.TokensPerDay > 0 {
+		var used float64
+		if err = tx.Model(&LLMUsage{}).Select("COALESCE(SUM(prompt_tokens + completion_tokens), 0)").
+			Where("login = ? AND created_at >= ?", login, later(dayStart, userReset)).Scan(&used).Error; err != nil {
+			return nil, err
+		}
+		status.Usage = append(status.Usage, QuotaUsage{Name: QuotaTokensPerDay, Used: used, Limit: float64(limit.TokensPerDay)})
+	}
+	if limit.MonthlyCost > 0 {
+		var used float64
+		if err = tx.Model(&LLMUsage{}).Select("COALESCE(SUM(cost), 0)").
+			Where("login = ? AND created_at >= ?", login, later(monthStart, userReset)).Scan(&used).Error; err != nil {
+			return nil, err
+		}
+		status.Usage = append(status.Usage, QuotaUsage{Name: QuotaMonthlyCost, Used: used, Limit: limit.MonthlyCost})
+	}
+
+	for _, q := range quotas {
+		if q.GroupID == nil || q.GroupBudget <= 0 {
+			continue
+		}
+		var group Group
+		if err = tx.First(&group, *q.GroupID).Error; err != nil {
+			continue // group is deleted
+		}
+		var used float64
+		if err = tx.Model(&LLMUsage{}).Select("COALESCE(SUM(llm_usages.cost), 0)").
+			Joins("JOIN users ON users.username = llm_usages.login").
+			Joins("JOIN user_groups ON user_groups.user_id = users.id").
+			Where("user_groups.group_id = ? AND llm_usages.created_at >= ?", group.ID, later(monthStart, s.resetAt(tx, QuotaSubjectGroup, group.Name))).
+			Scan(&used).Error; err != nil {
+			return nil, err
+		}
+		status.Usage = append(status.Usage, QuotaUsage{Name: QuotaGroupBudget, Group: group.Name, Used: used, Limit: q.GroupBudget})
+	}
+	return status, nil
+}
+
+// generous returns the most generous limit of the quotas, 0 (not limited) if any quota does not limit it or there are no quotas
+func generous[T int | int64 | float64](quotas []Quota, limit func(Quota) T) (res T) {
+	for i, q := range quotas {
+		l := limit(q)
+		if l <= 0 {
+			return 0
+		}
+		if i == 0 || l > res {
+			res = l
+		}
+	}
+	return res
+}
+
+func (s *QuotaService) resetAt(tx *gorm.DB, subject, name string) time.Time {
+	var r QuotaReset
+	tx.Where("subject = ?", subject+":"+name).Limit(1).Find(&r)
+	return r.ResetAt
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,134 @@
+package userstorage_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	us "gitlab.dev.ict/golang/go-ai/db/user_storage"
+)
+
+func TestQuotaService(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	lo.Must(db.DB()).SetMaxOpenConns(1)
+	require.NoError(t, us.AutoMigrate(db))
+	ss := us.NewStorageService(db, log, false)
+
+	require.NoError(t, ss.RoleService().CreateRole(ctx, &us.Role{Name: "VoIP support", Code: "voip"}))
+	users := map[string]*us.User{}
+	for _, name := range []string{"alice", "bob", "carol"} {
+		users[name] = &us.User{Username: name, Password: "secret-" + name}
+		require.NoError(t, ss.CreateUser(ctx, users[name]))
+	}
+	_, err = ss.GroupService().CreateOrUpdateGroup(ctx, "TEAM", "")
+	require.NoError(t, err)
+	require.NoError(t, ss.RoleService().AddRoleToUser(ctx, users["alice"].ID, "VOIP"))
+	require.NoError(t, ss.UpdateUserGroups(ctx, users["alice"].ID, []string{"TEAM"}))
+	require.NoError(t, ss.UpdateUserGroups(ctx, users["bob"].ID, []string{"TEAM"}))
+
+	qs := ss.QuotaService()
+	require.NoError(t, qs.SetRoleQuota(ctx, "voip", &us.Quota{RequestsPerHour: 2, TokensPerDay: 1000}))
+	require.NoError(t, qs.SetGroupQuota(ctx, "TEAM", &us.Quota{RequestsPerHour: 1, TokensPerDay: 500, MonthlyCost: 1, GroupBudget: 1.5}))
+	require.NoError(t, qs.SetRoleQuota(ctx, "VOIP", &us.Quota{RequestsPerHour: 3, TokensPerDay: 1000}), "quota is replaced")
+	assert.Len(t, lo.Must(qs.GetQuotas(ctx)), 2)
+	assert.Error(t, qs.SetGroupQuota(ctx, "missing", &us.Quota{}))
+
+	usage := func(s *us.QuotaStatus, name string) us.QuotaUsage {
+		u, _ := lo.Find(s.Usage, func(u us.QuotaUsage) bool { return u.Name == name })
+		return u
+	}
+
+	t.Run("requests per hour, most generous limit", func(t *testing.T) {
+		for i := 1; i <= 3; i++ {
+			s, err := qs.Admit(ctx, "alice", "VoipAgents")
+			require.NoError(t, err)
+			assert.Equal(t, us.QuotaUsage{Name: us.QuotaRequestsPerHour, Used: float64(i), Limit: 3}, usage(s, us.QuotaRequestsPerHour))
+			if i == 3 {
+				assert.Empty(t, s.Exceeded(), "admitted request is not over quota")
+				assert.Equal(t, "Last AI request allowed by quota: requests per hour 3/3", s.Message())
+			}
+		}
+		s, err := qs.Admit(ctx, "alice", "VoipAgents")
+		require.ErrorIs(t, err, us.ErrQuotaExceeded)
+		require.Len(t, s.Exceeded(), 1)
+		assert.Equal(t, "AI quota is used up: requests per hour 3/3", s.Message())
+		assert.EqualValues(t, 3, usage(lo.Must(qs.Status(ctx, "alice")), us.QuotaRequestsPerHour).Used, "rejected request is not counted")
+
+		require.NoError(t, qs.Reset(ctx, us.QuotaSubjectUser, "alice"))
+		s, err = qs.Admit(ctx, "alice", "VoipAgents")
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, usage(s, us.QuotaRequestsPerHour).Used)
+	})
+
+	t.Run("concurrent requests", func(t *testing.T) {
+		require.NoError(t, qs.Reset(ctx, us.QuotaSubjectUser, "alice"))
+		var wg sync.WaitGroup
+		var admitted atomic.Int32
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := qs.Admit(ctx, "alice", "VoipAgents"); err == nil {
+					admitted.Add(1)
+				} else {
+					assert.ErrorIs(t, err, us.ErrQuotaExceeded)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.EqualValues(t, 3, admitted.Load())
+		assert.EqualValues(t, 3, usage(lo.Must(qs.Status(ctx, "alice")), us.QuotaRequestsPerHour).Used, "rejected requests are not counted")
+		require.NoError(t, qs.Reset(ctx, us.QuotaSubjectUser, "alice"))
+	})
+
+	t.Run("tokens, cost and group budget", func(t *testing.T) {
+		for _, r := range []us.LLMUsage{
+			{Login: "alice", Chain: "VoipAgents", PromptTokens: 800, CompletionTokens: 50, Cost: 0.6},
+			{Login: "bob", Chain: "VoipAgents", PromptTokens: 100, CompletionTokens: 10, Cost: 1},
+		} {
+			require.NoError(t, ss.UsageService().Record(ctx, &r))
+		}
+
+		alice := lo.Must(qs.Status(ctx, "alice"))
+		assert.Equal(t, us.QuotaUsage{Name: us.QuotaTokensPerDay, Used: 850, Limit: 1000}, usage(alice, us.QuotaTokensPerDay))
+		assert.Equal(t, us.QuotaUsage{Name: us.QuotaGroupBudget, Group: "TEAM", Used: 1.6, Limit: 1.5}, usage(alice, us.QuotaGroupBudget))
+		assert.Equal(t, "AI quota is used up: group budget of TEAM $1.60/$1.50", alice.Message())
+
+		require.NoError(t, qs.Reset(ctx, us.QuotaSubjectGroup, "TEAM"))
+		bob, err := qs.Admit(ctx, "bob", "DbCimChain")
+		require.ErrorIs(t, err, us.ErrQuotaExceeded)
+		assert.Equal(t, []us.QuotaUsage{{Name: us.QuotaMonthlyCost, Used: 1, Limit: 1}}, bob.Exceeded(), "cost of bob is not reset with the group")
+
+		alice = lo.Must(qs.Status(ctx, "alice"))
+		assert.Empty(t, alice.Exceeded())
+		assert.Equal(t, "AI quota is nearly used: tokens per day 850/1000", alice.Message())
+	})
+
+	t.Run("zero in any quota is not limited", func(t *testing.T) {
+		require.NoError(t, qs.SetRoleQuota(ctx, "VOIP", &us.Quota{TokensPerDay: 1000}))
+		alice := lo.Must(qs.Status(ctx, "alice"))
+		assert.Zero(t, usage(alice, us.QuotaRequestsPerHour), "requests per hour of TEAM is not applied")
+		assert.Equal(t, us.QuotaUsage{Name: us.QuotaTokensPerDay, Used: 850, Limit: 1000}, usage(alice, us.QuotaTokensPerDay))
+		assert.Zero(t, usage(alice, us.QuotaMonthlyCost))
+
+		bob := lo.Must(qs.Status(ctx, "bob"))
+		assert.Equal(t, us.QuotaUsage{Name: us.QuotaRequestsPerHour, Used: 0, Limit: 1}, usage(bob, us.QuotaRequestsPerHour), "limit of the only quota")
+	})
+
+	t.Run("no quota", func(t *testing.T) {
+		s, err := qs.Admit(ctx, "carol", "VoipAgents")
+		require.NoError(t, err)
+		assert.Empty(t, s.Usage)
+		assert.Empty(t, s.Message())
+	})
+
+	assert.Error(t, qs.Reset(ctx, "role", "VOIP"))
+}
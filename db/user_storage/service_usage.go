@@ -0,0 +1,134 @@
+package userstorage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gitlab.dev.ict/golang/libs/gologgers"
+	"gorm.io/gorm"
+)
+
+// Grouping of usage aggregates
+const (
+	UsageByUser    = "user"
+	UsageByDay     = "day"
+	UsageByMonth   = "month"
+	UsageByChain   = "chain"
+	UsageByProfile = "profile"
+	UsageByModel   = "model"
+	UsageByGroup   = "group"
+)
+
+// LLMUsage is token usage and estimated cost of one LLM call
+type LLMUsage struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt        time.Time `gorm:"index" json:"createdAt"`
+	Login            string    `gorm:"type:varchar(100);index" json:"login"`
+	ChatUUID         string    `gorm:"type:varchar(64);index" json:"chatUuid"`
+	Chain            string    `gorm:"type:varchar(100);index" json:"chain"`
+	Profile          string    `gorm:"type:varchar(100)" json:"profile"`
+	Model            string    `gorm:"type:varchar(100)" json:"model"`
+	PromptTokens     int       `json:"promptTokens"`
+	CompletionTokens int       `json:"completionTokens"`
+	LatencyMs        int64     `json:"latencyMs"`
+	Cost             float64   `json:"cost"` // USD
+}
+
+// UsageFilter selects usage records for aggregation, zero fields are not applied
+type UsageFilter struct {
+	By    string // user, day, month, chain, profile, model, group
+	From  time.Time
+	To    time.Time // exclusive
+	Login string
+	Chain string
+}
+
+// UsageAggregate is total usage of the group of records
+type UsageAggregate struct {
+	Key              string  `json:"key"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens"`
+	Cost             float64 `json:"cost"`
+	AvgLatencyMs     float64 `json:"avgLatencyMs"`
+}
+
+// UsageService handles LLM usage accounting
+type UsageService struct {
+	db  *gorm.DB
+	log *gologgers.Logger
+}
+
+func NewUsageService(db *gorm.DB, logger *gologgers.Logger) *UsageService {
+	return &UsageService{
+		db:  db,
+		log: logger,
+	}
+}
+
+// Record saves usage of LLM call
+func (s *UsageService) Record(ctx context.Context, u *LLMUsage) error {
+	return s.db.WithContext(createCtx(ctx, s.log)).Create(u).Error
+}
+
+// Aggregate sums usage grouped by f.By, groups are ordered by key. Usage of users in several groups
+// is counted in every group of the user.
+func (s *UsageService) Aggregate(ctx context.Context, f UsageFilter) ([]UsageAggregate, error) {
+	key, err := s.groupKey(f.By)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := s.db.WithContext(createCtx(ctx, s.log)).Model(&LLMUsage{}).
+		Select(key + ` AS key, COUNT(*) AS requests, SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens,
+			SUM(cost) AS cost, AVG(latency_ms) AS avg_latency_ms`)
+	if f.By == UsageByGroup {
+		tx = tx.Joins("JOIN users ON users.username = llm_usages.login AND users.deleted_at IS NULL").
+			Joins("JOIN user_groups ON user_groups.user_id = users.id").
+			Joins("JOIN groups ON groups.id = user_groups.group_id AND groups.deleted_at IS NULL")
+	}
+	if !f.From.IsZero() {
+		tx = tx.Where("llm_usages.created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		tx = tx.Where("llm_usages.created_at < ?", f.To)
+	}
+	if f.Login != "" {
+		tx = tx.Where("llm_usages.login = ?", f.Login)
+	}
+	if f.Chain != "" {
+		tx = tx.Where("llm_usages.chain = ?", f.Chain)
+	}
+
+	var res []UsageAggregate
+	err = tx.Group(key).Order(key).Scan(&res).Error
+	return res, err
+}
+
+func (s *UsageService) groupKey(by string) (string, error) {
+	isPG := s.db.Dialector.Name() == "postgres"
+	switch by {
+	case UsageByUser:
+		return "llm_usages.login", nil
+	case UsageByChain:
+		return "llm_usages.chain", nil
+	case UsageByProfile:
+		return "llm_usages.profile", nil
+	case UsageByModel:
+		return "llm_usages.model", nil
+	case UsageByGroup:
+		return "groups.name", nil
+	case UsageByDay:
+		if isPG {
+			return "to_char(llm_usages.created_at, 'YYYY-MM-DD')", nil
+		}
+		return "strftime('%Y-%m-%d', llm_usages.created_at)", nil
+	case UsageByMonth:
+		if isPG {
+			return "to_char(llm_usages.created_at, 'YYYY-MM')", nil
+		}
+		return "strftime('%Y-%m', llm_usages.created_at)", nil
+	}
+	return "", fmt.Errorf("unknown usage grouping %q", by)
+}
@@ -0,0 +1,76 @@
+package userstorage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	us "gitlab.dev.ict/golang/go-ai/db/user_storage"
+)
+
+func TestUsageService_Aggregate(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	lo.Must(db.DB()).SetMaxOpenConns(1)
+	require.NoError(t, us.AutoMigrate(db))
+	ss := us.NewStorageService(db, log, false)
+
+	for _, u := range []us.User{{Username: "alice", Password: "secret1"}, {Username: "bob", Password: "secret2"}} {
+		require.NoError(t, ss.CreateUser(ctx, &u))
+		_, err := ss.GroupService().CreateOrUpdateGroup(ctx, "VOIP-"+u.Username, "")
+		require.NoError(t, err)
+		require.NoError(t, ss.UpdateUserGroups(ctx, u.ID, []string{"VOIP-" + u.Username}))
+	}
+
+	march, april := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC), time.Date(2025, 4, 2, 9, 0, 0, 0, time.UTC)
+	usage := ss.UsageService()
+	for _, r := range []us.LLMUsage{
+		{CreatedAt: march, Login: "alice", Chain: "VoipAgents", Model: "gpt-4o", PromptTokens: 1000, CompletionTokens: 100, LatencyMs: 800, Cost: 0.0035},
+		{CreatedAt: march, Login: "alice", Chain: "VoipAgents", Model: "gpt-4o-mini", PromptTokens: 500, CompletionTokens: 50, LatencyMs: 400, Cost: 0.0001},
+		{CreatedAt: march.Add(24 * time.Hour), Login: "bob", Chain: "DbCimChain", Model: "gpt-4o", PromptTokens: 2000, CompletionTokens: 200, LatencyMs: 1200, Cost: 0.007},
+		{CreatedAt: april, Login: "bob", Chain: "VoipAgents", Model: "gpt-4o", PromptTokens: 100, CompletionTokens: 10, LatencyMs: 300, Cost: 0.00035},
+	} {
+		require.NoError(t, usage.Record(ctx, &r))
+	}
+
+	keys := func(f us.UsageFilter) map[string]us.UsageAggregate {
+		t.Helper()
+		res, err := usage.Aggregate(ctx, f)
+		require.NoError(t, err)
+		return lo.KeyBy(res, func(a us.UsageAggregate) string { return a.Key })
+	}
+
+	byUser := keys(us.UsageFilter{By: us.UsageByUser})
+	assert.Len(t, byUser, 2)
+	assert.EqualValues(t, 2, byUser["alice"].Requests)
+	assert.EqualValues(t, 1500, byUser["alice"].PromptTokens)
+	assert.EqualValues(t, 150, byUser["alice"].CompletionTokens)
+	assert.InDelta(t, 0.0036, byUser["alice"].Cost, 1e-9)
+	assert.InDelta(t, 600, byUser["alice"].AvgLatencyMs, 1e-9)
+
+	byDay := keys(us.UsageFilter{By: us.UsageByDay, From: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)})
+	assert.Len(t, byDay, 2, "april is filtered out")
+	assert.EqualValues(t, 2, byDay["2025-03-10"].Requests)
+	assert.EqualValues(t, 1, byDay["2025-03-11"].Requests)
+
+	byMonth := keys(us.UsageFilter{By: us.UsageByMonth, Chain: "VoipAgents"})
+	assert.EqualValues(t, 2, byMonth["2025-03"].Requests)
+	assert.EqualValues(t, 1, byMonth["2025-04"].Requests)
+
+	byGroup := keys(us.UsageFilter{By: us.UsageByGroup, Chain: "VoipAgents"})
+	assert.Len(t, byGroup, 2)
+	assert.InDelta(t, 0.0036, byGroup["VOIP-alice"].Cost, 1e-9)
+	assert.InDelta(t, 0.00035, byGroup["VOIP-bob"].Cost, 1e-9)
+
+	byChain := keys(us.UsageFilter{By: us.UsageByChain, Login: "bob"})
+	assert.Equal(t, map[string]int64{"DbCimChain": 1, "VoipAgents": 1}, lo.MapValues(byChain, func(a us.UsageAggregate, _ string) int64 { return a.Requests }))
+
+	_, err = usage.Aggregate(ctx, us.UsageFilter{By: "week"})
+	assert.Error(t, err)
+}
@@ -41,6 +41,8 @@ func (s *StorageService) Db() *gorm.DB                            { return s.db
 func (s *StorageService) RoleService() *UserRoleService           { return NewUserRoleService(s.db, s.log) }
 func (s *StorageService) GroupService() *GroupService             { return NewGroupService(s.db, s.log) }
 func (s *StorageService) PermService() *PermissionService         { return NewPermService(s.db, s.log) }
+func (s *StorageService) UsageService() *UsageService             { return NewUsageService(s.db, s.log) }
+func (s *StorageService) QuotaService() *QuotaService             { return NewQuotaService(s.db, s.log) }
 func (s *StorageService) l(ctx context.Context) *gologgers.LogRec { return s.log.RecWithCtx(ctx, CH) }
 func (s *StorageService) ctx(ctx context.Context) context.Context { return createCtx(ctx, s.log) }
 
@@ -9,6 +9,8 @@ type UserStorage interface {
 	RoleService() *UserRoleService
 	GroupService() *GroupService
 	PermService() *PermissionService
+	UsageService() *UsageService
+	QuotaService() *QuotaService
 
 	UpdateUserGroups(ctx context.Context, userID uint, groups []string) error
 
@@ -49,6 +49,7 @@ require (
 )
 
 require (
+	github.com/go-openapi/strfmt v0.23.0
 	github.com/godoes/gorm-oracle v1.6.9
 	github.com/godror/godror v0.44.0
 	github.com/google/uuid v1.6.0
@@ -90,7 +91,6 @@ require (
 	github.com/go-openapi/jsonreference v0.21.0 // indirect
 	github.com/go-openapi/loads v0.21.1 // indirect
 	github.com/go-openapi/spec v0.21.0 // indirect
-	github.com/go-openapi/strfmt v0.23.0 // indirect
 	github.com/go-openapi/swag v0.23.0 // indirect
 	github.com/go-openapi/validate v0.21.0 // indirect
 	github.com/go-playground/locales v0.14.1 // indirect
@@ -3,6 +3,8 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -10,12 +12,14 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gookit/goutil"
+	"github.com/samber/lo"
 	us "gitlab.dev.ict/golang/go-ai/db/user_storage"
 	help "gitlab.dev.ict/golang/go-ai/helpers"
 	"gitlab.dev.ict/golang/go-ai/logic/ailogic"
 	"gitlab.dev.ict/golang/go-ai/models"
 	"gitlab.dev.ict/golang/go-ai/models/sse"
 	"gitlab.dev.ict/golang/go-ai/services"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
 	ad "gitlab.dev.ict/golang/libs/goldap"
 	"gitlab.dev.ict/golang/libs/gologgers"
 	goai "gitlab.dev.ict/golang/libs/goopenai"
@@ -134,7 +138,8 @@ func (a *AppHandler) processUserAIRequest(c *fiber.Ctx, chainName string, callAI
 	log.Infof("Marshalled input request: %s", utils.Json(body))
 
 	u := getUser(c)
-	ctx := ailogic.AddToCtxLogin(log.Ctx, u.Login)
+	postDelta := sendToChanFN(c, sse.EvtChatDelta, body.TabId)
+	ctx := ailogic.AddToCtxStream(ailogic.AddToCtxLogin(log.Ctx, u.Login), func(delta string) { postDelta(string(lo.Must(json.Marshal(delta)))) })
 	userFromDB, err := a.uStorage.GetUserWithChatsByUserName(ctx, u.Login)
 	if err != nil {
 		log.WithError(err).Errorf("Error fetching user from DB")
@@ -151,14 +156,33 @@ func (a *AppHandler) processUserAIRequest(c *fiber.Ctx, chainName string, callAI
 		chat = goai.NewChatEmpty()
 	case body.ChatId != "":
 		log.Warn("ChatId is not empty. Get chat from DB")
-		chatDB = userFromDB.GetChat(body.ChatId)
+		if chatDB = userFromDB.GetChat(body.ChatId); chatDB == nil {
+			log.Errorf("Chat[%s] of user[%s] not found", body.ChatId, u.Login)
+			return c.Status(fiber.StatusNotFound).JSON(models.R(404, "Chat not found", nil))
+		}
 		log.Infof("ChatDB: %s", utils.Json(chatDB))
 		chat = chatDB.Data()
 	}
 	log.Debugf("Chat BEFORE call AI logic: %s", utils.Json(chat))
 
+	// request is counted by quota only when it is valid and is sent to AI
+	postQuota := sendToChanFN(c, sse.EvtQuota, body.TabId)
+	quota, err := a.uStorage.QuotaService().Admit(log.Ctx, u.Login, chainName)
+	switch {
+	case errors.Is(err, us.ErrQuotaExceeded):
+		log.Warnf("Request of user[%s] to chain[%s] is rejected: %s", u.Login, chainName, quota.Message())
+		postQuota(utils.JsonStr(fiber.Map{"message": quota.Message(), "exceeded": true, "usage": quota.Usage}))
+		return c.Status(fiber.StatusTooManyRequests).JSON(models.R(429, quota.Message(), quota))
+	case err != nil:
+		log.Errorf("Quota check of user[%s] failed, request is not limited: %v", u.Login, err)
+	case quota.Message() != "":
+		postQuota(utils.JsonStr(fiber.Map{"message": quota.Message(), "exceeded": false, "usage": quota.Usage}))
+	}
+
 	postToChannel := sendToChanFN(c, sse.EvtChatGptResp, body.TabId)
+	postCitations := sendToChanFN(c, sse.EvtCitations, body.TabId)
 
+	ctx = ailogic.AddToCtxLoginCnCu(ctx, u.Login, chainName, chat.ID) // tags of LLM usage records
 	go func() {
 		resp, err := callAI(ctx, body.Question, chat, u.ChanMessages, postToChannel)
 		log.WithError(err).Infof("Response from AI: %s", utils.JsonPretty(resp))
@@ -168,15 +192,25 @@ func (a *AppHandler) processUserAIRequest(c *fiber.Ctx, chainName string, callAI
 			return
 		}
 
+		var citations []w.Citation
+		if answer, ok := resp.(*ailogic.AgentAnswer); ok && len(answer.Citations) > 0 {
+			citations = answer.Citations
+			postCitations(utils.JsonStr(citations))
+		}
+
 		postToChannel("######")
-		log.Infof("Finish call AI! countMessages=%d LastMsg=%s", len(chat.Messages), utils.JsonPretty(chat.GetLastMessage()))
+		log.Infof("Finish call AI! countMessages=%d citations=%d LastMsg=%s", len(chat.Messages), len(citations), utils.JsonPretty(chat.GetLastMessage()))
 
 		if chatDB != nil {
 			if err = a.uStorage.AddMessageToChat(ctx, chatDB.ID, chat.GetMessages()[len(chat.GetMessages())-2]); err == nil {
-				a.uStorage.AddMessageToChat(ctx, chatDB.ID, chat.GetLastMessage())
+				a.uStorage.AddMessageToChat(ctx, chatDB.ID, lo.Ternary[any](len(citations) > 0, us.MessageWithCitations(chat.GetLastMessage(), citations), chat.GetLastMessage()))
 			}
 		} else {
-			a.uStorage.CreateChat(ctx, us.NewChat(userFromDB.ID, chat).WithChainName(chainName))
+			newChat := us.NewChat(userFromDB.ID, chat).WithChainName(chainName)
+			if len(citations) > 0 {
+				newChat.WithLastMessageCitations(citations)
+			}
+			a.uStorage.CreateChat(ctx, newChat)
 		}
 	}()
 
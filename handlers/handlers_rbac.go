@@ -3,6 +3,7 @@ package handlers
 import (
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	us "gitlab.dev.ict/golang/go-ai/db/user_storage"
@@ -87,6 +88,8 @@ type RBACHandler struct {
 	rs  *us.UserRoleService
 	gs  *us.GroupService
 	ps  *us.PermissionService
+	usg *us.UsageService
+	qs  *us.QuotaService
 }
 
 func NewRBACHandler(opts ...Option) *RBACHandler {
@@ -101,6 +104,8 @@ func NewRBACHandler(opts ...Option) *RBACHandler {
 	h.rs = h.ss.RoleService()
 	h.gs = h.ss.GroupService()
 	h.ps = h.ss.PermService()
+	h.usg = h.ss.UsageService()
+	h.qs = h.ss.QuotaService()
 	return h
 }
 
@@ -403,3 +408,79 @@ func (h *RBACHandler) CreatePermission(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON([]us.Permission{*permission})
 
 }
+
+// GetUsage returns LLM usage grouped by user, day, month, chain, profile, model or group.
+// Query params: from, to - dates (YYYY-MM-DD, both inclusive), login, chain.
+// E.g. cost of VoIP assistant per group for March: /access/usage/group?chain=VoipAgents&from=2025-03-01&to=2025-03-31
+func (h *RBACHandler) GetUsage(c *fiber.Ctx) error {
+	f := us.UsageFilter{By: c.Params("by", us.UsageByUser), Login: c.Query("login"), Chain: c.Query("chain")}
+	var err error
+	if v := c.Query("from"); v != "" {
+		if f.From, err = time.Parse(time.DateOnly, v); err != nil {
+			return h.handleError(c, "Invalid param from", err)
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if f.To, err = time.Parse(time.DateOnly, v); err != nil {
+			return h.handleError(c, "Invalid param to", err)
+		}
+		f.To = f.To.AddDate(0, 0, 1)
+	}
+
+	usage, err := h.usg.Aggregate(c.Context(), f)
+	if err != nil {
+		return h.handleError(c, "Failed to get usage", err)
+	}
+	return c.JSON(usage)
+}
+
+// GetQuotas returns quotas of all roles and groups
+func (h *RBACHandler) GetQuotas(c *fiber.Ctx) error {
+	quotas, err := h.qs.GetQuotas(c.Context())
+	if err != nil {
+		return h.handleError(c, "Failed to get quotas", err)
+	}
+	return c.JSON(quotas)
+}
+
+// SetQuota creates or replaces quota of the role (/quota/roles/:name, name is role code) or the group (/quota/groups/:name)
+func (h *RBACHandler) SetQuota(c *fiber.Ctx) error {
+	var input us.Quota
+	if err := c.BodyParser(&input); err != nil {
+		return h.handleError(c, "Failed to parse quota input", err)
+	}
+	h.log.Infof("Set quota of %s[%s]: %+v", c.Params("subject"), c.Params("name"), input)
+
+	var err error
+	switch c.Params("subject") {
+	case "roles":
+		err = h.qs.SetRoleQuota(c.Context(), c.Params("name"), &input)
+	case "groups":
+		err = h.qs.SetGroupQuota(c.Context(), c.Params("name"), &input)
+	default:
+		err = errors.New("quota can be set for roles or groups")
+	}
+	if err != nil {
+		return h.handleError(c, "Failed to set quota", err)
+	}
+	return c.JSON(input)
+}
+
+// GetUserQuota returns usage of the quota of the user
+func (h *RBACHandler) GetUserQuota(c *fiber.Ctx) error {
+	status, err := h.qs.Status(c.Context(), c.Params("login"))
+	if err != nil {
+		return h.handleError(c, "Failed to get quota status", err)
+	}
+	return c.JSON(status)
+}
+
+// ResetQuota restarts counting of usage of the user (/quota/users/:name/reset) or the group (/quota/groups/:name/reset)
+func (h *RBACHandler) ResetQuota(c *fiber.Ctx) error {
+	subject := strings.TrimSuffix(c.Params("subject"), "s")
+	h.log.Infof("Reset quota usage of %s[%s]", subject, c.Params("name"))
+	if err := h.qs.Reset(c.Context(), subject, c.Params("name")); err != nil {
+		return h.handleError(c, "Failed to reset quota", err)
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "quota usage reset successfully"})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -10,6 +11,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gookit/goutil/arrutil"
 	"github.com/gookit/slog"
+	"gitlab.dev.ict/golang/go-ai/db"
 	us "gitlab.dev.ict/golang/go-ai/db/user_storage"
 	help "gitlab.dev.ict/golang/go-ai/helpers"
 	"gitlab.dev.ict/golang/go-ai/models/sse"
@@ -54,6 +56,19 @@ func (u *User) WithStoreDB(storage us.UserStorage) *User {
 	return u
 }
 
+// IsAdmin checks if the user has super admin or vector DB admin role, directly or via groups
+func (u *User) IsAdmin(ctx context.Context) bool {
+	if u.storeDB == nil || u.DBID == 0 {
+		return false
+	}
+	for _, code := range []string{db.RoleCodeSuperAdmin, db.RoleCodeVectorDBAdmin} {
+		if ok, err := u.storeDB.RoleService().HasRoleIncludingGroups(ctx, u.DBID, code); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (u *User) String() string {
 	return fmt.Sprintf("UUID=[%s] login=%s passwd=[%s] conTime=[%s] id_in_db=%d", u.UUID, u.Login, strings.Repeat("*", len(u.Password)), u.ConnTime.Format(time.RFC3339), u.DBID)
 }
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.dev.ict/golang/go-ai/db"
+	us "gitlab.dev.ict/golang/go-ai/db/user_storage"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestUser_IsAdmin(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	lo.Must(gdb.DB()).SetMaxOpenConns(1)
+	require.NoError(t, us.AutoMigrate(gdb))
+	ss := us.NewStorageService(gdb, log, false)
+
+	// codes are uppercased on save
+	require.NoError(t, ss.RoleService().CreateRole(ctx, &us.Role{Name: "Vector DB admin", Code: strings.ToLower(db.RoleCodeVectorDBAdmin)}))
+	require.NoError(t, ss.RoleService().CreateRole(ctx, &us.Role{Name: "Super admin", Code: db.RoleCodeSuperAdmin}))
+	users := map[string]*us.User{}
+	for _, name := range []string{"alice", "bob", "carol"} {
+		users[name] = &us.User{Username: name, Password: "secret-" + name}
+		require.NoError(t, ss.CreateUser(ctx, users[name]))
+	}
+	require.NoError(t, ss.RoleService().AddRoleToUser(ctx, users["alice"].ID, db.RoleCodeVectorDBAdmin))
+	_, err = ss.GroupService().CreateOrUpdateGroup(ctx, "ADMINS", "", db.RoleCodeSuperAdmin)
+	require.NoError(t, err)
+	require.NoError(t, ss.UpdateUserGroups(ctx, users["bob"].ID, []string{"ADMINS"}))
+
+	isAdmin := func(name string) bool {
+		return (&User{Login: name, DBID: users[name].ID}).WithStoreDB(ss).IsAdmin(ctx)
+	}
+	assert.True(t, isAdmin("alice"), "vector db admin role")
+	assert.True(t, isAdmin("bob"), "super admin role of the group")
+	assert.False(t, isAdmin("carol"))
+	assert.False(t, (&User{Login: "dave"}).IsAdmin(ctx))
+}
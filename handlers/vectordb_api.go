@@ -1,8 +1,18 @@
 package handlers
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -10,13 +20,14 @@ import (
 	"github.com/valyala/fasthttp"
 	help "gitlab.dev.ict/golang/go-ai/helpers"
 	m "gitlab.dev.ict/golang/go-ai/models"
+	"gitlab.dev.ict/golang/go-ai/models/sse"
 	"gitlab.dev.ict/golang/go-ai/services"
 	wvservice "gitlab.dev.ict/golang/go-ai/services/weaviate"
 	"gitlab.dev.ict/golang/libs/gologgers"
 	"gitlab.dev.ict/golang/libs/utils"
 )
 
-var searchOptsGetAllDocs = wvservice.DefaultSO().Limit(200).Fields(wvservice.FieldContent, wvservice.FieldAdditional2).SortOrder(wvservice.FieldTitle, false)
+var searchOptsGetAllDocs = wvservice.DefaultSO().Limit(200).Fields(wvservice.FieldContent, wvservice.FieldSummary, wvservice.FieldKeywords, wvservice.FieldLanguage, wvservice.FieldPages, wvservice.FieldSheet, wvservice.FieldRowRange, wvservice.FieldAdditional2).SortOrder(wvservice.FieldTitle, false)
 
 type VectorDBHandler struct {
 	app     *fiber.App
@@ -47,23 +58,66 @@ func (h *VectorDBHandler) webPageHandler(c *fiber.Ctx) error {
 func (h *VectorDBHandler) apiGroup(app *fiber.App, handlers ...func(*fiber.Ctx) error) {
 	api := app.Group("/api/vdb/v1", handlers...)
 	api.Get("/objects", func(c *fiber.Ctx) error {
-		ki, err := h.fetchDataFromWeaviate(c)
+		page, err := h.listObjects(c)
 		if err != nil {
-			return c.Status(500).JSON(&m.Response{Code: 500, Message: "Weaviate search error"})
+			return c.Status(statusOf(err)).JSON(&m.Response{Code: statusOf(err), Message: "Vector db list error", Error: err.Error()})
 		}
-		return c.JSON(&m.Response{Code: 0, Message: "Get all objects", Data: ki})
+		return c.JSON(&m.Response{Code: 0, Message: "Get objects", Data: page})
 	})
 	api.Post("/objects", h.WeaviateDocumentUpload)
 	api.Delete("/objects/:id", h.WeaviateDeleteObjectHandler)
 	api.Get("/suggest", h.suggestHandler)
+	api.Get("/export", h.exportHandler)
+	api.Post("/import", h.importHandler)
+	api.Get("/jobs", h.jobsListHandler)
+	api.Get("/jobs/:id", h.jobGetHandler)
+	api.Delete("/jobs/:id", h.jobCancelHandler)
 	app.Post("/search", h.WeaviateDocumntsHandler)
+	api.Get("/objects/:id", h.getObjectHandler)
+	api.Put("/objects/:id", h.updateObjectHandler)
+}
 
-	api.Get("/objects/:id", func(c *fiber.Ctx) error {
-		return c.SendString("Get object with id")
-	})
-	api.Put("/objects/:id", func(c *fiber.Ctx) error {
-		return c.SendString("Update object with id")
-	})
+// getObjectHandler returns object with all properties, id and timestamps in _additional.
+func (h *VectorDBHandler) getObjectHandler(c *fiber.Ctx) error {
+	r := help.Log(c)
+	item, err := h.rag.Store().GetByID(r.Ctx, c.Params("id"))
+	if err != nil {
+		status := lo.Ternary(errors.Is(err, wvservice.ErrObjectNotFound), fiber.StatusNotFound, fiber.StatusInternalServerError)
+		r.Errorf("Get object[%s] error: %v", c.Params("id"), err)
+		return c.Status(status).JSON(&m.Response{Code: status, Message: "Get object error", Error: err.Error()})
+	}
+	return c.JSON(&m.Response{Code: 0, Message: "Get object", Data: item})
+}
+
+// updateObjectHandler edits title/content/category/keyWords/summary of the object (see wvservice.ItemPatch).
+// Body must contain lastUpdateTimeUnix of the object read by the editor, 409 with the current object is returned
+// if the object was modified after it.
+func (h *VectorDBHandler) updateObjectHandler(c *fiber.Ctx) error {
+	r := help.Log(c)
+	id := c.Params("id")
+	var patch wvservice.ItemPatch
+	if err := c.BodyParser(&patch); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&m.Response{Code: fiber.StatusBadRequest, Message: "Invalid request body", Error: err.Error()})
+	}
+
+	item, err := h.rag.Store().Update(r.Ctx, id, &patch)
+	switch {
+	case err == nil:
+		if u, ok := c.Locals(help.CtxUser).(*User); ok {
+			r.Infof("Object[%s] updated by user=%s", id, u.Login)
+		}
+		return c.JSON(&m.Response{Code: 0, Message: "Object updated", Data: item})
+	case errors.Is(err, wvservice.ErrObjectNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(&m.Response{Code: fiber.StatusNotFound, Message: "Object not found", Error: err.Error()})
+	case errors.Is(err, wvservice.ErrInvalidItem):
+		return c.Status(fiber.StatusBadRequest).JSON(&m.Response{Code: fiber.StatusBadRequest, Message: "Invalid object", Error: err.Error()})
+	case errors.Is(err, wvservice.ErrVersionConflict):
+		current, _ := h.rag.Store().GetByID(r.Ctx, id)
+		return c.Status(fiber.StatusConflict).JSON(&m.Response{Code: fiber.StatusConflict, Message: "Object was modified, reload it and repeat the edit", Error: err.Error(), Data: current})
+	default:
+		r.Errorf("Update object[%s] error: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(&m.Response{Code: fiber.StatusInternalServerError, Message: "Update object error", Error: err.Error()})
+	}
 }
 
 // Handler for search suggestions
@@ -83,34 +137,175 @@ func (h *VectorDBHandler) fetchDataFromWeaviate(c *fiber.Ctx, so ...*wvservice.S
 	r := help.Log(c)
 	r.Infof("Start vector db fetching documents... Search options: %s", utils.Json(so))
 
-	gr, err := wvservice.WeaviateSearch(r, h.rag.GetKB().Client, wvservice.DefaultClassKB, utils.FirstOrDefault(searchOptsGetAllDocs, so...))
+	ki, err := h.rag.Store().Search(r.Ctx, utils.FirstOrDefault(searchOptsGetAllDocs, so...))
 	if err != nil {
-		r.Errorf("Error fetching from Weaviate: %v", err)
+		r.Errorf("Error fetching from vector db: %v", err)
 		return nil, err
 	}
 	r.Info("Finish vector db")
-	ki := wvservice.GQLRespConvert[wvservice.KnowledgeItem](gr, wvservice.DefaultClassKB)
 	r.Infof("VectorDB return %d objects", wvservice.KnowledgeItems(ki).Len())
 	return ki, nil
 }
 
+// sortableFields - fields allowed in the sort query parameter of objects list.
+var sortableFields = []string{"title", "category", "chunkNo", "url", "_creationTimeUnix", "_lastUpdateTimeUnix"}
+
+// listObjects returns page of objects by query parameters:
+//   - page (1-based, default 1) or after (cursor: ID of the last object of previous page, no search/filter/sort)
+//   - size (default 50, max 500), sort (see sortableFields) and desc=true, search - hybrid search text
+//   - category, urlPrefix, tag - comma separated values (see MetaFilter), updatedAfter/updatedBefore - YYYY-MM-DD
+func (h *VectorDBHandler) listObjects(c *fiber.Ctx) (*objectsPage, error) {
+	r := help.Log(c)
+	so := wvservice.NewSO().
+		SetFields(searchOptsGetAllDocs.FieldsReturn...).
+		Limit(c.QueryInt("size", wvservice.DefaultPageSize)).
+		SearchTxt(c.Query("search")).
+		WithAfter(c.Query("after"))
+	if sortField := c.Query("sort"); sortField != "" {
+		if !lo.Contains(sortableFields, sortField) {
+			return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("sort by [%s] is not supported, allowed: %v", sortField, sortableFields))
+		}
+		so.SortOrder(wvservice.Field(sortField), c.QueryBool("desc"))
+	} else if so.After == "" && so.SearchText == "" {
+		so.SortBy = searchOptsGetAllDocs.SortBy
+	}
+
+	filter := &wvservice.MetaFilter{
+		Categories:    queryList(c, "category"),
+		URLPrefixes:   queryList(c, "urlPrefix"),
+		Tags:          queryList(c, "tag"),
+		UpdatedAfter:  c.Query("updatedAfter"),
+		UpdatedBefore: c.Query("updatedBefore"),
+	}
+	if err := so.WithFilter(filter); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	page, err := wvservice.Paginate(r.Ctx, h.rag.Store(), so, c.QueryInt("page", 1))
+	if errors.Is(err, wvservice.ErrCursorWithQuery) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		r.Errorf("List objects error: %v", err)
+		return nil, err
+	}
+	r.Infof("List objects: page=%d after=%s returned=%d total=%d", page.Number, page.After, len(page.Items), page.Total)
+	return &objectsPage{Page: page, query: c.Queries()}, nil
+}
+
+// objectsPage is a page of objects with links to other pages for the admin UI.
+type objectsPage struct {
+	*wvservice.Page
+	query map[string]string
+}
+
+// Link returns URL of the page with the same size, sort and filter.
+func (p *objectsPage) Link(page int) string {
+	q := url.Values{}
+	for k, v := range p.query {
+		q.Set(k, v)
+	}
+	q.Del("after")
+	q.Set("page", strconv.Itoa(page))
+	return "/wdocs?" + q.Encode()
+}
+
+// NextLink returns URL of the next page: by cursor or by number.
+func (p *objectsPage) NextLink() string {
+	if !p.IsCursor() {
+		return p.Link(p.NextPage())
+	}
+	q := url.Values{}
+	for k, v := range p.query {
+		q.Set(k, v)
+	}
+	q.Set("after", p.Next)
+	return "/wdocs?" + q.Encode()
+}
+
+// Query returns value of the query parameter of the page request.
+func (p *objectsPage) Query(key string) string { return p.query[key] }
+
+// SortFields returns fields allowed for sorting.
+func (p *objectsPage) SortFields() []string { return sortableFields }
+
+// Sizes returns page sizes offered by the admin UI.
+func (p *objectsPage) Sizes() []int { return []int{20, wvservice.DefaultPageSize, 100, 200} }
+
+// queryList returns comma separated values of the query parameter.
+func queryList(c *fiber.Ctx, key string) []string {
+	if v := c.Query(key); v != "" {
+		return strings.Split(v, ",")
+	}
+	return nil
+}
+
+// statusOf returns status of fiber error or 500.
+func statusOf(err error) int {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return fe.Code
+	}
+	return fiber.StatusInternalServerError
+}
+
+// WeaviateDocumentUpload puts uploaded file and/or URL into the background ingestion queue and returns the job.
+// Progress of the job is sent to the user as SSE events "ingest_job".
 func (h *VectorDBHandler) WeaviateDocumentUpload(c *fiber.Ctx) error {
 	r := help.Log(c)
 	r.Info("Start")
+	jobs := h.rag.Jobs()
+	if jobs == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(&m.Response{Code: fiber.StatusServiceUnavailable, Message: "Ingestion queue is not available"})
+	}
+
 	file, err := c.FormFile("file-upload")
 	if err != nil && err != fasthttp.ErrMissingFile {
 		r.Error("File get error: ", err)
 		return c.Status(fiber.StatusNotFound).SendString("File upload error")
 	}
 
+	var (
+		items     []*services.IngestJobItem
+		uploadDir string // removed here unless the job takes it over
+	)
+	defer func() {
+		if uploadDir != "" {
+			os.RemoveAll(uploadDir)
+		}
+	}()
 	if file != nil {
-		filePath := fmt.Sprintf("/tmp/%s_%s", time.Now().Format("2006-01-02_15-04-05"), file.Filename)
+		t, err := h.fileLogicType(file)
+		if err != nil {
+			r.Errorf("File[%s] type resolve - FAIL! err=%v", file.Filename, err)
+			return c.Status(fiber.StatusUnsupportedMediaType).JSON(&m.Response{Code: fiber.StatusUnsupportedMediaType, Message: "Unsupported file type, allowed: " + strings.Join(h.rag.Files().Extensions(), ", "), Error: err.Error()})
+		}
+		// file is saved to unique dir under its own name, identity of the source in KB is owner and name of the file,
+		// so the next upload of the same file replaces its documents
+		dir, err := os.MkdirTemp("", "upload-*")
+		if err != nil {
+			r.Errorf("File[%s] temp dir create - FAIL! err=%v", file.Filename, err)
+			return c.Status(500).SendString("File save error")
+		}
+		uploadDir = dir
+		fileName := filepath.Base(file.Filename)
+		filePath := filepath.Join(dir, fileName)
 		if err := c.SaveFile(file, filePath); err != nil {
-			r.Errorf("File[%s] save to[%s] - FAIL! err=%v", err)
+			r.Errorf("File[%s] save to[%s] - FAIL! err=%v", file.Filename, filePath, err)
 			return c.Status(400).SendString("File save error")
 		}
 		r.Infof("File[%s] save to[%s] - OK!", file.Filename, filePath)
-		h.rag.GetLogic(m.LogicTypeDocx).WithExternalSource(filePath).Process(r.Ctx, m.ContentSaveToVectorDB(h.rag.GetKB()), m.ContentBackupLocal(r, "/tmp"))
+		if logic, err := h.rag.NewLogic(t); err != nil {
+			r.Errorf("File[%s] processor create - FAIL! err=%v", file.Filename, err)
+			return c.Status(fiber.StatusUnsupportedMediaType).JSON(&m.Response{Code: fiber.StatusUnsupportedMediaType, Message: "Unsupported file type", Error: err.Error()})
+		} else if bundle, ok := logic.(*services.BundlePprocessor); ok {
+			if err := bundle.Validate(filePath); err != nil {
+				r.Errorf("Bundle[%s] is rejected: %v", file.Filename, err)
+				status := lo.Ternary(errors.Is(err, services.ErrBundleLimit), fiber.StatusRequestEntityTooLarge, fiber.StatusBadRequest)
+				return c.Status(status).JSON(&m.Response{Code: status, Message: "Bundle is rejected", Error: err.Error()})
+			}
+		}
+		items = append(items, services.NewIngestJobItem(t, filePath).WithURI(services.UploadURI(GetUser(c).Login, fileName)).WithDir(dir))
 	}
 
 	urlInput := c.FormValue("url-input")
@@ -120,26 +315,184 @@ func (h *VectorDBHandler) WeaviateDocumentUpload(c *fiber.Ctx) error {
 			r.Errorf("URL input is not valid: %s", urlInput)
 			return c.Status(400).SendString("URL input is not valid")
 		}
-		r.Infof("URL input: %s", urlInput)
-		if lo.Contains([]string{"lifecell.ua", "lifecell.com.ua"}, hostname) {
-			r.Debug("SCRAP from official lifecell web page")
-			h.rag.GetLogic(m.LogicTypeWebLifecell).WithExternalSource(urlInput).Process(r.Ctx, m.ContentSaveToVectorDB(h.rag.GetKB()), m.ContentBackupLocal(r, "/tmp"))
-		} else {
-			h.rag.GetLogic(m.LogicTypeWebOther).WithExternalSource(urlInput).Process(r.Ctx, m.ContentSaveToVectorDB(h.rag.GetKB()), m.ContentBackupLocal(r, "/tmp"))
+		// only the page is ingested, pages under the URL are crawled if the user asks for it
+		isCrawl := lo.Contains([]string{"on", "true"}, c.FormValue("crawl"))
+		r.Infof("URL input: %s crawl=%t", urlInput, isCrawl)
+		isLifecell := lo.Contains([]string{"lifecell.ua", "lifecell.com.ua"}, hostname)
+		items = append(items, services.NewIngestJobItem(lo.Ternary(isLifecell, m.LogicTypeWebLifecell, m.LogicTypeWebOther), urlInput).WithCrawl(isCrawl))
+	}
+
+	if len(items) == 0 {
+		return c.Status(400).JSON(&m.Response{Code: 400, Message: "File or URL is required"})
+	}
+
+	job, err := jobs.Submit(r.Ctx, GetUser(c).Login, items, jobProgressNotifier(c))
+	if err != nil {
+		r.Errorf("Submit ingestion job - FAIL! err=%v", err)
+		return c.Status(500).JSON(&m.Response{Code: 500, Message: "Ingestion job submit error", Error: err.Error()})
+	}
+	uploadDir = ""
+	return c.Status(fiber.StatusAccepted).JSON(&m.Response{Code: 0, Message: "Ingestion job queued", Data: job})
+}
+
+// fileLogicType resolves processor of the uploaded file by extension, Content-Type of the part and sniffed content type.
+func (h *VectorDBHandler) fileLogicType(file *multipart.FileHeader) (m.LogicType, error) {
+	mimes := []string{file.Header.Get(fiber.HeaderContentType)}
+	if f, err := file.Open(); err == nil {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(f, head)
+		f.Close()
+		mimes = append(mimes, http.DetectContentType(head[:n]))
+	}
+	return h.rag.Files().Resolve(file.Filename, lo.Compact(mimes)...)
+}
+
+// jobProgressNotifier sends job progress to the user SSE stream. Event is dropped if the user has no active stream.
+func jobProgressNotifier(c *fiber.Ctx) func(*services.JobProgress) {
+	user := getUser(c)
+	if user == nil {
+		return nil
+	}
+	return func(p *services.JobProgress) {
+		select {
+		case user.ChanEventMsg <- sse.Event{Type: sse.EvtIngestJob, Msg: utils.JsonStr(p)}:
+		case <-time.After(time.Second):
 		}
 	}
+}
+
+// exportHandler streams all objects of the vector store as JSONL (see wvservice.Export).
+// Query: vectors=true - include vectors, pageSize - objects per store request.
+func (h *VectorDBHandler) exportHandler(c *fiber.Ctx) error {
+	r := help.Log(c)
+	d, ok := h.rag.Store().(wvservice.Dumper)
+	if !ok {
+		return c.Status(fiber.StatusNotImplemented).JSON(&m.Response{Code: fiber.StatusNotImplemented, Message: "Vector store does not support export"})
+	}
+	opts := wvservice.DumpOptions{PageSize: c.QueryInt("pageSize"), WithVector: c.QueryBool("vectors")}
+	r.Infof("Export vector db: %s", utils.Json(opts))
+
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="kb_%s.jsonl"`, time.Now().Format("2006-01-02_15-04-05")))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		count, err := wvservice.Export(r.Ctx, d, w, opts)
+		if err != nil {
+			r.Errorf("Export vector db - FAIL after %d objects! err=%v", count, err)
+		} else {
+			r.Infof("Export vector db - OK! objects=%d", count)
+		}
+		w.Flush()
+	})
+	return nil
+}
 
-	if file != nil || urlInput != "" {
-		batchResp, err := h.rag.GetKB().AddToWeaviateBatchWithAutoClear(r.Ctx)
+// importHandler restores JSONL dump (form file "file" or request body) into the vector store.
+// Query: class - target Weaviate class (created if absent, default - class of the store), reEmbed=true - ignore vectors of the dump.
+func (h *VectorDBHandler) importHandler(c *fiber.Ctx) error {
+	r := help.Log(c)
+	var in io.Reader = bytes.NewReader(c.Body())
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
 		if err != nil {
-			r.Errorf("Error adding to Weaviate: %v", err)
-			return err
+			r.Errorf("Open uploaded dump[%s] error: %v", file.Filename, err)
+			return c.Status(400).JSON(&m.Response{Code: 400, Message: "File upload error"})
 		}
-		ft, _ := os.CreateTemp("", "wv-upload-data-*")
-		ft.Write(lo.Must(batchResp[0].MarshalJSON()))
-		r.Infof("Batch response: %s", utils.Json(batchResp))
+		defer f.Close()
+		in = f
+	} else if err != fasthttp.ErrMissingFile && !errors.Is(err, fasthttp.ErrNoMultipartForm) {
+		r.Error("File get error: ", err)
+		return c.Status(400).JSON(&m.Response{Code: 400, Message: "File upload error"})
+	}
+
+	target, err := wvservice.ImportTarget(r.Ctx, h.rag.Store(), c.Query("class"))
+	if err != nil {
+		r.Errorf("Import target[%s] error: %v", c.Query("class"), err)
+		return c.Status(400).JSON(&m.Response{Code: 400, Message: "Import target error", Error: err.Error()})
+	}
+	opts := wvservice.ImportOptions{BatchSize: c.QueryInt("batchSize"), ReEmbed: c.QueryBool("reEmbed")}
+	res, err := wvservice.Import(r.Ctx, target, in, opts)
+	r.Infof("Import vector db class[%s]: %s", c.Query("class"), res)
+	if err != nil && len(res.Succeeded()) == 0 {
+		return c.Status(500).JSON(&m.Response{Code: 500, Message: "Import error", Error: err.Error(), Data: res})
 	}
-	return c.SendStatus(200)
+	if err != nil {
+		return c.JSON(&m.Response{Code: 0, Message: "Import finished with errors", Error: err.Error(), Data: res})
+	}
+	return c.JSON(&m.Response{Code: 0, Message: "Import finished", Data: res})
+}
+
+func (h *VectorDBHandler) jobsListHandler(c *fiber.Ctx) error {
+	if h.rag.Jobs() == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(&m.Response{Code: fiber.StatusServiceUnavailable, Message: "Ingestion queue is not available"})
+	}
+	// user sees own jobs, admin can list jobs of another owner or of all owners (owner=*)
+	user, owner := GetUser(c), c.Query("owner")
+	if user.Login == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(&m.Response{Code: fiber.StatusUnauthorized, Message: "User is not authorized"})
+	}
+	if owner != "" && owner != user.Login && !user.IsAdmin(help.Log(c).Ctx) {
+		return c.Status(fiber.StatusForbidden).JSON(&m.Response{Code: fiber.StatusForbidden, Message: "Only admin can list jobs of other users"})
+	}
+	switch owner {
+	case "":
+		owner = user.Login
+	case "*":
+		owner = ""
+	}
+	jobs, err := h.rag.Jobs().List(help.Log(c).Ctx, owner, c.QueryInt("limit", 20))
+	if err != nil {
+		help.Log(c).Errorf("List ingestion jobs error: %v", err)
+		return c.Status(500).JSON(&m.Response{Code: 500, Message: "List ingestion jobs error"})
+	}
+	return c.JSON(&m.Response{Code: 0, Message: "Ingestion jobs", Data: jobs})
+}
+
+func (h *VectorDBHandler) jobGetHandler(c *fiber.Ctx) error {
+	job, err := h.userJob(c)
+	if job == nil {
+		return err
+	}
+	return c.JSON(&m.Response{Code: 0, Message: "Ingestion job", Data: job})
+}
+
+func (h *VectorDBHandler) jobCancelHandler(c *fiber.Ctx) error {
+	job, err := h.userJob(c)
+	if job == nil {
+		return err
+	}
+	job, err = h.rag.Jobs().Cancel(help.Log(c).Ctx, job.ID)
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		return c.Status(404).JSON(&m.Response{Code: 404, Message: err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusConflict).JSON(&m.Response{Code: fiber.StatusConflict, Message: err.Error(), Data: job})
+	}
+	help.Log(c).Infof("Ingestion job[%s] cancel requested", job.ID)
+	return c.JSON(&m.Response{Code: 0, Message: "Ingestion job cancel requested", Data: job})
+}
+
+// userJob returns job of the path param "id" if the user is its owner or admin.
+// Otherwise the error response is sent, job is nil and err is the result of sending the response.
+func (h *VectorDBHandler) userJob(c *fiber.Ctx) (*services.IngestJob, error) {
+	if h.rag.Jobs() == nil {
+		return nil, c.Status(fiber.StatusServiceUnavailable).JSON(&m.Response{Code: fiber.StatusServiceUnavailable, Message: "Ingestion queue is not available"})
+	}
+	user := GetUser(c)
+	if user.Login == "" {
+		return nil, c.Status(fiber.StatusUnauthorized).JSON(&m.Response{Code: fiber.StatusUnauthorized, Message: "User is not authorized"})
+	}
+	job, err := h.rag.Jobs().Get(help.Log(c).Ctx, c.Params("id"))
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		return nil, c.Status(404).JSON(&m.Response{Code: 404, Message: err.Error()})
+	case err != nil:
+		help.Log(c).Errorf("Get ingestion job error: %v", err)
+		return nil, c.Status(500).JSON(&m.Response{Code: 500, Message: "Get ingestion job error"})
+	case job.Owner != user.Login && !user.IsAdmin(help.Log(c).Ctx):
+		help.Log(c).Warnf("User[%s] has no access to ingestion job[%s] of [%s]", user.Login, job.ID, job.Owner)
+		return nil, c.Status(fiber.StatusForbidden).JSON(&m.Response{Code: fiber.StatusForbidden, Message: "Only owner or admin can access the job"})
+	}
+	return job, nil
 }
 
 func (h *VectorDBHandler) WeaviateDeleteObjectHandler(c *fiber.Ctx) error {
@@ -148,9 +501,9 @@ func (h *VectorDBHandler) WeaviateDeleteObjectHandler(c *fiber.Ctx) error {
 
 	log.Infof("Deleting object with ID: %s\n", id)
 
-	if err := h.rag.GetKB().DeleteItemFromWeaviate(help.Log(c).Ctx, id); err != nil {
+	if err := h.rag.Store().Delete(help.Log(c).Ctx, id); err != nil {
 		log.Errorf("Delete object error: %v", err)
-		return c.Status(500).SendString("Weaviate delete error")
+		return c.Status(500).SendString("Vector db delete error")
 	}
 
 	return c.Status(200).Send(nil)
@@ -159,28 +512,35 @@ func (h *VectorDBHandler) WeaviateDeleteObjectHandler(c *fiber.Ctx) error {
 func (h *VectorDBHandler) WeaviateDocumntsHandler(c *fiber.Ctx) error {
 	log := help.Log(c)
 	log.Info("Start handle request")
-	var ki []*wvservice.KnowledgeItem
-	var err error
-	if c.Method() == fiber.MethodPost {
-		var req wvservice.SearchRequest
-		if err := c.BodyParser(&req); err != nil {
-			log.Error("Error parsing request body: ", err)
-			return err
+	if c.Method() != fiber.MethodPost {
+		page, err := h.listObjects(c)
+		if err != nil {
+			log.Errorf("Error fetching from vector db: %v", err)
+			return c.Status(statusOf(err)).SendString("Error fetching documents: " + err.Error())
 		}
-		ki, err = h.fetchDataFromWeaviate(c, req.ToSearchOptions())
-	} else {
-		ki, err = h.fetchDataFromWeaviate(c)
+		return renderTemplate(c, "partials/fromWeaviate", page)
 	}
 
+	var req wvservice.SearchRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error("Error parsing request body: ", err)
+		return err
+	}
+	so, err := req.ToSearchOptions()
+	if err != nil {
+		log.Warnf("Invalid search request: %v", err)
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	ki, err := h.fetchDataFromWeaviate(c, so)
 	if err != nil {
 		log.Errorf("Error fetching from Weaviate: %v", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Error fetching documents")
 	}
 
 	log.Infof("Fetched %d documents from Weaviate", len(ki))
-	if h.isDebug {
+	if h.isDebug && len(ki) > 0 {
 		log.Info(utils.JsonPrettyStr(ki[0]))
 		log.Infof("First document: TimeCreationString=%v; LastUpdateTime=%v", ki[0].TimeCreationString(), ki[0].Additional.LastUpdateTime())
 	}
-	return renderTemplate(c, "partials/fromWeaviate", ki)
+	return renderTemplate(c, "partials/fromWeaviate", &objectsPage{Page: wvservice.NewPage(ki)})
 }
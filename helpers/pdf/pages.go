@@ -0,0 +1,192 @@
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+	"github.com/samber/lo"
+	"gitlab.dev.ict/golang/go-ai/helpers/sheets"
+)
+
+const (
+	lineTolerance = 0.5  // glyphs with Y difference less than font size * lineTolerance are in the same line
+	wordGap       = 0.15 // gap (in font sizes) between glyphs which separates words
+	cellGap       = 1.2  // gap (in font sizes) between glyphs which separates table cells
+	colTolerance  = 2.0  // max shift (in font sizes) of the cell start from the column start
+)
+
+// ErrNoText is returned when PDF has no text layer (e.g. scanned document).
+var ErrNoText = errors.New("PDF has no text layer")
+
+// Page is a text of the PDF page. Tables (rows with aligned cells) are rendered as markdown tables.
+type Page struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+}
+
+// LoadPages returns text of non-empty pages and title of the document (Info/Title or file name).
+func LoadPages(pathToPDF string) (pages []Page, title string, err error) {
+	f, r, err := pdf.Open(pathToPDF)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	title = strings.TrimSpace(r.Trailer().Key("Info").Key("Title").Text())
+	if title == "" {
+		title = filepath.Base(pathToPDF)
+	}
+	for i := 1; i <= r.NumPage(); i++ {
+		p := r.Page(i)
+		if p.V.IsNull() {
+			continue
+		}
+		texts, err := pageTexts(p)
+		if err != nil {
+			return pages, title, fmt.Errorf("page %d: %w", i, err)
+		}
+		if txt := renderLines(groupLines(texts)); txt != "" {
+			pages = append(pages, Page{Number: i, Text: txt})
+		}
+	}
+	if len(pages) == 0 {
+		return nil, title, ErrNoText
+	}
+	return pages, title, nil
+}
+
+// pageTexts returns glyphs of the page, panic of the parser on malformed content is returned as error.
+func pageTexts(p pdf.Page) (texts []pdf.Text, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("parse content: %v", r)
+		}
+	}()
+	return p.Content().Text, nil
+}
+
+type cell struct {
+	x    float64
+	text string
+}
+
+type line struct {
+	cells    []cell
+	fontSize float64
+}
+
+func (l line) String() string {
+	return strings.Join(lo.Map(l.cells, func(c cell, _ int) string { return c.text }), " ")
+}
+
+// groupLines joins glyphs into lines (top to bottom) and lines into cells separated by wide gaps.
+func groupLines(texts []pdf.Text) (lines []line) {
+	texts = lo.Filter(texts, func(t pdf.Text, _ int) bool { return t.S != "" })
+	sort.SliceStable(texts, func(i, j int) bool {
+		if math.Abs(texts[i].Y-texts[j].Y) >= fontSize(texts[i])*lineTolerance {
+			return texts[i].Y > texts[j].Y
+		}
+		return texts[i].X < texts[j].X
+	})
+
+	var cur []pdf.Text
+	flush := func() {
+		if l := buildLine(cur); len(l.cells) > 0 {
+			lines = append(lines, l)
+		}
+		cur = nil
+	}
+	for _, t := range texts {
+		if len(cur) > 0 && math.Abs(cur[0].Y-t.Y) >= fontSize(cur[0])*lineTolerance {
+			flush()
+		}
+		cur = append(cur, t)
+	}
+	flush()
+	return
+}
+
+func buildLine(glyphs []pdf.Text) (l line) {
+	sort.SliceStable(glyphs, func(i, j int) bool { return glyphs[i].X < glyphs[j].X })
+	var (
+		sb   strings.Builder
+		x    float64
+		end  float64
+		size float64
+	)
+	flush := func() {
+		if txt := strings.Join(strings.Fields(sb.String()), " "); txt != "" {
+			l.cells = append(l.cells, cell{x: x, text: txt})
+		}
+		sb.Reset()
+	}
+	for i, g := range glyphs {
+		fs := fontSize(g)
+		size = max(size, fs)
+		if i > 0 {
+			gap := g.X - end
+			switch {
+			case gap > fs*cellGap:
+				flush()
+			case gap > fs*wordGap:
+				sb.WriteByte(' ')
+			}
+		}
+		if sb.Len() == 0 {
+			x = g.X
+		}
+		sb.WriteString(g.S)
+		end = max(end, g.X+g.W)
+	}
+	flush()
+	l.fontSize = size
+	return
+}
+
+func fontSize(t pdf.Text) float64 {
+	return lo.Ternary(t.FontSize > 0, t.FontSize, 10)
+}
+
+// renderLines returns text of lines, runs of 2+ lines with the same count (2+) of aligned cells are rendered as markdown table.
+// Multi-column page layout can be recognized as table as well.
+func renderLines(lines []line) string {
+	var out []string
+	for i := 0; i < len(lines); {
+		j := i + 1
+		for j < len(lines) && isTableRow(lines[i], lines[j]) {
+			j++
+		}
+		if j-i >= 2 && len(lines[i].cells) >= 2 {
+			out = append(out, "", markdownTable(lines[i:j]), "")
+		} else {
+			out = append(out, lines[i].String())
+			j = i + 1
+		}
+		i = j
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// isTableRow reports whether line continues the table started by the header: same count of cells starting at the same columns.
+func isTableRow(header, l line) bool {
+	if len(header.cells) < 2 || len(l.cells) != len(header.cells) {
+		return false
+	}
+	tol := max(header.fontSize, l.fontSize) * colTolerance
+	for i, c := range l.cells {
+		if math.Abs(c.x-header.cells[i].x) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+func markdownTable(rows []line) string {
+	texts := lo.Map(rows, func(l line, _ int) []string { return lo.Map(l.cells, func(c cell, _ int) string { return c.text }) })
+	return sheets.MarkdownTable(texts[0], texts[1:])
+}
@@ -0,0 +1,66 @@
+package pdf
+
+import (
+	"testing"
+
+	"github.com/ledongthuc/pdf"
+	"github.com/stretchr/testify/assert"
+)
+
+// glyphs returns glyphs of the word placed at x, y (font size 10, glyph width 5).
+func glyphs(s string, x, y float64) (res []pdf.Text) {
+	for i, r := range []rune(s) {
+		res = append(res, pdf.Text{FontSize: 10, X: x + float64(i)*5, Y: y, W: 5, S: string(r)})
+	}
+	return
+}
+
+func page(words ...[]pdf.Text) (res []pdf.Text) {
+	for _, w := range words {
+		res = append(res, w...)
+	}
+	return
+}
+
+func TestRenderLines_Text(t *testing.T) {
+	texts := page(
+		glyphs("second", 10, 680),
+		glyphs("Hello", 10, 700), glyphs("world", 38, 700.5),
+	)
+	assert.Equal(t, "Hello world\nsecond", renderLines(groupLines(texts)))
+}
+
+func TestRenderLines_Table(t *testing.T) {
+	texts := page(
+		glyphs("Intro", 10, 720),
+		glyphs("Name", 10, 700), glyphs("Price", 100, 700),
+		glyphs("Basic", 10, 685), glyphs("10", 102, 685),
+		glyphs("a|b", 12, 670), glyphs("20", 100, 670),
+		glyphs("Footer", 10, 650),
+	)
+	assert.Equal(t, `Intro
+
+| Name | Price |
+| --- | --- |
+| Basic | 10 |
+| a\|b | 20 |
+
+Footer`, renderLines(groupLines(texts)))
+}
+
+func TestRenderLines_NotAligned(t *testing.T) {
+	texts := page(
+		glyphs("a", 10, 700), glyphs("b", 100, 700),
+		glyphs("c", 10, 685), glyphs("d", 200, 685),
+	)
+	assert.Equal(t, "a b\nc d", renderLines(groupLines(texts)))
+}
+
+func TestLoadPages(t *testing.T) {
+	pages, title, err := LoadPages(PDF1)
+	if assert.NoError(t, err) && assert.NotEmpty(t, pages) {
+		assert.NotEmpty(t, title)
+		assert.Positive(t, pages[0].Number)
+		assert.NotEmpty(t, pages[0].Text)
+	}
+}
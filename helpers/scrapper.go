@@ -136,7 +136,11 @@ func ScrapAndParse(log *slog.Record, rc *resty.Client, url string, isDebug bool,
 		log.Errorf("Error while get url [%s] >> %v", url, err)
 		return &Result{URL: url, Err: err}
 	}
+	return ParseHTML(log, url, respBytes, isDebug, arrProcessSelectionFN, skipArr...)
+}
 
+// ParseHTML parses already fetched page (see ScrapAndParse), used by crawler which makes requests itself.
+func ParseHTML(log *slog.Record, url string, respBytes []byte, isDebug bool, arrProcessSelectionFN []ProcessSelectionFN, skipArr ...SkipElementFN) (res *Result) {
 	// respBytes, e = m.Bytes("text/html", resp.Body())
 	// if e != nil {
 	// respBytes = resp.Body()
@@ -0,0 +1,289 @@
+// Package sheets reads spreadsheets (XLSX, CSV) as plain string cells.
+// XLSX is read with the standard library: values are taken as stored, so dates are returned as Excel serial numbers
+// and formulas as their cached results.
+package sheets
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupported is returned for files which are neither XLSX nor CSV.
+var ErrUnsupported = errors.New("unsupported spreadsheet format")
+
+// Row is a non-empty row of the sheet, Number is 1-based number of the row in the sheet.
+type Row struct {
+	Number int
+	Cells  []string
+}
+
+// Sheet is a named table. Empty rows are skipped, trailing empty cells are trimmed.
+type Sheet struct {
+	Name string
+	Rows []Row
+}
+
+// Width returns max number of cells in a row.
+func (s *Sheet) Width() (n int) {
+	for _, r := range s.Rows {
+		n = max(n, len(r.Cells))
+	}
+	return
+}
+
+// Load reads XLSX or CSV file by the extension.
+func Load(filePath string) ([]Sheet, error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".xlsx", ".xlsm":
+		return LoadXLSX(filePath)
+	case ".csv", ".tsv":
+		f, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		s, err := ReadCSV(f, strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)))
+		if err != nil {
+			return nil, err
+		}
+		return []Sheet{*s}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, filePath)
+}
+
+// ReadCSV reads CSV with delimiter (comma, semicolon or tab) detected by the first line.
+func ReadCSV(r io.Reader, name string) (*Sheet, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.Comma = detectDelimiter(data)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	s := &Sheet{Name: name}
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		s.addRow(line, rec)
+	}
+	return s, nil
+}
+
+func detectDelimiter(data []byte) rune {
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+	best, cnt := ',', bytes.Count(first, []byte(","))
+	for _, d := range []rune{';', '\t'} {
+		if c := bytes.Count(first, []byte(string(d))); c > cnt {
+			best, cnt = d, c
+		}
+	}
+	return best
+}
+
+func (s *Sheet) addRow(n int, cells []string) {
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	for len(cells) > 0 && cells[len(cells)-1] == "" {
+		cells = cells[:len(cells)-1]
+	}
+	if len(cells) > 0 {
+		s.Rows = append(s.Rows, Row{Number: n, Cells: cells})
+	}
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRels struct {
+	Rels []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is a text of shared or inline string: plain <t> or rich text runs <r><t>.
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var sb strings.Builder
+	for _, r := range t.Runs {
+		sb.WriteString(r.T)
+	}
+	return sb.String()
+}
+
+type xlsxSST struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R  string   `xml:"r,attr"`
+			T  string   `xml:"t,attr"`
+			V  string   `xml:"v"`
+			Is xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// LoadXLSX reads all worksheets of the workbook in the workbook order.
+func LoadXLSX(filePath string) ([]Sheet, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return readXLSX(&zr.Reader)
+}
+
+func readXLSX(zr *zip.Reader) ([]Sheet, error) {
+	var (
+		wb   xlsxWorkbook
+		rels xlsxRels
+		sst  xlsxSST
+	)
+	if err := unmarshalZip(zr, "xl/workbook.xml", &wb); err != nil {
+		return nil, err
+	}
+	if err := unmarshalZip(zr, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	if err := unmarshalZip(zr, "xl/sharedStrings.xml", &sst); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	targets := map[string]string{}
+	for _, r := range rels.Rels {
+		t := strings.TrimPrefix(r.Target, "/")
+		if !strings.HasPrefix(t, "xl/") {
+			t = path.Join("xl", t)
+		}
+		targets[r.ID] = t
+	}
+
+	var sheets []Sheet
+	for _, ws := range wb.Sheets {
+		var data xlsxWorksheet
+		if err := unmarshalZip(zr, targets[ws.RID], &data); err != nil {
+			return nil, fmt.Errorf("sheet %q: %w", ws.Name, err)
+		}
+		s := Sheet{Name: ws.Name}
+		for i, row := range data.Rows {
+			var cells []string
+			for j, c := range row.Cells {
+				col := columnIndex(c.R)
+				if col < 0 {
+					col = j
+				}
+				for len(cells) <= col {
+					cells = append(cells, "")
+				}
+				cells[col] = cellValue(c.T, c.V, c.Is, sst.Items)
+			}
+			s.addRow(orDefault(row.R, i+1), cells)
+		}
+		sheets = append(sheets, s)
+	}
+	return sheets, nil
+}
+
+func unmarshalZip(zr *zip.Reader, name string, v any) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = xml.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func cellValue(typ, v string, inline xlsxText, sst []xlsxText) string {
+	switch typ {
+	case "s":
+		if i, err := strconv.Atoi(v); err == nil && i >= 0 && i < len(sst) {
+			return sst[i].String()
+		}
+		return ""
+	case "inlineStr":
+		return inline.String()
+	case "b":
+		return strconv.FormatBool(v == "1")
+	case "str", "e":
+		return v
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		// drop float noise of stored values, e.g. 0.30000000000000004
+		f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return v
+}
+
+// columnIndex returns 0-based column of the cell reference, e.g. "AB12" => 27.
+func columnIndex(ref string) (col int) {
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+	}
+	return col - 1
+}
+
+func orDefault(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+// MarkdownTable renders rows with the header as markdown table, whitespace in cells is collapsed and '|' is escaped.
+func MarkdownTable(header []string, rows [][]string) string {
+	row := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, c := range cells {
+			escaped[i] = strings.ReplaceAll(strings.Join(strings.Fields(c), " "), "|", `\|`)
+		}
+		return "| " + strings.Join(escaped, " | ") + " |"
+	}
+	lines := []string{row(header), "|" + strings.Repeat(" --- |", len(header))}
+	for _, r := range rows {
+		lines = append(lines, row(r))
+	}
+	return strings.Join(lines, "\n")
+}
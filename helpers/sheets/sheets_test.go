@@ -0,0 +1,90 @@
+package sheets
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeXLSX creates minimal workbook with files of the xl folder.
+func writeXLSX(t *testing.T, files map[string]string) string {
+	p := filepath.Join(t.TempDir(), "book.xlsx")
+	f, err := os.Create(p)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+	return p
+}
+
+func TestLoadXLSX(t *testing.T) {
+	p := writeXLSX(t, map[string]string{
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Tariffs" sheetId="1" r:id="rId1"/><sheet name="Empty" sheetId="2" r:id="rId2"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="worksheet" Target="/xl/worksheets/sheet2.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><si><t>Name</t></si><si><t>Price</t></si><si><r><t>Ba</t></r><r><t>sic</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+<row r="2"/>
+<row r="3"><c r="A3" t="s"><v>2</v></c><c r="C3"><v>0.30000000000000004</v></c></row>
+<row r="4"><c r="A4" t="inlineStr"><is><t>Flag</t></is></c><c r="B4" t="b"><v>1</v></c><c r="C4" t="s"/></row>
+</sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData/></worksheet>`,
+	})
+
+	sheets, err := Load(p)
+	require.NoError(t, err)
+	require.Len(t, sheets, 2)
+	assert.Equal(t, "Tariffs", sheets[0].Name)
+	assert.Equal(t, []Row{
+		{Number: 1, Cells: []string{"Name", "Price"}},
+		{Number: 3, Cells: []string{"Basic", "", "0.3"}},
+		{Number: 4, Cells: []string{"Flag", "true"}},
+	}, sheets[0].Rows)
+	assert.Equal(t, 3, sheets[0].Width())
+	assert.Empty(t, sheets[1].Rows)
+}
+
+func TestReadCSV(t *testing.T) {
+	s, err := ReadCSV(strings.NewReader("\ufeffRange;Operator\n\n380630000000;lifecell\n380670000000;\"kyiv;star\"\n"), "ranges")
+	require.NoError(t, err)
+	assert.Equal(t, "ranges", s.Name)
+	assert.Equal(t, []Row{
+		{Number: 1, Cells: []string{"Range", "Operator"}},
+		{Number: 3, Cells: []string{"380630000000", "lifecell"}},
+		{Number: 4, Cells: []string{"380670000000", "kyiv;star"}},
+	}, s.Rows)
+}
+
+func TestMarkdownTable(t *testing.T) {
+	assert.Equal(t, "| Code | Meaning |\n| --- | --- |\n| 409 | a\\|b conflict |",
+		MarkdownTable([]string{"Code", "Meaning"}, [][]string{{"409", " a|b\n conflict"}}))
+}
+
+func TestColumnIndex(t *testing.T) {
+	assert.Equal(t, 0, columnIndex("A1"))
+	assert.Equal(t, 27, columnIndex("AB12"))
+	assert.Equal(t, -1, columnIndex(""))
+}
+
+func TestLoad_Unsupported(t *testing.T) {
+	_, err := Load("file.xls")
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
@@ -1,11 +1,13 @@
 package ailogic
 
 import (
+	"net/http"
 	"os"
 	"testing"
 
 	"github.com/caarlos0/env/v6"
 	"github.com/olekukonko/tablewriter"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"github.com/tmc/langchaingo/chains"
 	"github.com/tmc/langchaingo/llms"
@@ -14,6 +16,7 @@ import (
 	"github.com/tmc/langchaingo/memory/sqlite3"
 	"github.com/tmc/langchaingo/schema"
 	"gitlab.dev.ict/golang/go-ai/logic/ailogic/callbackhandlers"
+	llmreg "gitlab.dev.ict/golang/go-ai/logic/ailogic/llm"
 	"gitlab.dev.ict/golang/go-ai/logic/ailogic/tools"
 	"gitlab.dev.ict/golang/go-ai/logic/biz"
 	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
@@ -66,6 +69,7 @@ var (
 
 	llm = llm_openai
 	// llm = llm_groq
+	models = lo.Must(llmreg.NewRegistry(log, func(bool) *http.Client { return HttpCl }, llmreg.DefaultRegistryConfig(GPT_4o)))
 
 	chainOpts   = []chains.ChainCallOption{chains.WithCallback(callbackH), chains.WithMaxTokens(1000), chains.WithTemperature(0.25)}
 	chatHistory = []llms.ChatMessage{
@@ -113,7 +117,7 @@ func iHelp_init_ws_for_test(t *testing.T) *biz.WSGetter {
 
 func Help_init_ALL_tools_for_test(t *testing.T) {
 	t.Helper()
-	tools.InitTools(iHelp_init_ws_for_test(t), Help_init_VECTORDB(t))
+	tools.InitTools(iHelp_init_ws_for_test(t), w.NewRetriever(log, Help_init_VECTORDB(t)))
 }
 
 func help_prettyPrintStruct_T(t *testing.T, v interface{}) {
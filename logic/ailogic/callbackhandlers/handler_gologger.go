@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/tmc/langchaingo/callbacks"
 	"github.com/tmc/langchaingo/llms"
@@ -17,6 +18,38 @@ type HandlerExt interface {
 	HandlePrettyAsJS(ctx context.Context, msg string, data interface{})
 }
 
+// LLMAttempt describes one call of LLM made by resilient model (see llm.ResilientModel).
+type LLMAttempt struct {
+	Profile  string
+	Model    string
+	Attempt  int
+	Duration time.Duration
+	Err      error
+	Backoff  time.Duration // delay before the next attempt, 0 - no more attempts with the profile
+	Skipped  bool          // profile is skipped as its circuit breaker is open
+}
+
+// AttemptHandler receives every attempt of LLM call.
+type AttemptHandler interface {
+	HandleLLMAttempt(ctx context.Context, a LLMAttempt)
+}
+
+// HandleAttempt reports the attempt to the handler if it is AttemptHandler, and to handlers combined in it.
+func HandleAttempt(ctx context.Context, h callbacks.Handler, a LLMAttempt) {
+	switch h := h.(type) {
+	case AttemptHandler:
+		h.HandleLLMAttempt(ctx, a)
+	case callbacks.CombiningHandler:
+		for _, c := range h.Callbacks {
+			HandleAttempt(ctx, c, a)
+		}
+	case *callbacks.CombiningHandler:
+		for _, c := range h.Callbacks {
+			HandleAttempt(ctx, c, a)
+		}
+	}
+}
+
 // LoggerHandler is a callback handler that prints to the standard output.
 type LoggerHandler struct {
 	log *gl.Logger
@@ -37,6 +70,7 @@ func (l LoggerHandler) HandlePrettyAsJS(ctx context.Context, msg string, data in
 
 var _ callbacks.Handler = LoggerHandler{}
 var _ HandlerExt = LoggerHandler{}
+var _ AttemptHandler = LoggerHandler{}
 
 func DefaultLoggerCallback() LoggerHandler {
 	return LoggerHandler{log: gl.New(gl.WithLevel("debug"), gl.WithColor(), gl.WithOC())}
@@ -87,6 +121,19 @@ func (l LoggerHandler) HandleLLMError(ctx context.Context, err error) {
 	l.rec(ctx).Info("Exiting LLM with error:", err)
 }
 
+func (l LoggerHandler) HandleLLMAttempt(ctx context.Context, a LLMAttempt) {
+	switch {
+	case a.Skipped:
+		l.rec(ctx).Warnf("LLM profile[%s] model[%s] skipped: circuit breaker is open", a.Profile, a.Model)
+	case a.Err == nil:
+		l.rec(ctx).Infof("LLM profile[%s] model[%s] attempt=%d succeeded in %v", a.Profile, a.Model, a.Attempt, a.Duration)
+	case a.Backoff > 0:
+		l.rec(ctx).Warnf("LLM profile[%s] model[%s] attempt=%d failed in %v, retry in %v: %v", a.Profile, a.Model, a.Attempt, a.Duration, a.Backoff, a.Err)
+	default:
+		l.rec(ctx).Errorf("LLM profile[%s] model[%s] attempt=%d failed in %v: %v", a.Profile, a.Model, a.Attempt, a.Duration, a.Err)
+	}
+}
+
 func (l LoggerHandler) HandleChainStart(ctx context.Context, inputs map[string]any) {
 	l.rec(ctx).Info("Entering chain with inputs:", formatChainValues(inputs))
 }
@@ -0,0 +1,96 @@
+package callbackhandlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/llms"
+	gl "gitlab.dev.ict/golang/libs/gologgers"
+)
+
+// Price of LLM in USD per 1M tokens.
+type Price struct {
+	Input  float64 `yaml:"input" json:"input"`
+	Output float64 `yaml:"output" json:"output"`
+}
+
+// Cost returns estimated cost of the call in USD.
+func (p Price) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Input + float64(completionTokens)*p.Output) / 1e6
+}
+
+// Usage is token usage of one LLM call.
+type Usage struct {
+	Login            string
+	ChatUUID         string
+	Chain            string // chain called by the user (e.g. VoipAgents)
+	Profile          string // LLM profile of the agent of the chain (see llm.Registry)
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	Latency          time.Duration
+	Cost             float64 // estimated cost in USD
+}
+
+// UsageRecorder persists usage of LLM calls.
+type UsageRecorder interface {
+	RecordUsage(ctx context.Context, u Usage) error
+}
+
+type UsageRecorderFunc func(ctx context.Context, u Usage) error
+
+func (f UsageRecorderFunc) RecordUsage(ctx context.Context, u Usage) error { return f(ctx, u) }
+
+// UsageHandler records tokens, latency and estimated cost of every LLM call.
+// Login, chain and chat UUID are taken from ctx by the tags func (e.g. ailogic.GetLCCFromCtx),
+// latency - from start time of the call put to ctx by WithCallStart.
+// Handler is bound to profile and model of LLM client by ForModel.
+type UsageHandler struct {
+	callbacks.SimpleHandler
+	log      *gl.Logger
+	recorder UsageRecorder
+	tags     func(ctx context.Context) (login, chain, chatUUID string)
+	profile  string
+	model    string
+	price    Price
+}
+
+var _ callbacks.Handler = &UsageHandler{}
+
+func NewUsageHandler(log *gl.Logger, recorder UsageRecorder, tags func(ctx context.Context) (login, chain, chatUUID string)) *UsageHandler {
+	return &UsageHandler{log: log, recorder: recorder, tags: tags}
+}
+
+type callStartKey struct{}
+
+// WithCallStart returns ctx of LLM call started at t.
+func WithCallStart(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, callStartKey{}, t)
+}
+
+// ForModel returns handler which records calls of the model with the price.
+func (h *UsageHandler) ForModel(profile, model string, price Price) *UsageHandler {
+	c := *h
+	c.profile, c.model, c.price = profile, model, price
+	return &c
+}
+
+func (h *UsageHandler) HandleLLMGenerateContentEnd(ctx context.Context, res *llms.ContentResponse) {
+	u := Usage{Profile: h.profile, Model: h.model}
+	if start, ok := ctx.Value(callStartKey{}).(time.Time); ok {
+		u.Latency = time.Since(start)
+	}
+	if res != nil && len(res.Choices) > 0 {
+		// usage of the request is the same in all choices
+		u.PromptTokens, _ = res.Choices[0].GenerationInfo["PromptTokens"].(int)
+		u.CompletionTokens, _ = res.Choices[0].GenerationInfo["CompletionTokens"].(int)
+	}
+	u.Cost = h.price.Cost(u.PromptTokens, u.CompletionTokens)
+	if h.tags != nil {
+		u.Login, u.Chain, u.ChatUUID = h.tags(ctx)
+	}
+	if err := h.recorder.RecordUsage(context.WithoutCancel(ctx), u); err != nil {
+		h.log.RecWithCtx(ctx).Errorf("record LLM usage failed! usage=%+v err: %v", u, err)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/tmc/langchaingo/callbacks"
 	"github.com/tmc/langchaingo/chains"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 	"github.com/tmc/langchaingo/prompts"
 	"github.com/tmc/langchaingo/schema"
 	"gitlab.dev.ict/golang/libs/gologgers"
@@ -33,11 +32,6 @@ var (
 			prompts.NewHumanMessagePromptTemplate(PromptTmplUser, []string{PromptTmplUserInput}),
 		})
 	}
-	openaiOptsAg1 = []openai.Option{
-		openai.WithModel(GPT_4o),
-		openai.WithHTTPClient(HttpCl),
-		openai.WithResponseFormat(responseFormatSchemaAg1),
-	}
 )
 
 type LifecellAgentFirst struct {
@@ -58,11 +52,8 @@ func promptFromFS(fileName string) string {
 	return string(content)
 }
 
-func Agent1(ctx context.Context, l *gologgers.Logger, openaiOpts []openai.Option, chatHst ...schema.ChatMessageHistory) *LifecellAgentFirst {
-	llm, err := openai.New(openaiOpts...)
-	if err != nil {
-		panic(err)
-	}
+func Agent1(ctx context.Context, l *gologgers.Logger, models Models, chatHst ...schema.ChatMessageHistory) *LifecellAgentFirst {
+	llm, callOpts := modelOf(models, chainName1, chainCallbacks(l), responseFormatSchemaAg1)
 	return &LifecellAgentFirst{
 		LifecellChain: LifecellChainNew(
 			l, llm,
@@ -74,7 +65,7 @@ func Agent1(ctx context.Context, l *gologgers.Logger, openaiOpts []openai.Option
 					func() schema.ChatMessageHistory { return CreateSqliteMem(ctx, chainName1) },
 					func() schema.ChatMessageHistory { return chatHst[0] },
 				)),
-			WithCallOpts(defCallOpts...),
+			WithCallOpts(callOpts...),
 		),
 	}
 }
@@ -100,7 +91,7 @@ func (c *LifecellAgentFirst) Call(ctx context.Context, inputs map[string]any, _
 	c.chatHistory().AddMessage(ctx, promptValue.Messages()[0])
 	rec.Infof("chat messages before send to AI full_size(with sysprompt)=%d\n [W/O sys_msg] =>\n%s", len(promptValue.Messages()), ChatHistoryAsStringSafe(promptValue.Messages()))
 
-	result, err := c.LLM.GenerateContent(ctx, MessageContentFromChat(promptValue), append(c.callOpts, llms.WithModel(GPT_4o))...)
+	result, err := c.LLM.GenerateContent(ctx, MessageContentFromChat(promptValue), append(c.callOpts, streamingOpts(ctx, Response)...)...)
 
 	/* <<<<<< FOR TEST >>>>>>*/
 	// result, err := &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: respContent}}}, nil
@@ -21,7 +21,7 @@ type testFixture struct {
 func setupTest1(t *testing.T) *testFixture {
 	t.Helper()
 	ctx := utils.GenerateCtxWithRid()
-	agent := Agent1(ctx, logTrace, openaiOptsAg1)
+	agent := Agent1(ctx, logTrace, models)
 	return &testFixture{
 		ctx:     ctx,
 		agent:   agent,
@@ -41,7 +41,7 @@ func TestAgentInitialization(t *testing.T) {
 		uuid := "test-uuid"
 		ctx := context.WithValue(context.Background(), _ctx_u_cu, uuid)
 		hist := CreateSqliteMem(ctx, chainName1)
-		agent := Agent1(ctx, logTrace, openaiOptsAg1, hist)
+		agent := Agent1(ctx, logTrace, models, hist)
 		assert.NotNil(t, agent)
 		assert.Equal(t, hist, agent.chatHistory())
 	})
@@ -6,6 +6,7 @@ import (
 	"embed"
 	_ "embed"
 	"fmt"
+	"slices"
 
 	"github.com/tmc/langchaingo/callbacks"
 	"github.com/tmc/langchaingo/chains"
@@ -68,7 +69,7 @@ func LifecellChainNew(l *gologgers.Logger, llm llms.Model, opts ...OptFn) *Lifec
 		panic("chain name is required")
 	}
 	if l != nil {
-		opt.callbacks = callbackhandlers.NewLoggerHandler(l)
+		opt.callbacks = chainCallbacks(l)
 	}
 	memCreate(&opt)
 	return &LifecellChain{
@@ -78,11 +79,21 @@ func LifecellChainNew(l *gologgers.Logger, llm llms.Model, opts ...OptFn) *Lifec
 		tools:         opt.tools,
 		inputKey:      opt.inputKey,
 		tresholdTools: opt.thresholdTools,
-		callOpts:      opt.optsCall,
+		callOpts:      slices.Clip(opt.optsCall),
 	}
 }
 
-func LifecellAgentSolutionSearcher(ctx context.Context, l *gologgers.Logger, llm llms.Model, opts ...OptFn) *LifecellChain {
+// chainCallbacks returns callback handler of the chain logged by l, the same handler is given to its LLM (see modelOf),
+// so retries and fallbacks of LLM are reported with the chain.
+func chainCallbacks(l *gologgers.Logger) callbacks.Handler {
+	if l == nil {
+		return callbackhandlers.NewLoggerHandler(gologgers.Defult())
+	}
+	return callbackhandlers.NewLoggerHandler(l)
+}
+
+func LifecellAgentSolutionSearcher(ctx context.Context, l *gologgers.Logger, models Models, opts ...OptFn) *LifecellChain {
+	llm, callOpts := modelOf(models, chainName2, chainCallbacks(l), openai.ResponseFormatJSON)
 	return LifecellChainNew(l, llm,
 		WithName(chainName2),
 		WithTools(tools.ToolFuncs),
@@ -91,15 +102,12 @@ func LifecellAgentSolutionSearcher(ctx context.Context, l *gologgers.Logger, llm
 		WithThreshold(6),
 		// WithMem(CreateMemoryDefault(ctx)),
 		WithMemHist(CreateSqliteMem(ctx, chainName2)),
-		WithCallOpts(llms.WithModel(GPT_4o), llms.WithTools(tools.ToolFuncs), llms.WithMaxTokens(700), llms.WithTemperature(0.08), llms.WithJSONMode()), //llms.WithJSONMode()))
+		WithCallOpts(append(callOpts, llms.WithTools(tools.ToolFuncs))...),
 	)
 }
 
-func NewAgentCallIssue(ctx context.Context, l *gologgers.Logger) *LifecellChain {
-	llm, err := openai.New(openai.WithModel(GPT_4o), openai.WithHTTPClient(HttpCl), openai.WithCallback(callbackhandlers.NewLoggerHandler(l)), openai.WithResponseFormat(responseFormatCallIssue))
-	if err != nil {
-		panic(err)
-	}
+func NewAgentCallIssue(ctx context.Context, l *gologgers.Logger, models Models) *LifecellChain {
+	llm, callOpts := modelOf(models, chainNameCI, chainCallbacks(l), responseFormatCallIssue)
 	return LifecellChainNew(l, llm,
 		WithName(chainNameCI),
 		WithTools(tools.ToolFuncs),
@@ -107,7 +115,7 @@ func NewAgentCallIssue(ctx context.Context, l *gologgers.Logger) *LifecellChain
 		WithOutputParse(NewOutputParserJSONSimple[any]()),
 		WithThreshold(6),
 		WithMemHist(CreateSqliteMem(ctx, chainNameCI)),
-		WithCallOpts(llms.WithModel(GPT_4o), llms.WithTools(tools.ToolFuncs), llms.WithMaxTokens(700), llms.WithTemperature(0.00)),
+		WithCallOpts(append(callOpts, llms.WithTools(tools.ToolFuncs))...),
 	)
 }
 
@@ -124,7 +132,7 @@ func (c *LifecellChain) Call(ctx context.Context, inputs map[string]any, _ ...ch
 	messages := MessageContentFromChat(promptValue)
 	for toolCallCount := 0; toolCallCount < c.tresholdTools; toolCallCount++ {
 		rec.Info("Call LLM GenerateContent...")
-		resp, err := c.LLM.GenerateContent(ctx, messages, c.callOpts...)
+		resp, err := c.LLM.GenerateContent(ctx, messages, append(c.callOpts, streamingOpts(ctx, FinalResponse)...)...)
 		if err != nil {
 			rec.Errorf("LLM GenerateContent error: %v", err)
 			return nil, err
@@ -163,6 +171,8 @@ func (c *LifecellChain) Call(ctx context.Context, inputs map[string]any, _ ...ch
 	rec.Infof("outputKey-name=%s value=%s", c.OutputKey, mapOfResJson[c.OutputKey])
 	mapOfResJson[c.OutputKey+"_llm"] = messages[len(messages)-1].Parts[0].(llms.TextContent).Text
 	mapOfResJson["full_history"] = messages
+	// citations are collected here, as callback handlers may replace "full_history" in the outputs of the chain
+	mapOfResJson[Citations] = tools.CitationsFromHistory(messages)
 	return mapOfResJson, nil
 }
 
@@ -2,13 +2,17 @@ package ailogic
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/tmc/langchaingo/chains"
 	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/memory"
 	"gitlab.dev.ict/golang/go-ai/logic/ailogic/tools"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
+	"gitlab.dev.ict/golang/libs/gologgers"
 	"gitlab.dev.ict/golang/libs/utils"
 )
 
@@ -95,7 +99,7 @@ func TestSolutionSearcherBasicFlow(t *testing.T) {
 
 func TestCallIssueAgent(t *testing.T) {
 	cfg := setupTest(t)
-	agent := NewAgentCallIssue(cfg.ctx, logTrace)
+	agent := NewAgentCallIssue(cfg.ctx, logTrace, models)
 	input := "У мене проблеми з дзвінками на номері 380933780687"
 
 	t.Run("agent configuration", func(t *testing.T) {
@@ -115,3 +119,45 @@ func TestCallIssueAgent(t *testing.T) {
 		assert.IsType(t, "", res[agent.OutputKey])
 	})
 }
+
+// scriptedLLM returns prepared responses one by one
+type scriptedLLM struct {
+	responses []*llms.ContentResponse
+}
+
+func (l *scriptedLLM) GenerateContent(_ context.Context, _ []llms.MessageContent, _ ...llms.CallOption) (*llms.ContentResponse, error) {
+	resp := l.responses[0]
+	l.responses = l.responses[1:]
+	return resp, nil
+}
+
+func (l *scriptedLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, l, prompt, options...)
+}
+
+func TestLifecellChain_CitationsWithLoggerCallback(t *testing.T) {
+	docs := w.KnowledgeItems{{Title: "Error 409", ChunkNo: 2, SectionPath: "SIP > Errors", URL: "https://wiki/409"}}
+	relevantDocs := tools.AvailableVoipTools[tools.ToolNameRelevantDocs]
+	tools.AvailableVoipTools[tools.ToolNameRelevantDocs] = func(*gologgers.LogRec, json.RawMessage) (string, error) { return docs.Json(), nil }
+	t.Cleanup(func() { tools.AvailableVoipTools[tools.ToolNameRelevantDocs] = relevantDocs })
+
+	llm := &scriptedLLM{responses: []*llms.ContentResponse{
+		{Choices: []*llms.ContentChoice{{StopReason: "tool_calls", ToolCalls: []llms.ToolCall{{
+			ID: "call_1", Type: "function",
+			FunctionCall: &llms.FunctionCall{Name: tools.ToolNameRelevantDocs, Arguments: `{"query":"error 409"}`},
+		}}}}},
+		{Choices: []*llms.ContentChoice{{StopReason: "stop", Content: `{"finalResponse":"409 is a conflict"}`}}},
+	}}
+	// info level of the logger callback replaces full history in the outputs by its length
+	chain := LifecellChainNew(logInfo, llm,
+		WithName(chainName2),
+		WithPrompt(newPromptFromFS(PROMPT_FILE_TC_6a__3a)),
+		WithOutputParse(NewOutputParserJSONSimple[any]()),
+		WithMemHist(memory.NewChatMessageHistory()),
+	)
+
+	res, err := chains.Call(ctx, chain, MapAny{chain.inputKey: "What does error 409 mean?"})
+	require.NoError(t, err)
+	assert.Equal(t, "409 is a conflict", res[chain.OutputKey])
+	assert.Equal(t, docs.Citations(), CitationsFromResult(res))
+}
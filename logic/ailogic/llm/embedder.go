@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms/openai"
+	"gitlab.dev.ict/golang/libs/gologgers"
+)
+
+const (
+	EmbHash = "hash" // local hashing embedder, dimensions can be set by suffix: "hash-512"
+
+	DefaultHashDims     = 256
+	DefaultEmbBatchSize = 100
+)
+
+// Embedder creates vectors for documents and queries on the client side.
+// Vectors of different models are not comparable, so every vector store class should use a single model.
+type Embedder interface {
+	embeddings.Embedder
+	Model() string
+}
+
+// NewEmbedder creates embedder by model name: EmbHash[-dims] - local HashEmbedder, otherwise OpenAI model (e.g. TXT_EMB_L).
+func NewEmbedder(model string, log *gologgers.Logger, httpClient *http.Client) (Embedder, error) {
+	if model == EmbHash || strings.HasPrefix(model, EmbHash+"-") {
+		dims := DefaultHashDims
+		if s, ok := strings.CutPrefix(model, EmbHash+"-"); ok {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid dimensions of hash embedder: %s", model)
+			}
+			dims = n
+		}
+		return NewHashEmbedder(dims), nil
+	}
+	return OpenAIEmbedder(model, log, httpClient)
+}
+
+type openAIEmbedder struct {
+	*embeddings.EmbedderImpl
+	model string
+}
+
+func (e *openAIEmbedder) Model() string { return e.model }
+
+// OpenAIEmbedder creates embedder which calls OpenAI embeddings API with the model.
+func OpenAIEmbedder(model string, log *gologgers.Logger, httpClient *http.Client) (Embedder, error) {
+	client, err := openai.New(
+		openai.WithEmbeddingModel(model),
+		openai.WithHTTPClient(httpClient),
+	)
+	if err != nil {
+		return nil, err
+	}
+	impl, err := embeddings.NewEmbedder(client, embeddings.WithBatchSize(DefaultEmbBatchSize))
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("OpenAI embedder created: model=%s", model)
+	return &openAIEmbedder{EmbedderImpl: impl, model: model}, nil
+}
+
+// HashEmbedder is a local deterministic embedder: words and word bigrams are hashed into vector of fixed dimensions
+// (feature hashing) and vector is L2-normalized. It needs no network and gives reproducible vectors for tests.
+type HashEmbedder struct {
+	dims int
+}
+
+func NewHashEmbedder(dims int) *HashEmbedder {
+	if dims <= 0 {
+		dims = DefaultHashDims
+	}
+	return &HashEmbedder{dims: dims}
+}
+
+func (e *HashEmbedder) Model() string { return fmt.Sprintf("%s-%d", EmbHash, e.dims) }
+
+func (e *HashEmbedder) EmbedDocuments(_ context.Context, texts []string) ([][]float32, error) {
+	res := make([][]float32, len(texts))
+	for i, text := range texts {
+		res[i] = e.embed(text)
+	}
+	return res, nil
+}
+
+func (e *HashEmbedder) EmbedQuery(_ context.Context, text string) ([]float32, error) {
+	return e.embed(text), nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	vec := make([]float32, e.dims)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for i, w := range words {
+		e.add(vec, w, 1)
+		if i > 0 {
+			e.add(vec, words[i-1]+" "+w, 0.5)
+		}
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vec
+	}
+	norm = math.Sqrt(norm)
+	for i := range vec {
+		vec[i] = float32(float64(vec[i]) / norm)
+	}
+	return vec
+}
+
+// add puts feature into bucket by hash, sign of the value is taken from the hash too to reduce collisions bias.
+func (e *HashEmbedder) add(vec []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	vec[sum%uint64(e.dims)] += weight
+}
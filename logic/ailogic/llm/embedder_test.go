@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashEmbedder(t *testing.T) {
+	ctx := context.Background()
+	e := NewHashEmbedder(64)
+	assert.Equal(t, "hash-64", e.Model())
+
+	docs, err := e.EmbedDocuments(ctx, []string{"VoIP error 409 registration", "Tariff plan change", ""})
+	require.NoError(t, err)
+	require.Len(t, docs, 3)
+	assert.Len(t, docs[0], 64)
+	assert.InDelta(t, 1, norm(docs[0]), 1e-5)
+	assert.Zero(t, norm(docs[2]), "empty text gives zero vector")
+
+	q, err := e.EmbedQuery(ctx, "voip ERROR 409")
+	require.NoError(t, err)
+	q2, _ := e.EmbedQuery(ctx, "voip ERROR 409")
+	assert.Equal(t, q, q2, "deterministic")
+
+	// vectors are normalized, so dot product is cosine similarity
+	assert.Greater(t, dot(q, docs[0]), dot(q, docs[1]))
+}
+
+func TestNewEmbedder(t *testing.T) {
+	e, err := NewEmbedder("hash", log, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hash-256", e.Model())
+
+	e, err = NewEmbedder("hash-512", log, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hash-512", e.Model())
+
+	_, err = NewEmbedder("hash-x", log, nil)
+	assert.Error(t, err)
+}
+
+func norm(v []float32) float32 { return dot(v, v) }
+
+func dot(a, b []float32) (res float32) {
+	for i := range a {
+		res += a[i] * b[i]
+	}
+	return
+}
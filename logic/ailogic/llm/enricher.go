@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/tmc/langchaingo/llms"
+	"gitlab.dev.ict/golang/libs/utils"
+)
+
+// DefaultEnrichDocLen limits length (in runes) of the content sent to LLM for enrichment.
+const DefaultEnrichDocLen = 6000
+
+// EnrichPromptName is the name of the system prompt template in assets/prompt_templates/prompt_sys_summarize.tmpl.
+const EnrichPromptName = "enrich_document_sys_prompt"
+
+const promptEnrichUser = `Categories: %s
+
+Title: %s
+
+Content:
+%s`
+
+// Enrichment is metadata of the document generated by LLM.
+type Enrichment struct {
+	Summary  string `json:"summary"`
+	Keywords string `json:"keywords"`
+	Language string `json:"language"`
+	Category string `json:"category"`
+}
+
+// LLMEnricher generates summary, keywords, language and suggested category of the document with LLM.
+type LLMEnricher struct {
+	llm        llms.Model
+	sysPrompt  string
+	categories []string
+	docLen     int
+	opts       []llms.CallOption
+}
+
+// NewLLMEnricher creates enricher with the system prompt, see EnrichPromptName.
+func NewLLMEnricher(llm llms.Model, sysPrompt string, opts ...llms.CallOption) *LLMEnricher {
+	return &LLMEnricher{llm: llm, sysPrompt: sysPrompt, docLen: DefaultEnrichDocLen, opts: opts}
+}
+
+// WithCategories sets categories which LLM can suggest, suggested category out of the list is dropped.
+func (e *LLMEnricher) WithCategories(categories ...string) *LLMEnricher {
+	e.categories = categories
+	return e
+}
+
+func (e *LLMEnricher) WithDocLen(n int) *LLMEnricher {
+	if n > 0 {
+		e.docLen = n
+	}
+	return e
+}
+
+func (e *LLMEnricher) Enrich(ctx context.Context, title, content string) (*Enrichment, error) {
+	msgs := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, e.sysPrompt),
+		llms.TextParts(llms.ChatMessageTypeHuman, fmt.Sprintf(promptEnrichUser, strings.Join(e.categories, ", "), title, utils.StrCut(content, e.docLen))),
+	}
+	opts := append([]llms.CallOption{llms.WithTemperature(0), llms.WithJSONMode(), llms.WithMaxTokens(500)}, e.opts...)
+	resp, err := e.llm.GenerateContent(ctx, msgs, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("enrich response has no choices")
+	}
+
+	var res Enrichment
+	if err = json.Unmarshal([]byte(resp.Choices[0].Content), &res); err != nil {
+		return nil, fmt.Errorf("parse enrich response: %w", err)
+	}
+	res.Summary, res.Keywords = strings.TrimSpace(res.Summary), strings.TrimSpace(res.Keywords)
+	res.Language = strings.ToLower(strings.TrimSpace(res.Language))
+	if !lo.Contains(e.categories, res.Category) {
+		res.Category = ""
+	}
+	return &res, nil
+}
@@ -0,0 +1,37 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms/fake"
+)
+
+func TestLLMEnricher(t *testing.T) {
+	tmpl, err := template.ParseFiles("../../../assets/prompt_templates/prompt_sys_summarize.tmpl")
+	require.NoError(t, err)
+	var sb strings.Builder
+	require.NoError(t, tmpl.ExecuteTemplate(&sb, EnrichPromptName, nil))
+	assert.Contains(t, sb.String(), `"keywords"`)
+
+	e := NewLLMEnricher(fake.NewFakeLLM([]string{
+		`{"summary": " Налаштування FMC для бізнесу. ", "keywords": "FMC, SIP", "language": "UK", "category": "FRD"}`,
+		`{"summary": "s", "keywords": "k", "language": "en", "category": "unknown"}`,
+		`oops`,
+	}), sb.String()).WithCategories("FRD", "WEB")
+
+	res, err := e.Enrich(context.Background(), "FMC", "content")
+	require.NoError(t, err)
+	assert.Equal(t, &Enrichment{Summary: "Налаштування FMC для бізнесу.", Keywords: "FMC, SIP", Language: "uk", Category: "FRD"}, res)
+
+	res, err = e.Enrich(context.Background(), "FMC", "content")
+	require.NoError(t, err)
+	assert.Empty(t, res.Category, "category out of the list is dropped")
+
+	_, err = e.Enrich(context.Background(), "FMC", "content")
+	assert.Error(t, err)
+}
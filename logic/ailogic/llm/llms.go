@@ -19,6 +19,7 @@ import (
 const SslPathOracleLinux = "/etc/ssl/certs/ca-bundle.crt"
 const SslPath = "/etc/ssl/certs/ca-certificates.crt"
 const GPT_4o = "gpt-4o"
+const GPT_4o_mini = "gpt-4o-mini"
 const GPT_4 = "gpt-4"
 const TXT_EMB_L = "text-embedding-3-large"
 const TXT_EMB_S = "text-embedding-3-small"
@@ -0,0 +1,330 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/openai"
+	"gitlab.dev.ict/golang/libs/gologgers"
+	"gopkg.in/yaml.v2"
+
+	"gitlab.dev.ict/golang/go-ai/logic/ailogic/callbackhandlers"
+)
+
+const (
+	ProviderOpenAI = "openai" // OpenAI and OpenAI-compatible servers (vLLM, Ollama, LM Studio, Groq, ...)
+	ProviderAzure  = "azure"
+
+	FormatJSONSchema = "json_schema"
+	FormatJSONObject = "json_object"
+	FormatText       = "text"
+
+	ProfileDefault = "default"
+
+	// ChainEnricher and ChainRetriever are LLM calls of ingestion enrichment and of reranking/query rewriting of retrieval
+	ChainEnricher  = "enricher"
+	ChainRetriever = "retriever"
+)
+
+// ModelPrices are list prices of known models (USD per 1M tokens), used when profile has no price.
+var ModelPrices = map[string]callbackhandlers.Price{
+	GPT_4o:      {Input: 2.5, Output: 10},
+	GPT_4o_mini: {Input: 0.15, Output: 0.6},
+	GPT_4:       {Input: 30, Output: 60},
+}
+
+// Profile describes LLM: where it is served and default parameters of calls.
+type Profile struct {
+	Provider       string                  `yaml:"provider" json:"provider"`
+	BaseURL        string                  `yaml:"base_url" json:"baseUrl,omitempty"`
+	Model          string                  `yaml:"model" json:"model"`
+	TokenEnv       string                  `yaml:"token_env" json:"tokenEnv,omitempty"`     // env var with API key, OPENAI_API_KEY by default
+	APIVersion     string                  `yaml:"api_version" json:"apiVersion,omitempty"` // required by azure
+	Temperature    *float64                `yaml:"temperature" json:"temperature,omitempty"`
+	MaxTokens      int                     `yaml:"max_tokens" json:"maxTokens,omitempty"`
+	ResponseFormat string                  `yaml:"response_format" json:"responseFormat,omitempty"` // best supported: json_schema (default), json_object, text
+	NoProxy        bool                    `yaml:"no_proxy" json:"noProxy,omitempty"`
+	Timeout        time.Duration           `yaml:"timeout" json:"timeout,omitempty"`   // timeout of one attempt, 0 - timeout of retry policy
+	Fallback       []string                `yaml:"fallback" json:"fallback,omitempty"` // profiles called in order when this profile is not available
+	Price          *callbackhandlers.Price `yaml:"price" json:"price,omitempty"`       // USD per 1M tokens, empty - price of the model from ModelPrices
+}
+
+// price returns price of the profile, 0 for unknown models (e.g. local).
+func (p *Profile) price() callbackhandlers.Price {
+	if p.Price != nil {
+		return *p.Price
+	}
+	return ModelPrices[p.Model]
+}
+
+func (p *Profile) String() string {
+	return fmt.Sprintf("Profile{provider=%s, baseURL=%s, model=%s, temperature=%v, maxTokens=%d, responseFormat=%s, noProxy=%t, timeout=%v, fallback=%v}",
+		p.Provider, p.BaseURL, p.Model, lo.FromPtr(p.Temperature), p.MaxTokens, p.ResponseFormat, p.NoProxy, p.Timeout, p.Fallback)
+}
+
+// responseFormat degrades requested format to the best format supported by the profile.
+func (p *Profile) responseFormat(rf *openai.ResponseFormat) *openai.ResponseFormat {
+	if rf == nil {
+		return nil
+	}
+	switch {
+	case rf.Type == FormatJSONSchema && p.ResponseFormat == FormatJSONObject:
+		return openai.ResponseFormatJSON
+	case rf.Type == FormatJSONSchema && p.ResponseFormat == FormatText, rf.Type == FormatJSONObject && p.ResponseFormat == FormatText:
+		return nil
+	}
+	return rf
+}
+
+func (p *Profile) validate() error {
+	switch {
+	case p.Provider != ProviderOpenAI && p.Provider != ProviderAzure:
+		return fmt.Errorf("unknown provider %q", p.Provider)
+	case p.Model == "":
+		return fmt.Errorf("model is required")
+	case p.Provider == ProviderAzure && (p.BaseURL == "" || p.APIVersion == ""):
+		return fmt.Errorf("base_url and api_version are required by azure")
+	case !slices.Contains([]string{FormatJSONSchema, FormatJSONObject, FormatText}, p.ResponseFormat):
+		return fmt.Errorf("unknown response format %q", p.ResponseFormat)
+	}
+	return nil
+}
+
+// RegistryConfig is a file of LLM profiles (see assets/llm_profiles.yml).
+type RegistryConfig struct {
+	Profiles map[string]*Profile `yaml:"profiles" json:"profiles"`
+	Chains   map[string]string   `yaml:"chains" json:"chains"` // chain name -> profile name, other chains use profile "default"
+	Retry    RetryPolicy         `yaml:"retry" json:"retry"`
+}
+
+func LoadRegistryConfig(data []byte) (*RegistryConfig, error) {
+	var cfg RegistryConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// DefaultRegistryConfig returns built-in profiles of the chains (same as assets/llm_profiles.yml),
+// it is used when there is no file of profiles. Profile "default" uses the model.
+func DefaultRegistryConfig(model string) *RegistryConfig {
+	return &RegistryConfig{
+		Profiles: map[string]*Profile{
+			ProfileDefault:     {Model: model},
+			"agent_first":      {Model: GPT_4o, Temperature: lo.ToPtr(0.3), MaxTokens: 800, Fallback: []string{"mini"}},
+			"agent_second":     {Model: GPT_4o, Temperature: lo.ToPtr(0.08), MaxTokens: 700, ResponseFormat: FormatJSONObject, Fallback: []string{"mini"}},
+			"agent_call_issue": {Model: GPT_4o, Temperature: lo.ToPtr(0.0), MaxTokens: 700, Fallback: []string{"mini"}},
+			"mini":             {Model: GPT_4o_mini},
+			"gpt4o":            {Model: GPT_4o, Fallback: []string{"mini"}},
+			"db_cim":           {Temperature: lo.ToPtr(0.15), MaxTokens: 800},
+		},
+		Chains: map[string]string{
+			"agent_first":      "agent_first",
+			"agent_second":     "agent_second",
+			"agent_call_issue": "agent_call_issue",
+			"DbCimChain":       "db_cim",
+			ChainEnricher:      "gpt4o",
+			ChainRetriever:     "gpt4o",
+		},
+	}
+}
+
+// WithChains overrides profiles of chains by "chain=profile,chain2=profile2" (e.g. from env).
+func (cfg *RegistryConfig) WithChains(s string) *RegistryConfig {
+	for _, pair := range strings.Split(s, ",") {
+		if chain, profile, ok := strings.Cut(pair, "="); ok {
+			if cfg.Chains == nil {
+				cfg.Chains = map[string]string{}
+			}
+			cfg.Chains[strings.TrimSpace(chain)] = strings.TrimSpace(profile)
+		}
+	}
+	return cfg
+}
+
+// Registry creates LLMs of named profiles, chains reference profiles by name, so model of an agent
+// can be switched to another vendor or local OpenAI-compatible server without code changes.
+type Registry struct {
+	log        *gologgers.Logger
+	httpClient func(withProxy bool) *http.Client
+	profiles   map[string]*Profile
+	chains     map[string]string
+	policy     RetryPolicy
+	breakers   map[string]*breaker // shared by all LLMs of the profile
+	usage      *callbackhandlers.UsageHandler
+}
+
+// NewRegistry validates the config, empty provider, model and response format of profiles are set to defaults
+// (model of profile "default" is used if model of a profile is not set).
+func NewRegistry(log *gologgers.Logger, httpClient func(withProxy bool) *http.Client, cfg *RegistryConfig) (*Registry, error) {
+	def, ok := cfg.Profiles[ProfileDefault]
+	if !ok || def == nil {
+		return nil, fmt.Errorf("profile %q is required", ProfileDefault)
+	}
+	for name, p := range cfg.Profiles {
+		if p == nil {
+			return nil, fmt.Errorf("profile %q is empty", name)
+		}
+		p.Model = lo.Ternary(p.Model == "", def.Model, p.Model)
+		p.Provider = lo.Ternary(p.Provider == "", ProviderOpenAI, p.Provider)
+		p.ResponseFormat = lo.Ternary(p.ResponseFormat == "", FormatJSONSchema, p.ResponseFormat)
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+	for chain, name := range cfg.Chains {
+		if _, ok := cfg.Profiles[name]; !ok {
+			return nil, fmt.Errorf("chain %q references unknown profile %q", chain, name)
+		}
+	}
+	policy := cfg.Retry.withDefaults()
+	breakers := map[string]*breaker{}
+	for name, p := range cfg.Profiles {
+		for _, fb := range p.Fallback {
+			if _, ok := cfg.Profiles[fb]; !ok || fb == name {
+				return nil, fmt.Errorf("profile %q: invalid fallback profile %q", name, fb)
+			}
+		}
+		breakers[name] = newBreaker(policy)
+	}
+	return &Registry{log: log, httpClient: httpClient, profiles: cfg.Profiles, chains: lo.Assign(cfg.Chains), policy: policy, breakers: breakers}, nil
+}
+
+func (r *Registry) String() string {
+	var sb strings.Builder
+	names := lo.Keys(r.profiles)
+	sort.Strings(names)
+	for _, name := range names {
+		chains := lo.Filter(lo.Keys(r.chains), func(c string, _ int) bool { return r.chains[c] == name })
+		sort.Strings(chains)
+		fmt.Fprintf(&sb, "%s: %s chains=%v\n", name, r.profiles[name], chains)
+	}
+	return sb.String()
+}
+
+// Profile returns the profile by name.
+func (r *Registry) Profile(name string) (*Profile, bool) {
+	p, ok := r.profiles[name]
+	return p, ok
+}
+
+// ProfileOf returns name of the profile of the chain.
+func (r *Registry) ProfileOf(chain string) string {
+	return lo.ValueOr(r.chains, chain, ProfileDefault)
+}
+
+// WithUsage sets handler which records token usage and cost of calls of all LLMs created after it.
+func (r *Registry) WithUsage(h *callbackhandlers.UsageHandler) *Registry {
+	r.usage = h
+	return r
+}
+
+// Model creates LLM of the profile. Response format is client level option of OpenAI API, it is degraded
+// to the format supported by the profile (json_schema -> json_object -> none).
+func (r *Registry) Model(name string, rf ...*openai.ResponseFormat) (llms.Model, error) {
+	return r.model(name, nil, rf...)
+}
+
+// model creates LLM of the profile which reports calls to the handler (logger of the registry if nil) and to usage handler.
+func (r *Registry) model(name string, handler callbacks.Handler, rf ...*openai.ResponseFormat) (llms.Model, error) {
+	p, ok := r.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown LLM profile %q", name)
+	}
+	if handler == nil {
+		handler = callbackhandlers.NewLoggerHandler(r.log)
+	}
+	if r.usage != nil {
+		handler = callbacks.CombiningHandler{Callbacks: []callbacks.Handler{handler, r.usage.ForModel(name, p.Model, p.price())}}
+	}
+	opts := []openai.Option{
+		openai.WithModel(p.Model),
+		openai.WithHTTPClient(r.httpClient(!p.NoProxy)),
+		openai.WithCallback(handler),
+	}
+	token := os.Getenv(lo.Ternary(p.TokenEnv == "", "OPENAI_API_KEY", p.TokenEnv))
+	switch {
+	case token != "":
+		opts = append(opts, openai.WithToken(token))
+	case p.BaseURL != "":
+		opts = append(opts, openai.WithToken("none")) // local servers usually do not check the key
+	}
+	if p.BaseURL != "" {
+		opts = append(opts, openai.WithBaseURL(p.BaseURL))
+	}
+	if p.Provider == ProviderAzure {
+		opts = append(opts, openai.WithAPIType(openai.APITypeAzure), openai.WithAPIVersion(p.APIVersion))
+	}
+	if len(rf) > 0 {
+		if f := p.responseFormat(rf[0]); f != nil {
+			opts = append(opts, openai.WithResponseFormat(f))
+		}
+	}
+	llm, err := openai.New(opts...)
+	if err != nil || r.usage == nil {
+		return llm, err
+	}
+	return timedModel{llm}, nil
+}
+
+// timedModel puts start time of every call to ctx, so usage handler measures latency of the call.
+type timedModel struct {
+	llms.Model
+}
+
+func (m timedModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func (m timedModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	return m.Model.GenerateContent(callbackhandlers.WithCallStart(ctx, time.Now()), messages, options...)
+}
+
+// CallOptions returns model, temperature and max tokens of the profile.
+func (r *Registry) CallOptions(name string) []llms.CallOption {
+	p, ok := r.profiles[name]
+	if !ok {
+		return nil
+	}
+	opts := []llms.CallOption{llms.WithModel(p.Model)}
+	if p.Temperature != nil {
+		opts = append(opts, llms.WithTemperature(*p.Temperature))
+	}
+	if p.MaxTokens > 0 {
+		opts = append(opts, llms.WithMaxTokens(p.MaxTokens))
+	}
+	return opts
+}
+
+// ForChain returns resilient LLM (see ResilientModel) and call options of the profile of the chain.
+// LLM retries the profile and falls back to profiles of its Fallback. Every call and attempt is reported to
+// the callback handler of the chain, nil handler is replaced with logger of the registry.
+func (r *Registry) ForChain(chain string, handler callbacks.Handler, rf ...*openai.ResponseFormat) (llms.Model, []llms.CallOption, error) {
+	if handler == nil {
+		handler = callbackhandlers.NewLoggerHandler(r.log)
+	}
+	name := r.ProfileOf(chain)
+	m := &ResilientModel{policy: r.policy, handler: handler}
+	for i, pn := range append([]string{name}, r.profiles[name].Fallback...) {
+		llm, err := r.model(pn, handler, rf...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("LLM of chain %q: %w", chain, err)
+		}
+		p := r.profiles[pn]
+		t := target{profile: pn, model: p.Model, llm: llm, timeout: lo.Ternary(p.Timeout > 0, p.Timeout, r.policy.Timeout), breaker: r.breakers[pn]}
+		if i > 0 {
+			t.opts = r.CallOptions(pn)
+		}
+		m.targets = append(m.targets, t)
+	}
+	return m, r.CallOptions(name), nil
+}
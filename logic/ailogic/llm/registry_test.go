@@ -0,0 +1,185 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/openai"
+
+	"gitlab.dev.ict/golang/go-ai/logic/ailogic/callbackhandlers"
+)
+
+const testProfiles = `
+profiles:
+  default:
+    model: ""
+  local:
+    base_url: %s
+    model: qwen2.5
+    token_env: LOCAL_LLM_KEY
+    temperature: 0.2
+    max_tokens: 300
+    response_format: json_object
+    no_proxy: true
+  plain:
+    base_url: %s
+    model: llama3
+    response_format: text
+chains:
+  agent_first: local
+  agent_second: plain
+`
+
+func TestRegistry_ForChain(t *testing.T) {
+	var req map[string]any
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		req = map[string]any{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"{\"response\":\"ok\"}"},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+	t.Setenv("LOCAL_LLM_KEY", "secret")
+
+	cfg, err := LoadRegistryConfig([]byte(strings.ReplaceAll(testProfiles, "%s", srv.URL)))
+	require.NoError(t, err)
+	cfg.Profiles[ProfileDefault].Model = GPT_4
+	var proxies []bool
+	r, err := NewRegistry(log, func(withProxy bool) *http.Client { proxies = append(proxies, withProxy); return srv.Client() }, cfg.WithChains("agent_call_issue=plain"))
+	require.NoError(t, err)
+	assert.Equal(t, "local", r.ProfileOf("agent_first"))
+	assert.Equal(t, "plain", r.ProfileOf("agent_call_issue"))
+	assert.Equal(t, ProfileDefault, r.ProfileOf("DbCimChain"))
+
+	schema := &openai.ResponseFormat{Type: FormatJSONSchema, JSONSchema: &openai.ResponseFormatJSONSchema{Name: "answer", Strict: true,
+		Schema: &openai.ResponseFormatJSONSchemaProperty{Type: "object"}}}
+	call := func(chain string) {
+		t.Helper()
+		m, opts, err := r.ForChain(chain, nil, schema)
+		require.NoError(t, err)
+		_, err = m.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hi")}, opts...)
+		require.NoError(t, err)
+	}
+
+	call("agent_first")
+	assert.Equal(t, "qwen2.5", req["model"])
+	assert.InDelta(t, 0.2, req["temperature"], 1e-9)
+	assert.EqualValues(t, 300, req["max_completion_tokens"])
+	assert.Equal(t, map[string]any{"type": FormatJSONObject}, req["response_format"], "json_schema is degraded to json_object")
+	assert.Equal(t, "Bearer secret", auth)
+	assert.Equal(t, []bool{false}, proxies)
+
+	call("agent_second")
+	assert.Equal(t, "llama3", req["model"])
+	assert.NotContains(t, req, "response_format", "profile supports only text")
+	assert.NotContains(t, req, "max_completion_tokens")
+	assert.Equal(t, []bool{false, true}, proxies)
+
+	p, ok := r.Profile(ProfileDefault)
+	require.True(t, ok)
+	assert.Equal(t, GPT_4, p.Model)
+	assert.Equal(t, ProviderOpenAI, p.Provider)
+	assert.Equal(t, FormatJSONSchema, p.ResponseFormat)
+	assert.Equal(t, schema, p.responseFormat(schema))
+}
+
+func TestNewRegistry_Errors(t *testing.T) {
+	httpClient := func(bool) *http.Client { return http.DefaultClient }
+	for name, cfg := range map[string]*RegistryConfig{
+		"no default":     {Profiles: map[string]*Profile{"local": {Model: "llama3"}}},
+		"no model":       {Profiles: map[string]*Profile{ProfileDefault: {}}},
+		"unknown chain":  DefaultRegistryConfig(GPT_4).WithChains("agent_first=missing"),
+		"unknown format": {Profiles: map[string]*Profile{ProfileDefault: {Model: GPT_4, ResponseFormat: "xml"}}},
+		"azure version":  {Profiles: map[string]*Profile{ProfileDefault: {Model: GPT_4, Provider: ProviderAzure, BaseURL: "https://x.openai.azure.com"}}},
+		"unknown vendor": {Profiles: map[string]*Profile{ProfileDefault: {Model: GPT_4, Provider: "vertex"}}},
+	} {
+		_, err := NewRegistry(log, httpClient, cfg)
+		assert.Error(t, err, name)
+	}
+
+	r, err := NewRegistry(log, httpClient, DefaultRegistryConfig(GPT_4))
+	require.NoError(t, err)
+	_, err = r.Model("missing")
+	assert.Error(t, err)
+}
+
+func TestLLMProfilesFile(t *testing.T) {
+	data, err := os.ReadFile("../../../assets/llm_profiles.yml")
+	require.NoError(t, err)
+	cfg, err := LoadRegistryConfig(data)
+	require.NoError(t, err)
+	cfg.Profiles[ProfileDefault].Model = GPT_4
+	r, err := NewRegistry(log, func(bool) *http.Client { return http.DefaultClient }, cfg)
+	require.NoError(t, err)
+
+	def, err := NewRegistry(log, func(bool) *http.Client { return http.DefaultClient }, DefaultRegistryConfig(GPT_4))
+	require.NoError(t, err)
+	assert.Equal(t, def.policy, r.policy)
+	for _, chain := range []string{"agent_first", "agent_second", "agent_call_issue", "DbCimChain", ChainEnricher, ChainRetriever} {
+		p, _ := r.Profile(r.ProfileOf(chain))
+		for _, fb := range p.Fallback {
+			fp, _ := r.Profile(fb)
+			dp, _ := def.Profile(fb)
+			assert.Equal(t, dp, fp, "fallback %s", fb)
+		}
+		d, _ := def.Profile(def.ProfileOf(chain))
+		assert.Equal(t, d, p, "file and built-in profiles of %s are the same", chain)
+	}
+}
+
+func TestRegistry_WithUsage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":1200,"completion_tokens":300,"total_tokens":1500}}`))
+	}))
+	defer srv.Close()
+
+	var records []callbackhandlers.Usage
+	recorder := callbackhandlers.UsageRecorderFunc(func(_ context.Context, u callbackhandlers.Usage) error {
+		records = append(records, u)
+		return nil
+	})
+	tags := func(context.Context) (string, string, string) { return "alice", "VoipAgents", "chat-1" }
+	r, err := NewRegistry(log, func(bool) *http.Client { return srv.Client() }, &RegistryConfig{
+		Profiles: map[string]*Profile{
+			ProfileDefault: {BaseURL: srv.URL, Model: GPT_4o},
+			"local":        {BaseURL: srv.URL, Model: "qwen2.5", Price: &callbackhandlers.Price{Input: 1, Output: 2}},
+		},
+		Chains: map[string]string{"agent_second": "local"},
+	})
+	require.NoError(t, err)
+	r.WithUsage(callbackhandlers.NewUsageHandler(log, recorder, tags))
+
+	for _, chain := range []string{"agent_first", "agent_second"} {
+		m, opts, err := r.ForChain(chain, nil)
+		require.NoError(t, err)
+		_, err = m.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hi")}, opts...)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, records, 2)
+	for i, want := range []struct {
+		profile, model string
+		cost           float64
+	}{{ProfileDefault, GPT_4o, (1200*2.5 + 300*10) / 1e6}, {"local", "qwen2.5", (1200*1 + 300*2) / 1e6}} {
+		u := records[i]
+		assert.Equal(t, want.profile, u.Profile)
+		assert.Equal(t, want.model, u.Model)
+		assert.Equal(t, 1200, u.PromptTokens)
+		assert.Equal(t, 300, u.CompletionTokens)
+		assert.InDelta(t, want.cost, u.Cost, 1e-12)
+		assert.Positive(t, u.Latency)
+		assert.Equal(t, []string{"alice", "VoipAgents", "chat-1"}, []string{u.Login, u.Chain, u.ChatUUID})
+	}
+}
@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+	"gitlab.dev.ict/golang/libs/utils"
+)
+
+// DefaultRerankDocLen limits length (in runes) of every document sent to LLM for scoring.
+const DefaultRerankDocLen = 1500
+
+const promptRerank = `You are a relevance grader of a retrieval system for Lifecell support engineers.
+Rate how useful every document is for answering the query: 0 - irrelevant, 10 - fully answers the query.
+Query and documents may be in Ukrainian or English.
+
+Query: %s
+
+Documents:
+%s
+Return JSON object {"scores": [...]} with exactly %d numbers, one score per document in the same order.`
+
+// LLMReranker scores documents with LLM (listwise: all documents in one call).
+type LLMReranker struct {
+	llm    llms.Model
+	docLen int
+	opts   []llms.CallOption
+}
+
+func NewLLMReranker(llm llms.Model, opts ...llms.CallOption) *LLMReranker {
+	return &LLMReranker{llm: llm, docLen: DefaultRerankDocLen, opts: opts}
+}
+
+func (r *LLMReranker) WithDocLen(n int) *LLMReranker {
+	if n > 0 {
+		r.docLen = n
+	}
+	return r
+}
+
+// Rerank returns scores in range [0..1] aligned with docs.
+func (r *LLMReranker) Rerank(ctx context.Context, query string, docs []string) ([]float64, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	var sb strings.Builder
+	for i, doc := range docs {
+		fmt.Fprintf(&sb, "[%d]\n%s\n\n", i, utils.StrCut(doc, r.docLen))
+	}
+
+	opts := append([]llms.CallOption{llms.WithTemperature(0), llms.WithJSONMode()}, r.opts...)
+	resp, err := llms.GenerateFromSinglePrompt(ctx, r.llm, fmt.Sprintf(promptRerank, query, sb.String(), len(docs)), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Scores []float64 `json:"scores"`
+	}
+	if err = json.Unmarshal([]byte(resp), &res); err != nil {
+		return nil, fmt.Errorf("parse rerank response: %w", err)
+	}
+	if len(res.Scores) != len(docs) {
+		return nil, fmt.Errorf("rerank response has %d scores for %d documents", len(res.Scores), len(docs))
+	}
+	for i, s := range res.Scores {
+		res.Scores[i] = min(max(s, 0), 10) / 10
+	}
+	return res.Scores, nil
+}
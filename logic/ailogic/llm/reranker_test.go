@@ -0,0 +1,28 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms/fake"
+)
+
+func TestLLMReranker(t *testing.T) {
+	ctx := context.Background()
+	docs := []string{"doc A", "doc B", "doc C"}
+
+	r := NewLLMReranker(fake.NewFakeLLM([]string{`{"scores": [2, 10, 15]}`}))
+	scores, err := r.Rerank(ctx, "query", docs)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.2, 1, 1}, scores)
+
+	r = NewLLMReranker(fake.NewFakeLLM([]string{`{"scores": [2]}`}))
+	_, err = r.Rerank(ctx, "query", docs)
+	assert.Error(t, err, "count of scores mismatch")
+
+	r = NewLLMReranker(fake.NewFakeLLM([]string{`not json`}))
+	_, err = r.Rerank(ctx, "query", docs)
+	assert.Error(t, err)
+}
@@ -0,0 +1,233 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/llms"
+
+	"gitlab.dev.ict/golang/go-ai/logic/ailogic/callbackhandlers"
+)
+
+// ErrCircuitOpen is returned for the profile which is skipped as it failed too many times in a row.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+var reStatusCode = regexp.MustCompile(`status code: (\d{3})`)
+
+// RetryPolicy of LLM calls of chains, zero values are set to defaults (see withDefaults).
+type RetryPolicy struct {
+	Attempts        int           `yaml:"attempts" json:"attempts"`                // attempts per profile (1 - no retries)
+	Backoff         time.Duration `yaml:"backoff" json:"backoff"`                  // base delay between attempts, doubled every attempt, jittered
+	MaxBackoff      time.Duration `yaml:"max_backoff" json:"maxBackoff"`           // max delay between attempts
+	Timeout         time.Duration `yaml:"timeout" json:"timeout"`                  // timeout of one attempt (profile can override it)
+	BreakerFailures int           `yaml:"breaker_failures" json:"breakerFailures"` // failures in a row which open circuit breaker of the profile
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown" json:"breakerCooldown"` // profile is skipped while breaker is open, then one probe call is allowed
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	p.Attempts = max(p.Attempts, 0)
+	if p.Attempts == 0 {
+		p.Attempts = 3
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = 500 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 8 * time.Second
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = 60 * time.Second
+	}
+	if p.BreakerFailures <= 0 {
+		p.BreakerFailures = 5
+	}
+	if p.BreakerCooldown <= 0 {
+		p.BreakerCooldown = 30 * time.Second
+	}
+	return p
+}
+
+// backoff returns delay before the next attempt: exponential with "equal jitter" (half of the delay is random),
+// so chains hit by the same rate limit do not retry at the same moment.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := min(p.MaxBackoff, p.Backoff<<min(attempt-1, 16))
+	return d/2 + rand.N(d/2+1)
+}
+
+// IsRetryable reports if LLM call failed because provider is not available: rate limit (429), server error (5xx),
+// network error (incl. broken stream) or timeout of the attempt.
+func IsRetryable(err error) bool {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &netErr):
+		return true
+	}
+	if m := reStatusCode.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return code == 429 || code >= 500
+	}
+	return false
+}
+
+// breaker is circuit breaker of LLM profile: after BreakerFailures failures in a row the profile is skipped
+// for BreakerCooldown, then one probe call is allowed (half-open), its result closes or opens the breaker again.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newBreaker(p RetryPolicy) *breaker {
+	return &breaker{threshold: p.BreakerFailures, cooldown: p.BreakerCooldown}
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.failures < b.threshold:
+		return true
+	case time.Now().Before(b.openUntil), b.probing:
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures, b.probing = 0, false
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// release ends the probe call which was interrupted by the caller.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// target is LLM of one profile of the fallback chain.
+type target struct {
+	profile string
+	model   string
+	llm     llms.Model
+	opts    []llms.CallOption // call options of fallback profile, they override options of the chain
+	timeout time.Duration
+	breaker *breaker
+}
+
+// ResilientModel calls LLM of the profile with retries on rate limits and server errors, and falls back
+// to the next profiles (see Profile.Fallback) when attempts are exhausted or circuit breaker of the profile is open.
+// Every attempt is reported to the callback handler of the chain (see callbackhandlers.HandleAttempt).
+// Errors which are not retryable (e.g. 400) are returned at once.
+// If the answer was partially streamed to the user, the call is not repeated, so the user does not get the same deltas twice.
+type ResilientModel struct {
+	targets []target
+	policy  RetryPolicy
+	handler callbacks.Handler
+}
+
+var _ llms.Model = &ResilientModel{}
+
+func (m *ResilientModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func (m *ResilientModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	streamed := false
+	var o llms.CallOptions
+	for _, opt := range options {
+		opt(&o)
+	}
+	if o.StreamingFunc != nil {
+		options = append(slices.Clip(options), llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			streamed = true
+			return o.StreamingFunc(ctx, chunk)
+		}))
+	}
+
+	var errs []error
+	for i, t := range m.targets {
+		opts := options
+		if i > 0 {
+			opts = append(slices.Clip(options), t.opts...)
+		}
+		var lastErr error
+		for attempt := 1; attempt <= m.policy.Attempts; attempt++ {
+			if !t.breaker.allow() {
+				if attempt == 1 {
+					callbackhandlers.HandleAttempt(ctx, m.handler, callbackhandlers.LLMAttempt{Profile: t.profile, Model: t.model, Skipped: true})
+				}
+				errs = append(errs, fmt.Errorf("%s: %w", t.profile, errors.Join(lastErr, ErrCircuitOpen)))
+				break
+			}
+
+			actx, cancel := context.WithTimeout(ctx, t.timeout)
+			start := time.Now()
+			resp, err := t.llm.GenerateContent(actx, messages, opts...)
+			cancel()
+			a := callbackhandlers.LLMAttempt{Profile: t.profile, Model: t.model, Attempt: attempt, Duration: time.Since(start), Err: err}
+			switch {
+			case err == nil:
+				t.breaker.success()
+				callbackhandlers.HandleAttempt(ctx, m.handler, a)
+				return resp, nil
+			case ctx.Err() != nil:
+				t.breaker.release()
+				callbackhandlers.HandleAttempt(ctx, m.handler, a)
+				return nil, err
+			case !IsRetryable(err):
+				t.breaker.success() // provider answered, the request is wrong
+				callbackhandlers.HandleAttempt(ctx, m.handler, a)
+				return nil, err
+			}
+
+			t.breaker.failure()
+			lastErr = err
+			if streamed {
+				callbackhandlers.HandleAttempt(ctx, m.handler, a)
+				return nil, fmt.Errorf("answer was partially streamed: %w", err)
+			}
+			if attempt < m.policy.Attempts {
+				a.Backoff = m.policy.backoff(attempt)
+			}
+			callbackhandlers.HandleAttempt(ctx, m.handler, a)
+			if a.Backoff == 0 {
+				errs = append(errs, fmt.Errorf("%s: %w", t.profile, err))
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(a.Backoff):
+			}
+		}
+	}
+	return nil, fmt.Errorf("all LLM profiles failed: %w", errors.Join(errs...))
+}
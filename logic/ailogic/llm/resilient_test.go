@@ -0,0 +1,218 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/llms"
+
+	"gitlab.dev.ict/golang/go-ai/logic/ailogic/callbackhandlers"
+)
+
+// fakeProvider is OpenAI-compatible server which answers with the queued status codes per model, then with 200.
+type fakeProvider struct {
+	mu     sync.Mutex
+	codes  map[string][]int
+	calls  []string
+	stream bool
+}
+
+func (f *fakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	f.mu.Lock()
+	f.calls = append(f.calls, req.Model)
+	code := http.StatusOK
+	if q := f.codes[req.Model]; len(q) > 0 {
+		code, f.codes[req.Model] = q[0], q[1:]
+	}
+	f.mu.Unlock()
+
+	switch {
+	case code != http.StatusOK:
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"error":{"message":"status %d"}}`, code)
+	case req.Stream && f.stream:
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"content":"Hel"}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler) // connection is broken in the middle of the answer
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"answer of %s"},"finish_reason":"stop"}]}`, req.Model)
+	}
+}
+
+func newResilientTest(t *testing.T, f *fakeProvider, retry RetryPolicy) *Registry {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	cfg := &RegistryConfig{
+		Profiles: map[string]*Profile{
+			ProfileDefault: {BaseURL: srv.URL, Model: "gpt-4o", Fallback: []string{"mini", "local"}},
+			"mini":         {BaseURL: srv.URL, Model: "gpt-4o-mini"},
+			"local":        {BaseURL: srv.URL, Model: "qwen2.5", Timeout: time.Second},
+		},
+		Retry: retry,
+	}
+	r, err := NewRegistry(log, func(bool) *http.Client { return srv.Client() }, cfg)
+	require.NoError(t, err)
+	return r
+}
+
+// attemptRecorder is callback handler of the chain which records attempts and started calls of LLM.
+type attemptRecorder struct {
+	callbacks.SimpleHandler
+	mu       sync.Mutex
+	attempts []callbackhandlers.LLMAttempt
+	started  int
+}
+
+func (h *attemptRecorder) HandleLLMAttempt(_ context.Context, a callbackhandlers.LLMAttempt) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.attempts = append(h.attempts, a)
+}
+
+func (h *attemptRecorder) HandleLLMGenerateContentStart(context.Context, []llms.MessageContent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.started++
+}
+
+func generate(r *Registry, opts ...llms.CallOption) (string, error) {
+	return generateWith(r, nil, opts...)
+}
+
+func generateWith(r *Registry, h callbacks.Handler, opts ...llms.CallOption) (string, error) {
+	m, callOpts, err := r.ForChain("agent_first", h)
+	if err != nil {
+		return "", err
+	}
+	resp, err := m.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hi")}, append(callOpts, opts...)...)
+	if err != nil {
+		return "", err
+	}
+	return resp.Choices[0].Content, nil
+}
+
+func TestResilientModel(t *testing.T) {
+	fast := RetryPolicy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, BreakerFailures: 3, BreakerCooldown: time.Hour}
+
+	t.Run("retry", func(t *testing.T) {
+		f := &fakeProvider{codes: map[string][]int{"gpt-4o": {429, 503}}}
+		answer, err := generate(newResilientTest(t, f, fast))
+		require.NoError(t, err)
+		assert.Equal(t, "answer of gpt-4o", answer)
+		assert.Equal(t, []string{"gpt-4o", "gpt-4o", "gpt-4o"}, f.calls)
+	})
+
+	t.Run("fallback and circuit breaker", func(t *testing.T) {
+		f := &fakeProvider{codes: map[string][]int{"gpt-4o": {429, 429, 429}, "gpt-4o-mini": {500, 502, 504}}}
+		r := newResilientTest(t, f, fast)
+		answer, err := generate(r)
+		require.NoError(t, err)
+		assert.Equal(t, "answer of qwen2.5", answer)
+		assert.Equal(t, []string{"gpt-4o", "gpt-4o", "gpt-4o", "gpt-4o-mini", "gpt-4o-mini", "gpt-4o-mini", "qwen2.5"}, f.calls)
+
+		f.calls = nil
+		answer, err = generate(r)
+		require.NoError(t, err)
+		assert.Equal(t, "answer of qwen2.5", answer)
+		assert.Equal(t, []string{"qwen2.5"}, f.calls, "profiles with open breaker are skipped")
+	})
+
+	t.Run("attempts are reported to handler of the chain", func(t *testing.T) {
+		f := &fakeProvider{codes: map[string][]int{"gpt-4o": {429, 429, 429}, "gpt-4o-mini": {503}}}
+		h := &attemptRecorder{}
+		answer, err := generateWith(newResilientTest(t, f, fast), callbacks.CombiningHandler{Callbacks: []callbacks.Handler{callbackhandlers.NewLoggerHandler(log), h}})
+		require.NoError(t, err)
+		assert.Equal(t, "answer of gpt-4o-mini", answer)
+		assert.Equal(t, len(f.calls), h.started, "every call of LLM is reported to the chain")
+		got := lo.Map(h.attempts, func(a callbackhandlers.LLMAttempt, _ int) string {
+			return fmt.Sprintf("%s/%d/%t", a.Profile, a.Attempt, a.Err == nil)
+		})
+		assert.Equal(t, []string{"default/1/false", "default/2/false", "default/3/false", "mini/1/false", "mini/2/true"}, got)
+	})
+
+	t.Run("breaker probe", func(t *testing.T) {
+		f := &fakeProvider{codes: map[string][]int{"gpt-4o": {500, 500, 500}}}
+		r := newResilientTest(t, f, RetryPolicy{Attempts: 3, Backoff: time.Millisecond, BreakerFailures: 3, BreakerCooldown: 20 * time.Millisecond})
+		_, err := generate(r)
+		require.NoError(t, err)
+		time.Sleep(30 * time.Millisecond)
+		f.calls = nil
+		answer, err := generate(r)
+		require.NoError(t, err)
+		assert.Equal(t, "answer of gpt-4o", answer, "breaker is closed after successful probe")
+		assert.Equal(t, []string{"gpt-4o"}, f.calls)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		f := &fakeProvider{codes: map[string][]int{"gpt-4o": {400}}}
+		_, err := generate(newResilientTest(t, f, fast))
+		assert.ErrorContains(t, err, "400")
+		assert.False(t, IsRetryable(err))
+		assert.Equal(t, []string{"gpt-4o"}, f.calls)
+	})
+
+	t.Run("all failed", func(t *testing.T) {
+		f := &fakeProvider{codes: map[string][]int{"gpt-4o": {429}, "gpt-4o-mini": {429}, "qwen2.5": {503}}}
+		_, err := generate(newResilientTest(t, f, RetryPolicy{Attempts: 1}))
+		assert.ErrorContains(t, err, "all LLM profiles failed")
+		assert.ErrorContains(t, err, "local: API returned unexpected status code: 503")
+		assert.Len(t, f.calls, 3)
+	})
+
+	t.Run("partial stream is not repeated", func(t *testing.T) {
+		f := &fakeProvider{stream: true}
+		var chunks []string
+		_, err := generate(newResilientTest(t, f, fast), llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+			chunks = append(chunks, string(chunk))
+			return nil
+		}))
+		assert.ErrorContains(t, err, "partially streamed")
+		assert.Equal(t, []string{"Hel"}, chunks)
+		assert.Equal(t, []string{"gpt-4o"}, f.calls)
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	for err, want := range map[error]bool{
+		errors.New("API returned unexpected status code: 429: Rate limit"): true,
+		errors.New("API returned unexpected status code: 502"):             true,
+		errors.New("API returned unexpected status code: 401: bad key"):    false,
+		fmt.Errorf("call: %w", context.DeadlineExceeded):                   true,
+		context.Canceled: false,
+		fmt.Errorf("stream: %w", io.ErrUnexpectedEOF): true,
+	} {
+		assert.Equal(t, want, IsRetryable(err), err.Error())
+	}
+	assert.False(t, IsRetryable(nil))
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}.withDefaults()
+	for attempt, d := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 5: time.Second, 60: time.Second} {
+		for range 20 {
+			b := p.backoff(attempt)
+			assert.GreaterOrEqual(t, b, d/2)
+			assert.LessOrEqual(t, b, d)
+		}
+	}
+}
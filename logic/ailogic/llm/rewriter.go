@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/samber/lo"
+	"github.com/tmc/langchaingo/llms"
+)
+
+const promptRewrite = `You prepare search queries for a knowledge base of Lifecell (mobile operator) support engineers.
+Documents are in Ukrainian, English or mixed: product and tariff descriptions, VoIP/FMC settings, troubleshooting guides.
+For the user query below return JSON object with fields:
+- "uk": the query in Ukrainian
+- "en": the query in English
+- "keywords": 3-7 most important keywords and terms (product names, error codes, settings names), space separated
+- "hypothetical_answer": short (2-4 sentences) hypothetical passage of documentation which answers the query, in the language of the query
+
+User query: %s`
+
+// QueryVariants is a result of query rewriting.
+type QueryVariants struct {
+	UK                 string `json:"uk"`
+	EN                 string `json:"en"`
+	Keywords           string `json:"keywords"`
+	HypotheticalAnswer string `json:"hypothetical_answer"`
+}
+
+// List returns non-empty unique variants.
+func (v *QueryVariants) List() []string {
+	return lo.Uniq(lo.Compact([]string{v.UK, v.EN, v.Keywords, v.HypotheticalAnswer}))
+}
+
+// LLMQueryRewriter generates variants of the search query with LLM: UA/EN translations, keywords and HyDE answer.
+type LLMQueryRewriter struct {
+	llm  llms.Model
+	opts []llms.CallOption
+}
+
+func NewLLMQueryRewriter(llm llms.Model, opts ...llms.CallOption) *LLMQueryRewriter {
+	return &LLMQueryRewriter{llm: llm, opts: opts}
+}
+
+func (r *LLMQueryRewriter) Variants(ctx context.Context, query string) (*QueryVariants, error) {
+	opts := append([]llms.CallOption{llms.WithTemperature(0), llms.WithJSONMode(), llms.WithMaxTokens(400)}, r.opts...)
+	resp, err := llms.GenerateFromSinglePrompt(ctx, r.llm, fmt.Sprintf(promptRewrite, query), opts...)
+	if err != nil {
+		return nil, err
+	}
+	var v QueryVariants
+	if err = json.Unmarshal([]byte(resp), &v); err != nil {
+		return nil, fmt.Errorf("parse rewrite response: %w", err)
+	}
+	return &v, nil
+}
+
+// Rewrite returns variants of the query, see QueryVariants.
+func (r *LLMQueryRewriter) Rewrite(ctx context.Context, query string) ([]string, error) {
+	v, err := r.Variants(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Without(v.List(), query), nil
+}
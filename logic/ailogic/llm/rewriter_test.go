@@ -0,0 +1,21 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms/fake"
+)
+
+func TestLLMQueryRewriter(t *testing.T) {
+	r := NewLLMQueryRewriter(fake.NewFakeLLM([]string{`{"uk": "налаштування FMC", "en": "FMC settings", "keywords": "FMC settings", "hypothetical_answer": "To configure FMC use SIP server..."}`}))
+	variants, err := r.Rewrite(context.Background(), "налаштування FMC")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FMC settings", "To configure FMC use SIP server..."}, variants, "original query and duplicates are excluded")
+
+	r = NewLLMQueryRewriter(fake.NewFakeLLM([]string{`oops`}))
+	_, err = r.Rewrite(context.Background(), "q")
+	assert.Error(t, err)
+}
@@ -0,0 +1,221 @@
+package ailogic
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	_ctx_stream CtxKey = "stream"
+	_ctx_sink   CtxKey = "stream_sink"
+)
+
+// AddToCtxStream sets the function which receives deltas of the answer to the user while LLM generates it.
+// Chains which support streaming (see ChainVoip.Run) forward the user-facing field of JSON output of agents to it.
+func AddToCtxStream(ctx context.Context, send func(string)) context.Context {
+	if send == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, _ctx_stream, send)
+}
+
+// withDeltaSink turns on streaming for LLM calls with ctx if there is a function set by AddToCtxStream.
+func withDeltaSink(ctx context.Context) (context.Context, *deltaSink) {
+	send, ok := ctx.Value(_ctx_stream).(func(string))
+	if !ok {
+		return ctx, nil
+	}
+	sink := &deltaSink{send: send}
+	return context.WithValue(ctx, _ctx_sink, sink), sink
+}
+
+func sinkFromCtx(ctx context.Context) *deltaSink {
+	s, _ := ctx.Value(_ctx_sink).(*deltaSink)
+	return s
+}
+
+// deltaSink forwards deltas to the user and remembers what was sent.
+type deltaSink struct {
+	mu   sync.Mutex
+	send func(string)
+	sent strings.Builder
+}
+
+func (s *deltaSink) write(delta string) {
+	if delta == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent.WriteString(delta)
+	s.send(delta)
+}
+
+func (s *deltaSink) streamed() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sent.String()
+}
+
+// finish sends the whole answer if nothing was streamed (answer is taken from another field or output is not JSON).
+func (s *deltaSink) finish(answer string) {
+	if s != nil && s.streamed() == "" {
+		s.write(answer)
+	}
+}
+
+// streamingOpts returns call options which stream values of the fields of LLM JSON output to the sink from ctx.
+// It is nil if there is no sink. Options must be created for every LLM call, as the parser keeps state of one output.
+func streamingOpts(ctx context.Context, fields ...string) []llms.CallOption {
+	sink := sinkFromCtx(ctx)
+	if sink == nil {
+		return nil
+	}
+	p := newJSONFieldStreamer(sink.write, fields...)
+	return []llms.CallOption{llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+		p.Write(chunk)
+		return nil
+	})}
+}
+
+// jsonFieldStreamer incrementally parses JSON object and emits decoded text of string values of top level fields as they grow.
+// Text before the object (e.g. ```json) is ignored, output which is not an object (e.g. chunks of tool calls) is ignored at all.
+type jsonFieldStreamer struct {
+	fields []string
+	emit   func(string)
+
+	started, done bool
+	depth         int
+	inStr, esc    bool
+	expectKey     bool
+	isKey, isOut  bool
+	key           strings.Builder
+	lastKey       string
+	hex           []byte
+	surrogate     rune
+	out, tail     []byte
+}
+
+func newJSONFieldStreamer(emit func(string), fields ...string) *jsonFieldStreamer {
+	return &jsonFieldStreamer{fields: fields, emit: emit}
+}
+
+func (p *jsonFieldStreamer) Write(chunk []byte) {
+	p.out = append(p.out[:0], p.tail...)
+	p.tail = p.tail[:0]
+	for _, b := range chunk {
+		if p.done {
+			break
+		}
+		p.next(b)
+	}
+	// incomplete UTF-8 sequence at the end is sent with the next chunk
+	for k := 1; k <= min(utf8.UTFMax-1, len(p.out)); k++ {
+		if i := len(p.out) - k; utf8.RuneStart(p.out[i]) {
+			if !utf8.FullRune(p.out[i:]) {
+				p.tail = append(p.tail, p.out[i:]...)
+				p.out = p.out[:i]
+			}
+			break
+		}
+	}
+	if len(p.out) > 0 {
+		p.emit(string(p.out))
+	}
+}
+
+func (p *jsonFieldStreamer) next(b byte) {
+	switch {
+	case !p.started:
+		switch b {
+		case '{':
+			p.started, p.depth, p.expectKey = true, 1, true
+		case '[':
+			p.done = true
+		}
+	case p.inStr:
+		p.nextInString(b)
+	case b == '"':
+		p.inStr = true
+		p.isKey = p.depth == 1 && p.expectKey
+		p.isOut = p.depth == 1 && !p.expectKey && lo.Contains(p.fields, p.lastKey)
+		p.key.Reset()
+	case b == '{' || b == '[':
+		p.depth++
+	case b == '}' || b == ']':
+		if p.depth--; p.depth == 0 {
+			p.done = true
+		}
+	case b == ',' && p.depth == 1:
+		p.expectKey = true
+	}
+}
+
+func (p *jsonFieldStreamer) nextInString(b byte) {
+	switch {
+	case p.hex != nil:
+		if p.hex = append(p.hex, b); len(p.hex) == 4 {
+			n, _ := strconv.ParseUint(string(p.hex), 16, 32)
+			p.hex = nil
+			p.char(rune(n))
+		}
+	case p.esc:
+		p.esc = false
+		switch b {
+		case 'u':
+			p.hex = make([]byte, 0, 4)
+		case 'n':
+			p.char('\n')
+		case 't':
+			p.char('\t')
+		case 'r':
+			p.char('\r')
+		case 'b':
+			p.char('\b')
+		case 'f':
+			p.char('\f')
+		default: // " \ /
+			p.char(rune(b))
+		}
+	case b == '\\':
+		p.esc = true
+	case b == '"':
+		p.inStr = false
+		if p.isKey {
+			p.lastKey, p.expectKey = p.key.String(), false
+		}
+	default:
+		p.writeByte(b)
+	}
+}
+
+// char writes decoded rune of escape sequence, UTF-16 surrogate pairs are joined.
+func (p *jsonFieldStreamer) char(r rune) {
+	switch {
+	case utf16.IsSurrogate(r) && p.surrogate == 0:
+		p.surrogate = r
+		return
+	case p.surrogate != 0:
+		r, p.surrogate = utf16.DecodeRune(p.surrogate, r), 0
+	}
+	var buf [utf8.UTFMax]byte
+	for _, b := range buf[:utf8.EncodeRune(buf[:], r)] {
+		p.writeByte(b)
+	}
+}
+
+func (p *jsonFieldStreamer) writeByte(b byte) {
+	switch {
+	case p.isKey:
+		p.key.WriteByte(b)
+	case p.isOut:
+		p.out = append(p.out, b)
+	}
+}
@@ -0,0 +1,56 @@
+package ailogic
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func Test_jsonFieldStreamer(t *testing.T) {
+	out := "```json\n" + `{"queryType":"info","reasoning":["a","{\"response\":\"x\"}"],"nested":{"response":"no"},` +
+		`"response":"Привіт! \"VoIP\" – це\nтелефонія 📞 \u00e9\ud83d\ude00 a\\b\/c","nextAgent":false}` + "\n```"
+	want := "Привіт! \"VoIP\" – це\nтелефонія 📞 é😀 a\\b/c"
+
+	for _, size := range []int{1, 2, 3, 5, 7, len(out)} {
+		var deltas []string
+		p := newJSONFieldStreamer(func(s string) { deltas = append(deltas, s) }, Response)
+		for b := []byte(out); len(b) > 0; b = b[min(size, len(b)):] {
+			p.Write(b[:min(size, len(b))])
+		}
+		assert.Equal(t, want, strings.Join(deltas, ""), "chunk size %d", size)
+		assert.NotContains(t, deltas, "", "chunk size %d", size)
+	}
+
+	var deltas []string
+	p := newJSONFieldStreamer(func(s string) { deltas = append(deltas, s) }, FinalResponse)
+	p.Write([]byte(`[{"function":{"arguments":"{\"finalResponse\":\"tool\"}"}}]`))
+	p.Write([]byte(`{"finalResponse":"answer"}`))
+	assert.Empty(t, deltas, "tool calls are ignored")
+}
+
+func Test_streamingOpts(t *testing.T) {
+	assert.Nil(t, streamingOpts(context.Background(), Response))
+
+	var sent []string
+	ctx, sink := withDeltaSink(AddToCtxStream(context.Background(), func(s string) { sent = append(sent, s) }))
+	o := llms.CallOptions{}
+	for _, opt := range streamingOpts(ctx, FinalResponse) {
+		opt(&o)
+	}
+	for _, chunk := range []string{`{"finalRes`, `ponse":"Hel`, `lo`, `!","chainOfThoughts":["x"]}`} {
+		assert.NoError(t, o.StreamingFunc(ctx, []byte(chunk)))
+	}
+	assert.Equal(t, []string{"Hel", "lo", "!"}, sent)
+
+	sink.finish("Hello!")
+	assert.Len(t, sent, 3, "streamed answer is not sent again")
+
+	_, sink = withDeltaSink(AddToCtxStream(context.Background(), func(s string) { sent = append(sent, s) }))
+	sink.finish("Clarify?")
+	assert.Equal(t, "Clarify?", sent[len(sent)-1], "not streamed answer is sent at once")
+	var nilSink *deltaSink
+	nilSink.finish("no stream")
+}
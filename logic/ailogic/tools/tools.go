@@ -11,6 +11,7 @@ import (
 	"gorm.io/gorm"
 
 	"gitlab.dev.ict/golang/go-ai/logic/biz"
+	"gitlab.dev.ict/golang/go-ai/models"
 )
 
 const (
@@ -19,6 +20,8 @@ const (
 	sqlRes  = `[{"ACCOUNT_CODE":391,"ATTRIBUTE_TYPE_CODE":"MSISDN","VALUE":"380632106999"},{"ACCOUNT_CODE":401,"ATTRIBUTE_TYPE_CODE":"MSISDN","VALUE":"380632106975"},{"ACCOUNT_CODE":414,"ATTRIBUTE_TYPE_CODE":"MSISDN","VALUE":"380636733910"},{"ACCOUNT_CODE":431,"ATTRIBUTE_TYPE_CODE":"MSISDN","VALUE":"380632106799"}]`
 )
 
+const ToolNameRelevantDocs = "getRelevantDocsFromVectorDB"
+
 var (
 	ToolFuncs = []llms.Tool{
 		{
@@ -41,8 +44,8 @@ var (
 		{
 			Type: "function",
 			Function: &llms.FunctionDefinition{
-				Name:        "getRelevantDocsFromVectorDB",
-				Description: "API to retrieve documents pieces from Vector DB, that answer customer queries or provide necessary information regarding services and troubleshooting. Documents types: technical documentation for tariffs, products, services; troubleshooting guides, etc.",
+				Name:        ToolNameRelevantDocs,
+				Description: "API to retrieve documents pieces from Vector DB, that answer customer queries or provide necessary information regarding services and troubleshooting. Documents types: technical documentation for tariffs, products, services; troubleshooting guides, etc. Optional filters restrict the search, use them only when the user asks for specific source or period.",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
@@ -50,6 +53,29 @@ var (
 							"type":        "string",
 							"description": "User query",
 						},
+						"categories": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]interface{}{"type": "string", "enum": models.Categories},
+							"description": "Source of documents: FRD - functional requirements documents, WEB - pages of lifecell.ua, confluence - internal wiki, runbook - curated runbooks of the support team",
+						},
+						"urlPrefixes": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]interface{}{"type": "string"},
+							"description": "Document URL must start with one of prefixes, e.g. https://www.lifecell.ua/uk/biznesu/",
+						},
+						"tags": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]interface{}{"type": "string"},
+							"description": "Document must have at least one of tags",
+						},
+						"updatedAfter": map[string]interface{}{
+							"type":        "string",
+							"description": "Only documents updated since the date, format YYYY-MM-DD",
+						},
+						"updatedBefore": map[string]interface{}{
+							"type":        "string",
+							"description": "Only documents updated before the date, format YYYY-MM-DD",
+						},
 					},
 					"required": []string{"query"},
 				},
@@ -110,10 +136,10 @@ var (
 	}
 
 	AvailableVoipTools = map[string]ToolCallHandler{
-		"getAccountData":              GetAccountData,
-		"getRelevantDocsFromVectorDB": GetRelevantDocsFromVectorDB,
-		"get_FMC_VOIP_settings":       GetFMCVoipSettings,
-		"get_FMC_MOBILE_settings":     GetFMCMobileSettings,
+		"getAccountData":          GetAccountData,
+		ToolNameRelevantDocs:      GetRelevantDocsFromVectorDB,
+		"get_FMC_VOIP_settings":   GetFMCVoipSettings,
+		"get_FMC_MOBILE_settings": GetFMCMobileSettings,
 	}
 
 	AvailableSqlTools = map[string]ToolCallHandler{
@@ -121,18 +147,21 @@ var (
 	}
 )
 
+// fieldsRelevantDocs - fields returned to LLM by 'getRelevantDocsFromVectorDB', metadata is used to build citations.
+var fieldsRelevantDocs = []w.Field{w.FieldTitle, w.FieldChunkNo, w.FieldSectionPath, w.FieldUrl, w.FieldCategory, w.FieldPages, w.FieldSheet, w.FieldRowRange, w.FieldContent, w.FieldAdditional1}
+
 type ToolCallHandler func(rec *gologgers.LogRec, arguments json.RawMessage) (string, error)
 
 var isTestMode = false
 var ws *biz.WSGetter
-var db *w.KnowledgeBase
+var db *w.Retriever
 var cimDB *gorm.DB
 
 func TestMode(isEnable ...bool) {
 	isTestMode = utils.FirstOrDefault(true, isEnable...)
 }
 
-func InitTools(wsGetter *biz.WSGetter, dbVector *w.KnowledgeBase) {
+func InitTools(wsGetter *biz.WSGetter, dbVector *w.Retriever) {
 	once.Do(func() { ws = wsGetter; db = dbVector })
 }
 
@@ -165,6 +194,7 @@ func GetRelevantDocsFromVectorDB(rec *gologgers.LogRec, arguments json.RawMessag
 	validateDB()
 	var query struct {
 		Query string `json:"query"`
+		w.MetaFilter
 	}
 
 	if err := json.Unmarshal(arguments, &query); err != nil {
@@ -177,19 +207,45 @@ func GetRelevantDocsFromVectorDB(rec *gologgers.LogRec, arguments json.RawMessag
 		return relDoc, nil
 	}
 
-	gqlResp, err := w.WeaviateSearch(rec, db.Client, db.Class, w.DefaultSO().Limit(2).Fields(w.FieldContent).SearchTxt(query.Query))
+	so := w.DefaultSO().Fields(fieldsRelevantDocs...).SearchTxt(query.Query)
+	if err := so.WithFilter(&query.MetaFilter); err != nil {
+		return "", err
+	}
+	if !query.MetaFilter.IsEmpty() {
+		rec.Infof("Filter: %s", utils.Json(query.MetaFilter))
+	}
+
+	ki, err := db.Retrieve(rec.Ctx, so)
 	if err != nil {
 		return "", err
 	}
 
 	rec.Info("Finish vector db")
-	ki := w.GQLRespConvert[w.KnowledgeItem](gqlResp, db.Class)
 	knowItems := w.KnowledgeItems(ki)
 	rec.Infof("VectorDB return %d objects", knowItems.Len())
 	rec.Debugf("Documents from VectorDB:\n%s", knowItems.Json())
 	return knowItems.Json(), nil
 }
 
+// CitationsFromHistory collects documents returned by tool 'getRelevantDocsFromVectorDB' in the messages history.
+func CitationsFromHistory(messages []llms.MessageContent) []w.Citation {
+	var items w.KnowledgeItems
+	for _, m := range messages {
+		if m.Role != llms.ChatMessageTypeTool {
+			continue
+		}
+		for _, p := range m.Parts {
+			if tcr, ok := p.(llms.ToolCallResponse); ok && tcr.Name == ToolNameRelevantDocs {
+				var ki w.KnowledgeItems
+				if err := json.Unmarshal([]byte(tcr.Content), &ki); err == nil {
+					items = append(items, ki...)
+				}
+			}
+		}
+	}
+	return items.Citations()
+}
+
 func ExecuteSQLQuery(rec *gologgers.LogRec, arguments json.RawMessage) (string, error) {
 	var query struct {
 		ClarifyQuestion string `json:"clarifyQuestion"`
@@ -18,7 +18,7 @@ import (
 // ToolContext encapsulates tool dependencies.
 type ToolContext struct {
 	WS     *biz.WSGetter
-	DB     *w.KnowledgeBase
+	DB     w.VectorStore
 	CimDB  *gorm.DB
 	IsTest bool
 }
@@ -28,7 +28,7 @@ var toolContext ToolContext
 var once sync.Once
 
 // InitToolContext initializes the global tool context.
-func InitToolContext(ws *biz.WSGetter, db *w.KnowledgeBase, cimDB *gorm.DB, isTest bool) {
+func InitToolContext(ws *biz.WSGetter, db w.VectorStore, cimDB *gorm.DB, isTest bool) {
 	once.Do(func() {
 		toolContext = ToolContext{
 			WS:     ws,
@@ -181,3 +181,22 @@ func TestFMCSettings(t *testing.T) {
 		t.Logf("Updated Message History: %s", utils.JsonPrettyStr(updatedHistory))
 	})
 }
+
+func TestCitationsFromHistory(t *testing.T) {
+	docs := `[{"title":"FMC","chunkNo":2,"sectionPath":"Tariffs > FMC","url":"/x/fmc","category":"confluence","content":"...","_additional":{"id":"id-1","score":"0.81"}},` +
+		`{"title":"FMC","chunkNo":2,"content":"...","_additional":{"id":"id-1","score":"0.81"}},` +
+		`{"title":"VoIP","url":"https://lifecell.ua/voip","_additional":{"id":"id-2","score":"0.5"}}]`
+	hst := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, "question"),
+		{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{llms.ToolCallResponse{ToolCallID: "1", Name: "getAccountData", Content: accData}}},
+		{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{llms.ToolCallResponse{ToolCallID: "2", Name: ToolNameRelevantDocs, Content: docs}}},
+	}
+
+	citations := CitationsFromHistory(hst)
+	assert.Len(t, citations, 2)
+	assert.Equal(t, "id-1", citations[0].ID)
+	assert.Equal(t, "https://docs-bizcell.lifecell.ua/x/fmc", citations[0].URL)
+	assert.Equal(t, "Tariffs > FMC", citations[0].SectionPath)
+	assert.Equal(t, 0.81, citations[0].Score)
+	assert.Equal(t, "https://lifecell.ua/voip", citations[1].URL)
+}
@@ -6,9 +6,10 @@ import (
 	"strings"
 	"time"
 
-	"github.com/samber/lo"
+	"github.com/tmc/langchaingo/callbacks"
 	"github.com/tmc/langchaingo/chains"
 	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/openai"
 	"gitlab.dev.ict/golang/go-ai/logic/ailogic/tools"
 	"gitlab.dev.ict/golang/go-ai/logic/biz"
 	"gitlab.dev.ict/golang/go-ai/models/sse"
@@ -25,6 +26,7 @@ const (
 	ClarifyQuestions = "clarifyQuestions"
 	ChainOfThoughts  = "chainOfThoughts"
 	SelfCheck        = "selfCheck"
+	Citations        = "citations"
 
 	chainName1  = "agent_first"
 	chainName2  = "agent_second"
@@ -37,8 +39,23 @@ const (
 	escalation_path_ag_ci = "ai_agent_ci"
 )
 
+// Models provides LLM and call options of the chain by its name (see llm.Registry), so the model of every agent is configured separately.
+type Models interface {
+	ForChain(chain string, handler callbacks.Handler, rf ...*openai.ResponseFormat) (llms.Model, []llms.CallOption, error)
+}
+
+// modelOf returns LLM and call options of the chain which report calls to the handler of the chain,
+// it panics like other constructors of chains if LLM cannot be created.
+func modelOf(models Models, chain string, handler callbacks.Handler, rf ...*openai.ResponseFormat) (llms.Model, []llms.CallOption) {
+	llm, opts, err := models.ForChain(chain, handler, rf...)
+	if err != nil {
+		panic(err)
+	}
+	return llm, opts
+}
+
 type ChainVoip struct {
-	llm        llms.Model
+	models     Models
 	chainFirst chains.Chain
 	// chainSecond chains.Chain
 	agents   map[string]chains.Chain
@@ -46,14 +63,27 @@ type ChainVoip struct {
 	inputKey string
 	outKey   string
 	ws       *biz.WSGetter
-	db       *w.KnowledgeBase
+	db       *w.Retriever
+}
+
+// AgentAnswer is the final answer of agents with the documents cited to build it.
+type AgentAnswer struct {
+	Answer    string       `json:"answer"`
+	Citations []w.Citation `json:"citations,omitempty"`
+}
+
+func (a *AgentAnswer) String() string { return a.Answer }
+
+// CitationsFromResult returns citations collected by Run.
+func CitationsFromResult(outMap map[string]any) []w.Citation {
+	c, _ := outMap[Citations].([]w.Citation)
+	return c
 }
 
 type runOpts struct {
 	chainOpts                          []chains.ChainCallOption
 	callbackToUser                     func(context.Context, string) error
 	callbackToUserStreamChunkToChannel func(context.Context, *gologgers.Logger, string, chan string, int) error
-	callbackToChannel                  func(context.Context, *gologgers.Logger, string, int, func(string)) error
 	sseChannel                         chan string
 	sendToChan                         func(string)
 }
@@ -80,10 +110,6 @@ func WithSSEChan(channel chan string) RunOptFn {
 	return func(vo *runOpts) { vo.sseChannel = channel }
 }
 
-func WithCallbackToChannel(callbackFN func(context.Context, *gologgers.Logger, string, int, func(string)) error) RunOptFn {
-	return func(vo *runOpts) { vo.callbackToChannel = callbackFN }
-}
-
 // WithSendToChan sets the sendToChan field
 func WithSendToChan(callback func(string)) RunOptFn {
 	return func(vo *runOpts) { vo.sendToChan = callback }
@@ -106,8 +132,8 @@ func NewRunOptions(opts ...RunOptFn) *runOpts {
 	return vo
 }
 
-func NewChainVoipExt(ctx context.Context, llm llms.Model, log *gologgers.Logger, ws *biz.WSGetter, db *w.KnowledgeBase) *ChainVoip {
-	ch := NewChainVoip(ctx, llm, log)
+func NewChainVoipExt(ctx context.Context, models Models, log *gologgers.Logger, ws *biz.WSGetter, db *w.Retriever) *ChainVoip {
+	ch := NewChainVoip(ctx, models, log)
 	ch.ws = ws
 	ch.db = db
 
@@ -115,17 +141,17 @@ func NewChainVoipExt(ctx context.Context, llm llms.Model, log *gologgers.Logger,
 	return ch
 }
 
-func NewChainVoip(ctx context.Context, llm llms.Model, log *gologgers.Logger) *ChainVoip {
+func NewChainVoip(ctx context.Context, models Models, log *gologgers.Logger) *ChainVoip {
 	ch := &ChainVoip{
-		llm:    llm,
+		models: models,
 		log:    log,
 		outKey: defVoipKeyOUT,
 		// chainFirst:  LifecellAgentFirstNew(ctx, log, llm),
-		chainFirst: Agent1(ctx, log, openaiOptsAg1),
+		chainFirst: Agent1(ctx, log, models),
 		// chainSecond: LifecellAgentSolutionSearcher(ctx, log, llm),
 		agents: map[string]chains.Chain{
-			escalation_path_ag_2:  LifecellAgentSolutionSearcher(ctx, log, llm),
-			escalation_path_ag_ci: NewAgentCallIssue(ctx, log),
+			escalation_path_ag_2:  LifecellAgentSolutionSearcher(ctx, log, models),
+			escalation_path_ag_ci: NewAgentCallIssue(ctx, log, models),
 		},
 	}
 	return ch
@@ -208,28 +234,6 @@ func CallbackSSEStream(ctx context.Context, log *gologgers.Logger, data string,
 	return nil
 }
 
-func CallbackSSEStreamWithFN(ctx context.Context, log *gologgers.Logger, data string, chunkSize int, sendToChan func(string)) error {
-	rec := log.RecWithCtx(ctx, "STREAM")
-	rec.Info("Start callback! Data:", data)
-
-	// Split the data into chunks of the specified size
-	chunks := strings.Split(data, "\n")
-	rec.Infof("Chunks: %v", chunks)
-
-	for _, chunk := range chunks {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			sendToChan(lo.If(chunk == "", " ").Else(chunk))
-			time.Sleep(100 * time.Millisecond) // Simulate latency
-		}
-	}
-
-	rec.Info("End callback!")
-	return nil
-}
-
 func (c *ChainVoip) GetMemoryKey() string {
 	return c.chainFirst.GetMemory().GetMemoryKey(context.Background())
 }
@@ -248,9 +252,12 @@ func (c *ChainVoip) GetKeyOut() string {
 	return c.outKey
 }
 
+// Run calls the first agent and, if it asks for escalation, the agent of the escalation path.
+// If ctx has a function set by AddToCtxStream, the answer is streamed to it while LLM generates it.
 func (c *ChainVoip) Run(ctx context.Context, values map[string]any, optsFuncs ...RunOptFn) (map[string]any, error) {
 	opts := NewRunOptions(optsFuncs...)
 	c.log.Info("Start Run")
+	ctx, sink := withDeltaSink(ctx)
 	rec := c.log.RecWithCtx(ctx)
 
 	outMap, err := chains.Call(ctx, c.chainFirst, values, opts.chainOpts...)
@@ -269,11 +276,7 @@ func (c *ChainVoip) Run(ctx context.Context, values map[string]any, optsFuncs ..
 		rec.Infof("Explicitly call lifecell voip team => %t", isUserWantLifecellEmployee)
 
 		if !na {
-			rec.Info("Start callbackToUserStreamChunkToChannel! Chunk by chunk send response to user via SSE channel!")
-			switch {
-			case opts.callbackToChannel != nil:
-				opts.callbackToChannel(ctx, c.log, outMap[Response].(string), 10, opts.sendToChan)
-			}
+			sink.finish(outMap[Response].(string))
 			outMap[c.GetKeyOut()] = outMap[Response].(string)
 			return outMap, nil
 		}
@@ -291,13 +294,13 @@ func (c *ChainVoip) Run(ctx context.Context, values map[string]any, optsFuncs ..
 	}
 	rec.Tracef("OUT_MAP from second chain:\n%s", PrettyPrintStruct(resp))
 
-	if opts.callbackToChannel != nil {
-		opts.callbackToChannel(ctx, c.log, resp[c.agents[escalationPath].GetOutputKeys()[0]].(string), 10, opts.sendToChan)
-	}
+	sink.finish(resp[c.agents[escalationPath].GetOutputKeys()[0]].(string))
 
 	// outMap[c.GetKeyOut()] = resp[FinalResponse].(string)
 	outMap[c.GetKeyOut()] = resp[c.agents[escalationPath].GetOutputKeys()[0]].(string)
 	outMap["agent2_result"] = resp
+	outMap[Citations] = CitationsFromResult(resp)
+	rec.Infof("Citations from agent[%s]: %d", escalationPath, len(CitationsFromResult(outMap)))
 
 	return outMap, nil
 }
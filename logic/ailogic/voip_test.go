@@ -62,7 +62,7 @@ func TestChainVoip_Run(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			voip := NewChainVoip(tt.args.ctx, llm, log)
+			voip := NewChainVoip(tt.args.ctx, models, log)
 			resultMap, err := voip.Run(tt.args.ctx, map[string]any{voip.GetKeyIn(): tt.args.input, PlaceholderForHistory: tt.args.hst}, tt.args.optsFuncs...)
 
 			assert.NoError(t, err)
@@ -83,9 +83,9 @@ func TestNewChainVoipExt(t *testing.T) {
 	ctx := context.Background()
 	inputKey := "userPrompt"
 	ws := &biz.WSGetter{}
-	db := &w.KnowledgeBase{}
+	db := w.NewRetriever(log, &w.KnowledgeBase{})
 
-	voip := NewChainVoipExt(ctx, llm, log, ws, db)
+	voip := NewChainVoipExt(ctx, models, log, ws, db)
 	t.Log("inputKey =>", voip.GetKeyIn())
 	t.Log("outKey =>", voip.outKey)
 
@@ -107,7 +107,7 @@ func TestNewChainVoipExt(t *testing.T) {
 func TestFirstChain(t *testing.T) {
 	ctx := AddToCtxUUIDAI(ctx, "a5584496-b231-48b3-9710-f8dfe7c36ee3")
 	help_prettyPrintStruct_T(t, ctx)
-	voip := NewChainVoip(ctx, llm, log)
+	voip := NewChainVoip(ctx, models, log)
 	ag1 := voip.chainFirst.(*LifecellAgentFirst)
 	ag1.GetBuffer().ReturnMessages = true
 
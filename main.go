@@ -55,6 +55,11 @@ func main() {
 		panic(fmt.Sprintf("Failed to load configuration: %v", err))
 	}
 
+	// Run CLI subcommand instead of server, see runCommand
+	if args := commandArgs(); len(args) > 0 {
+		os.Exit(runCommand(cfg, args))
+	}
+
 	// Create and start server
 	srv := server.New(cfg, cfg.HandlerApp, initStorage())
 
@@ -73,6 +78,7 @@ func main() {
 	// Wait for shutdown signal
 	<-done
 	cfg.Log.Info("Server shutting down...")
+	cfg.Close()
 	cfg.Log.Info("Server stopped")
 	os.Exit(0)
 }
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"time"
 
 	"gitlab.dev.ict/golang/libs/utils"
 )
@@ -10,16 +11,26 @@ import (
 type DocumentAttribute string
 
 const (
-	AttrSummary  DocumentAttribute = "summary"
-	AttrKeywords DocumentAttribute = "keywords"
-	AttrCategory DocumentAttribute = "category"
-	AttrOriginal DocumentAttribute = "original"
-
-	CategoryFRD  = "FRD"
-	CategoryWEB  = "WEB"
-	CategoryCONF = "confluence"
+	AttrSummary    DocumentAttribute = "summary"
+	AttrKeywords   DocumentAttribute = "keywords"
+	AttrCategory   DocumentAttribute = "category"
+	AttrOriginal   DocumentAttribute = "original"
+	AttrTags       DocumentAttribute = "tags"
+	AttrLanguage   DocumentAttribute = "language"
+	AttrSheet      DocumentAttribute = "sheet"
+	AttrRowRange   DocumentAttribute = "rowRange"
+	AttrOwner      DocumentAttribute = "owner"
+	AttrValidUntil DocumentAttribute = "validUntil"
+
+	CategoryFRD     = "FRD"
+	CategoryWEB     = "WEB"
+	CategoryCONF    = "confluence"
+	CategoryRunbook = "runbook"
 )
 
+// Categories are all document categories, curated runbooks (CategoryRunbook) can be filtered separately from scraped web pages.
+var Categories = []string{CategoryFRD, CategoryWEB, CategoryCONF, CategoryRunbook}
+
 // Attributes represents a collection of document attributes
 type Attributes map[DocumentAttribute]any
 
@@ -54,6 +65,7 @@ type Doc struct {
 	Link        string
 	Attrs       map[DocumentAttribute]any
 	errLoading  error
+	unchanged   bool
 }
 
 // String returns a JSON string representation of the document
@@ -83,6 +95,17 @@ func (d *Doc) ErrorLoading() error {
 	return d.errLoading
 }
 
+// WithUnchanged marks the source which was not changed since the previous sync (incremental processors emit only the link):
+// it is kept in vector DB as is, savers must not store its content.
+func (d *Doc) WithUnchanged() *Doc {
+	d.unchanged = true
+	return d
+}
+
+func (d *Doc) IsUnchanged() bool {
+	return d.unchanged
+}
+
 func (d *Doc) WithSummary(s string) *Doc {
 	return d.WithAttr(AttrSummary, s)
 }
@@ -126,3 +149,70 @@ func (d *Doc) Original() string {
 	}
 	return ""
 }
+
+func (d *Doc) WithLanguage(s string) *Doc {
+	return d.WithAttr(AttrLanguage, s)
+}
+
+func (d *Doc) Language() string {
+	if s, ok := d.Attrs[AttrLanguage].(string); ok {
+		return s
+	}
+	return ""
+}
+
+func (d *Doc) WithTags(tags ...string) *Doc {
+	return d.WithAttr(AttrTags, tags)
+}
+
+func (d *Doc) Tags() []string {
+	if s, ok := d.Attrs[AttrTags].([]string); ok {
+		return s
+	}
+	return nil
+}
+
+func (d *Doc) WithSheet(name, rowRange string) *Doc {
+	return d.WithAttr(AttrSheet, name).WithAttr(AttrRowRange, rowRange)
+}
+
+func (d *Doc) Sheet() string {
+	if s, ok := d.Attrs[AttrSheet].(string); ok {
+		return s
+	}
+	return ""
+}
+
+func (d *Doc) RowRange() string {
+	if s, ok := d.Attrs[AttrRowRange].(string); ok {
+		return s
+	}
+	return ""
+}
+
+func (d *Doc) WithOwner(s string) *Doc {
+	return d.WithAttr(AttrOwner, s)
+}
+
+func (d *Doc) Owner() string {
+	if s, ok := d.Attrs[AttrOwner].(string); ok {
+		return s
+	}
+	return ""
+}
+
+func (d *Doc) WithValidUntil(t time.Time) *Doc {
+	return d.WithAttr(AttrValidUntil, t)
+}
+
+// ValidUntil returns the date after which the document (runbook) should be reviewed.
+func (d *Doc) ValidUntil() (time.Time, bool) {
+	t, ok := d.Attrs[AttrValidUntil].(time.Time)
+	return t, ok
+}
+
+// IsExpired reports whether valid-until date of the document is before now.
+func (d *Doc) IsExpired(now time.Time) bool {
+	t, ok := d.ValidUntil()
+	return ok && t.Before(now)
+}
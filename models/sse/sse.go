@@ -28,6 +28,10 @@ const (
 	EvtInfo
 	EvtAnnounce
 	EvtSQLResult
+	EvtCitations
+	EvtIngestJob
+	EvtChatDelta
+	EvtQuota
 )
 
 var (
@@ -38,6 +42,10 @@ var (
 		EvtInfo:        "info",
 		EvtAnnounce:    "announce",
 		EvtSQLResult:   "sql_table_as_json",
+		EvtCitations:   "citations",
+		EvtIngestJob:   "ingest_job",
+		EvtChatDelta:   "chatgpt_delta",
+		EvtQuota:       "quota",
 	}
 )
 
@@ -26,16 +26,35 @@ const (
 	LogicTypePDF
 	LogicTypeWebLifecell
 	LogicTypeWebOther
+	LogicTypeSheet
+	LogicTypeMarkdown
+	LogicTypeBundle
 )
 
 type ContentSaverFunc func(ctx context.Context, doc *Doc)
 
-func ContentSaveToVectorDB(db *w.KnowledgeBase) ContentSaverFunc {
+// ContentSaveToVectorDB stages documents in the batch, which should be flushed by the caller (see w.Batch.Flush).
+// Unchanged sources (see Doc.WithUnchanged) are skipped.
+func ContentSaveToVectorDB(b *w.Batch) ContentSaverFunc {
 	return func(ctx context.Context, d *Doc) {
-		db.AddItem(ctx, d.Title, d.TextContent, d.Link, d.Category(), d.Summary(), d.Keywords())
+		if d.IsUnchanged() {
+			return
+		}
+		b.Add(DocToKI(d))
 	}
 }
 
+// DocToKI converts document with its attributes to KnowledgeItem (not split into chunks yet).
+func DocToKI(d *Doc) *w.KnowledgeItem {
+	validUntil, _ := d.ValidUntil()
+	return w.NewKI(d.Title, d.TextContent, d.Link, d.Category(), d.Summary(), d.Keywords()).
+		WithTags(d.Tags()...).
+		WithLanguage(d.Language()).
+		WithSheet(d.Sheet(), d.RowRange()).
+		WithOwner(d.Owner()).
+		WithValidUntil(validUntil)
+}
+
 func ContentBackupLocal(rec *slog.Record, dir string) ContentSaverFunc {
 	dir = utils.ExpandPath(dir)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -45,6 +64,9 @@ func ContentBackupLocal(rec *slog.Record, dir string) ContentSaverFunc {
 	rec.Infof("Conten backup to dir=%s", dir)
 
 	return func(ctx context.Context, d *Doc) {
+		if d.IsUnchanged() {
+			return
+		}
 		rec.Debugf("Process doc [%s]", utils.JsonPretty(d))
 		fileName := filepath.Join(utils.ExpandPath(dir), h.ToSnake(d.Title))
 
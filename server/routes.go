@@ -97,6 +97,15 @@ func (s *Server) setupAccessManagementRoutes(router fiber.Router) {
 	rbac.Get("/permissions", h.GetPermissions)
 	rbac.Post("/permissions", h.CreatePermission)
 	rbac.Delete("/permissions/:id", h.DeletePermission)
+
+	// LLM usage routes
+	rbac.Get("/usage/:by?", h.GetUsage)
+
+	// LLM quota routes
+	rbac.Get("/quota", h.GetQuotas)
+	rbac.Put("/quota/:subject/:name", h.SetQuota)
+	rbac.Get("/quota/users/:login", h.GetUserQuota)
+	rbac.Post("/quota/:subject/:name/reset", h.ResetQuota)
 }
 
 func (s *Server) setupChatRoutes(router fiber.Router) {
@@ -52,6 +52,7 @@ func (s *Server) initialize() {
 		Views:             engine,
 		ErrorHandler:      s.ErrorsHandler,
 		PassLocalsToViews: true,
+		BodyLimit:         (s.config.BundleMaxMB + 1) << 20, // uploaded ZIP bundle with multipart overhead
 	})
 
 	hooks.InitFiberHooks(s.app, s.config.Log)
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/samber/lo"
+)
+
+const confPageLimit = 100
+
+// ConfContent is a page or attachment of Confluence REST API (/rest/api/content).
+type ConfContent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Ancestors []confRef `json:"ancestors"`
+	Version   struct {
+		Number int       `json:"number"`
+		When   time.Time `json:"when"`
+	} `json:"version"`
+	Metadata struct {
+		Labels struct {
+			Results []confLabel `json:"results"`
+		} `json:"labels"`
+	} `json:"metadata"`
+	Body struct {
+		View    struct{ Value string } `json:"view"`
+		Storage struct{ Value string } `json:"storage"`
+	} `json:"body"`
+	Extensions struct {
+		MediaType string `json:"mediaType"`
+		FileSize  int64  `json:"fileSize"`
+	} `json:"extensions"`
+	Links struct {
+		Webui    string `json:"webui"`
+		Download string `json:"download"`
+	} `json:"_links"`
+}
+
+// ParentID returns ID of the direct parent page (empty for the space root).
+func (c *ConfContent) ParentID() string {
+	if len(c.Ancestors) == 0 {
+		return ""
+	}
+	return c.Ancestors[len(c.Ancestors)-1].ID
+}
+
+func (c *ConfContent) Labels() []string {
+	return lo.Map(c.Metadata.Labels.Results, func(l confLabel, _ int) string { return l.Name })
+}
+
+func (c *ConfContent) HTML() string {
+	return lo.Ternary(c.Body.View.Value != "", c.Body.View.Value, c.Body.Storage.Value)
+}
+
+type confRef struct {
+	ID string `json:"id"`
+}
+
+type confLabel struct {
+	Name string `json:"name"`
+}
+
+type confContentList struct {
+	Results []ConfContent `json:"results"`
+	Size    int           `json:"size"`
+}
+
+// ConfluenceClient is a client of Confluence REST API used by incremental sync (page tree with versions, attachments).
+type ConfluenceClient struct {
+	client  *resty.Client
+	baseURL string
+	token   string
+}
+
+// NewConfluenceClient creates client, token is a personal access token (Bearer), empty token - anonymous access.
+func NewConfluenceClient(client *resty.Client, baseURL, token string) *ConfluenceClient {
+	return &ConfluenceClient{client: client, baseURL: strings.TrimRight(baseURL, "/"), token: token}
+}
+
+func (cc *ConfluenceClient) String() string {
+	return fmt.Sprintf("ConfluenceClient{baseURL=%s, withToken=%t}", cc.baseURL, cc.token != "")
+}
+
+// URL returns absolute URL of the link of REST API response (webui, download).
+func (cc *ConfluenceClient) URL(link string) string {
+	if link == "" || strings.HasPrefix(link, "http") {
+		return link
+	}
+	return cc.baseURL + link
+}
+
+func (cc *ConfluenceClient) request(ctx context.Context) *resty.Request {
+	r := cc.client.R().SetContext(ctx).SetHeader("Accept", "application/json")
+	if cc.token != "" {
+		r.SetAuthToken(cc.token)
+	}
+	return r
+}
+
+func (cc *ConfluenceClient) get(ctx context.Context, path string, query url.Values, res any) error {
+	resp, err := cc.request(ctx).SetQueryParamsFromValues(query).SetResult(res).Get(cc.baseURL + path)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return fmt.Errorf("confluence GET %s: status %s", path, resp.Status())
+	}
+	return nil
+}
+
+// list reads all pages of the paginated result.
+func (cc *ConfluenceClient) list(ctx context.Context, path string, query url.Values) (res []ConfContent, err error) {
+	for start := 0; ; start += confPageLimit {
+		query.Set("start", strconv.Itoa(start))
+		query.Set("limit", strconv.Itoa(confPageLimit))
+		var l confContentList
+		if err = cc.get(ctx, path, query, &l); err != nil {
+			return nil, err
+		}
+		res = append(res, l.Results...)
+		if len(l.Results) < confPageLimit {
+			return res, nil
+		}
+	}
+}
+
+// Tree returns the root page and all its descendant pages with versions, ancestors and labels (without bodies).
+func (cc *ConfluenceClient) Tree(ctx context.Context, rootID string) ([]ConfContent, error) {
+	expand := url.Values{"expand": {"version,ancestors,metadata.labels"}}
+	var root ConfContent
+	if err := cc.get(ctx, "/rest/api/content/"+rootID, expand, &root); err != nil {
+		return nil, err
+	}
+	pages, err := cc.list(ctx, "/rest/api/content/"+rootID+"/descendant/page", expand)
+	if err != nil {
+		return nil, err
+	}
+	return append([]ConfContent{root}, pages...), nil
+}
+
+// Page returns the page with body of the expand type (e.g. body.view, body.storage).
+func (cc *ConfluenceClient) Page(ctx context.Context, id, bodyExpand string) (*ConfContent, error) {
+	var p ConfContent
+	err := cc.get(ctx, "/rest/api/content/"+id, url.Values{"expand": {bodyExpand + ",version,ancestors,metadata.labels"}}, &p)
+	return &p, err
+}
+
+// Attachments returns attachments of the page with versions.
+func (cc *ConfluenceClient) Attachments(ctx context.Context, pageID string) ([]ConfContent, error) {
+	return cc.list(ctx, "/rest/api/content/"+pageID+"/child/attachment", url.Values{"expand": {"version"}})
+}
+
+// Download saves attachment to the file.
+func (cc *ConfluenceClient) Download(ctx context.Context, a *ConfContent, filePath string) error {
+	resp, err := cc.request(ctx).SetOutput(filePath).Get(cc.URL(a.Links.Download))
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return fmt.Errorf("download attachment %s: status %s", a.Title, resp.Status())
+	}
+	return nil
+}
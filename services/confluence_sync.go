@@ -0,0 +1,308 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gookit/slog"
+	"github.com/samber/lo"
+	"gitlab.dev.ict/golang/go-ai/models"
+	"gorm.io/gorm"
+)
+
+const maxConfAttachmentSize = 50 << 20
+
+// ConfPageState is a page of the synced Confluence tree as it was seen on the last sync.
+type ConfPageState struct {
+	RootID      string                          `json:"rootId" gorm:"primaryKey;size:64"`
+	PageID      string                          `json:"pageId" gorm:"primaryKey;size:64"`
+	ParentID    string                          `json:"parentId"`
+	Title       string                          `json:"title"`
+	Link        string                          `json:"link"`
+	Version     int                             `json:"version"`
+	Modified    time.Time                       `json:"modified"`
+	Labels      []string                        `json:"labels" gorm:"serializer:json"`
+	Attachments map[string]*ConfAttachmentState `json:"attachments" gorm:"serializer:json"`
+	SyncedAt    time.Time                       `json:"syncedAt"`
+}
+
+func (ConfPageState) TableName() string { return "confluence_pages" }
+
+// ConfAttachmentState is a version of the ingested attachment and source URIs of the documents produced from it.
+type ConfAttachmentState struct {
+	Version int      `json:"version"`
+	Sources []string `json:"sources"`
+}
+
+// sources returns URIs of the page and its attachments.
+func (s *ConfPageState) sources() []string {
+	res := []string{s.Link}
+	for _, a := range s.Attachments {
+		res = append(res, a.Sources...)
+	}
+	return res
+}
+
+// ConfStateStore persists page versions of synced Confluence trees.
+type ConfStateStore interface {
+	List(ctx context.Context, rootID string) ([]*ConfPageState, error)
+	Save(ctx context.Context, s *ConfPageState) error
+	Delete(ctx context.Context, rootID string, pageIDs ...string) error
+}
+
+// ConfStateGorm is a ConfStateStore persisted with gorm (usually in the DB of the ingestion ledger).
+type ConfStateGorm struct {
+	db *gorm.DB
+}
+
+func NewConfStateGorm(db *gorm.DB) (*ConfStateGorm, error) {
+	if err := db.AutoMigrate(&ConfPageState{}); err != nil {
+		return nil, err
+	}
+	return &ConfStateGorm{db: db}, nil
+}
+
+func (s *ConfStateGorm) List(ctx context.Context, rootID string) (res []*ConfPageState, err error) {
+	err = s.db.WithContext(ctx).Where("root_id = ?", rootID).Order("page_id").Find(&res).Error
+	return
+}
+
+func (s *ConfStateGorm) Save(ctx context.Context, st *ConfPageState) error {
+	return s.db.WithContext(ctx).Save(st).Error
+}
+
+func (s *ConfStateGorm) Delete(ctx context.Context, rootID string, pageIDs ...string) error {
+	if len(pageIDs) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Delete(&ConfPageState{}, "root_id = ? AND page_id IN ?", rootID, pageIDs).Error
+}
+
+// ConfSyncStats is a summary of one sync.
+type ConfSyncStats struct {
+	Pages       int `json:"pages"`
+	Changed     int `json:"changed"`
+	Moved       int `json:"moved"`
+	Unchanged   int `json:"unchanged"`
+	Deleted     int `json:"deleted"`
+	Attachments int `json:"attachments"`
+	Failed      int `json:"failed"`
+}
+
+func (s *ConfSyncStats) String() string {
+	return fmt.Sprintf("ConfSyncStats={pages=%d, changed=%d, moved=%d, unchanged=%d, deleted=%d, attachments=%d, failed=%d}",
+		s.Pages, s.Changed, s.Moved, s.Unchanged, s.Deleted, s.Attachments, s.Failed)
+}
+
+// FileLogicFN returns a new processor of the file by name and MIME type (see RAGService.FileLogic).
+// Processor is configured with WithExternalSource, so it must not be the shared processor of full runs.
+type FileLogicFN func(filename string, mimes ...string) (models.Logic, error)
+
+// confSync is a state of one sync of the page tree.
+type confSync struct {
+	*ConfluenceProcessor
+	log   *slog.Record
+	emit  models.ContentSaverFunc
+	stats *ConfSyncStats
+}
+
+// sync compares the page tree (versions, parents, labels) with the state of the previous sync:
+// only new, modified and moved pages are fetched with body, unchanged pages and attachments are emitted as unchanged docs
+// (see models.Doc.WithUnchanged), pages deleted or moved out of the tree are not emitted at all, so full ingestion removes them.
+func (cp *ConfluenceProcessor) sync(ctx context.Context, csf ...models.ContentSaverFunc) *ConfSyncStats {
+	s := &confSync{ConfluenceProcessor: cp, log: cp.log.RecWithCtx(ctx, "confl-sync"), stats: &ConfSyncStats{}}
+	s.emit = func(ctx context.Context, d *models.Doc) {
+		for _, f := range csf {
+			f(ctx, d)
+		}
+	}
+	s.log.Infof("Start sync of confluence tree: root=%s api=%s", cp.rootPageID, cp.api)
+
+	states, err := cp.state.List(ctx, cp.rootPageID)
+	if err != nil {
+		s.log.Errorf("Load sync state of root=%s failed: %v", cp.rootPageID, err)
+		return s.stats
+	}
+	known := lo.KeyBy(states, func(st *ConfPageState) string { return st.PageID })
+
+	tree, err := cp.api.Tree(ctx, cp.rootPageID)
+	if err != nil {
+		s.log.Errorf("Get page tree of root=%s failed, %d known pages are kept: %v", cp.rootPageID, len(states), err)
+		for _, st := range states {
+			s.keep(ctx, st.sources()...)
+		}
+		return s.stats
+	}
+
+	for i := range tree {
+		p := &tree[i]
+		st := known[p.ID]
+		delete(known, p.ID)
+		s.stats.Pages++
+		if ctx.Err() != nil {
+			if st != nil {
+				s.keep(ctx, st.sources()...)
+			}
+			continue
+		}
+		s.syncPage(ctx, p, st)
+	}
+
+	for _, st := range known {
+		s.log.Infof("Page was deleted or moved out of the tree: id=%s title=[%s] link=%s", st.PageID, st.Title, st.Link)
+		s.stats.Deleted++
+	}
+	if err = cp.state.Delete(ctx, cp.rootPageID, lo.Keys(known)...); err != nil {
+		s.log.Errorf("Delete sync state of removed pages failed: %v", err)
+	}
+	s.log.Infof("Finish sync of confluence tree: root=%s %s", cp.rootPageID, s.stats)
+	return s.stats
+}
+
+// keep emits unchanged docs of the sources.
+func (s *confSync) keep(ctx context.Context, sources ...string) {
+	for _, src := range sources {
+		s.emit(ctx, models.NewDoc("", "", src).WithUnchanged())
+	}
+}
+
+func (s *confSync) syncPage(ctx context.Context, p *ConfContent, prev *ConfPageState) {
+	next := &ConfPageState{
+		RootID:   s.rootPageID,
+		PageID:   p.ID,
+		ParentID: p.ParentID(),
+		Title:    p.Title,
+		Link:     s.api.URL(p.Links.Webui),
+		Version:  p.Version.Number,
+		Modified: p.Version.When,
+		Labels:   p.Labels(),
+		SyncedAt: time.Now(),
+	}
+	isMoved := prev != nil && prev.ParentID != next.ParentID
+	isChanged := prev == nil || isMoved || prev.Version != next.Version || prev.Link != next.Link || !slices.Equal(prev.Labels, next.Labels)
+
+	if isChanged {
+		full, err := s.api.Page(ctx, p.ID, lo.Ternary(s.expBodyType != "", s.expBodyType, ExpBV))
+		if err != nil {
+			s.log.Errorf("Get page id=%s title=[%s] failed: %v", p.ID, p.Title, err)
+			s.stats.Failed++
+			if prev != nil {
+				s.keep(ctx, prev.sources()...) // state is not updated, page is fetched again on the next sync
+			}
+			return
+		}
+		s.log.Infof("Process page: id=%s version=%d isNew=%t isMoved=%t title=[%s]", p.ID, next.Version, prev == nil, isMoved, p.Title)
+		d := models.NewDoc(full.Title, s.parseContent(ctx, full.HTML()), next.Link).WithCategory(models.CategoryCONF)
+		if len(next.Labels) > 0 {
+			d.WithKeywords(strings.Join(next.Labels, ","))
+		}
+		s.emit(ctx, d)
+		s.stats.Changed++
+		if isMoved {
+			s.stats.Moved++
+		}
+	} else {
+		s.keep(ctx, prev.Link)
+		s.stats.Unchanged++
+	}
+
+	next.Attachments = s.syncAttachments(ctx, p, prev, next.Labels)
+	if err := s.state.Save(ctx, next); err != nil {
+		s.log.Errorf("Save sync state of page id=%s failed: %v", p.ID, err)
+	}
+}
+
+// syncAttachments ingests new and modified attachments which have registered processor (see WithAttachments).
+func (s *confSync) syncAttachments(ctx context.Context, p *ConfContent, prev *ConfPageState, labels []string) map[string]*ConfAttachmentState {
+	res := map[string]*ConfAttachmentState{}
+	if prev != nil && prev.Attachments != nil {
+		res = prev.Attachments
+	}
+	if s.fileLogic == nil {
+		for _, a := range res {
+			s.keep(ctx, a.Sources...)
+		}
+		return res
+	}
+	atts, err := s.api.Attachments(ctx, p.ID)
+	if err != nil {
+		s.log.Errorf("Get attachments of page id=%s failed: %v", p.ID, err)
+		s.stats.Failed++
+		for _, a := range res {
+			s.keep(ctx, a.Sources...)
+		}
+		return res
+	}
+
+	next := map[string]*ConfAttachmentState{}
+	for i := range atts {
+		a := &atts[i]
+		old := res[a.ID]
+		if old != nil && old.Version == a.Version.Number {
+			s.keep(ctx, old.Sources...)
+			next[a.ID] = old
+			continue
+		}
+		logic, err := s.fileLogic(a.Title, a.Extensions.MediaType)
+		if err != nil {
+			s.log.Debugf("Skip attachment [%s] of page id=%s: %v", a.Title, p.ID, err)
+			continue
+		}
+		if a.Extensions.FileSize > maxConfAttachmentSize {
+			s.log.Warnf("Skip attachment [%s] of page id=%s: size %d > %d", a.Title, p.ID, a.Extensions.FileSize, maxConfAttachmentSize)
+			continue
+		}
+		sources, err := s.ingestAttachment(ctx, a, logic, labels)
+		if err != nil {
+			s.log.Errorf("Ingest attachment [%s] of page id=%s failed: %v", a.Title, p.ID, err)
+			s.stats.Failed++
+			if old != nil {
+				s.keep(ctx, old.Sources...)
+				next[a.ID] = old
+			}
+			continue
+		}
+		s.log.Infof("Attachment [%s] of page id=%s ingested: version=%d docs=%d", a.Title, p.ID, a.Version.Number, len(sources))
+		next[a.ID] = &ConfAttachmentState{Version: a.Version.Number, Sources: sources}
+		s.stats.Attachments++
+	}
+	return next
+}
+
+// ingestAttachment downloads the attachment and processes it with the logic. Links of the documents point to the attachment
+// (without version query), page labels are added to keywords.
+func (s *confSync) ingestAttachment(ctx context.Context, a *ConfContent, logic models.Logic, labels []string) ([]string, error) {
+	dir, err := os.MkdirTemp("", "confl-att-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, filepath.Base(a.Title))
+	if err = s.api.Download(ctx, a, filePath); err != nil {
+		return nil, err
+	}
+
+	link, _, _ := strings.Cut(s.api.URL(a.Links.Download), "?")
+	var sources []string
+	logic.WithExternalSource(filePath).Process(ctx, func(ctx context.Context, d *models.Doc) {
+		d.Link = link + strings.TrimPrefix(d.Link, filePath)
+		if d.Category() == "" {
+			d.WithCategory(models.CategoryCONF)
+		}
+		if len(labels) > 0 {
+			d.WithKeywords(strings.Join(lo.Compact(append([]string{d.Keywords()}, labels...)), ","))
+		}
+		sources = append(sources, DocSourceURI(d))
+		s.emit(ctx, d)
+	})
+	if len(sources) == 0 {
+		return nil, errors.New("no documents were produced")
+	}
+	return sources, nil
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.dev.ict/golang/go-ai/models"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
+)
+
+type stubConfPage struct {
+	title, parent, body string
+	version             int
+	labels              []string
+	atts                []stubConfAttachment
+}
+
+type stubConfAttachment struct {
+	id, title, mediaType, content string
+	version                       int
+}
+
+// confStub is a stand-in of Confluence REST API (content, descendants, attachments, downloads).
+type confStub struct {
+	*httptest.Server
+	mu       sync.Mutex
+	pages    map[string]*stubConfPage
+	bodyGets []string
+	unauth   int
+}
+
+func newConfStub(t *testing.T, pages map[string]*stubConfPage) *confStub {
+	s := &confStub{pages: pages}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *confStub) ancestors(id string) (res []string) {
+	for p := s.pages[id].parent; p != ""; p = s.pages[p].parent {
+		res = append([]string{p}, res...)
+	}
+	return
+}
+
+func (s *confStub) content(id string, withBody bool) map[string]any {
+	p := s.pages[id]
+	c := map[string]any{
+		"id": id, "type": "page", "title": p.title,
+		"ancestors": lo.Map(s.ancestors(id), func(a string, _ int) map[string]string { return map[string]string{"id": a} }),
+		"version":   map[string]any{"number": p.version, "when": "2024-05-01T10:00:00.000Z"},
+		"metadata":  map[string]any{"labels": map[string]any{"results": lo.Map(p.labels, func(l string, _ int) map[string]string { return map[string]string{"name": l} })}},
+		"_links":    map[string]string{"webui": "/pages/viewpage.action?pageId=" + id},
+	}
+	if withBody {
+		c["body"] = map[string]any{"view": map[string]string{"value": p.body}}
+	}
+	return c
+}
+
+func (s *confStub) handle(rw http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer tkn" {
+		s.unauth++
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/download/attachments/") {
+		pageID, name := filepath.Split(strings.TrimPrefix(r.URL.Path, "/download/attachments/"))
+		for _, a := range s.pages[strings.Trim(pageID, "/")].atts {
+			if a.title == name {
+				fmt.Fprint(rw, a.content)
+				return
+			}
+		}
+		http.NotFound(rw, r)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/rest/api/content/"), "/")
+	if _, ok := s.pages[parts[0]]; !ok {
+		http.NotFound(rw, r)
+		return
+	}
+	var res any
+	switch {
+	case len(parts) == 1:
+		withBody := strings.Contains(r.URL.Query().Get("expand"), "body.view")
+		if withBody {
+			s.bodyGets = append(s.bodyGets, parts[0])
+		}
+		res = s.content(parts[0], withBody)
+	case len(parts) == 3 && parts[1] == "descendant":
+		ids := lo.Filter(lo.Keys(s.pages), func(id string, _ int) bool { return slices.Contains(s.ancestors(id), parts[0]) })
+		slices.Sort(ids)
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		ids = ids[min(start, len(ids)):min(start+limit, len(ids))]
+		res = map[string]any{"results": lo.Map(ids, func(id string, _ int) map[string]any { return s.content(id, false) }), "size": len(ids)}
+	case len(parts) == 3 && parts[1] == "child":
+		res = map[string]any{"results": lo.Map(s.pages[parts[0]].atts, func(a stubConfAttachment, _ int) map[string]any {
+			return map[string]any{
+				"id": a.id, "type": "attachment", "title": a.title,
+				"version":    map[string]any{"number": a.version},
+				"extensions": map[string]any{"mediaType": a.mediaType, "fileSize": len(a.content)},
+				"_links":     map[string]string{"download": fmt.Sprintf("/download/attachments/%s/%s?version=%d&api=v2", parts[0], a.title, a.version)},
+			}
+		})}
+	default:
+		http.NotFound(rw, r)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(res)
+}
+
+func TestConfluenceProcessor_Sync(t *testing.T) {
+	stub := newConfStub(t, map[string]*stubConfPage{
+		"1": {title: "VoIP", body: "<p>Root page</p>", version: 1},
+		"2": {title: "SIP", parent: "1", body: "<p>SIP trunks</p>", version: 1, labels: []string{"sip"}, atts: []stubConfAttachment{
+			{id: "att1", title: "runbook.md", mediaType: "text/markdown", content: "# Restart\nRestart SIP trunk", version: 1},
+			{id: "att2", title: "scheme.png", mediaType: "image/png", content: "png", version: 1},
+		}},
+		"3": {title: "Old", parent: "1", body: "<p>Old page</p>", version: 3},
+		"5": {title: "Codecs", parent: "3", body: "<p>Codecs</p>", version: 1},
+	})
+	link := func(id string) string { return stub.URL + "/pages/viewpage.action?pageId=" + id }
+	attLink := stub.URL + "/download/attachments/2/runbook.md"
+
+	ledger, err := NewLedgerSqlite(filepath.Join(t.TempDir(), "ledger.db"))
+	require.NoError(t, err)
+	state, err := NewConfStateGorm(ledger.DB())
+	require.NoError(t, err)
+	registry := NewProcessorRegistry().Register(models.LogicTypeMarkdown, MarkdownExts, MimeMarkdown)
+	md := NewMarkdownPprocessor(log)
+	cp := NewConfluenceProcessor(log, true).WithPageID("1").
+		WithSync(NewConfluenceClient(resty.New(), stub.URL, "tkn"), state).
+		WithAttachments(func(filename string, mimes ...string) (models.Logic, error) {
+			_, err := registry.Resolve(filename, mimes...)
+			return md, err
+		})
+	store := &fakeItemsWriter{objects: map[string]*w.KnowledgeItem{}}
+	in := NewIngestor(log, store, ledger)
+	items := func() map[string]*w.KnowledgeItem {
+		return lo.MapEntries(store.objects, func(_ string, ki *w.KnowledgeItem) (string, *w.KnowledgeItem) { return ki.URL, ki })
+	}
+
+	diff := in.Run(ctx, cp, true)
+	assert.ElementsMatch(t, []string{link("1"), link("2"), link("3"), link("5"), attLink}, diff.Added)
+	assert.Empty(t, diff.Failed)
+	assert.ElementsMatch(t, []string{"1", "2", "3", "5"}, stub.bodyGets)
+	kis := items()
+	assert.Equal(t, "sip", kis[link("2")].KeyWords)
+	assert.Equal(t, models.CategoryCONF, kis[link("2")].Category)
+	assert.Contains(t, kis[link("2")].Content, "SIP trunks")
+	assert.Equal(t, "sip", kis[attLink].KeyWords, "page labels are keywords of attachments")
+	assert.Equal(t, models.CategoryRunbook, kis[attLink].Category)
+	assert.Contains(t, kis[attLink].Content, "Restart SIP trunk")
+
+	// second sync: SIP is modified, Old is deleted, Codecs is moved under SIP, Trunks is added, root and attachment are unchanged
+	stub.mu.Lock()
+	stub.pages["2"].body, stub.pages["2"].version = "<p>SIP trunks v2</p>", 2
+	stub.pages["5"].parent = "2"
+	delete(stub.pages, "3")
+	stub.pages["4"] = &stubConfPage{title: "Trunks", parent: "2", body: "<p>Trunks</p>", version: 1, labels: []string{"trunk", "sip"}}
+	stub.bodyGets = nil
+	stub.mu.Unlock()
+
+	diff = in.Run(ctx, cp, true)
+	assert.Equal(t, []string{link("4")}, diff.Added)
+	assert.Equal(t, []string{link("2")}, diff.Updated)
+	assert.Equal(t, []string{link("3")}, diff.Removed)
+	assert.ElementsMatch(t, []string{link("1"), link("5"), attLink}, diff.Unchanged)
+	assert.ElementsMatch(t, []string{"2", "4", "5"}, stub.bodyGets, "only modified, new and moved pages are fetched")
+	kis = items()
+	assert.Contains(t, kis[link("2")].Content, "SIP trunks v2")
+	assert.Equal(t, "trunk,sip", kis[link("4")].KeyWords)
+	assert.NotContains(t, kis, link("3"))
+	assert.Len(t, kis, 5)
+
+	states, err := state.List(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "4", "5"}, lo.Map(states, func(s *ConfPageState, _ int) string { return s.PageID }))
+	assert.Equal(t, "2", states[3].ParentID)
+
+	// third sync: attachment is removed, API is unavailable afterwards - known pages are kept
+	stub.mu.Lock()
+	stub.pages["2"].atts = nil
+	stub.bodyGets = nil
+	stub.mu.Unlock()
+	diff = in.Run(ctx, cp, true)
+	assert.Equal(t, []string{attLink}, diff.Removed)
+	assert.Empty(t, stub.bodyGets)
+
+	stub.Close()
+	diff = in.Run(ctx, cp, true)
+	assert.Empty(t, diff.Removed)
+	assert.ElementsMatch(t, []string{link("1"), link("2"), link("4"), link("5")}, diff.Unchanged)
+	assert.Zero(t, stub.unauth)
+}
+
+func TestConfluenceProcessor_AttachmentsKeepSourcesOfFullRuns(t *testing.T) {
+	docx, err := os.ReadFile("../_testdata/docx/Gift-short-2.docx")
+	require.NoError(t, err)
+	configured := filepath.Join(t.TempDir(), "gift.docx")
+	require.NoError(t, os.WriteFile(configured, docx, 0o644))
+	stub := newConfStub(t, map[string]*stubConfPage{
+		"1": {title: "VoIP", body: "<p>Root page</p>", version: 1, atts: []stubConfAttachment{
+			{id: "att1", title: "tariffs.docx", mediaType: MimeDocx, content: string(docx), version: 1},
+		}},
+	})
+
+	ledger, err := NewLedgerSqlite(filepath.Join(t.TempDir(), "ledger.db"))
+	require.NoError(t, err)
+	state, err := NewConfStateGorm(ledger.DB())
+	require.NoError(t, err)
+	rag := NewRAGService(log, &w.KnowledgeBase{}, nil)
+	rag.Files().Register(models.LogicTypeDocx, []string{".docx"}, MimeDocx).
+		RegisterFactory(models.LogicTypeDocx, func() models.Logic { return NewDocxPprocessor(log, resty.New()) })
+	processorDocx := NewDocxPprocessor(log, resty.New()).WithFilePaths(configured)
+	rag.AppendLogic(NewConfluenceProcessor(log, true).WithPageID("1").
+		WithSync(NewConfluenceClient(resty.New(), stub.URL, "tkn"), state).
+		WithAttachments(rag.FileLogic)).
+		AppendLogic(processorDocx)
+	in := NewIngestor(log, &fakeItemsWriter{objects: map[string]*w.KnowledgeItem{}}, ledger)
+
+	for i := 0; i < 2; i++ {
+		for _, logic := range rag.LogicsForDataSources {
+			diff := in.Run(ctx, logic, true)
+			assert.Empty(t, diff.Removed, "run %d of logic type[%d]", i, logic.Type())
+			assert.Empty(t, diff.Failed, "run %d of logic type[%d]", i, logic.Type())
+		}
+	}
+	assert.Equal(t, []string{configured}, processorDocx.filePaths, "attachment does not replace configured files")
+	entries, err := ledger.List(ctx, models.LogicTypeDocx)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	entries, err = ledger.List(ctx, models.LogicTypeConfluence)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "page and its attachment")
+}
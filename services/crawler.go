@@ -0,0 +1,482 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-resty/resty/v2"
+	"github.com/gookit/slog"
+	"github.com/samber/lo"
+	h "gitlab.dev.ict/golang/go-ai/helpers"
+	gl "gitlab.dev.ict/golang/libs/gologgers"
+)
+
+const (
+	DefaultCrawlUserAgent = "go-ai-crawler/1.0"
+	DefaultCrawlMaxPages  = 300
+
+	maxCrawlDelay       = 30 * time.Second // upper bound of robots.txt Crawl-delay
+	maxSitemapIndexDeep = 2
+)
+
+// crawlSkipExts are links to files which are not HTML pages, they are not requested at all.
+var crawlSkipExts = []string{".pdf", ".doc", ".docx", ".xls", ".xlsx", ".zip", ".rar", ".jpg", ".jpeg", ".png", ".gif", ".svg", ".webp", ".mp3", ".mp4", ".css", ".js", ".ico", ".xml"}
+
+// CrawlOptions limits the crawl started from seed URLs. Only hosts of the seeds are crawled.
+type CrawlOptions struct {
+	MaxDepth     int           // depth of links followed from seeds (0 - seeds and sitemap pages only)
+	MaxPages     int           // max count of requested pages per crawl (DefaultCrawlMaxPages if 0)
+	PathPrefixes []string      // discovered pages must have one of the path prefixes (seeds are always crawled)
+	UseSitemap   bool          // discover pages from sitemaps of robots.txt and /sitemap.xml
+	Delay        time.Duration // politeness delay between requests to the same host (robots.txt Crawl-delay if it is greater)
+	UserAgent    string        // User-Agent header and robots.txt agent (DefaultCrawlUserAgent if empty)
+}
+
+// Under returns options which limit crawling to pages under the path of the seed URL, sitemaps of the site are not used.
+func (o CrawlOptions) Under(seed string) CrawlOptions {
+	o.UseSitemap, o.PathPrefixes = false, []string{"/"}
+	if u, err := url.Parse(seed); err == nil && u.Path != "" {
+		o.PathPrefixes = []string{u.Path}
+	}
+	return o
+}
+
+// CrawledPage is a page passed to the visitor. NotModified pages were answered with 304 on conditional GET, Body is empty.
+type CrawledPage struct {
+	URL         string
+	Depth       int
+	Body        []byte
+	NotModified bool
+}
+
+// CrawlStats is a summary of one crawl.
+type CrawlStats struct {
+	Fetched     int `json:"fetched"`
+	NotModified int `json:"notModified"`
+	Disallowed  int `json:"disallowed"`
+	Failed      int `json:"failed"`
+	FromSitemap int `json:"fromSitemap"`
+}
+
+func (s CrawlStats) String() string {
+	return fmt.Sprintf("CrawlStats={fetched=%d, notModified=%d, disallowed=%d, failed=%d, fromSitemap=%d}", s.Fetched, s.NotModified, s.Disallowed, s.Failed, s.FromSitemap)
+}
+
+// pageValidator keeps ETag/Last-Modified of the page for conditional GET on re-crawl, links are reused when page is not modified.
+type pageValidator struct {
+	etag         string
+	lastModified string
+	links        []string
+}
+
+// Crawler walks a site breadth-first from seed URLs honouring robots.txt.
+// Validators of the pages are kept between crawls, so unchanged pages are not downloaded again.
+type Crawler struct {
+	log        *gl.Logger
+	client     *resty.Client
+	opts       CrawlOptions
+	mu         sync.Mutex
+	validators map[string]*pageValidator
+}
+
+func NewCrawler(log *gl.Logger, client *resty.Client, opts CrawlOptions) *Crawler {
+	if opts.MaxPages <= 0 {
+		opts.MaxPages = DefaultCrawlMaxPages
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultCrawlUserAgent
+	}
+	return &Crawler{log: log, client: client, opts: opts, validators: map[string]*pageValidator{}}
+}
+
+func (c *Crawler) String() string {
+	return fmt.Sprintf("Crawler={maxDepth=%d, maxPages=%d, prefixes=%v, sitemap=%t, delay=%s}", c.opts.MaxDepth, c.opts.MaxPages, c.opts.PathPrefixes, c.opts.UseSitemap, c.opts.Delay)
+}
+
+// crawlRun is a state of one crawl: robots rules and time of the last request per host.
+type crawlRun struct {
+	*Crawler
+	log     *slog.Record
+	hosts   map[string]string // host -> scheme of the seed
+	robots  map[string]*robotsRules
+	lastHit map[string]time.Time
+	stats   CrawlStats
+}
+
+type crawlTask struct {
+	url   string
+	depth int
+}
+
+// Crawl requests seeds, pages of sitemaps and links found on the pages (up to MaxDepth) and passes them to visit.
+func (c *Crawler) Crawl(ctx context.Context, seeds []string, visit func(p *CrawledPage)) CrawlStats {
+	r := &crawlRun{Crawler: c, log: c.log.RecWithCtx(ctx, "crawler"), hosts: map[string]string{}, robots: map[string]*robotsRules{}, lastHit: map[string]time.Time{}}
+	r.log.Infof("Start crawl: %s seeds=%v", c, seeds)
+
+	var queue []crawlTask
+	seen := map[string]bool{}
+	enqueue := func(u string, depth int) bool {
+		if seen[u] {
+			return false
+		}
+		seen[u] = true
+		queue = append(queue, crawlTask{u, depth})
+		return true
+	}
+	for _, s := range seeds {
+		u, ok := normalizeLink(nil, s)
+		if !ok {
+			r.log.Warnf("Skip invalid seed %q", s)
+			continue
+		}
+		if host := hostOf(u); r.hosts[host] == "" {
+			r.hosts[host] = strings.SplitN(u, ":", 2)[0]
+		}
+		enqueue(u, 0)
+	}
+	if c.opts.UseSitemap {
+		for _, host := range lo.Keys(r.hosts) {
+			for _, u := range r.sitemapURLs(ctx, host) {
+				if r.inScope(u) && enqueue(u, 0) {
+					r.stats.FromSitemap++
+				}
+			}
+		}
+	}
+
+	for len(queue) > 0 && ctx.Err() == nil && r.stats.Fetched+r.stats.NotModified < c.opts.MaxPages {
+		t := queue[0]
+		queue = queue[1:]
+		if !r.robotsFor(ctx, t.url).allowed(t.url) {
+			r.log.Debugf("Disallowed by robots.txt: %s", t.url)
+			r.stats.Disallowed++
+			continue
+		}
+		p, links, err := r.fetchPage(ctx, t)
+		if err != nil {
+			r.log.Warnf("Fetch page %s failed: %v", t.url, err)
+			r.stats.Failed++
+			continue
+		}
+		visit(p)
+		if t.depth >= c.opts.MaxDepth {
+			continue
+		}
+		for _, l := range links {
+			if r.inScope(l) {
+				enqueue(l, t.depth+1)
+			}
+		}
+	}
+	r.log.Infof("Finish crawl: %s queued=%d", r.stats, len(queue))
+	return r.stats
+}
+
+// fetchPage requests the page with If-None-Match/If-Modified-Since of the previous crawl.
+func (r *crawlRun) fetchPage(ctx context.Context, t crawlTask) (*CrawledPage, []string, error) {
+	r.mu.Lock()
+	prev := r.validators[t.url]
+	r.mu.Unlock()
+
+	headers := map[string]string{}
+	if prev != nil {
+		if prev.etag != "" {
+			headers["If-None-Match"] = prev.etag
+		}
+		if prev.lastModified != "" {
+			headers["If-Modified-Since"] = prev.lastModified
+		}
+	}
+	resp, err := r.get(ctx, t.url, headers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case resp.StatusCode() == http.StatusNotModified && prev != nil:
+		r.stats.NotModified++
+		return &CrawledPage{URL: t.url, Depth: t.depth, NotModified: true}, prev.links, nil
+	case resp.StatusCode() != http.StatusOK:
+		return nil, nil, fmt.Errorf("status %s", resp.Status())
+	case !strings.Contains(resp.Header().Get("Content-Type"), "html"):
+		return nil, nil, fmt.Errorf("not HTML content type %q", resp.Header().Get("Content-Type"))
+	}
+
+	r.stats.Fetched++
+	body := resp.Body()
+	links := extractLinks(resp.RawResponse.Request.URL, body)
+	r.mu.Lock()
+	r.validators[t.url] = &pageValidator{etag: resp.Header().Get("ETag"), lastModified: resp.Header().Get("Last-Modified"), links: links}
+	r.mu.Unlock()
+	return &CrawledPage{URL: t.url, Depth: t.depth, Body: body}, links, nil
+}
+
+// get makes request after the politeness delay for the host.
+func (r *crawlRun) get(ctx context.Context, u string, headers map[string]string) (*resty.Response, error) {
+	host := hostOf(u)
+	delay := r.opts.Delay
+	if rules := r.robots[host]; rules != nil && rules.crawlDelay > delay {
+		delay = rules.crawlDelay
+	}
+	if last, ok := r.lastHit[host]; ok {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Until(last.Add(delay))):
+		}
+	}
+	defer func() { r.lastHit[host] = time.Now() }()
+	return r.client.R().SetContext(ctx).SetHeader("User-Agent", r.opts.UserAgent).SetHeaders(headers).Get(u)
+}
+
+// inScope reports whether discovered link is on the crawled hosts and has one of the path prefixes.
+func (r *crawlRun) inScope(u string) bool {
+	pu, err := url.Parse(u)
+	if err != nil || r.hosts[strings.ToLower(pu.Host)] == "" || lo.Contains(crawlSkipExts, strings.ToLower(path.Ext(pu.Path))) {
+		return false
+	}
+	return len(r.opts.PathPrefixes) == 0 || lo.SomeBy(r.opts.PathPrefixes, func(p string) bool { return strings.HasPrefix(pu.Path, p) })
+}
+
+// robotsFor returns (and loads once per crawl) robots.txt rules of the URL host.
+// Missing robots.txt allows everything, server errors disallow the host.
+func (r *crawlRun) robotsFor(ctx context.Context, u string) *robotsRules {
+	host := hostOf(u)
+	if rules, ok := r.robots[host]; ok {
+		return rules
+	}
+	pu, _ := url.Parse(u)
+	robotsURL := pu.Scheme + "://" + pu.Host + "/robots.txt"
+	rules := &robotsRules{}
+	resp, err := r.get(ctx, robotsURL, nil)
+	switch {
+	case err != nil:
+		r.log.Warnf("Get %s failed, host is not crawled: %v", robotsURL, err)
+		rules.disallowAll = true
+	case resp.StatusCode() >= 500:
+		r.log.Warnf("Get %s failed, host is not crawled: status %s", robotsURL, resp.Status())
+		rules.disallowAll = true
+	case resp.StatusCode() == http.StatusOK:
+		rules = parseRobots(string(resp.Body()), r.opts.UserAgent)
+		r.log.Infof("Loaded %s: rules=%d crawlDelay=%s sitemaps=%v", robotsURL, len(rules.rules), rules.crawlDelay, rules.sitemaps)
+	}
+	if rules.crawlDelay > maxCrawlDelay {
+		rules.crawlDelay = maxCrawlDelay
+	}
+	r.robots[host] = rules
+	return rules
+}
+
+// sitemapURLs returns page URLs of sitemaps declared in robots.txt or /sitemap.xml of the host (sitemap indexes are followed).
+func (r *crawlRun) sitemapURLs(ctx context.Context, host string) (res []string) {
+	base := r.hosts[host] + "://" + host
+	sitemaps := r.robotsFor(ctx, base+"/").sitemaps
+	if len(sitemaps) == 0 {
+		sitemaps = []string{base + "/sitemap.xml"}
+	}
+	seen := map[string]bool{}
+	var walk func(u string, deep int)
+	walk = func(u string, deep int) {
+		if seen[u] || deep > maxSitemapIndexDeep || len(res) >= r.opts.MaxPages {
+			return
+		}
+		seen[u] = true
+		resp, err := r.get(ctx, u, nil)
+		if err == nil && resp.StatusCode() != http.StatusOK {
+			err = fmt.Errorf("status %s", resp.Status())
+		}
+		if err != nil {
+			r.log.Warnf("Get sitemap %s failed: %v", u, err)
+			return
+		}
+		var sm sitemapXML
+		if err = xml.Unmarshal(resp.Body(), &sm); err != nil {
+			r.log.Warnf("Parse sitemap %s failed: %v", u, err)
+			return
+		}
+		for _, l := range sm.URLs {
+			if nu, ok := normalizeLink(nil, l.Loc); ok {
+				res = append(res, nu)
+			}
+		}
+		for _, s := range sm.Sitemaps {
+			walk(strings.TrimSpace(s.Loc), deep+1)
+		}
+	}
+	for _, s := range sitemaps {
+		walk(s, 0)
+	}
+	r.log.Infof("Sitemaps of %s: %v => urls=%d", host, sitemaps, len(res))
+	return
+}
+
+// sitemapXML is <urlset> or <sitemapindex> document.
+type sitemapXML struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// extractLinks returns normalized absolute links of the page.
+func extractLinks(base *url.URL, body []byte) []string {
+	d, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	if href, ok := d.Find("base[href]").First().Attr("href"); ok {
+		if bu, err := base.Parse(href); err == nil {
+			base = bu
+		}
+	}
+	return lo.Uniq(lo.FilterMap(h.GetAllLinksAsArr(d.Selection), func(l string, _ int) (string, bool) { return normalizeLink(base, l) }))
+}
+
+// normalizeLink resolves link against base and drops fragment, only http(s) links are valid.
+func normalizeLink(base *url.URL, link string) (string, bool) {
+	link = strings.TrimSpace(link)
+	if link == "" || strings.HasPrefix(link, "#") {
+		return "", false
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return "", false
+	}
+	u.Host, u.Fragment, u.RawFragment = strings.ToLower(u.Host), "", ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String(), true
+}
+
+func hostOf(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(pu.Host)
+}
+
+// robotsRules are rules of robots.txt group which applies to the crawler.
+type robotsRules struct {
+	disallowAll bool
+	rules       []robotsRule
+	crawlDelay  time.Duration
+	sitemaps    []string
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+// allowed applies the most specific (longest) matching rule, Allow wins on equal length.
+func (rr *robotsRules) allowed(u string) bool {
+	if rr.disallowAll {
+		return false
+	}
+	pu, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	target := pu.RequestURI()
+	var best *robotsRule
+	for i := range rr.rules {
+		rule := &rr.rules[i]
+		if !rule.re.MatchString(target) {
+			continue
+		}
+		if best == nil || len(rule.pattern) > len(best.pattern) || len(rule.pattern) == len(best.pattern) && rule.allow {
+			best = rule
+		}
+	}
+	return best == nil || best.allow
+}
+
+// parseRobots parses robots.txt: groups of the agent (product token of userAgent) are used, "*" groups otherwise.
+func parseRobots(content, userAgent string) *robotsRules {
+	type group struct {
+		agents     []string
+		rules      []robotsRule
+		crawlDelay time.Duration
+	}
+	var (
+		groups   []*group
+		cur      *group
+		sitemaps []string
+	)
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if cur == nil || len(cur.rules) > 0 || cur.crawlDelay > 0 {
+				cur = &group{}
+				groups = append(groups, cur)
+			}
+			cur.agents = append(cur.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if cur != nil && value != "" {
+				cur.rules = append(cur.rules, robotsRule{allow: key == "allow", pattern: value, re: robotsPattern(value)})
+			}
+		case "crawl-delay":
+			if sec, err := strconv.ParseFloat(value, 64); cur != nil && err == nil && sec > 0 {
+				cur.crawlDelay = time.Duration(sec * float64(time.Second))
+			}
+		case "sitemap":
+			sitemaps = append(sitemaps, value)
+		}
+	}
+
+	token := strings.ToLower(strings.SplitN(userAgent, "/", 2)[0])
+	match := func(agent string) func(g *group) bool {
+		return func(g *group) bool { return lo.Contains(g.agents, agent) }
+	}
+	selected := lo.Filter(groups, func(g *group, _ int) bool { return match(token)(g) })
+	if len(selected) == 0 {
+		selected = lo.Filter(groups, func(g *group, _ int) bool { return match("*")(g) })
+	}
+	res := &robotsRules{sitemaps: sitemaps}
+	for _, g := range selected {
+		res.rules = append(res.rules, g.rules...)
+		res.crawlDelay = max(res.crawlDelay, g.crawlDelay)
+	}
+	return res
+}
+
+// robotsPattern converts path pattern with "*" and "$" wildcards to regexp matched from the path start.
+func robotsPattern(p string) *regexp.Regexp {
+	anchored := strings.HasSuffix(p, "$")
+	p = strings.TrimSuffix(p, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.dev.ict/golang/go-ai/models"
+)
+
+// testSite is a stand-in site: robots.txt, sitemap index, pages under /docs/ and /docs/a with ETag.
+type testSite struct {
+	*httptest.Server
+	mu       sync.Mutex
+	hits     map[string]int
+	agents   map[string]bool
+	notModif int
+}
+
+func newTestSite(t *testing.T) *testSite {
+	s := &testSite{hits: map[string]int{}, agents: map[string]bool{}}
+	page := func(links ...string) string {
+		var b strings.Builder
+		b.WriteString("<html><head><title>page</title></head><body><h1>Title</h1>")
+		for _, l := range links {
+			fmt.Fprintf(&b, `<p><a href="%s">link</a></p>`, l)
+		}
+		b.WriteString("<p>text</p></body></html>")
+		return b.String()
+	}
+	pages := map[string]string{
+		"/docs/":               page("a", "/docs/#top", "mailto:x@y.z"),
+		"/docs/a":              page("/docs/b", "/docs/private/secret", "/docs/private/open", "/blog/x", "http://other.example/docs/", "/docs/file.pdf"),
+		"/docs/b":              page("/docs/c"),
+		"/docs/c":              page(),
+		"/docs/orphan":         page(),
+		"/docs/private/open":   page(),
+		"/docs/private/secret": page(),
+		"/blog/x":              page(),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.hits[r.URL.Path]++
+		s.agents[r.UserAgent()] = true
+		s.mu.Unlock()
+		base := "http://" + r.Host
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: other\nDisallow: /\n\nUser-agent: *\nDisallow: /docs/private/\nAllow: /docs/private/open\nCrawl-delay: 0.01\n\nSitemap: %s/sitemap_index.xml\n", base)
+		case "/sitemap_index.xml":
+			fmt.Fprintf(w, `<?xml version="1.0"?><sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><sitemap><loc>%s/sitemap1.xml</loc></sitemap></sitemapindex>`, base)
+		case "/sitemap1.xml":
+			fmt.Fprintf(w, `<?xml version="1.0"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%[1]s/docs/orphan</loc></url><url><loc>%[1]s/blog/x</loc></url></urlset>`, base)
+		default:
+			body, ok := pages[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			if r.URL.Path == "/docs/a" {
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					s.mu.Lock()
+					s.notModif++
+					s.mu.Unlock()
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", `"v1"`)
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, body)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestCrawler_Crawl(t *testing.T) {
+	site := newTestSite(t)
+	c := NewCrawler(log, resty.New(), CrawlOptions{MaxDepth: 2, PathPrefixes: []string{"/docs/"}, UseSitemap: true, Delay: time.Millisecond})
+
+	crawl := func() (urls []string, notModified []string, stats CrawlStats) {
+		stats = c.Crawl(context.Background(), []string{site.URL + "/docs/"}, func(p *CrawledPage) {
+			urls = append(urls, strings.TrimPrefix(p.URL, site.URL))
+			if p.NotModified {
+				notModified = append(notModified, strings.TrimPrefix(p.URL, site.URL))
+			}
+		})
+		return
+	}
+
+	urls, notModified, stats := crawl()
+	assert.Equal(t, []string{"/docs/", "/docs/orphan", "/docs/a", "/docs/b", "/docs/private/open"}, urls)
+	assert.Empty(t, notModified)
+	assert.Equal(t, CrawlStats{Fetched: 5, Disallowed: 1, FromSitemap: 1}, stats)
+	assert.Zero(t, site.hits["/docs/c"], "deeper than MaxDepth")
+	assert.Zero(t, site.hits["/blog/x"], "out of path prefix")
+	assert.Zero(t, site.hits["/docs/private/secret"], "disallowed by robots.txt")
+	assert.Zero(t, site.hits["/docs/file.pdf"])
+	assert.Equal(t, map[string]bool{DefaultCrawlUserAgent: true}, site.agents)
+
+	// re-crawl: /docs/a is not modified, its links are taken from the previous crawl
+	urls, notModified, stats = crawl()
+	assert.Equal(t, []string{"/docs/", "/docs/orphan", "/docs/a", "/docs/b", "/docs/private/open"}, urls)
+	assert.Equal(t, []string{"/docs/a"}, notModified)
+	assert.Equal(t, 1, stats.NotModified)
+	assert.Equal(t, 1, site.notModif)
+}
+
+func TestCrawler_MaxPagesAndCancel(t *testing.T) {
+	site := newTestSite(t)
+	c := NewCrawler(log, resty.New(), CrawlOptions{MaxDepth: 5, MaxPages: 2})
+	var urls []string
+	c.Crawl(context.Background(), []string{site.URL + "/docs/"}, func(p *CrawledPage) { urls = append(urls, p.URL) })
+	assert.Len(t, urls, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	urls = nil
+	c.Crawl(ctx, []string{site.URL + "/docs/"}, func(p *CrawledPage) { urls = append(urls, p.URL) })
+	assert.Empty(t, urls)
+}
+
+func TestParseRobots(t *testing.T) {
+	content := `# comment
+User-agent: *
+Disallow: /
+
+User-agent: Go-AI-Crawler
+User-agent: bot
+Disallow: /private   # inline comment
+Allow: /private/public
+Disallow: /*.json$
+Disallow:
+Crawl-delay: 2
+
+Sitemap: https://site/sitemap.xml
+`
+	rr := parseRobots(content, "go-ai-crawler/1.0")
+	assert.Equal(t, 2*time.Second, rr.crawlDelay)
+	assert.Equal(t, []string{"https://site/sitemap.xml"}, rr.sitemaps)
+	for u, allowed := range map[string]bool{
+		"https://site/":                    true,
+		"https://site/private":             false,
+		"https://site/private/x":           false,
+		"https://site/private/public/page": true,
+		"https://site/data.json":           false,
+		"https://site/data.json?x=1":       true,
+	} {
+		assert.Equal(t, allowed, rr.allowed(u), u)
+	}
+
+	rr = parseRobots(content, "unknown")
+	assert.False(t, rr.allowed("https://site/"), "group * is used for unknown agent")
+	assert.True(t, parseRobots("", "unknown").allowed("https://site/"))
+}
+
+func TestNormalizeLink(t *testing.T) {
+	base, _ := url.Parse("https://Site.UA/uk/a/")
+	for in, want := range map[string]string{
+		"b?x=1#frag":            "https://site.ua/uk/a/b?x=1",
+		"/c":                    "https://site.ua/c",
+		"https://site.ua":       "https://site.ua/",
+		"//cdn.site.ua/x":       "https://cdn.site.ua/x",
+		"mailto:a@b.c":          "",
+		"javascript:void(0)":    "",
+		"#top":                  "",
+		"ftp://site.ua/file.gz": "",
+	} {
+		got, ok := normalizeLink(base, in)
+		assert.Equal(t, want != "", ok, in)
+		assert.Equal(t, want, got, in)
+	}
+}
+
+func TestWebPagesProcessor_Crawl(t *testing.T) {
+	site := newTestSite(t)
+	wpp := NewWPPOther(log).WithHttpCl(resty.New()).WithWebURLs([]string{site.URL + "/docs/"}).
+		WithCrawl(CrawlOptions{MaxDepth: 1, PathPrefixes: []string{"/docs/"}})
+
+	process := func() map[string]*models.Doc {
+		docs := map[string]*models.Doc{}
+		wpp.Process(context.Background(), func(_ context.Context, d *models.Doc) { docs[strings.TrimPrefix(d.Link, site.URL)] = d })
+		return docs
+	}
+	first := process()
+	require.Len(t, first, 2)
+	assert.Contains(t, first["/docs/a"].TextContent, "Title")
+	assert.Equal(t, models.CategoryWEB, first["/docs/a"].Category())
+
+	second := process()
+	require.Len(t, second, 2, "not modified page is passed to savers again")
+	assert.Same(t, first["/docs/a"], second["/docs/a"])
+	assert.Equal(t, 1, site.notModif)
+}
+
+func TestCrawlOptions_Under(t *testing.T) {
+	opts := CrawlOptions{MaxDepth: 2, PathPrefixes: Prefixes_LifecellUA, UseSitemap: true}
+	assert.Equal(t, CrawlOptions{MaxDepth: 2, PathPrefixes: []string{"/uk/tariffs/"}}, opts.Under("https://lifecell.ua/uk/tariffs/?utm=x"))
+	assert.Equal(t, []string{"/"}, opts.Under("https://example.com").PathPrefixes)
+	assert.True(t, opts.UseSitemap, "options are not modified")
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gookit/slog"
+	"gitlab.dev.ict/golang/go-ai/logic/ailogic/llm"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Enricher generates metadata of the document text (see llm.LLMEnricher).
+type Enricher interface {
+	Enrich(ctx context.Context, title, content string) (*llm.Enrichment, error)
+}
+
+// EnrichCacheEntry is a cached enrichment of the content.
+type EnrichCacheEntry struct {
+	ContentHash string         `gorm:"primaryKey;size:64"`
+	Enrichment  llm.Enrichment `gorm:"embedded"`
+	CreatedAt   time.Time
+}
+
+func (EnrichCacheEntry) TableName() string { return "ingest_enrichment" }
+
+// EnrichCache stores enrichments by content hash, so unchanged content is not sent to LLM again.
+type EnrichCache interface {
+	// Get returns cached enrichment or nil.
+	Get(ctx context.Context, hash string) (*llm.Enrichment, error)
+	Put(ctx context.Context, hash string, e *llm.Enrichment) error
+}
+
+// EnrichCacheGorm is EnrichCache persisted with gorm (in the same DB as the ledger).
+type EnrichCacheGorm struct {
+	db *gorm.DB
+}
+
+func NewEnrichCacheGorm(db *gorm.DB) (*EnrichCacheGorm, error) {
+	if err := db.AutoMigrate(&EnrichCacheEntry{}); err != nil {
+		return nil, err
+	}
+	return &EnrichCacheGorm{db: db}, nil
+}
+
+func (c *EnrichCacheGorm) Get(ctx context.Context, hash string) (*llm.Enrichment, error) {
+	var e EnrichCacheEntry
+	err := c.db.WithContext(ctx).Where("content_hash = ?", hash).First(&e).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e.Enrichment, nil
+}
+
+func (c *EnrichCacheGorm) Put(ctx context.Context, hash string, e *llm.Enrichment) error {
+	return c.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&EnrichCacheEntry{ContentHash: hash, Enrichment: *e}).Error
+}
+
+// memEnrichCache is EnrichCache in memory.
+type memEnrichCache struct {
+	m sync.Map
+}
+
+func (c *memEnrichCache) Get(_ context.Context, hash string) (*llm.Enrichment, error) {
+	if v, ok := c.m.Load(hash); ok {
+		return v.(*llm.Enrichment), nil
+	}
+	return nil, nil
+}
+
+func (c *memEnrichCache) Put(_ context.Context, hash string, e *llm.Enrichment) error {
+	c.m.Store(hash, e)
+	return nil
+}
+
+// ContentHash returns sha256 of the chunk title and content, key of EnrichCache.
+func ContentHash(title, content string) string {
+	h := sha256.New()
+	h.Write([]byte(title))
+	h.Write([]byte{0})
+	h.Write([]byte(content))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// enrichItems fills empty summary, keywords, language and category of chunks with the enricher.
+// Cached enrichments are reused, errors are logged and do not stop ingestion.
+func (in *Ingestor) enrichItems(ctx context.Context, log *slog.Record, items []*w.KnowledgeItem) {
+	for _, item := range items {
+		if item.Summary != "" && item.KeyWords != "" && item.Language != "" && item.Category != "" || item.Content == "" {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return
+		}
+
+		hash := ContentHash(item.Title, item.Content)
+		e, err := in.enrichCache.Get(ctx, hash)
+		if err != nil {
+			log.Warnf("Enrich cache get[%s] failed: %v", item.Title, err)
+		}
+		if e == nil {
+			if e, err = in.enricher.Enrich(ctx, item.Title, item.Content); err != nil {
+				log.Errorf("Enrich chunk[%s#%d] failed: %v", item.Title, item.ChunkNo, err)
+				continue
+			}
+			if err = in.enrichCache.Put(ctx, hash, e); err != nil {
+				log.Warnf("Enrich cache put[%s] failed: %v", item.Title, err)
+			}
+		}
+
+		fill := func(dst *string, v string) {
+			if *dst == "" {
+				*dst = v
+			}
+		}
+		fill(&item.Summary, e.Summary)
+		fill(&item.KeyWords, e.Keywords)
+		fill(&item.Language, e.Language)
+		fill(&item.Category, e.Category)
+	}
+}
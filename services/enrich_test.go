@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.dev.ict/golang/go-ai/logic/ailogic/llm"
+	"gitlab.dev.ict/golang/go-ai/models"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
+)
+
+type fakeEnricher struct{ calls []string }
+
+func (f *fakeEnricher) Enrich(_ context.Context, title, _ string) (*llm.Enrichment, error) {
+	f.calls = append(f.calls, title)
+	return &llm.Enrichment{Summary: "summary of " + title, Keywords: "k1,k2", Language: "uk", Category: models.CategoryWEB}, nil
+}
+
+func TestIngestor_Enrich(t *testing.T) {
+	ledger, err := NewLedgerSqlite(filepath.Join(t.TempDir(), "ledger.db"))
+	require.NoError(t, err)
+	cache, err := NewEnrichCacheGorm(ledger.DB())
+	require.NoError(t, err)
+	store := &fakeItemsWriter{objects: map[string]*w.KnowledgeItem{}}
+	enricher := &fakeEnricher{}
+	in := NewIngestor(log, store, ledger).WithEnricher(enricher, cache)
+
+	logic := &fakeLogic{docs: []*models.Doc{
+		models.NewDoc("A", "content A", "https://x/a").WithCategory(models.CategoryCONF),
+		models.NewDoc("B", "content B", "https://x/b").WithSummary("own summary"),
+	}}
+	in.Run(ctx, logic, true)
+	assert.Equal(t, []string{"A", "B"}, enricher.calls)
+
+	a := store.objects[w.ItemUUID("https://x/a", 0).String()]
+	assert.Equal(t, "summary of A", a.Summary)
+	assert.Equal(t, "k1,k2", a.KeyWords)
+	assert.Equal(t, "uk", a.Language)
+	assert.Equal(t, models.CategoryCONF, a.Category, "category of the processor is kept")
+	b := store.objects[w.ItemUUID("https://x/b", 0).String()]
+	assert.Equal(t, "own summary", b.Summary)
+	assert.Equal(t, models.CategoryWEB, b.Category)
+
+	// the same content of another source and re-ingestion without ledger entry are served from the cache
+	enricher.calls = nil
+	require.NoError(t, ledger.Delete(ctx, "https://x/a"))
+	logic.docs = append(logic.docs, models.NewDoc("A", "content A", "https://y/a"))
+	in.Run(ctx, logic, true)
+	assert.Empty(t, enricher.calls)
+	assert.Equal(t, "summary of A", store.objects[w.ItemUUID("https://y/a", 0).String()].Summary)
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gookit/goutil/errorx"
+	"github.com/gookit/slog"
+	"github.com/samber/lo"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"gitlab.dev.ict/golang/go-ai/models"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
+	gl "gitlab.dev.ict/golang/libs/gologgers"
+)
+
+const chIngest = "ingest"
+
+// ErrDocExpired is reported for documents which valid-until date is passed, such documents are removed from vector DB.
+var ErrDocExpired = errors.New("document is expired")
+
+// ItemsWriter is the part of vector DB used by Ingestor.
+type ItemsWriter interface {
+	SplitItem(log *slog.Record, item *w.KnowledgeItem) []*w.KnowledgeItem
+	UpsertItems(ctx context.Context, sourceURI string, items ...*w.KnowledgeItem) ([]string, error)
+	DeleteObjects(ctx context.Context, ids ...string) error
+	DeleteWhere(ctx context.Context, where *filters.WhereBuilder) (int64, error)
+}
+
+var (
+	_ ItemsWriter = (*w.KnowledgeBase)(nil)
+	_ ItemsWriter = (*w.MemoryStore)(nil)
+)
+
+// IngestDiff is a report of the ingestion run.
+type IngestDiff struct {
+	LogicType models.LogicType  `json:"logicType"`
+	Added     []string          `json:"added"`
+	Updated   []string          `json:"updated"`
+	Removed   []string          `json:"removed"`
+	Unchanged []string          `json:"unchanged"`
+	Failed    map[string]string `json:"failed,omitempty"`
+	Skipped   map[string]string `json:"skipped,omitempty"`
+	Elapsed   string            `json:"elapsed"`
+
+	mu     sync.Mutex
+	seen   map[string]bool
+	origin string
+}
+
+func NewIngestDiff(t models.LogicType) *IngestDiff {
+	return &IngestDiff{LogicType: t, Failed: map[string]string{}, Skipped: map[string]string{}, seen: map[string]bool{}, origin: OriginUpload}
+}
+
+func (d *IngestDiff) String() string {
+	return fmt.Sprintf("IngestDiff[type=%d]: added=%d updated=%d removed=%d unchanged=%d failed=%d skipped=%d elapsed=%s",
+		d.LogicType, len(d.Added), len(d.Updated), len(d.Removed), len(d.Unchanged), len(d.Failed), len(d.Skipped), d.Elapsed)
+}
+
+func (d *IngestDiff) append(list *[]string, uri string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	*list = append(*list, uri)
+}
+
+func (d *IngestDiff) fail(uri string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Failed[uri] = err.Error()
+}
+
+func (d *IngestDiff) skip(uri string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Skipped[uri] = err.Error()
+}
+
+func (d *IngestDiff) markSeen(uri string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.seen[uri] = true
+}
+
+func (d *IngestDiff) isSeen(uri string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.seen[uri]
+}
+
+func (d *IngestDiff) countSeen() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.seen)
+}
+
+// Ingestor saves documents produced by models.Logic into vector DB incrementally, using Ledger:
+// unchanged documents are skipped, changed are overwritten, removed sources are deleted.
+// Without ledger every document is overwritten and nothing is removed.
+type Ingestor struct {
+	log         *gl.Logger
+	db          ItemsWriter
+	ledger      Ledger
+	enricher    Enricher
+	enrichCache EnrichCache
+}
+
+func NewIngestor(log *gl.Logger, db ItemsWriter, ledger Ledger) *Ingestor {
+	return &Ingestor{log: log, db: db, ledger: ledger}
+}
+
+// WithEnricher enables enrichment of chunks (summary, keywords, language, category) before saving.
+// Without cache enrichments are cached in memory.
+func (in *Ingestor) WithEnricher(e Enricher, cache EnrichCache) *Ingestor {
+	in.enricher, in.enrichCache = e, cache
+	if in.enrichCache == nil {
+		in.enrichCache = &memEnrichCache{}
+	}
+	return in
+}
+
+// Run processes all documents of the logic.
+// If isFull is true, documents are ingested as configured sources, and configured sources of the same logic type
+// which were not produced during this run are removed from vector DB. Otherwise documents are ingested as uploads,
+// which are removed only explicitly.
+func (in *Ingestor) Run(ctx context.Context, logic models.Logic, isFull bool, csf ...models.ContentSaverFunc) *IngestDiff {
+	log := in.log.RecWithCtx(ctx, chIngest)
+	start := time.Now()
+	diff := NewIngestDiff(logic.Type())
+	if isFull {
+		diff.origin = OriginConfigured
+	}
+
+	logic.Process(ctx, append([]models.ContentSaverFunc{in.Saver(diff)}, csf...)...)
+	if isFull && in.ledger != nil {
+		in.removeUnseen(ctx, log, diff)
+	}
+
+	diff.Elapsed = time.Since(start).String()
+	log.Infof("Finish ingestion: %s", diff)
+	return diff
+}
+
+// Saver returns ContentSaverFunc which upserts document only when its content hash differs from the ledger.
+func (in *Ingestor) Saver(diff *IngestDiff) models.ContentSaverFunc {
+	return func(ctx context.Context, d *models.Doc) {
+		log := in.log.RecWithCtx(ctx, chIngest)
+		uri := DocSourceURI(d)
+		diff.markSeen(uri)
+
+		if d.IsErrorLoading() && errors.Is(d.ErrorLoading(), ErrUnsupportedFile) {
+			log.Infof("Skip doc[%s]: %v", uri, d.ErrorLoading())
+			diff.skip(uri, d.ErrorLoading())
+			return
+		}
+		if d.IsErrorLoading() {
+			log.Warnf("Skip doc[%s] with loading error: %v", uri, d.ErrorLoading())
+			diff.fail(uri, d.ErrorLoading())
+			return
+		}
+		if d.IsExpired(time.Now()) {
+			in.expire(ctx, log, uri, diff)
+			return
+		}
+		if d.IsUnchanged() {
+			in.keep(ctx, log, uri, diff)
+			return
+		}
+
+		if in.ledger == nil {
+			if ids, err := in.upsert(ctx, log, uri, d, nil); err != nil {
+				log.Errorf("Upsert doc[%s] failed: %v", uri, err)
+				diff.fail(uri, err)
+			} else {
+				diff.append(&diff.Added, uri)
+				log.Infof("Doc[%s] saved: chunks=%d", uri, len(ids))
+			}
+			return
+		}
+
+		entry, err := in.ledger.Get(ctx, uri)
+		if err != nil {
+			log.Errorf("Ledger get[%s] failed: %v", uri, err)
+			diff.fail(uri, err)
+			return
+		}
+
+		hash := DocHash(d)
+		if entry != nil && diff.origin == OriginConfigured {
+			entry.Origin = OriginConfigured // uploaded source which is configured now
+		}
+		if entry != nil && entry.ContentHash == hash {
+			in.touch(ctx, log, entry)
+			diff.append(&diff.Unchanged, uri)
+			return
+		}
+
+		ids, err := in.upsert(ctx, log, uri, d, entry)
+		if err != nil {
+			log.Errorf("Upsert doc[%s] failed: %v", uri, err)
+			diff.fail(uri, err)
+			return
+		}
+
+		isNew := entry == nil
+		if isNew {
+			entry = &LedgerEntry{SourceURI: uri, LogicType: diff.LogicType, Origin: diff.origin}
+		}
+		entry.Title, entry.ContentHash, entry.ObjectIDs, entry.LastSeen = d.Title, hash, ids, time.Now()
+		if err = in.ledger.Save(ctx, entry); err != nil {
+			log.Errorf("Ledger save[%s] failed: %v", uri, err)
+			diff.fail(uri, err)
+			return
+		}
+		diff.append(lo.Ternary(isNew, &diff.Added, &diff.Updated), uri)
+		log.Infof("Doc[%s] saved: isNew=%t chunks=%d", uri, isNew, len(ids))
+	}
+}
+
+// keep handles source reported as unchanged by incremental processor: it is not re-read, only marked as seen.
+func (in *Ingestor) keep(ctx context.Context, log *slog.Record, uri string, diff *IngestDiff) {
+	if in.ledger != nil {
+		if entry, err := in.ledger.Get(ctx, uri); err != nil {
+			log.Errorf("Ledger get[%s] failed: %v", uri, err)
+		} else if entry != nil {
+			in.touch(ctx, log, entry)
+		}
+	}
+	diff.append(&diff.Unchanged, uri)
+}
+
+// expire removes the indexed expired document, so it is not served anymore. Expired document which is not indexed is skipped.
+func (in *Ingestor) expire(ctx context.Context, log *slog.Record, uri string, diff *IngestDiff) {
+	if in.ledger != nil {
+		entry, err := in.ledger.Get(ctx, uri)
+		if err != nil {
+			log.Errorf("Ledger get[%s] failed: %v", uri, err)
+			diff.fail(uri, err)
+			return
+		}
+		if entry != nil {
+			if err = in.Remove(ctx, entry); err != nil {
+				log.Errorf("Remove expired doc[%s] failed: %v", uri, err)
+				diff.fail(uri, err)
+				return
+			}
+			log.Warnf("Expired doc[%s] removed", uri)
+			diff.append(&diff.Removed, uri)
+			return
+		}
+	}
+	log.Warnf("Skip expired doc[%s]", uri)
+	diff.skip(uri, ErrDocExpired)
+}
+
+func (in *Ingestor) touch(ctx context.Context, log *slog.Record, entry *LedgerEntry) {
+	entry.LastSeen = time.Now()
+	if err := in.ledger.Save(ctx, entry); err != nil {
+		log.Errorf("Ledger save[%s] failed: %v", entry.SourceURI, err)
+	}
+}
+
+// upsert writes chunks of the document and removes chunks which are not present anymore.
+// For the source absent in the ledger, objects saved before the ledger existed are removed by url.
+// Documents without link are not matched to legacy objects, as unrelated objects can have the same title.
+func (in *Ingestor) upsert(ctx context.Context, log *slog.Record, uri string, d *models.Doc, entry *LedgerEntry) ([]string, error) {
+	if entry == nil && d.Link != "" {
+		if cnt, err := in.db.DeleteWhere(ctx, w.FilterWhereUrlEQ(d.Link)); err != nil {
+			log.Warnf("Remove legacy objects of doc[%s] failed: %v", uri, err)
+		} else if cnt > 0 {
+			log.Infof("Removed %d legacy objects of doc[%s]", cnt, uri)
+		}
+	}
+
+	items := in.db.SplitItem(log, models.DocToKI(d))
+	if in.enricher != nil {
+		in.enrichItems(ctx, log, items)
+	}
+	ids, err := in.db.UpsertItems(ctx, uri, items...)
+	if err != nil {
+		return ids, err
+	}
+
+	if entry != nil {
+		if stale := lo.Without(entry.ObjectIDs, ids...); len(stale) > 0 {
+			log.Infof("Remove %d stale chunks of doc[%s]", len(stale), uri)
+			if err = in.db.DeleteObjects(ctx, stale...); err != nil {
+				return ids, err
+			}
+		}
+	}
+	return ids, nil
+}
+
+// removeUnseen removes configured sources which were not produced by the full run.
+// Run which failed for some sources or produced nothing (e.g. data source is unavailable) removes nothing.
+func (in *Ingestor) removeUnseen(ctx context.Context, log *slog.Record, diff *IngestDiff) {
+	if failed, seen := len(diff.Failed), diff.countSeen(); failed > 0 || seen == 0 {
+		log.Warnf("Skip removal of unseen sources of type[%d]: failed=%d seen=%d", diff.LogicType, failed, seen)
+		return
+	}
+	entries, err := in.ledger.List(ctx, diff.LogicType)
+	if err != nil {
+		log.Errorf("Ledger list failed: %v", err)
+		return
+	}
+
+	for _, e := range entries {
+		if e.Origin != OriginConfigured || diff.isSeen(e.SourceURI) {
+			continue
+		}
+		if err := in.Remove(ctx, e); err != nil {
+			log.Errorf("Remove source[%s] failed: %v", e.SourceURI, err)
+			diff.fail(e.SourceURI, err)
+			continue
+		}
+		diff.append(&diff.Removed, e.SourceURI)
+	}
+}
+
+// Remove deletes all chunks of the source from vector DB and forgets it in the ledger.
+func (in *Ingestor) Remove(ctx context.Context, e *LedgerEntry) error {
+	errMap := errorx.ErrMap{}
+	if err := in.db.DeleteObjects(ctx, e.ObjectIDs...); err != nil {
+		errMap["objects"] = err
+	} else if err = in.ledger.Delete(ctx, e.SourceURI); err != nil {
+		errMap["ledger"] = err
+	}
+	return errMap.ErrorOrNil()
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gookit/slog"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"gitlab.dev.ict/golang/go-ai/logic/ailogic/llm"
+	"gitlab.dev.ict/golang/go-ai/models"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
+)
+
+type fakeItemsWriter struct {
+	objects map[string]*w.KnowledgeItem
+	upserts int
+}
+
+func (f *fakeItemsWriter) SplitItem(_ *slog.Record, item *w.KnowledgeItem) []*w.KnowledgeItem {
+	return []*w.KnowledgeItem{item}
+}
+
+func (f *fakeItemsWriter) UpsertItems(_ context.Context, uri string, items ...*w.KnowledgeItem) (ids []string, err error) {
+	f.upserts++
+	for _, item := range items {
+		id := w.ItemUUID(uri, item.ChunkNo).String()
+		f.objects[id] = item
+		ids = append(ids, id)
+	}
+	return
+}
+
+func (f *fakeItemsWriter) DeleteObjects(_ context.Context, ids ...string) error {
+	for _, id := range ids {
+		delete(f.objects, id)
+	}
+	return nil
+}
+
+func (f *fakeItemsWriter) DeleteWhere(context.Context, *filters.WhereBuilder) (int64, error) {
+	return 0, nil
+}
+
+type fakeLogic struct{ docs []*models.Doc }
+
+func (l *fakeLogic) Type() models.LogicType                    { return models.LogicTypeWebOther }
+func (l *fakeLogic) WithExternalSource(...string) models.Logic { return l }
+func (l *fakeLogic) Process(ctx context.Context, csf ...models.ContentSaverFunc) {
+	for _, d := range l.docs {
+		for _, f := range csf {
+			f(ctx, d)
+		}
+	}
+}
+
+func TestIngestor_Run(t *testing.T) {
+	ledger, err := NewLedgerSqlite(filepath.Join(t.TempDir(), "ledger.db"))
+	require.NoError(t, err)
+	store := &fakeItemsWriter{objects: map[string]*w.KnowledgeItem{}}
+	in := NewIngestor(log, store, ledger)
+
+	logic := &fakeLogic{docs: []*models.Doc{
+		models.NewDoc("A", "content A", "https://x/a"),
+		models.NewDoc("B", "content B", "https://x/b"),
+		models.NewDoc("C", "content C", ""),
+	}}
+	diff := in.Run(ctx, logic, true)
+	assert.ElementsMatch(t, []string{"https://x/a", "https://x/b", "title:C"}, diff.Added)
+	assert.Len(t, store.objects, 3)
+
+	// second run: A unchanged, B updated, C removed, D added
+	logic.docs = []*models.Doc{
+		models.NewDoc("A", "content A", "https://x/a"),
+		models.NewDoc("B", "content B v2", "https://x/b"),
+		models.NewDoc("D", "content D", "https://x/d"),
+	}
+	diff = in.Run(ctx, logic, true)
+	assert.Equal(t, []string{"https://x/a"}, diff.Unchanged)
+	assert.Equal(t, []string{"https://x/b"}, diff.Updated)
+	assert.Equal(t, []string{"https://x/d"}, diff.Added)
+	assert.Equal(t, []string{"title:C"}, diff.Removed)
+	assert.Empty(t, diff.Failed)
+	assert.Equal(t, 5, store.upserts)
+	assert.Len(t, store.objects, 3)
+	assert.Equal(t, "content B v2", store.objects[w.ItemUUID("https://x/b", 0).String()].Content)
+
+	entries, err := ledger.List(ctx, models.LogicTypeWebOther)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://x/a", "https://x/b", "https://x/d"}, lo.Map(entries, func(e *LedgerEntry, _ int) string { return e.SourceURI }))
+}
+
+func TestIngestor_RunMemoryStore(t *testing.T) {
+	ledger, err := NewLedgerSqlite(filepath.Join(t.TempDir(), "ledger.db"))
+	require.NoError(t, err)
+	store := w.NewMemoryStore(log).WithEmbedder(llm.NewHashEmbedder(64))
+	rag := NewRAGService(log, store, nil).WithLedger(ledger)
+
+	logic := &fakeLogic{docs: []*models.Doc{
+		models.NewDoc("A", "VoIP error 409 description", "https://x/a"),
+		models.NewDoc("B", "tariff plans", "https://x/b"),
+	}}
+	diff := rag.Ingestor().Run(ctx, logic, true)
+	assert.Len(t, diff.Added, 2)
+
+	items, err := rag.Store().Search(ctx, w.DefaultSO().SearchTxt("error 409"))
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "A", items[0].Title)
+
+	logic.docs = logic.docs[:1]
+	diff = rag.Ingestor().Run(ctx, logic, true)
+	assert.Equal(t, []string{"https://x/b"}, diff.Removed)
+	total, _ := rag.Store().Count(ctx)
+	assert.Equal(t, 1, total)
+}
+
+func TestRAGService_RunLogicsForDataSourcesWithoutLedger(t *testing.T) {
+	store := w.NewMemoryStore(log).WithEmbedder(llm.NewHashEmbedder(64))
+	rag := NewRAGService(log, store, nil).AppendLogic(&fakeLogic{docs: []*models.Doc{
+		models.NewDoc("A", "VoIP error 409 description", "https://x/a"),
+		models.NewDoc("B", "tariff plans", "https://x/b"),
+	}})
+
+	for i := 0; i < 2; i++ {
+		diffs := rag.RunLogicsForDataSources(ctx)
+		require.Len(t, diffs, 1)
+		assert.Len(t, diffs[0].Added, 2, "documents are overwritten on every run")
+		total, _ := rag.Store().Count(ctx)
+		assert.Equal(t, 2, total)
+	}
+}
+
+func TestIngestor_RunKeepsUploadsAndSkipsRemovalOnFailure(t *testing.T) {
+	ledger, err := NewLedgerSqlite(filepath.Join(t.TempDir(), "ledger.db"))
+	require.NoError(t, err)
+	store := &fakeItemsWriter{objects: map[string]*w.KnowledgeItem{}}
+	in := NewIngestor(log, store, ledger)
+
+	configured := &fakeLogic{docs: []*models.Doc{models.NewDoc("A", "content A", "https://x/a")}}
+	upload := &fakeLogic{docs: []*models.Doc{models.NewDoc("U", "uploaded", "upload:alice/u.html")}}
+	require.Len(t, in.Run(ctx, configured, true).Added, 1)
+	require.Len(t, in.Run(ctx, upload, false).Added, 1)
+
+	entry, err := ledger.Get(ctx, "upload:alice/u.html")
+	require.NoError(t, err)
+	assert.Equal(t, OriginUpload, entry.Origin)
+
+	configured.docs = nil
+	diff := in.Run(ctx, configured, true)
+	assert.Empty(t, diff.Removed, "run without documents removes nothing")
+
+	configured.docs = []*models.Doc{models.NewDoc("B", "", "https://x/b").WithErrorLoading(errors.New("503 Service Unavailable"))}
+	diff = in.Run(ctx, configured, true)
+	assert.Empty(t, diff.Removed, "failed run removes nothing")
+	assert.Contains(t, diff.Failed, "https://x/b")
+
+	configured.docs = []*models.Doc{models.NewDoc("B", "content B", "https://x/b")}
+	diff = in.Run(ctx, configured, true)
+	assert.Equal(t, []string{"https://x/a"}, diff.Removed, "upload is not removed by full run")
+	entries, err := ledger.List(ctx, models.LogicTypeWebOther)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://x/b", "upload:alice/u.html"}, lo.Map(entries, func(e *LedgerEntry, _ int) string { return e.SourceURI }))
+}
+
+func TestIngestor_RunRemovesExpiredDocs(t *testing.T) {
+	ledger, err := NewLedgerSqlite(filepath.Join(t.TempDir(), "ledger.db"))
+	require.NoError(t, err)
+	store := &fakeItemsWriter{objects: map[string]*w.KnowledgeItem{}}
+	in := NewIngestor(log, store, ledger)
+
+	nextYear := time.Now().AddDate(1, 0, 0)
+	runbook := func(validUntil time.Time) *models.Doc {
+		return models.NewDoc("Runbook", "restart SBC", "file:///runbooks/sbc.md").WithOwner("voip-team").WithValidUntil(validUntil)
+	}
+	logic := &fakeLogic{docs: []*models.Doc{runbook(nextYear), models.NewDoc("Old", "outdated", "file:///runbooks/old.md").WithValidUntil(time.Now().AddDate(0, 0, -1))}}
+	diff := in.Run(ctx, logic, true)
+	assert.Equal(t, []string{"file:///runbooks/sbc.md"}, diff.Added)
+	assert.Equal(t, map[string]string{"file:///runbooks/old.md": ErrDocExpired.Error()}, diff.Skipped)
+	item := store.objects[w.ItemUUID("file:///runbooks/sbc.md", 0).String()]
+	require.NotNil(t, item)
+	assert.Equal(t, "voip-team", item.Owner)
+	assert.True(t, nextYear.Equal(*item.ValidUntil))
+
+	logic.docs = []*models.Doc{runbook(time.Now().AddDate(0, 0, -1))}
+	diff = in.Run(ctx, logic, true)
+	assert.Equal(t, []string{"file:///runbooks/sbc.md"}, diff.Removed)
+	assert.Empty(t, store.objects)
+	entry, err := ledger.Get(ctx, "file:///runbooks/sbc.md")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
@@ -0,0 +1,496 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"gitlab.dev.ict/golang/go-ai/models"
+	gl "gitlab.dev.ict/golang/libs/gologgers"
+	"gitlab.dev.ict/golang/libs/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	chJobs = "ingest-jobs"
+
+	DefaultJobWorkers    = 2
+	DefaultJobRetries    = 2
+	DefaultJobRetryDelay = 5 * time.Second
+	jobQueueSize         = 100
+)
+
+type JobStatus string
+
+const (
+	JobQueued   JobStatus = "queued"
+	JobRunning  JobStatus = "running"
+	JobDone     JobStatus = "done"
+	JobPartial  JobStatus = "partial"
+	JobFailed   JobStatus = "failed"
+	JobCanceled JobStatus = "canceled"
+)
+
+var finalStatuses = []JobStatus{JobDone, JobPartial, JobFailed, JobCanceled}
+
+func (s JobStatus) IsFinal() bool {
+	return lo.Contains(finalStatuses, s)
+}
+
+var ErrJobNotFound = errors.New("ingestion job not found")
+
+// IngestJob is a background ingestion of one or more sources (uploaded files, URLs, etc.).
+type IngestJob struct {
+	ID         string           `json:"id" gorm:"primaryKey;size:36"`
+	Owner      string           `json:"owner" gorm:"index"`
+	Status     JobStatus        `json:"status" gorm:"index"`
+	Error      string           `json:"error,omitempty"`
+	MaxRetries int              `json:"maxRetries"`
+	Items      []*IngestJobItem `json:"items" gorm:"foreignKey:JobID;constraint:OnDelete:CASCADE"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	StartedAt  *time.Time       `json:"startedAt,omitempty"`
+	FinishedAt *time.Time       `json:"finishedAt,omitempty"`
+}
+
+func (IngestJob) TableName() string { return "ingest_jobs" }
+
+// IngestJobItem is a single source of the job with its own status and retries.
+type IngestJobItem struct {
+	ID        uint             `json:"-" gorm:"primaryKey"`
+	JobID     string           `json:"-" gorm:"index;size:36"`
+	LogicType models.LogicType `json:"logicType"`
+	Source    string           `json:"source"`
+	URI       string           `json:"uri,omitempty"`   // identity of the source in KB if Source is a local copy (e.g. uploaded file)
+	Crawl     bool             `json:"crawl,omitempty"` // web pages under the URL are crawled, only the page itself otherwise
+	Dir       string           `json:"-"`               // local dir of the source owned by the job, removed when the job is finished
+	Status    JobStatus        `json:"status"`
+	Attempts  int              `json:"attempts"`
+	Error     string           `json:"error,omitempty"`
+	Report    *IngestDiff      `json:"report,omitempty" gorm:"serializer:json"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+}
+
+func (IngestJobItem) TableName() string { return "ingest_job_items" }
+
+func NewIngestJobItem(t models.LogicType, source string) *IngestJobItem {
+	return &IngestJobItem{LogicType: t, Source: source, Status: JobQueued}
+}
+
+// WithURI sets identity of the source, links of the documents are rewritten from Source to it,
+// so re-ingestion of the same source replaces its documents.
+func (i *IngestJobItem) WithURI(uri string) *IngestJobItem {
+	i.URI = uri
+	return i
+}
+
+// WithDir hands over local dir of the source (e.g. dir of uploaded file) to the job, it is removed when the job is finished.
+func (i *IngestJobItem) WithDir(dir string) *IngestJobItem {
+	i.Dir = dir
+	return i
+}
+
+// WithCrawl turns on crawling of web pages under the URL of the source (see JobQueue.WithCrawl).
+func (i *IngestJobItem) WithCrawl(crawl bool) *IngestJobItem {
+	i.Crawl = crawl
+	return i
+}
+
+// JobProgress is a short state of the job, sent to the user as SSE event.
+type JobProgress struct {
+	JobID    string         `json:"jobId"`
+	Status   JobStatus      `json:"status"`
+	Total    int            `json:"total"`
+	Finished int            `json:"finished"`
+	Item     *IngestJobItem `json:"item,omitempty"`
+}
+
+func (j *IngestJob) Progress(item *IngestJobItem) *JobProgress {
+	return &JobProgress{
+		JobID:    j.ID,
+		Status:   j.Status,
+		Total:    len(j.Items),
+		Finished: lo.CountBy(j.Items, func(i *IngestJobItem) bool { return i.Status.IsFinal() }),
+		Item:     item,
+	}
+}
+
+// JobQueue runs ingestion jobs in background workers. Jobs are persisted, so unfinished ones are resumed after restart.
+// Documents are saved via Ingestor (per document upsert), shared kb.Items batch is not used.
+type JobQueue struct {
+	log        *gl.Logger
+	db         *gorm.DB
+	rag        *RAGService
+	ingestor   *Ingestor
+	workers    int
+	retryDelay time.Duration
+	crawl      CrawlOptions
+	queue      chan string
+
+	mu        sync.Mutex // guards start of jobs and transition to final status
+	cancels   map[string]context.CancelFunc
+	listeners map[string]func(*JobProgress)
+}
+
+func NewJobQueue(log *gl.Logger, db *gorm.DB, rag *RAGService) (*JobQueue, error) {
+	if err := db.AutoMigrate(&IngestJob{}, &IngestJobItem{}); err != nil {
+		return nil, err
+	}
+	in := rag.Ingestor()
+	if in == nil {
+		in = NewIngestor(log, rag.Writer(), nil)
+	}
+	return &JobQueue{
+		log:        log,
+		db:         db,
+		rag:        rag,
+		ingestor:   in,
+		workers:    DefaultJobWorkers,
+		retryDelay: DefaultJobRetryDelay,
+		queue:      make(chan string, jobQueueSize),
+		cancels:    map[string]context.CancelFunc{},
+		listeners:  map[string]func(*JobProgress){},
+	}, nil
+}
+
+func (q *JobQueue) WithWorkers(n int) *JobQueue {
+	q.workers = max(n, 1)
+	return q
+}
+
+func (q *JobQueue) WithRetryDelay(d time.Duration) *JobQueue {
+	q.retryDelay = d
+	return q
+}
+
+// WithCrawl sets options of crawling for items with Crawl flag, crawling is limited to pages under the URL of the item.
+func (q *JobQueue) WithCrawl(opts CrawlOptions) *JobQueue {
+	q.crawl = opts
+	return q
+}
+
+func (q *JobQueue) WithIngestor(in *Ingestor) *JobQueue {
+	q.ingestor = in
+	return q
+}
+
+// Start runs workers and re-queues jobs which were not finished before the previous shutdown.
+func (q *JobQueue) Start(ctx context.Context) error {
+	var ids []string
+	if err := q.db.WithContext(ctx).Model(&IngestJob{}).Where("status IN ?", []JobStatus{JobQueued, JobRunning}).Order("created_at").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for i := 0; i < q.workers; i++ {
+		go q.worker(ctx)
+	}
+	go func() {
+		for _, id := range ids {
+			q.log.RecWithCtx(ctx, chJobs).Infof("Resume unfinished job[%s]", id)
+			q.queue <- id
+		}
+	}()
+	return nil
+}
+
+// Submit persists new job and puts it into the queue. onProgress (optional) is called on every status change.
+func (q *JobQueue) Submit(ctx context.Context, owner string, items []*IngestJobItem, onProgress func(*JobProgress)) (*IngestJob, error) {
+	if len(items) == 0 {
+		return nil, errors.New("nothing to ingest")
+	}
+	job := &IngestJob{ID: uuid.NewString(), Owner: owner, Status: JobQueued, MaxRetries: DefaultJobRetries, Items: items}
+	if err := q.db.WithContext(ctx).Create(job).Error; err != nil {
+		return nil, err
+	}
+	if onProgress != nil {
+		q.mu.Lock()
+		q.listeners[job.ID] = onProgress
+		q.mu.Unlock()
+	}
+
+	select {
+	case q.queue <- job.ID:
+	default:
+		go func() { q.queue <- job.ID }()
+	}
+	q.log.RecWithCtx(ctx, chJobs).Infof("Job[%s] submitted by [%s]: items=%d", job.ID, owner, len(items))
+	return job, nil
+}
+
+func (q *JobQueue) Get(ctx context.Context, id string) (*IngestJob, error) {
+	var job IngestJob
+	err := q.db.WithContext(ctx).Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).First(&job, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobNotFound
+	}
+	return &job, err
+}
+
+// List returns the latest jobs, of the owner if it is not empty.
+func (q *JobQueue) List(ctx context.Context, owner string, limit int) (jobs []*IngestJob, err error) {
+	tx := q.db.WithContext(ctx).Preload("Items").Order("created_at DESC").Limit(limit)
+	if owner != "" {
+		tx = tx.Where("owner = ?", owner)
+	}
+	err = tx.Find(&jobs).Error
+	return
+}
+
+// Cancel stops running job (current document is interrupted via context) or marks queued job as canceled.
+func (q *JobQueue) Cancel(ctx context.Context, id string) (*IngestJob, error) {
+	job, err := q.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status.IsFinal() {
+		return job, fmt.Errorf("job[%s] is already %s", id, job.Status)
+	}
+
+	// worker can't start the job while it is being canceled
+	q.mu.Lock()
+	cancel, isRunning := q.cancels[id]
+	if isRunning {
+		q.mu.Unlock()
+		cancel()
+		return job, nil
+	}
+	job.Status = JobCanceled
+	for _, item := range job.Items {
+		if !item.Status.IsFinal() {
+			item.Status = JobCanceled
+		}
+	}
+	isCanceled := q.setFinal(ctx, job)
+	q.mu.Unlock()
+
+	if !isCanceled {
+		if job, err = q.Get(ctx, id); err != nil {
+			return nil, err
+		}
+		return job, fmt.Errorf("job[%s] is already %s", id, job.Status)
+	}
+	q.finished(ctx, job)
+	return job, nil
+}
+
+func (q *JobQueue) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-q.queue:
+			q.run(utils.GenerateCtxWithRid(), id)
+		}
+	}
+}
+
+func (q *JobQueue) run(ctx context.Context, id string) {
+	log := q.log.RecWithCtx(ctx, chJobs)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// job is loaded and registered as running at once, so Cancel either sees it running or finishes it before the start
+	q.mu.Lock()
+	job, err := q.Get(ctx, id)
+	if err == nil && !job.Status.IsFinal() {
+		q.cancels[id] = cancel
+	}
+	q.mu.Unlock()
+	if err != nil {
+		log.Errorf("Job[%s] load failed: %v", id, err)
+		return
+	}
+	if job.Status.IsFinal() {
+		log.Infof("Job[%s] skipped: status=%s", id, job.Status)
+		return
+	}
+	defer func() {
+		q.mu.Lock()
+		delete(q.cancels, id)
+		q.mu.Unlock()
+	}()
+
+	job.Status, job.StartedAt = JobRunning, lo.ToPtr(time.Now())
+	q.save(ctx, job, nil)
+	log.Infof("Job[%s] started: items=%d", id, len(job.Items))
+
+	for _, item := range job.Items {
+		switch {
+		case item.Status.IsFinal():
+			continue
+		case ctx.Err() != nil:
+			item.Status = JobCanceled
+			q.save(ctx, job, item)
+		default:
+			q.runItem(ctx, job, item)
+		}
+	}
+
+	job.Status = jobStatus(job.Items)
+	if !q.finish(context.WithoutCancel(ctx), job) {
+		log.Warnf("Job[%s] was already finished, status=%s is dropped", id, job.Status)
+		return
+	}
+	log.Infof("Job[%s] finished: status=%s", id, job.Status)
+}
+
+// runItem processes single source, retrying with linear backoff on failure.
+func (q *JobQueue) runItem(ctx context.Context, job *IngestJob, item *IngestJobItem) {
+	log := q.log.RecWithCtx(ctx, chJobs)
+	for item.Status != JobDone && item.Attempts <= job.MaxRetries {
+		item.Attempts++
+		item.Status = JobRunning
+		q.save(ctx, job, item)
+
+		report, err := q.process(ctx, item)
+		item.Report = report
+		if err == nil {
+			item.Status, item.Error = JobDone, ""
+			break
+		}
+
+		item.Status, item.Error = JobFailed, err.Error()
+		log.Warnf("Job[%s] source[%s] attempt %d/%d failed: %v", job.ID, item.Source, item.Attempts, job.MaxRetries+1, err)
+		if ctx.Err() != nil || item.Attempts > job.MaxRetries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(q.retryDelay * time.Duration(item.Attempts)):
+		}
+	}
+	if ctx.Err() != nil && item.Status != JobDone {
+		item.Status = JobCanceled
+	}
+	q.save(context.WithoutCancel(ctx), job, item)
+}
+
+// process runs a new processor of the item type for the source, processors of full runs are not touched.
+func (q *JobQueue) process(ctx context.Context, item *IngestJobItem) (*IngestDiff, error) {
+	logic, err := q.rag.NewLogic(item.LogicType)
+	if err != nil {
+		return nil, err
+	}
+	if wpp, ok := logic.(*WebPagesProcessor); ok && item.Crawl {
+		wpp.WithCrawl(q.crawl.Under(item.Source))
+	}
+
+	logic = logic.WithExternalSource(item.Source)
+	if item.URI != "" {
+		logic = &relinkedLogic{Logic: logic, filePath: item.Source, uri: item.URI}
+	}
+	diff := q.ingestor.Run(ctx, logic, false)
+	switch {
+	case ctx.Err() != nil:
+		return diff, ctx.Err()
+	case len(diff.Failed) > 0:
+		return diff, fmt.Errorf("failed documents: %s", utils.JsonStr(diff.Failed))
+	case len(diff.Added)+len(diff.Updated)+len(diff.Unchanged) == 0:
+		return diff, errors.New("no documents produced from source")
+	}
+	return diff, nil
+}
+
+// relinkedLogic processes local copy of the source, links of the documents are rewritten to uri of the source.
+type relinkedLogic struct {
+	models.Logic
+	filePath string
+	uri      string
+}
+
+func (l *relinkedLogic) Process(ctx context.Context, csf ...models.ContentSaverFunc) {
+	l.Logic.Process(ctx, func(ctx context.Context, d *models.Doc) {
+		relinkDoc(d, l.filePath, l.uri)
+		for _, f := range csf {
+			f(ctx, d)
+		}
+	})
+}
+
+// save persists job (and item if not nil) and notifies the listener.
+func (q *JobQueue) save(ctx context.Context, job *IngestJob, item *IngestJobItem) {
+	log := q.log.RecWithCtx(ctx, chJobs)
+	if err := q.db.WithContext(ctx).Omit("Items").Save(job).Error; err != nil {
+		log.Errorf("Job[%s] save failed: %v", job.ID, err)
+	}
+	if item != nil {
+		if err := q.db.WithContext(ctx).Save(item).Error; err != nil {
+			log.Errorf("Job[%s] item[%s] save failed: %v", job.ID, item.Source, err)
+		}
+	}
+	q.notify(job, item)
+}
+
+// finish sets final status of the job, it returns false if the job is already finished (e.g. canceled).
+func (q *JobQueue) finish(ctx context.Context, job *IngestJob) bool {
+	q.mu.Lock()
+	ok := q.setFinal(ctx, job)
+	q.mu.Unlock()
+	if ok {
+		q.finished(ctx, job)
+	}
+	return ok
+}
+
+// setFinal is the only transition of the job to final status: it is persisted only if the job is not final yet.
+// Caller must hold q.mu.
+func (q *JobQueue) setFinal(ctx context.Context, job *IngestJob) bool {
+	job.FinishedAt = lo.ToPtr(time.Now())
+	if job.Status == JobFailed {
+		job.Error = "all sources failed"
+	}
+	res := q.db.WithContext(ctx).Model(&IngestJob{}).
+		Where("id = ? AND status NOT IN ?", job.ID, finalStatuses).
+		Updates(map[string]any{"status": job.Status, "error": job.Error, "finished_at": job.FinishedAt})
+	if res.Error != nil {
+		q.log.RecWithCtx(ctx, chJobs).Errorf("Job[%s] finish failed: %v", job.ID, res.Error)
+	}
+	return res.Error == nil && res.RowsAffected == 1
+}
+
+// finished saves items of the finished job, notifies the listener and removes local dirs of the sources.
+func (q *JobQueue) finished(ctx context.Context, job *IngestJob) {
+	for _, item := range job.Items {
+		q.save(ctx, job, item)
+	}
+	q.save(ctx, job, nil)
+
+	q.mu.Lock()
+	delete(q.listeners, job.ID)
+	q.mu.Unlock()
+
+	for _, item := range job.Items {
+		if item.Dir == "" {
+			continue
+		}
+		if err := os.RemoveAll(item.Dir); err != nil {
+			q.log.RecWithCtx(ctx, chJobs).Errorf("Job[%s] dir[%s] remove failed: %v", job.ID, item.Dir, err)
+		}
+	}
+}
+
+func (q *JobQueue) notify(job *IngestJob, item *IngestJobItem) {
+	q.mu.Lock()
+	fn := q.listeners[job.ID]
+	q.mu.Unlock()
+	if fn != nil {
+		fn(job.Progress(item))
+	}
+}
+
+func jobStatus(items []*IngestJobItem) JobStatus {
+	done := lo.CountBy(items, func(i *IngestJobItem) bool { return i.Status == JobDone })
+	canceled := lo.CountBy(items, func(i *IngestJobItem) bool { return i.Status == JobCanceled })
+	switch {
+	case done == len(items):
+		return JobDone
+	case canceled > 0:
+		return JobCanceled
+	case done == 0:
+		return JobFailed
+	}
+	return JobPartial
+}
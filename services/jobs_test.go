@@ -0,0 +1,156 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.dev.ict/golang/go-ai/models"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
+)
+
+func newTestJobQueue(t *testing.T, logics ...models.Logic) (*JobQueue, *fakeItemsWriter) {
+	ledger, err := NewLedgerSqlite(filepath.Join(t.TempDir(), "jobs.db"))
+	require.NoError(t, err)
+	rag := NewRAGService(log, &w.KnowledgeBase{}, nil)
+	for _, l := range logics {
+		rag.AppendLogic(l)
+		rag.Files().RegisterFactory(l.Type(), func() models.Logic { return l })
+	}
+	store := &fakeItemsWriter{objects: map[string]*w.KnowledgeItem{}}
+	q, err := NewJobQueue(log, ledger.DB(), rag)
+	require.NoError(t, err)
+	return q.WithIngestor(NewIngestor(log, store, nil)).WithRetryDelay(time.Millisecond), store
+}
+
+func waitJob(t *testing.T, q *JobQueue, id string) *IngestJob {
+	var job *IngestJob
+	require.Eventually(t, func() bool {
+		j, err := q.Get(ctx, id)
+		require.NoError(t, err)
+		job = j
+		return j.Status.IsFinal()
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestJobQueue_RunWithRetries(t *testing.T) {
+	logic := &fakeLogic{docs: []*models.Doc{
+		models.NewDoc("A", "content A", "https://x/a"),
+		models.NewDoc("B", "content B", "https://x/b"),
+	}}
+	q, store := newTestJobQueue(t, logic)
+	require.NoError(t, q.Start(ctx))
+
+	var (
+		mu     sync.Mutex
+		events []*JobProgress
+	)
+	job, err := q.Submit(ctx, "tester", []*IngestJobItem{
+		NewIngestJobItem(models.LogicTypeWebOther, "https://x"),
+		NewIngestJobItem(models.LogicTypeDocx, "/tmp/absent.docx"),
+	}, func(p *JobProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, p)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, JobQueued, job.Status)
+
+	job = waitJob(t, q, job.ID)
+	assert.Equal(t, JobPartial, job.Status)
+	require.Len(t, job.Items, 2)
+	assert.Equal(t, JobDone, job.Items[0].Status)
+	assert.Equal(t, 1, job.Items[0].Attempts)
+	assert.ElementsMatch(t, []string{"https://x/a", "https://x/b"}, job.Items[0].Report.Added)
+	assert.Equal(t, JobFailed, job.Items[1].Status)
+	assert.Equal(t, DefaultJobRetries+1, job.Items[1].Attempts)
+	assert.Contains(t, job.Items[1].Error, "is not registered")
+	assert.Len(t, store.objects, 2)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, events)
+	last := events[len(events)-1]
+	assert.Equal(t, JobPartial, last.Status)
+	assert.Equal(t, 2, last.Finished)
+}
+
+func TestJobQueue_CancelQueued(t *testing.T) {
+	q, store := newTestJobQueue(t, &fakeLogic{docs: []*models.Doc{models.NewDoc("A", "content A", "https://x/a")}})
+
+	dir := t.TempDir()
+	job, err := q.Submit(ctx, "tester", []*IngestJobItem{NewIngestJobItem(models.LogicTypeWebOther, "https://x").WithDir(dir)}, nil)
+	require.NoError(t, err)
+	job, err = q.Cancel(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobCanceled, job.Status)
+	assert.NoDirExists(t, dir)
+	assert.False(t, q.finish(ctx, &IngestJob{ID: job.ID, Status: JobDone}), "final status is not overwritten")
+
+	require.NoError(t, q.Start(ctx))
+	job = waitJob(t, q, job.ID)
+	assert.Equal(t, JobCanceled, job.Status)
+	assert.Equal(t, JobCanceled, job.Items[0].Status)
+	assert.Empty(t, store.objects)
+
+	_, err = q.Cancel(ctx, job.ID)
+	assert.Error(t, err)
+	_, err = q.Get(ctx, "absent")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestJobQueue_UploadURI(t *testing.T) {
+	logic := &fakeLogic{}
+	q, store := newTestJobQueue(t, logic)
+	require.NoError(t, q.Start(ctx))
+
+	uri := UploadURI("tester", "tariffs.html")
+	for i := range 2 {
+		dir := filepath.Join(t.TempDir(), "upload-"+strconv.Itoa(i))
+		require.NoError(t, os.Mkdir(dir, 0o755))
+		path := filepath.Join(dir, "tariffs.html")
+		logic.docs = []*models.Doc{models.NewDoc("tariffs.html", "tariffs v"+strconv.Itoa(i), path)}
+		job, err := q.Submit(ctx, "tester", []*IngestJobItem{NewIngestJobItem(models.LogicTypeWebOther, path).WithURI(uri).WithDir(dir)}, nil)
+		require.NoError(t, err)
+		job = waitJob(t, q, job.ID)
+		require.Equal(t, JobDone, job.Status)
+		assert.Equal(t, []string{uri}, job.Items[0].Report.Added)
+		assert.Eventually(t, func() bool { _, err := os.Stat(dir); return os.IsNotExist(err) }, time.Second, 10*time.Millisecond, "dir of upload is removed")
+	}
+	require.Len(t, store.objects, 1, "re-upload replaces documents of the file")
+	assert.Equal(t, "tariffs v1", store.objects[w.ItemUUID(uri, 0).String()].Content)
+}
+
+func TestJobQueue_WebItemsUseNewProcessors(t *testing.T) {
+	site := newTestSite(t)
+	shared := NewWPPOther(log).WithHttpCl(resty.New()).WithWebURLs([]string{"https://configured.example/"}).
+		WithCrawl(CrawlOptions{MaxDepth: 2, UseSitemap: true})
+	q, _ := newTestJobQueue(t, shared)
+	q.rag.Files().RegisterFactory(models.LogicTypeWebOther, func() models.Logic { return NewWPPOther(log).WithHttpCl(resty.New()) })
+	q.WithCrawl(CrawlOptions{MaxDepth: 1, UseSitemap: true, Delay: time.Millisecond})
+	require.NoError(t, q.Start(ctx))
+
+	job, err := q.Submit(ctx, "tester", []*IngestJobItem{
+		NewIngestJobItem(models.LogicTypeWebOther, site.URL+"/docs/a"),
+		NewIngestJobItem(models.LogicTypeWebOther, site.URL+"/docs/").WithCrawl(true),
+	}, nil)
+	require.NoError(t, err)
+	job = waitJob(t, q, job.ID)
+	require.Equal(t, JobDone, job.Status)
+
+	paths := func(item *IngestJobItem) []string {
+		return lo.Map(item.Report.Added, func(uri string, _ int) string { return strings.TrimPrefix(uri, site.URL) })
+	}
+	assert.Equal(t, []string{"/docs/a"}, paths(job.Items[0]), "page is not crawled by default")
+	assert.ElementsMatch(t, []string{"/docs/", "/docs/a"}, paths(job.Items[1]), "crawled under the URL, sitemap is not used")
+	assert.Equal(t, []string{"https://configured.example/"}, shared.WebURLs, "seeds of full runs are kept")
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gitlab.dev.ict/golang/go-ai/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Origins of the ingested sources
+const (
+	OriginConfigured = "configured" // source of the configured data sources, ingested by full runs
+	OriginUpload     = "upload"     // source uploaded or submitted by user (ingestion jobs)
+)
+
+// LedgerEntry is a state of the ingested source (document) in the vector DB.
+type LedgerEntry struct {
+	SourceURI   string           `json:"sourceUri" gorm:"primaryKey"`
+	LogicType   models.LogicType `json:"logicType" gorm:"index"`
+	Origin      string           `json:"origin" gorm:"size:16;default:configured"` // only configured sources are removed by full runs
+	Title       string           `json:"title"`
+	ContentHash string           `json:"contentHash" gorm:"size:64"`
+	ObjectIDs   []string         `json:"objectIds" gorm:"serializer:json"`
+	LastSeen    time.Time        `json:"lastSeen"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	UpdatedAt   time.Time        `json:"updatedAt"`
+}
+
+func (LedgerEntry) TableName() string { return "ingest_ledger" }
+
+// Ledger stores what was ingested from every source, so re-ingestion can skip unchanged documents.
+type Ledger interface {
+	// Get returns entry by source URI or nil if source was never ingested.
+	Get(ctx context.Context, sourceURI string) (*LedgerEntry, error)
+	List(ctx context.Context, t models.LogicType) ([]*LedgerEntry, error)
+	Save(ctx context.Context, e *LedgerEntry) error
+	Delete(ctx context.Context, sourceURI string) error
+}
+
+// LedgerGorm is a Ledger persisted with gorm (sqlite or postgres).
+type LedgerGorm struct {
+	db *gorm.DB
+}
+
+func NewLedgerGorm(db *gorm.DB) (*LedgerGorm, error) {
+	if err := db.AutoMigrate(&LedgerEntry{}); err != nil {
+		return nil, err
+	}
+	return &LedgerGorm{db: db}, nil
+}
+
+// NewLedgerSqlite opens (or creates) sqlite file with ledger.
+func NewLedgerSqlite(path string) (*LedgerGorm, error) {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return NewLedgerGorm(db)
+}
+
+func (l *LedgerGorm) DB() *gorm.DB { return l.db }
+
+func (l *LedgerGorm) Get(ctx context.Context, sourceURI string) (*LedgerEntry, error) {
+	var e LedgerEntry
+	err := l.db.WithContext(ctx).Where("source_uri = ?", sourceURI).First(&e).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &e, err
+}
+
+func (l *LedgerGorm) List(ctx context.Context, t models.LogicType) (entries []*LedgerEntry, err error) {
+	err = l.db.WithContext(ctx).Where("logic_type = ?", t).Order("source_uri").Find(&entries).Error
+	return
+}
+
+func (l *LedgerGorm) Save(ctx context.Context, e *LedgerEntry) error {
+	return l.db.WithContext(ctx).Save(e).Error
+}
+
+func (l *LedgerGorm) Delete(ctx context.Context, sourceURI string) error {
+	return l.db.WithContext(ctx).Delete(&LedgerEntry{}, "source_uri = ?", sourceURI).Error
+}
+
+// DocSourceURI returns unique identity of the document: link, or title if link is empty.
+func DocSourceURI(d *models.Doc) string {
+	if d.Link != "" {
+		return d.Link
+	}
+	return "title:" + d.Title
+}
+
+// UploadURI returns identity of the file uploaded by the user.
+func UploadURI(owner, fileName string) string {
+	return "upload://" + owner + "/" + fileName
+}
+
+// relinkDoc rewrites link of the document produced from the local file to uri of the source, suffix of the link is kept.
+func relinkDoc(d *models.Doc, filePath, uri string) {
+	d.Link = uri + strings.TrimPrefix(strings.TrimPrefix(d.Link, "file://"), filePath)
+}
+
+// DocHash returns sha256 of the document fields that are stored in the vector DB.
+func DocHash(d *models.Doc) string {
+	h := sha256.New()
+	for _, v := range []string{d.Title, d.TextContent, d.Link, d.Category(), d.Summary(), d.Keywords()} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	if tags := d.Tags(); len(tags) > 0 {
+		h.Write([]byte(strings.Join(tags, ",")))
+	}
+	if owner := d.Owner(); owner != "" {
+		h.Write([]byte("owner:" + owner))
+	}
+	if vu, ok := d.ValidUntil(); ok {
+		h.Write([]byte("validUntil:" + vu.Format(time.DateOnly)))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
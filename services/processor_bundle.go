@@ -0,0 +1,247 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gookit/slog"
+	"github.com/samber/lo"
+	"gitlab.dev.ict/golang/go-ai/models"
+	gl "gitlab.dev.ict/golang/libs/gologgers"
+)
+
+const (
+	MimeZip = "application/zip"
+
+	DefaultBundleMaxSize      = 100 << 20
+	DefaultBundleMaxEntries   = 200
+	DefaultBundleMaxEntrySize = 50 << 20
+	DefaultBundleMaxTotalSize = 500 << 20
+)
+
+var (
+	// ErrBundleLimit is returned when archive exceeds BundleLimits, such archive is not processed at all.
+	ErrBundleLimit = errors.New("bundle limit exceeded")
+	// ErrUnsafePath is returned for archive entries with absolute path, "..", or symlinks.
+	ErrUnsafePath = errors.New("unsafe path in bundle")
+)
+
+// BundleLimits protects from huge archives and zip bombs (sizes are in bytes, zero value means default).
+type BundleLimits struct {
+	MaxSize      int64 // size of the archive file
+	MaxEntries   int   // count of files in the archive
+	MaxEntrySize int64 // uncompressed size of one file
+	MaxTotalSize int64 // uncompressed size of all files
+}
+
+func (l BundleLimits) withDefaults() BundleLimits {
+	l.MaxSize = lo.Ternary(l.MaxSize > 0, l.MaxSize, DefaultBundleMaxSize)
+	l.MaxEntries = lo.Ternary(l.MaxEntries > 0, l.MaxEntries, DefaultBundleMaxEntries)
+	l.MaxEntrySize = lo.Ternary(l.MaxEntrySize > 0, l.MaxEntrySize, DefaultBundleMaxEntrySize)
+	l.MaxTotalSize = lo.Ternary(l.MaxTotalSize > 0, l.MaxTotalSize, DefaultBundleMaxTotalSize)
+	return l
+}
+
+// BundlePprocessor ingests ZIP archives: every file is dispatched to a new processor of its type (see RAGService.FileLogic),
+// documents are passed to the same savers with links "<archive>!/<entry>", unsupported files are reported as skipped.
+// Bundles are uploaded only, so the processor is not a data source of full runs.
+type BundlePprocessor struct {
+	log       *gl.Logger
+	fileLogic FileLogicFN
+	limits    BundleLimits
+	filePaths []string
+}
+
+func (bp *BundlePprocessor) String() string {
+	return fmt.Sprintf("BundlePprocessor={limits=%+v, filePaths=%v}", bp.limits, strings.Join(bp.filePaths, ";"))
+}
+
+func NewBundlePprocessor(log *gl.Logger, fileLogic FileLogicFN) *BundlePprocessor {
+	return &BundlePprocessor{log: log, fileLogic: fileLogic, limits: BundleLimits{}.withDefaults()}
+}
+
+func (bp *BundlePprocessor) WithFilePaths(paths ...string) *BundlePprocessor {
+	bp.filePaths = paths
+	return bp
+}
+
+func (bp *BundlePprocessor) WithLimits(l BundleLimits) *BundlePprocessor {
+	bp.limits = l.withDefaults()
+	return bp
+}
+
+func (bp *BundlePprocessor) WithExternalSource(sources ...string) models.Logic {
+	return bp.WithFilePaths(sources...)
+}
+
+func (bp *BundlePprocessor) Type() models.LogicType {
+	return models.LogicTypeBundle
+}
+
+func (bp *BundlePprocessor) Process(ctx context.Context, csf ...models.ContentSaverFunc) {
+	log := bp.log.RecWithCtx(ctx, "bundle-scrap")
+	log.Infof("Start BundlePprocessor! %s", bp)
+	emit := func(ctx context.Context, d *models.Doc) {
+		for _, f := range csf {
+			f(ctx, d)
+		}
+	}
+	for _, fp := range bp.filePaths {
+		if err := bp.processArchive(ctx, log, fp, emit); err != nil {
+			log.Errorf("Bundle %s is rejected: %v", fp, err)
+			emit(ctx, models.NewDoc(filepath.Base(fp), "", fp).WithErrorLoading(err))
+		}
+	}
+}
+
+// Validate checks the archive against the limits without extracting, so upload can be rejected before queueing.
+func (bp *BundlePprocessor) Validate(archivePath string) error {
+	zr, files, err := bp.open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	if len(files) == 0 {
+		return errors.New("bundle has no files")
+	}
+	return nil
+}
+
+func (bp *BundlePprocessor) open(archivePath string) (*zip.ReadCloser, []*zip.File, error) {
+	st, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if st.Size() > bp.limits.MaxSize {
+		return nil, nil, fmt.Errorf("%w: archive size %d > %d", ErrBundleLimit, st.Size(), bp.limits.MaxSize)
+	}
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	files, err := bp.entries(zr.File)
+	if err != nil {
+		zr.Close()
+		return nil, nil, err
+	}
+	return zr, files, nil
+}
+
+// processArchive validates all entries of the archive, then extracts and processes them one by one.
+// Extracted bytes are counted across entries, processing is aborted once they exceed MaxTotalSize.
+func (bp *BundlePprocessor) processArchive(ctx context.Context, log *slog.Record, archivePath string, emit models.ContentSaverFunc) error {
+	zr, files, err := bp.open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	dir, err := os.MkdirTemp("", "bundle-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	log.Infof("Process bundle %s: entries=%d", archivePath, len(files))
+	var written int64
+	for i, f := range files {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		uri := archivePath + "!/" + f.Name
+		logic, err := bp.fileLogic(f.Name)
+		if err == nil && logic.Type() == models.LogicTypeBundle {
+			err = fmt.Errorf("%w: nested archive %s", ErrUnsupportedFile, f.Name)
+		}
+		if err == nil {
+			var n int64
+			n, err = bp.processEntry(ctx, log, f, filepath.Join(dir, strconv.Itoa(i), path.Base(f.Name)), uri, logic, emit, bp.limits.MaxTotalSize-written)
+			if written += n; written > bp.limits.MaxTotalSize {
+				return err
+			}
+		}
+		if err != nil {
+			log.Warnf("Bundle entry %s: %v", uri, err)
+			emit(ctx, models.NewDoc(path.Base(f.Name), "", uri).WithErrorLoading(err))
+		}
+	}
+	return nil
+}
+
+// entries returns files of the archive (directories, hidden files and macOS metadata are ignored) checked against the limits.
+func (bp *BundlePprocessor) entries(all []*zip.File) (res []*zip.File, err error) {
+	var total uint64
+	for _, f := range all {
+		name := f.Name
+		switch {
+		case f.FileInfo().IsDir():
+			continue
+		case !filepath.IsLocal(name) || strings.Contains(name, `\`) || f.Mode()&fs.ModeSymlink != 0:
+			return nil, fmt.Errorf("%w: %q", ErrUnsafePath, name)
+		case strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), "."):
+			continue
+		case f.UncompressedSize64 > uint64(bp.limits.MaxEntrySize):
+			return nil, fmt.Errorf("%w: entry %s size %d > %d", ErrBundleLimit, name, f.UncompressedSize64, bp.limits.MaxEntrySize)
+		}
+		if total += f.UncompressedSize64; total > uint64(bp.limits.MaxTotalSize) {
+			return nil, fmt.Errorf("%w: uncompressed size > %d", ErrBundleLimit, bp.limits.MaxTotalSize)
+		}
+		if res = append(res, f); len(res) > bp.limits.MaxEntries {
+			return nil, fmt.Errorf("%w: more than %d entries", ErrBundleLimit, bp.limits.MaxEntries)
+		}
+	}
+	return res, nil
+}
+
+// processEntry extracts the file and runs the logic, links of the documents are rewritten from the temp file to uri.
+// left is the rest of MaxTotalSize, count of extracted bytes is returned.
+func (bp *BundlePprocessor) processEntry(ctx context.Context, log *slog.Record, f *zip.File, filePath, uri string, logic models.Logic, emit models.ContentSaverFunc, left int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return 0, err
+	}
+	n, err := bp.extract(f, filePath, min(bp.limits.MaxEntrySize, left))
+	switch {
+	case err != nil:
+		return n, err
+	case n > left:
+		return n, fmt.Errorf("%w: uncompressed size > %d", ErrBundleLimit, bp.limits.MaxTotalSize)
+	case n > bp.limits.MaxEntrySize:
+		return n, fmt.Errorf("%w: entry %s size > %d", ErrBundleLimit, f.Name, bp.limits.MaxEntrySize)
+	}
+	produced := 0
+	logic.WithExternalSource(filePath).Process(ctx, func(ctx context.Context, d *models.Doc) {
+		relinkDoc(d, filePath, uri)
+		produced++
+		emit(ctx, d)
+	})
+	log.Infof("Bundle entry %s processed by logic type %d: docs=%d", uri, logic.Type(), produced)
+	if produced == 0 {
+		return n, errors.New("no documents were produced")
+	}
+	return n, nil
+}
+
+// extract writes up to limit+1 bytes of the entry to the file and returns their count, so sizes are checked
+// by the written bytes, as headers of the archive can lie.
+func (bp *BundlePprocessor) extract(f *zip.File, filePath string, limit int64) (int64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	out, err := os.Create(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+	return io.Copy(out, io.LimitReader(rc, limit+1))
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.dev.ict/golang/go-ai/models"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
+)
+
+type zipEntry struct{ name, content string }
+
+func writeZip(t *testing.T, entries ...zipEntry) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		f, err := zw.Create(e.name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	fp := filepath.Join(t.TempDir(), "docs.zip")
+	require.NoError(t, os.WriteFile(fp, buf.Bytes(), 0644))
+	return fp
+}
+
+func newTestBundle() *BundlePprocessor {
+	registry := NewProcessorRegistry().
+		Register(models.LogicTypeMarkdown, MarkdownExts, MimeMarkdown).
+		Register(models.LogicTypeWebOther, []string{".html", ".htm"}).
+		Register(models.LogicTypeBundle, []string{".zip"}, MimeZip)
+	fileLogic := func(filename string, mimes ...string) (models.Logic, error) {
+		t, err := registry.Resolve(filename, mimes...)
+		switch {
+		case err != nil:
+			return nil, err
+		case t == models.LogicTypeMarkdown:
+			return NewMarkdownPprocessor(log), nil
+		case t == models.LogicTypeWebOther:
+			return NewWPPOther(log).WithHttpCl(resty.New()), nil
+		}
+		return &BundlePprocessor{}, nil
+	}
+	return NewBundlePprocessor(log, fileLogic)
+}
+
+func TestBundlePprocessor_Ingest(t *testing.T) {
+	fp := writeZip(t,
+		zipEntry{"docs/runbook.md", "---\ntags: [sip]\n---\n# Restart\nRestart SIP trunk"},
+		zipEntry{"page.html", "<html><head><title>Tariffs</title></head><body><h1>Tariffs</h1><p>Unlimited calls</p></body></html>"},
+		zipEntry{"scheme.png", "png"},
+		zipEntry{"inner.zip", "zip"},
+		zipEntry{"__MACOSX/docs/._runbook.md", "meta"},
+		zipEntry{"docs/.DS_Store", "meta"},
+		zipEntry{"docs/empty/", ""},
+	)
+	ledger, err := NewLedgerSqlite(filepath.Join(t.TempDir(), "ledger.db"))
+	require.NoError(t, err)
+	store := &fakeItemsWriter{objects: map[string]*w.KnowledgeItem{}}
+
+	diff := NewIngestor(log, store, ledger).Run(ctx, newTestBundle().WithExternalSource(fp), false)
+	assert.ElementsMatch(t, []string{fp + "!/docs/runbook.md", fp + "!/page.html"}, diff.Added)
+	assert.Empty(t, diff.Failed)
+	assert.ElementsMatch(t, []string{fp + "!/scheme.png", fp + "!/inner.zip"}, lo.Keys(diff.Skipped))
+
+	kis := lo.MapEntries(store.objects, func(_ string, ki *w.KnowledgeItem) (string, *w.KnowledgeItem) { return ki.URL, ki })
+	require.Len(t, kis, 2)
+	assert.Contains(t, kis[fp+"!/docs/runbook.md"].Content, "Restart SIP trunk")
+	assert.Equal(t, "Restart", kis[fp+"!/docs/runbook.md"].Title)
+	assert.Contains(t, kis[fp+"!/page.html"].Content, "Unlimited calls")
+}
+
+func TestBundlePprocessor_Limits(t *testing.T) {
+	for name, tc := range map[string]struct {
+		entries []zipEntry
+		limits  BundleLimits
+		err     error
+	}{
+		"entries":    {[]zipEntry{{"a.md", "a"}, {"b.md", "b"}, {"c.md", "c"}}, BundleLimits{MaxEntries: 2}, ErrBundleLimit},
+		"entry size": {[]zipEntry{{"a.md", "0123456789"}}, BundleLimits{MaxEntrySize: 5}, ErrBundleLimit},
+		"total size": {[]zipEntry{{"a.md", "01234"}, {"b.md", "56789"}}, BundleLimits{MaxTotalSize: 8}, ErrBundleLimit},
+		"archive":    {[]zipEntry{{"a.md", "a"}}, BundleLimits{MaxSize: 10}, ErrBundleLimit},
+		"zip slip":   {[]zipEntry{{"a.md", "a"}, {"../evil.md", "x"}}, BundleLimits{}, ErrUnsafePath},
+		"absolute":   {[]zipEntry{{"/etc/evil.md", "x"}}, BundleLimits{}, ErrUnsafePath},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fp := writeZip(t, tc.entries...)
+			bp := newTestBundle().WithLimits(tc.limits)
+			assert.ErrorIs(t, bp.Validate(fp), tc.err)
+
+			var docs []*models.Doc
+			bp.WithExternalSource(fp).Process(ctx, func(_ context.Context, d *models.Doc) { docs = append(docs, d) })
+			require.Len(t, docs, 1, "archive is rejected as a whole")
+			assert.Equal(t, fp, docs[0].Link)
+			assert.True(t, errors.Is(docs[0].ErrorLoading(), tc.err))
+		})
+	}
+
+	assert.Error(t, newTestBundle().Validate(writeZip(t, zipEntry{"__MACOSX/x", "x"})), "bundle has no files")
+}
+
+func TestBundlePprocessor_ExtractedSize(t *testing.T) {
+	zr, err := zip.OpenReader(writeZip(t, zipEntry{"a.md", "# A\n0123456789"}))
+	require.NoError(t, err)
+	defer zr.Close()
+	bp := newTestBundle()
+	emit := func(context.Context, *models.Doc) {}
+	dir := t.TempDir()
+
+	n, err := bp.processEntry(ctx, log.RecWithCtx(ctx, "test"), zr.File[0], filepath.Join(dir, "1", "a.md"), "docs.zip!/a.md", NewMarkdownPprocessor(log), emit, 100)
+	require.NoError(t, err)
+	assert.EqualValues(t, 14, n)
+
+	n, err = bp.processEntry(ctx, log.RecWithCtx(ctx, "test"), zr.File[0], filepath.Join(dir, "2", "a.md"), "docs.zip!/a.md", NewMarkdownPprocessor(log), emit, 10)
+	assert.ErrorIs(t, err, ErrBundleLimit, "written bytes exceed the rest of total size")
+	assert.EqualValues(t, 11, n, "extraction stops after the limit")
+}
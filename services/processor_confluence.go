@@ -29,11 +29,14 @@ type ConfluenceProcessor struct {
 	expBodyType string
 	psFuncs     []h.ProcessSelectionFN
 	seFuncs     []h.SkipElementFN
+	api         *ConfluenceClient
+	state       ConfStateStore
+	fileLogic   FileLogicFN
 }
 
 // String
 func (cp *ConfluenceProcessor) String() string {
-	return fmt.Sprintf("ConfluenceProcessor{isParallel=%t, rootPageId=%s, isDebug=%t}", cp.isParallel, cp.rootPageID, cp.IsDebug)
+	return fmt.Sprintf("ConfluenceProcessor{isParallel=%t, rootPageId=%s, isDebug=%t, isSync=%t}", cp.isParallel, cp.rootPageID, cp.IsDebug, cp.api != nil && cp.state != nil)
 }
 
 func NewConfluenceProcessor(log *gologgers.Logger, isParallel bool) *ConfluenceProcessor {
@@ -75,10 +78,27 @@ func (cp *ConfluenceProcessor) EBT(t string) *ConfluenceProcessor {
 	return cp
 }
 
+// WithSync turns on incremental sync: page versions are persisted in the state, only modified pages are fetched,
+// pages deleted or moved out of the tree are removed from KB by full ingestion (see Ingestor.Run).
+func (cp *ConfluenceProcessor) WithSync(api *ConfluenceClient, state ConfStateStore) *ConfluenceProcessor {
+	cp.api, cp.state = api, state
+	return cp
+}
+
+// WithAttachments enables ingestion of page attachments (DOCX, PDF, ...) by processors resolved with fn (incremental sync only).
+func (cp *ConfluenceProcessor) WithAttachments(fn FileLogicFN) *ConfluenceProcessor {
+	cp.fileLogic = fn
+	return cp
+}
+
 // Process - process confluence pages.
 func (cp *ConfluenceProcessor) Process(ctx context.Context, csf ...models.ContentSaverFunc) {
 	log := cp.log.RecWithCtx(ctx, "confl-scrap")
 	log.Infof("Start processing confluence pages! Type of body expanding: %s", cp.expBodyType)
+	if cp.api != nil && cp.state != nil {
+		cp.sync(ctx, csf...)
+		return
+	}
 	var summary, keywords string
 	if !cp.isParallel {
 		cp.ProcessPages(ctx, cp.rootPageID, func(p *cf.Page) {
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.dev.ict/golang/go-ai/models"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
+)
+
+func TestDocxPprocessor_TablesAreNotSplit(t *testing.T) {
+	var docs []*models.Doc
+	NewDocxPprocessor(log, nil).WithTableStyle(TblStyleMD).WithExternalSource("../_testdata/docx/Gift-short-2.docx").
+		Process(ctx, func(_ context.Context, d *models.Doc) { docs = append(docs, d) })
+	require.Len(t, docs, 1)
+
+	// consecutive lines of markdown tables
+	var tables []string
+	var cur []string
+	for _, line := range append(strings.Split(docs[0].TextContent, "\n"), "") {
+		if strings.HasPrefix(strings.TrimSpace(line), "|") {
+			cur = append(cur, line)
+			continue
+		}
+		if len(cur) > 0 {
+			tables, cur = append(tables, strings.Join(cur, "\n")), nil
+		}
+	}
+	require.NotEmpty(t, tables, "tables are rendered as markdown")
+
+	const target = 100
+	chunks := w.NewHeadingChunker(target, 20).Split(docs[0].TextContent)
+	assert.True(t, lo.SomeBy(chunks, func(c w.Chunk) bool { return c.Tokens > target }), "table is larger than one chunk")
+	for _, tbl := range tables {
+		assert.True(t, lo.SomeBy(chunks, func(c w.Chunk) bool { return strings.Contains(c.Content, tbl) }), "table is split:\n%s", tbl)
+	}
+}
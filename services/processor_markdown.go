@@ -0,0 +1,288 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"gitlab.dev.ict/golang/go-ai/models"
+	gl "gitlab.dev.ict/golang/libs/gologgers"
+	"gopkg.in/yaml.v2"
+)
+
+const MimeMarkdown = "text/markdown"
+
+var (
+	MarkdownExts = []string{".md", ".markdown", ".txt"}
+
+	reMdImage     = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	reMdLink      = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	reMdRefDef    = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s+\S+.*$`)
+	reMdComment   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	reMdHTMLTag   = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	reMdEmphasis  = regexp.MustCompile(`(\*\*|__|~~)(\S(?:.*?\S)?)(\*\*|__|~~)`)
+	reMdRule      = regexp.MustCompile(`^\s{0,3}([-*_])(\s*([-*_])){2,}\s*$`)
+	reMdSetextH1  = regexp.MustCompile(`^\s{0,3}=+\s*$`)
+	reMdSetextH2  = regexp.MustCompile(`^\s{0,3}-+\s*$`)
+	reMdQuote     = regexp.MustCompile(`^\s{0,3}>\s?`)
+	reMdEmptyLine = regexp.MustCompile(`\n{3,}`)
+)
+
+// FrontMatter is YAML header of the runbook ("---" delimited), its fields are copied to Doc.Attrs.
+type FrontMatter struct {
+	Title      string     `yaml:"title"`
+	Category   string     `yaml:"category"`
+	Tags       stringList `yaml:"tags"`
+	Owner      string     `yaml:"owner"`
+	ValidUntil string     `yaml:"valid-until"`
+	Summary    string     `yaml:"summary"`
+	Keywords   stringList `yaml:"keywords"`
+}
+
+// stringList accepts YAML list or comma separated string.
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(unmarshal func(any) error) error {
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		*l = compactStrings(list)
+		return nil
+	}
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	*l = compactStrings(strings.Split(s, ","))
+	return nil
+}
+
+func compactStrings(list []string) []string {
+	return lo.Uniq(lo.Compact(lo.Map(list, func(s string, _ int) string { return strings.TrimSpace(s) })))
+}
+
+// MarkdownPprocessor ingests Markdown and plain text files (runbooks kept in git).
+// YAML front matter is parsed into document attributes, Markdown noise is stripped, headings and code blocks are kept,
+// so documents are chunked by headings (see wvservice.HeadingChunker).
+type MarkdownPprocessor struct {
+	log   *gl.Logger
+	paths []string
+}
+
+func (mp *MarkdownPprocessor) String() string {
+	return fmt.Sprintf("MarkdownPprocessor={paths=%v}", strings.Join(mp.paths, ";"))
+}
+
+func NewMarkdownPprocessor(log *gl.Logger) *MarkdownPprocessor {
+	return &MarkdownPprocessor{log: log}
+}
+
+// WithPaths sets files and directories (walked recursively, hidden ones are skipped) to ingest.
+func (mp *MarkdownPprocessor) WithPaths(paths ...string) *MarkdownPprocessor {
+	mp.paths = paths
+	return mp
+}
+
+func (mp *MarkdownPprocessor) WithExternalSource(sources ...string) models.Logic {
+	return mp.WithPaths(sources...)
+}
+
+func (mp *MarkdownPprocessor) Type() models.LogicType {
+	return models.LogicTypeMarkdown
+}
+
+func (mp *MarkdownPprocessor) Process(ctx context.Context, csf ...models.ContentSaverFunc) {
+	log := mp.log.RecWithCtx(ctx, "md-scrap")
+	log.Infof("Start MarkdownPprocessor! %s", mp)
+	var docs []*models.Doc
+
+	for _, fp := range mp.files(log.Errorf) {
+		d, err := loadMarkdown(fp)
+		if err != nil {
+			log.Errorf("Error while loading file %s. Error: %v", fp, err)
+			continue
+		}
+		if vu, ok := d.ValidUntil(); ok && vu.Before(time.Now()) {
+			log.Warnf("Runbook %s is expired and is removed from knowledge base: valid-until=%s owner=%s", fp, vu.Format(time.DateOnly), d.Owner())
+		}
+		log.Infof("Process file - %s. title=%s category=%s tags=%v", fp, d.Title, d.Category(), d.Tags())
+		docs = append(docs, d)
+	}
+
+	log.Infof("Total number of Markdown/TXT files was processed: %d", len(docs))
+	for _, dd := range docs {
+		for _, f := range csf {
+			f(ctx, dd)
+		}
+	}
+}
+
+// files returns files of the configured paths with Markdown/TXT extensions.
+func (mp *MarkdownPprocessor) files(logErr func(string, ...any)) (res []string) {
+	isHidden := func(name string) bool { return len(name) > 1 && strings.HasPrefix(name, ".") }
+	for _, p := range mp.paths {
+		err := filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				return err
+			case d.IsDir() && path != p && isHidden(d.Name()):
+				return filepath.SkipDir
+			case !d.IsDir() && (path == p || !isHidden(d.Name()) && lo.Contains(MarkdownExts, strings.ToLower(filepath.Ext(path)))):
+				res = append(res, path)
+			}
+			return nil
+		})
+		if err != nil {
+			logErr("Walk path %s failed: %v", p, err)
+		}
+	}
+	return
+}
+
+// loadMarkdown reads file, applies front matter and cleans Markdown (files with .md/.markdown extensions).
+// Title is taken from front matter, the first heading or the file name.
+func loadMarkdown(filePath string) (*models.Doc, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	fm, body, err := splitFrontMatter(string(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	if err != nil {
+		return nil, err
+	}
+	if ext := strings.ToLower(filepath.Ext(filePath)); ext == ".md" || ext == ".markdown" {
+		body = CleanMarkdown(body)
+	} else {
+		body = strings.TrimSpace(body)
+	}
+
+	title := fm.Title
+	if title == "" {
+		if m := reHeadingLine.FindStringSubmatch(body); m != nil {
+			title = strings.TrimSpace(m[1])
+		}
+	}
+	d := models.NewDoc(lo.Ternary(title != "", title, filepath.Base(filePath)), body, filePath).
+		WithCategory(lo.Ternary(fm.Category != "", fm.Category, models.CategoryRunbook))
+	if len(fm.Tags) > 0 {
+		d.WithTags(fm.Tags...)
+	}
+	if fm.Owner != "" {
+		d.WithOwner(fm.Owner)
+	}
+	if fm.Summary != "" {
+		d.WithSummary(strings.TrimSpace(fm.Summary))
+	}
+	if len(fm.Keywords) > 0 {
+		d.WithKeywords(strings.Join(fm.Keywords, ","))
+	}
+	if fm.ValidUntil != "" {
+		vu, err := parseDate(fm.ValidUntil)
+		if err != nil {
+			return nil, fmt.Errorf("front matter valid-until: %w", err)
+		}
+		d.WithValidUntil(vu)
+	}
+	return d, nil
+}
+
+var reHeadingLine = regexp.MustCompile(`(?m)^#{1,6}\s+(.+?)\s*#*$`)
+
+// splitFrontMatter returns parsed YAML front matter ("---" on the first line till the next "---" or "...") and the rest of the text.
+func splitFrontMatter(s string) (fm FrontMatter, body string, err error) {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if !strings.HasPrefix(s, "---\n") {
+		return fm, s, nil
+	}
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if l := strings.TrimRight(lines[i], " \t"); l == "---" || l == "..." {
+			if err = yaml.Unmarshal([]byte(strings.Join(lines[1:i], "\n")), &fm); err != nil {
+				return fm, s, fmt.Errorf("front matter: %w", err)
+			}
+			return fm, strings.Join(lines[i+1:], "\n"), nil
+		}
+	}
+	return fm, s, nil // not closed - not a front matter
+}
+
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.DateOnly, time.RFC3339, time.DateTime} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", s)
+}
+
+// CleanMarkdown strips Markdown noise (images, link targets, HTML, emphasis markers, rules, quotes) and keeps
+// headings (setext headings are converted to ATX), lists, tables and fenced code blocks as is.
+func CleanMarkdown(s string) string {
+	s = reMdComment.ReplaceAllString(strings.ReplaceAll(s, "\r\n", "\n"), "")
+	var (
+		out   []string
+		fence string
+	)
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				out, fence = append(out, "```"), ""
+			} else {
+				out = append(out, line)
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			out = append(out, "```"+strings.TrimLeft(trimmed, fence[:1])) // "~~~" fences are converted for the chunker
+			continue
+		}
+
+		prev := ""
+		if len(out) > 0 {
+			prev = strings.TrimSpace(out[len(out)-1])
+		}
+		switch {
+		case reMdRefDef.MatchString(line):
+			continue
+		case isParagraphLine(prev) && reMdSetextH1.MatchString(line):
+			out[len(out)-1] = "# " + prev
+			continue
+		case isParagraphLine(prev) && reMdSetextH2.MatchString(line):
+			out[len(out)-1] = "## " + prev
+			continue
+		case reMdRule.MatchString(line):
+			out = append(out, "")
+			continue
+		}
+		out = append(out, strings.TrimRight(cleanInline(reMdQuote.ReplaceAllString(line, "")), " \t"))
+	}
+	return strings.TrimSpace(reMdEmptyLine.ReplaceAllString(strings.Join(out, "\n"), "\n\n"))
+}
+
+var reMdBlockStart = regexp.MustCompile(`^(#|\||>|[-*+]\s|\d+[.)]\s|` + "```" + `)`)
+
+// isParagraphLine reports whether the line can be a text of setext heading (not a heading, list, table, quote or code).
+func isParagraphLine(s string) bool {
+	return s != "" && !reMdBlockStart.MatchString(s)
+}
+
+// cleanInline strips inline noise out of inline code spans (`code` is kept as is).
+func cleanInline(line string) string {
+	parts := strings.Split(line, "`")
+	for i := 0; i < len(parts); i += 2 {
+		p := reMdImage.ReplaceAllString(parts[i], "$1")
+		p = reMdLink.ReplaceAllString(p, "$1")
+		p = reMdHTMLTag.ReplaceAllString(p, "")
+		parts[i] = reMdEmphasis.ReplaceAllString(p, "$2")
+	}
+	return strings.Join(parts, "`")
+}
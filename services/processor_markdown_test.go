@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.dev.ict/golang/go-ai/models"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
+)
+
+const runbookMD = `---
+title: SIP trunk registration
+category: runbook
+tags: [voip, sip]
+owner: voip-team
+valid-until: 2030-01-31
+---
+Restart **SIP** trunk <br> when [registration](https://wiki/sip) fails.
+![diagram](img/sip.png)
+
+Check
+-----
+
+> Use the __admin__ account, see ` + "`__init__`" + `.
+
+~~~bash
+# not a heading
+systemctl restart sip  # **keep**
+~~~
+
+***
+
+[wiki]: https://wiki/sip
+`
+
+func TestCleanMarkdown(t *testing.T) {
+	_, body, err := splitFrontMatter(runbookMD)
+	require.NoError(t, err)
+	assert.Equal(t, "Restart SIP trunk  when registration fails.\ndiagram\n\n## Check\n\nUse the admin account, see `__init__`.\n\n```bash\n# not a heading\nsystemctl restart sip  # **keep**\n```", CleanMarkdown(body))
+}
+
+func TestSplitFrontMatter(t *testing.T) {
+	fm, body, err := splitFrontMatter("---\ntags: a, b ,a\nkeywords:\n  - x\n...\ntext")
+	require.NoError(t, err)
+	assert.Equal(t, stringList{"a", "b"}, fm.Tags)
+	assert.Equal(t, stringList{"x"}, fm.Keywords)
+	assert.Equal(t, "text", body)
+
+	_, body, err = splitFrontMatter("---\nnot closed")
+	assert.NoError(t, err)
+	assert.Equal(t, "---\nnot closed", body)
+
+	_, _, err = splitFrontMatter("---\ntags: [a\n---\n")
+	assert.Error(t, err)
+}
+
+func TestMarkdownPprocessor_Process(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sip.md"), []byte(runbookMD), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "notes.txt"), []byte("# Notes\n**plain** text"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD.md"), []byte("hidden"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "image.png"), []byte("png"), 0644))
+
+	var docs []*models.Doc
+	NewMarkdownPprocessor(log).WithExternalSource(dir).
+		Process(context.Background(), func(_ context.Context, d *models.Doc) { docs = append(docs, d) })
+
+	require.Len(t, docs, 2)
+	d := docs[0]
+	assert.Equal(t, "SIP trunk registration", d.Title)
+	assert.Equal(t, models.CategoryRunbook, d.Category())
+	assert.Equal(t, []string{"voip", "sip"}, d.Tags())
+	assert.Equal(t, "voip-team", d.Owner())
+	vu, ok := d.ValidUntil()
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC), vu)
+
+	assert.Equal(t, "Notes", docs[1].Title)
+	assert.Equal(t, "# Notes\n**plain** text", docs[1].TextContent, "TXT is not cleaned")
+
+	chunks := w.NewHeadingChunker(800, 0).Split(d.TextContent)
+	if assert.Len(t, chunks, 2) {
+		assert.Equal(t, "Check", chunks[1].SectionPath)
+		assert.Contains(t, chunks[1].Content, "# not a heading")
+	}
+}
@@ -3,11 +3,13 @@ package services
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"gitlab.dev.ict/golang/go-ai/helpers/pdf"
 	"gitlab.dev.ict/golang/go-ai/models"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
 	gl "gitlab.dev.ict/golang/libs/gologgers"
 )
 
@@ -23,7 +25,7 @@ func NewPDFPprocessor(log *gl.Logger, httpClient *resty.Client) *PDFPprocessor {
 }
 
 func (pp *PDFPprocessor) WithFilePaths(paths ...string) *PDFPprocessor {
-	pp.filePaths = append(pp.filePaths, paths...)
+	pp.filePaths = paths
 	return pp
 }
 
@@ -37,7 +39,7 @@ func (pp *PDFPprocessor) Process(ctx context.Context, csf ...models.ContentSaver
 	var docs []*models.Doc
 
 	pdfloader := func(filepath string) {
-		c, t, e := pdf.PDFLoad(filepath)
+		c, t, e := pdfLoad(filepath)
 		if e != nil {
 			pp.log.Errorf("Error while loading PDF file %s. Error: %v", filepath, e)
 		}
@@ -67,6 +69,23 @@ func (pp *PDFPprocessor) Process(ctx context.Context, csf ...models.ContentSaver
 	}
 }
 
+// pdfLoad returns text of the PDF with page markers (see w.PageMarker), so chunks get numbers of their pages.
+func pdfLoad(filePath string) (string, string, error) {
+	pages, title, err := pdf.LoadPages(filePath)
+	if err != nil {
+		return "", title, err
+	}
+	var sb strings.Builder
+	for _, p := range pages {
+		sb.WriteString(w.PageMarker(p.Number) + "\n\n" + p.Text + "\n\n")
+	}
+	return strings.TrimSpace(sb.String()), title, nil
+}
+
+func (pp *PDFPprocessor) WithExternalSource(sources ...string) models.Logic {
+	return pp.WithFilePaths(sources...)
+}
+
 func (wpp *PDFPprocessor) Type() models.LogicType {
 	return models.LogicTypePDF
 }
\ No newline at end of file
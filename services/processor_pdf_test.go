@@ -0,0 +1,20 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.dev.ict/golang/go-ai/models"
+	w "gitlab.dev.ict/golang/go-ai/services/weaviate"
+)
+
+func TestPDFPprocessor_Pages(t *testing.T) {
+	var docs []*models.Doc
+	NewPDFPprocessor(log, nil).WithExternalSource("../_testdata/bzip2.pdf").
+		Process(context.Background(), func(_ context.Context, d *models.Doc) { docs = append(docs, d) })
+	if assert.Len(t, docs, 1) {
+		assert.Contains(t, docs[0].TextContent, w.PageMarker(1))
+		assert.NotEmpty(t, docs[0].Title)
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/samber/lo"
+	"gitlab.dev.ict/golang/go-ai/helpers/sheets"
+	"gitlab.dev.ict/golang/go-ai/models"
+	gl "gitlab.dev.ict/golang/libs/gologgers"
+)
+
+const (
+	MimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	MimeCSV  = "text/csv"
+
+	DefaultSheetRowsPerGroup = 30
+)
+
+// SheetPprocessor ingests spreadsheets (XLSX, CSV): every sheet is divided into groups of rows,
+// each group is a document with the table (header row repeated) and sheet name with row range as metadata.
+type SheetPprocessor struct {
+	log          *gl.Logger
+	tblStyle     string
+	rowsPerGroup int
+	filePaths    []string
+}
+
+func (sp *SheetPprocessor) String() string {
+	return fmt.Sprintf("SheetPprocessor={tblStyle=%s, rowsPerGroup=%d, filePaths=%v}", sp.tblStyle, sp.rowsPerGroup, strings.Join(sp.filePaths, ";"))
+}
+
+func NewSheetPprocessor(log *gl.Logger) *SheetPprocessor {
+	return &SheetPprocessor{log: log, tblStyle: TblStyleMD, rowsPerGroup: DefaultSheetRowsPerGroup}
+}
+
+func (sp *SheetPprocessor) WithFilePaths(paths ...string) *SheetPprocessor {
+	sp.filePaths = paths
+	return sp
+}
+
+// WithTableStyle sets style of rendered tables, see TblStyleMD (default), TblStyleCSV, TblStylePretty.
+func (sp *SheetPprocessor) WithTableStyle(style string) *SheetPprocessor {
+	sp.tblStyle = style
+	return sp
+}
+
+// WithRowsPerGroup sets count of data rows (without header) in one document.
+func (sp *SheetPprocessor) WithRowsPerGroup(n int) *SheetPprocessor {
+	if n > 0 {
+		sp.rowsPerGroup = n
+	}
+	return sp
+}
+
+func (sp *SheetPprocessor) WithExternalSource(sources ...string) models.Logic {
+	return sp.WithFilePaths(sources...)
+}
+
+func (sp *SheetPprocessor) Type() models.LogicType {
+	return models.LogicTypeSheet
+}
+
+func (sp *SheetPprocessor) Process(ctx context.Context, csf ...models.ContentSaverFunc) {
+	log := sp.log.RecWithCtx(ctx, "sheet-scrap")
+	log.Infof("Start SheetPprocessor! %s", sp)
+	var docs []*models.Doc
+
+	for _, fp := range sp.filePaths {
+		ss, err := sheets.Load(fp)
+		if err != nil {
+			log.Errorf("Error while loading spreadsheet %s. Error: %v", fp, err)
+			continue
+		}
+		for _, s := range ss {
+			d := sp.sheetDocs(fp, &s)
+			log.Infof("Process file - %s; sheet=%s rows=%d => docs=%d", fp, s.Name, len(s.Rows), len(d))
+			docs = append(docs, d...)
+		}
+	}
+
+	log.Infof("Total number of spreadsheet documents was processed: %d", len(docs))
+	for _, dd := range docs {
+		for _, f := range csf {
+			f(ctx, dd)
+		}
+	}
+}
+
+// sheetDocs divides the sheet into documents by groups of rows, the first row is the header of every group.
+func (sp *SheetPprocessor) sheetDocs(filePath string, s *sheets.Sheet) (docs []*models.Doc) {
+	if len(s.Rows) == 0 {
+		return nil
+	}
+	width := s.Width()
+	pad := func(r sheets.Row) []string {
+		return append(r.Cells, make([]string, width-len(r.Cells))...)
+	}
+	header, data := pad(s.Rows[0]), s.Rows[1:]
+	groups := lo.Chunk(data, sp.rowsPerGroup)
+	if len(groups) == 0 {
+		groups = [][]sheets.Row{nil} // header only
+	}
+
+	title := fmt.Sprintf("%s: %s", filepath.Base(filePath), s.Name)
+	for _, g := range groups {
+		rows := lo.Ternary(len(g) > 0, g, s.Rows[:1])
+		rowRange := fmt.Sprintf("%d-%d", rows[0].Number, rows[len(rows)-1].Number)
+		table := renderTable(sp.tblStyle, header, lo.Map(g, func(r sheets.Row, _ int) []string { return pad(r) }))
+		link := fmt.Sprintf("%s#sheet=%s&rows=%s", filePath, url.QueryEscape(s.Name), rowRange)
+		docs = append(docs, models.NewDoc(title, table, link).WithSheet(s.Name, rowRange))
+	}
+	return
+}
+
+// renderTable renders rows with the header in the table style (TblStyleMD by default).
+func renderTable(style string, header []string, rows [][]string) string {
+	clean := func(cells []string) []string {
+		return lo.Map(cells, func(c string, _ int) string { return strings.Join(strings.Fields(c), " ") })
+	}
+	switch style {
+	case TblStyleCSV:
+		var buf bytes.Buffer
+		cw := csv.NewWriter(&buf)
+		cw.Write(clean(header))
+		for _, r := range rows {
+			cw.Write(clean(r))
+		}
+		cw.Flush()
+		return strings.TrimSpace(buf.String())
+	case TblStylePretty:
+		var buf bytes.Buffer
+		tw := tablewriter.NewWriter(&buf)
+		tw.SetHeader(clean(header))
+		tw.SetAutoFormatHeaders(false)
+		tw.SetAutoWrapText(false)
+		tw.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		tw.SetAlignment(tablewriter.ALIGN_LEFT)
+		tw.SetBorder(false)
+		for _, r := range rows {
+			tw.Append(clean(r))
+		}
+		tw.Render()
+		return strings.TrimRight(buf.String(), "\n")
+	}
+
+	return sheets.MarkdownTable(header, rows)
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.dev.ict/golang/go-ai/models"
+)
+
+func TestSheetPprocessor_Process(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "ranges.csv")
+	require.NoError(t, os.WriteFile(fp, []byte("Range,Operator,Region\n380630000000,lifecell,UA\n380930000000,lifecell\n\n380670000000,\"kyiv|star\",UA\n"), 0644))
+
+	var docs []*models.Doc
+	NewSheetPprocessor(log).WithRowsPerGroup(2).WithExternalSource(fp).
+		Process(context.Background(), func(_ context.Context, d *models.Doc) { docs = append(docs, d) })
+
+	require.Len(t, docs, 2)
+	assert.Equal(t, "ranges.csv: ranges", docs[0].Title)
+	assert.Equal(t, "ranges", docs[0].Sheet())
+	assert.Equal(t, "2-3", docs[0].RowRange())
+	assert.Equal(t, fp+"#sheet=ranges&rows=2-3", docs[0].Link)
+	assert.Equal(t, `| Range | Operator | Region |
+| --- | --- | --- |
+| 380630000000 | lifecell | UA |
+| 380930000000 | lifecell |  |`, docs[0].TextContent)
+
+	assert.Equal(t, "5-5", docs[1].RowRange())
+	assert.Equal(t, `| Range | Operator | Region |
+| --- | --- | --- |
+| 380670000000 | kyiv\|star | UA |`, docs[1].TextContent)
+}
+
+func TestRenderTable_Styles(t *testing.T) {
+	header, rows := []string{"Name", "Price"}, [][]string{{"Basic, new", "10"}}
+	assert.Equal(t, "Name,Price\n\"Basic, new\",10", renderTable(TblStyleCSV, header, rows))
+	assert.Contains(t, renderTable(TblStylePretty, header, rows), "Basic, new")
+	assert.Equal(t, "| Name | Price |\n| --- | --- |\n| Basic, new | 10 |", renderTable("", header, rows))
+}
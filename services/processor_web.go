@@ -2,9 +2,12 @@ package services
 
 import (
 	"context"
+	"strings"
+	"sync"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/go-resty/resty/v2"
+	"github.com/gookit/slog"
 	h "gitlab.dev.ict/golang/go-ai/helpers"
 	"gitlab.dev.ict/golang/go-ai/models"
 	gl "gitlab.dev.ict/golang/libs/gologgers"
@@ -21,6 +24,13 @@ var Links_LifecellUA = []string{
 	"https://lifecell.ua/uk/pidtrimka/pitannya-v-kategoriyi/?category=121",
 }
 
+// Prefixes_LifecellUA are paths of lifecell.ua pages discovered by the crawler (support and business services).
+var Prefixes_LifecellUA = []string{
+	"/uk/pidtrimka/",
+	"/uk/malii-biznes-lifecell/servisi/",
+	"/uk/velikii-biznes-lifecell/servisi/",
+}
+
 type WebPagesProcessor struct {
 	log        *gl.Logger
 	WebURLs    []string
@@ -29,6 +39,26 @@ type WebPagesProcessor struct {
 	httpClient *resty.Client
 	IsDebug    bool
 	typeLogic  models.LogicType
+	crawler    *Crawler
+	crawled    *crawledDocs
+}
+
+// crawledDocs keeps documents of crawled pages, they are passed to savers again when page is not modified (304).
+type crawledDocs struct {
+	mu   sync.Mutex
+	docs map[string]*models.Doc
+}
+
+func (cd *crawledDocs) get(url string) *models.Doc {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	return cd.docs[url]
+}
+
+func (cd *crawledDocs) put(url string, d *models.Doc) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	cd.docs[url] = d
 }
 
 var processSelectionFNLifecellUa = func(s *goquery.Selection) *goquery.Selection {
@@ -57,11 +87,23 @@ func (wpp *WebPagesProcessor) WithWebURLs(urls []string) *WebPagesProcessor {
 	return wpp
 }
 
+// WithCrawl turns on crawler mode: WebURLs are seeds, pages are discovered by links and sitemaps.
+// Crawler uses HTTP client set by WithHttpCl before.
+func (wpp *WebPagesProcessor) WithCrawl(opts CrawlOptions) *WebPagesProcessor {
+	wpp.crawler = NewCrawler(wpp.log, wpp.httpClient, opts)
+	wpp.crawled = &crawledDocs{docs: map[string]*models.Doc{}}
+	return wpp
+}
+
 func (wpp WebPagesProcessor) Process(ctx context.Context, csf ...models.ContentSaverFunc) {
 	log := wpp.log.RecWithCtx(ctx, "web-scrap")
-	log.WithData(gl.M{"isDebug": wpp.IsDebug}).Infof("Start scrap web pages")
+	log.WithData(gl.M{"isDebug": wpp.IsDebug, "isCrawl": wpp.crawler != nil}).Infof("Start scrap web pages")
+	if wpp.crawler != nil {
+		wpp.crawl(ctx, log, csf...)
+		return
+	}
 	for _, v := range wpp.WebURLs {
-		pageParsed := h.ScrapAndParse(log, wpp.httpClient.SetDisableWarn(true), v, wpp.IsDebug, wpp.psFuncs, wpp.seFuncs...)
+		pageParsed := h.ScrapAndParse(log, wpp.httpClient.SetDisableWarn(true), fileURL(v), wpp.IsDebug, wpp.psFuncs, wpp.seFuncs...)
 		log.Infof("pageParsed: %s", pageParsed)
 		if pageParsed.Err != nil {
 			log.Errorf("Error while process page [%s] >> %s", v, pageParsed.Err)
@@ -75,6 +117,41 @@ func (wpp WebPagesProcessor) Process(ctx context.Context, csf ...models.ContentS
 	}
 }
 
+func (wpp WebPagesProcessor) crawl(ctx context.Context, log *slog.Record, csf ...models.ContentSaverFunc) {
+	stats := wpp.crawler.Crawl(ctx, wpp.WebURLs, func(p *CrawledPage) {
+		d := wpp.crawled.get(p.URL)
+		switch {
+		case p.NotModified && d == nil:
+			log.Warnf("Page not modified, but was not parsed before [%s]", p.URL)
+			return
+		case p.NotModified:
+			log.Infof("Page not modified [%s]", p.URL)
+		default:
+			pageParsed := h.ParseHTML(log, p.URL, p.Body, wpp.IsDebug, wpp.psFuncs, wpp.seFuncs...)
+			if pageParsed.Err != nil {
+				log.Errorf("Error while process page [%s] >> %s", p.URL, pageParsed.Err)
+				return
+			}
+			pageParsed.TextContent = h.CleanEmptyLine(pageParsed.TextContent)
+			log.Infof("Process page [%s] depth=%d >> %s", p.URL, p.Depth, pageParsed)
+			d = models.NewDoc(pageParsed.Title, pageParsed.TextContent, p.URL).WithCategory(models.CategoryWEB).WithOriginal(pageParsed.OriginalPage)
+			wpp.crawled.put(p.URL, d)
+		}
+		for _, f := range csf {
+			f(ctx, d)
+		}
+	})
+	log.Infof("Finish crawl web pages: %s", stats)
+}
+
+// fileURL converts local path (uploaded HTML file) to file:// URL, which is read by ScrapAndParse from disk.
+func fileURL(src string) string {
+	if strings.Contains(src, "://") {
+		return src
+	}
+	return "file://" + src
+}
+
 func (wpp *WebPagesProcessor) Type() models.LogicType {
 	return wpp.typeLogic
 }
@@ -11,6 +11,7 @@ import (
 	"gitlab.dev.ict/golang/go-ai/logic/ailogic"
 	"gitlab.dev.ict/golang/go-ai/logic/ailogic/callbackhandlers"
 	"gitlab.dev.ict/golang/go-ai/logic/ailogic/dbchain"
+	"gitlab.dev.ict/golang/go-ai/logic/ailogic/llm"
 	experemental "gitlab.dev.ict/golang/go-ai/logic/ailogic/tools/experemental"
 	"gitlab.dev.ict/golang/go-ai/logic/biz"
 	dic "gitlab.dev.ict/golang/go-ai/logic/db_info_collector"
@@ -21,24 +22,32 @@ import (
 	"gitlab.dev.ict/golang/libs/utils"
 )
 
+// chainDbCim - name of the chain which answers questions about CIM DB, it is used to choose LLM profile
+const chainDbCim = "DbCimChain"
+
 type RAGService struct {
 	log                  *gologgers.Logger
-	db                   *w.KnowledgeBase
+	db                   w.VectorStore
+	retriever            *w.Retriever
 	ws                   *biz.WSGetter
 	ai                   *goai.Client
 	dbCim                dic.DBSchemaInfoProvider
 	llm                  llms.Model
+	models               *llm.Registry
+	ingestor             *Ingestor
+	jobs                 *JobQueue
+	files                *ProcessorRegistry
 	LogicsForDataSources []models.Logic
 }
 
 // NewRAGService - create new RAGService
-func NewRAGService(log *gologgers.Logger, db *w.KnowledgeBase, ai *goai.Client) *RAGService {
+func NewRAGService(log *gologgers.Logger, db w.VectorStore, ai *goai.Client) *RAGService {
 	if db == nil {
-		e := fmt.Errorf("(db *w.KnowledgeBase) - should not be null")
+		e := fmt.Errorf("(db w.VectorStore) - should not be null")
 		log.Error(e)
 		panic(e)
 	}
-	return &RAGService{log: log, db: db, ai: ai}
+	return &RAGService{log: log, db: db, ai: ai, retriever: w.NewRetriever(log, db), files: NewProcessorRegistry()}
 }
 
 // InitWS - init WS
@@ -52,6 +61,25 @@ func (rag *RAGService) LLM(llm llms.Model) *RAGService {
 	return rag
 }
 
+// WithModels - set registry of LLM profiles used by chains and AI agents
+func (rag *RAGService) WithModels(r *llm.Registry) *RAGService {
+	rag.models = r
+	return rag
+}
+
+// chainModel - LLM and call options of the chain from registry of models, default LLM is used if registry is not set.
+// LLM reports calls to the same logger as the chain (see dbchain.DbChainNew)
+func (rag *RAGService) chainModel(chain string) (llms.Model, []ailogic.OptFn, error) {
+	if rag.models == nil {
+		return rag.llm, nil, nil
+	}
+	m, callOpts, err := rag.models.ForChain(chain, callbackhandlers.NewLoggerHandler(rag.log))
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, []ailogic.OptFn{ailogic.WithCallOpts(callOpts...)}, nil
+}
+
 func (rag *RAGService) DBCim(i dic.DBSchemaInfoProvider) *RAGService {
 	rag.dbCim = i
 	return rag
@@ -61,20 +89,70 @@ func (rag *RAGService) Info() {
 	rag.log.Infof("RAGService:\n\tdb => %+v\n\tLogics count => %d", rag.db, len(rag.LogicsForDataSources))
 }
 
+// WithLedger - enable incremental ingestion of data sources based on the ledger
+func (rag *RAGService) WithLedger(ledger Ledger) *RAGService {
+	rag.ingestor = NewIngestor(rag.log, rag.Writer(), ledger)
+	return rag
+}
+
+func (rag *RAGService) Ingestor() *Ingestor {
+	return rag.ingestor
+}
+
+// WithJobQueue - set queue for background ingestion of uploaded documents
+func (rag *RAGService) WithJobQueue(q *JobQueue) *RAGService {
+	rag.jobs = q
+	return rag
+}
+
+func (rag *RAGService) Jobs() *JobQueue {
+	return rag.jobs
+}
+
 // AppendLogic - append logic
 func (rag *RAGService) AppendLogic(logic models.Logic) *RAGService {
 	rag.LogicsForDataSources = append(rag.LogicsForDataSources, logic)
 	return rag
 }
 
-// RunLogicsForDataSources - run logics for data sources
-func (rag *RAGService) RunLogicsForDataSources(ctx context.Context, csf ...models.ContentSaverFunc) {
+// RunLogicsForDataSources - run logics for data sources, documents are saved to vector DB and diff per logic is returned,
+// so csf should contain only additional savers (e.g. ContentBackupLocal).
+// If ledger is set (see WithLedger), documents are saved incrementally, otherwise every document is overwritten.
+func (rag *RAGService) RunLogicsForDataSources(ctx context.Context, csf ...models.ContentSaverFunc) (diffs []*IngestDiff) {
+	in := rag.ingestor
+	if in == nil {
+		in = NewIngestor(rag.log, rag.Writer(), nil)
+	}
 	for _, v := range rag.LogicsForDataSources {
-		v.Process(ctx, csf...)
+		diffs = append(diffs, in.Run(ctx, v, true, csf...))
+	}
+	return
+}
+
+// Files - registry of processors for uploaded files
+func (rag *RAGService) Files() *ProcessorRegistry {
+	return rag.files
+}
+
+// FileLogic - returns a new processor of the file resolved by registry of processors (see Files)
+func (rag *RAGService) FileLogic(filename string, mimes ...string) (models.Logic, error) {
+	t, err := rag.files.Resolve(filename, mimes...)
+	if err != nil {
+		return nil, err
 	}
+	logic, err := rag.files.New(t)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFile, err)
+	}
+	return logic, nil
+}
+
+// NewLogic - returns a new processor of the type created by factory of registry of processors (see Files)
+func (rag *RAGService) NewLogic(t models.LogicType) (models.Logic, error) {
+	return rag.files.New(t)
 }
 
-// GetLogic - get logic
+// GetLogic - get shared processor of the type, which runs for configured data sources (see AppendLogic)
 func (rag *RAGService) GetLogic(t models.LogicType) models.Logic {
 	for _, v := range rag.LogicsForDataSources {
 		if v.Type() == t {
@@ -84,22 +162,50 @@ func (rag *RAGService) GetLogic(t models.LogicType) models.Logic {
 	return nil
 }
 
+// GetKB - returns Weaviate knowledge base or nil if another vector store is used
 func (rag *RAGService) GetKB() *w.KnowledgeBase {
+	kb, _ := rag.db.(*w.KnowledgeBase)
+	return kb
+}
+
+// Store - returns vector store
+func (rag *RAGService) Store() w.VectorStore {
 	return rag.db
 }
 
+// WithRetriever - set retrieval pipeline (reranking, score threshold, token budget) used by agents and search.
+// Default is retriever with lexical reranker.
+func (rag *RAGService) WithRetriever(r *w.Retriever) *RAGService {
+	rag.retriever = r
+	return rag
+}
+
+func (rag *RAGService) Retriever() *w.Retriever {
+	return rag.retriever
+}
+
+// Writer - returns vector store as ItemsWriter used for ingestion
+func (rag *RAGService) Writer() ItemsWriter {
+	return rag.db.(ItemsWriter)
+}
+
 func (rag *RAGService) CallAICimDBChanEventMsg(ctx context.Context, query string, chat *goai.Chat, userChan chan string, sendToChan func(string)) (any, error) {
 	log := rag.log.RecWithCtx(ctx, "rag")
 	log.Info("Start calling AI agents...")
 	ctx = ailogic.AddToCtxUUIDAI(ctx, chat.ID)
 
-	chain := dbchain.DbChainNew(rag.log, rag.llm, rag.dbCim,
-		ailogic.WithName("DbCimChain"),
+	model, modelOpts, err := rag.chainModel(chainDbCim)
+	if err != nil {
+		log.Errorf("Error creating LLM: %v", err)
+		return "", err
+	}
+	chain := dbchain.DbChainNew(rag.log, model, rag.dbCim, append(modelOpts,
+		ailogic.WithName(chainDbCim),
 		// al.WithPrompt(defPrompt),
 		ailogic.WithCtx(ctx),
 		ailogic.WithLog(rag.log),
 		ailogic.WithUserLogin("XXXXXX"),
-		ailogic.WithAvailableTools(experemental.NewToolSqlRunner2(rag.log, rag.dbCim.G())))
+		ailogic.WithAvailableTools(experemental.NewToolSqlRunner2(rag.log, rag.dbCim.G())))...)
 
 	// mapResult, err := chain.Run(ctx, dbchain.PrepareInputsCM(query), ailogic.WithCBSse(ailogic.CallbackSSEStream), ailogic.WithSSEChan(userChan), ailogic.WithChainOptions(chains.WithCallback(callbackhandlers.NewLoggerHandler(rag.log))))
 	mapResult, err := chain.Run(ctx, dbchain.PrepareInputsCM(query), ailogic.RunOptsInstantSSE(userChan, rag.log)...)
@@ -121,13 +227,18 @@ func (rag *RAGService) CallAICimDB(ctx context.Context, query string, chat *goai
 	log.Info("Start calling AI agents...")
 	ctx = ailogic.AddToCtxUUIDAI(ctx, chat.ID)
 
-	chain := dbchain.DbChainNew(rag.log, rag.llm, rag.dbCim,
-		ailogic.WithName("DbCimChain"),
+	model, modelOpts, err := rag.chainModel(chainDbCim)
+	if err != nil {
+		log.Errorf("Error creating LLM: %v", err)
+		return "", err
+	}
+	chain := dbchain.DbChainNew(rag.log, model, rag.dbCim, append(modelOpts,
+		ailogic.WithName(chainDbCim),
 		// al.WithPrompt(defPrompt),
 		ailogic.WithCtx(ctx),
 		ailogic.WithLog(rag.log),
 		ailogic.WithUserLogin("XXXXXX"),
-		ailogic.WithAvailableTools(experemental.NewToolSqlRunner2(rag.log, rag.dbCim.G())))
+		ailogic.WithAvailableTools(experemental.NewToolSqlRunner2(rag.log, rag.dbCim.G())))...)
 
 	// mapResult, err := chain.Run(ctx, dbchain.PrepareInputsCM(query), ailogic.WithCBSse(ailogic.CallbackSSEStream), ailogic.WithSSEChan(userChan), ailogic.WithChainOptions(chains.WithCallback(callbackhandlers.NewLoggerHandler(rag.log))))
 	mapResult, err := chain.Run(ctx, dbchain.PrepareInputsCM(query), ailogic.RunOpts(userChan, rag.log)...)
@@ -163,7 +274,10 @@ func (rag *RAGService) CallAIAgents(ctx context.Context, query string, chat *goa
 
 	log.Info("Start calling AI agents...")
 	ctx = ailogic.AddToCtxUUIDAI(ctx, chat.ID)
-	voipChain := ailogic.NewChainVoipExt(ctx, rag.llm, rag.log, rag.ws, rag.db)
+	if rag.models == nil {
+		return "", fmt.Errorf("models of AI agents are not set")
+	}
+	voipChain := ailogic.NewChainVoipExt(ctx, rag.models, rag.log, rag.ws, rag.retriever)
 
 	values := map[string]any{
 		voipChain.GetKeyIn():          query,
@@ -173,8 +287,6 @@ func (rag *RAGService) CallAIAgents(ctx context.Context, query string, chat *goa
 	mapResult, err := voipChain.Run(ctx, values,
 		ailogic.WithCBSse(ailogic.CallbackSSEStream),
 		ailogic.WithSSEChan(userChan),
-		ailogic.WithCallbackToChannel(ailogic.CallbackSSEStreamWithFN),
-		ailogic.WithSendToChan(sendToChan),
 		ailogic.WithChainOptions(chains.WithCallback(callbackhandlers.NewLoggerHandler(rag.log)), chains.WithTemperature(0.2)))
 	if err != nil {
 		log.Errorf("Error calling AI agents: %v", err)
@@ -184,31 +296,29 @@ func (rag *RAGService) CallAIAgents(ctx context.Context, query string, chat *goa
 	log.Infof("AI agents mapResult[%s]: %v", voipChain.GetKeyOut(), mapResult[voipChain.GetKeyOut()])
 	chat.WithName(mapResult["userIntent"].(string)).AddUserMessage(query).AddAssistantMessage(mapResult[voipChain.GetKeyOut()].(string))
 
-	return mapResult[voipChain.GetKeyOut()], nil
+	return &ailogic.AgentAnswer{Answer: mapResult[voipChain.GetKeyOut()].(string), Citations: ailogic.CitationsFromResult(mapResult)}, nil
 }
 
 // SearchInVectorAndAskAIStream - search in vector and ask ai stream
 func (rag *RAGService) SearchInVectorAndAskAIStream(ctx context.Context, query string, chat *goai.Chat, userChan chan string, fn ...goai.PromptGenFN) (string, error) {
 	rid, ctx := utils.GetRidOrAdd(ctx)
 	log := rag.log.WithField("rid", rid)
-	list := rag.db.SearchInContentsHybrid(ctx, query, 1, w.FieldTitle, w.FieldContent)
+	list, err := rag.retriever.Retrieve(ctx, w.NewSO().SetFields(w.FieldTitle, w.FieldContent).SearchTxt(query).SF("content"))
+	if err != nil {
+		log.Errorf("Error searching in VectorDB: %v", err)
+		return "I'm so sorry :( I can't find anything for you.", err
+	}
 
 	if len(list) == 0 {
 		log.Warnf("Result searching in VectorDB => NOT_FOUND! q=[%s]", query)
 		return "I'm so sorry :( I can't find anything for you.", nil
 	}
 
-	obj, err := rag.db.GetObjByID(ctx, w.IdOfFirstEl(list))
-	if err != nil {
-		log.Errorf("Error getting obj by id[%s]: %v", w.IdOfFirstEl(list), err)
-		return "I'm so sorry :( I can't find anything for you.", err
-	}
-
 	if len(fn) == 0 {
 		fn = append(fn, goai.CreatePromptGen(goai.UserTemplate1))
 	}
 
-	stream, e := rag.ai.AskAIStream(ctx, fn[0](w.ObjContent(obj), query), chat)
+	stream, e := rag.ai.AskAIStream(ctx, fn[0](w.JoinContents(list), query), chat)
 	if e != nil {
 		log.Errorf("Error asking ai stream: %v", e)
 		return "", e
@@ -142,10 +142,11 @@ func populateTestData(kb *w.KnowledgeBase, ctx context.Context) error {
         {"Third Item", "TEST"},
     }
 
+    batch := kb.NewBatch(ctx)
     for _, item := range testItems {
-        kb.AddItem(ctx, item.title, "", "", item.category, "", "")
+        batch.AddItem(item.title, "", "", item.category, "", "")
     }
 
-    _, err := kb.AddToWeaviateBatch(ctx)
+    _, err := batch.Flush(ctx)
     return err
 }
\ No newline at end of file
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/samber/lo"
+	"gitlab.dev.ict/golang/go-ai/models"
+)
+
+const (
+	MimeDocx = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	MimePDF  = "application/pdf"
+)
+
+// ErrUnsupportedFile is returned when no processor is registered for the file extension or MIME type.
+var ErrUnsupportedFile = errors.New("unsupported file type")
+
+// LogicFactory creates a new processor. Processors of single sources (uploads, URLs, attachments) are created per source,
+// as WithExternalSource of the shared processor replaces sources of scheduled full runs.
+type LogicFactory func() models.Logic
+
+// ProcessorRegistry maps uploaded files to logic types of processors by extension and MIME type
+// and creates processors of logic types by registered factories.
+type ProcessorRegistry struct {
+	mu        sync.RWMutex
+	exts      map[string]models.LogicType
+	mimes     map[string]models.LogicType
+	factories map[models.LogicType]LogicFactory
+}
+
+func NewProcessorRegistry() *ProcessorRegistry {
+	return &ProcessorRegistry{exts: map[string]models.LogicType{}, mimes: map[string]models.LogicType{}, factories: map[models.LogicType]LogicFactory{}}
+}
+
+// Register maps file extensions (with or without dot) and MIME types to the logic type.
+func (r *ProcessorRegistry) Register(t models.LogicType, exts []string, mimes ...string) *ProcessorRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ext := range exts {
+		r.exts[normExt(ext)] = t
+	}
+	for _, m := range mimes {
+		r.mimes[normMime(m)] = t
+	}
+	return r
+}
+
+// RegisterFactory sets factory of processors of the logic type.
+func (r *ProcessorRegistry) RegisterFactory(t models.LogicType, f LogicFactory) *ProcessorRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[t] = f
+	return r
+}
+
+// New returns a new processor of the logic type.
+func (r *ProcessorRegistry) New(t models.LogicType) (models.Logic, error) {
+	r.mu.RLock()
+	f, ok := r.factories[t]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("processor of type %d is not registered", t)
+	}
+	return f(), nil
+}
+
+// Resolve returns logic type of the file: by extension of the file name first, then by MIME types in the given order.
+// Generic MIME types (application/octet-stream, text/plain from sniffing) are ignored unless registered explicitly.
+func (r *ProcessorRegistry) Resolve(filename string, mimes ...string) (models.LogicType, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if t, ok := r.exts[normExt(filepath.Ext(filename))]; ok {
+		return t, nil
+	}
+	for _, m := range mimes {
+		if t, ok := r.mimes[normMime(m)]; ok {
+			return t, nil
+		}
+	}
+	return models.LogicTypeUnknown, fmt.Errorf("%w: file=%s; mime=%s", ErrUnsupportedFile, filename, strings.Join(mimes, ","))
+}
+
+// Extensions returns registered extensions, e.g. for the "accept" attribute of the upload form.
+func (r *ProcessorRegistry) Extensions() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := lo.Keys(r.exts)
+	slices.Sort(res)
+	return res
+}
+
+func normExt(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+func normMime(m string) string {
+	if mt, _, err := mime.ParseMediaType(m); err == nil {
+		return mt
+	}
+	return strings.ToLower(strings.TrimSpace(m))
+}
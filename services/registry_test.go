@@ -0,0 +1,35 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.dev.ict/golang/go-ai/models"
+)
+
+func TestProcessorRegistry_Resolve(t *testing.T) {
+	r := NewProcessorRegistry().
+		Register(models.LogicTypeDocx, []string{".docx"}, MimeDocx).
+		Register(models.LogicTypePDF, []string{"PDF"}, MimePDF)
+
+	tests := []struct {
+		file  string
+		mimes []string
+		want  models.LogicType
+	}{
+		{"report.docx", nil, models.LogicTypeDocx},
+		{"Report.PDF", []string{"application/octet-stream"}, models.LogicTypePDF},
+		{"report", []string{"application/octet-stream", "application/pdf; charset=binary"}, models.LogicTypePDF},
+		{"report.bin", []string{MimeDocx}, models.LogicTypeDocx},
+	}
+	for _, tt := range tests {
+		got, err := r.Resolve(tt.file, tt.mimes...)
+		if assert.NoError(t, err, tt.file) {
+			assert.Equal(t, tt.want, got, tt.file)
+		}
+	}
+
+	_, err := r.Resolve("image.png", "image/png")
+	assert.ErrorIs(t, err, ErrUnsupportedFile)
+	assert.Equal(t, []string{".docx", ".pdf"}, r.Extensions())
+}
@@ -0,0 +1,261 @@
+package wvservice
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/gookit/goutil/errorx"
+	"github.com/gookit/slog"
+	"github.com/samber/lo"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+const (
+	// DefaultBatchSize is a count of objects sent to Weaviate in one batch request
+	DefaultBatchSize = 100
+	// DefaultBatchMaxBytes limits approximate payload size of one batch request
+	DefaultBatchMaxBytes = 8 << 20
+)
+
+// ObjectResult is a result of saving single object to Weaviate.
+type ObjectResult struct {
+	ID      string `json:"id,omitempty"`
+	Title   string `json:"title"`
+	ChunkNo int    `json:"chunkNo,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (r ObjectResult) IsOK() bool { return r.Error == "" }
+
+// BatchResult holds per object results of the batch.
+type BatchResult struct {
+	Results []ObjectResult `json:"results"`
+	Calls   int            `json:"calls"`
+}
+
+func (br *BatchResult) add(item *KnowledgeItem, id string, err error) {
+	r := ObjectResult{ID: id, Title: item.Title, ChunkNo: item.ChunkNo}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	br.Results = append(br.Results, r)
+}
+
+// Merge appends results and calls of other batch.
+func (br *BatchResult) Merge(other *BatchResult) {
+	if other == nil {
+		return
+	}
+	br.Results = append(br.Results, other.Results...)
+	br.Calls += other.Calls
+}
+
+func (br *BatchResult) Succeeded() []ObjectResult {
+	return lo.Filter(br.Results, func(r ObjectResult, _ int) bool { return r.IsOK() })
+}
+
+func (br *BatchResult) Failed() []ObjectResult {
+	return lo.Reject(br.Results, func(r ObjectResult, _ int) bool { return r.IsOK() })
+}
+
+// IDs returns IDs of successfully saved objects.
+func (br *BatchResult) IDs() []string {
+	return lo.Map(br.Succeeded(), func(r ObjectResult, _ int) string { return r.ID })
+}
+
+// Err returns error map of failed objects (key is "title#chunkNo") or nil.
+func (br *BatchResult) Err() error {
+	errMap := errorx.ErrMap{}
+	for _, r := range br.Failed() {
+		errMap[fmt.Sprintf("%s#%d", r.Title, r.ChunkNo)] = errorx.E(r.Error)
+	}
+	return errMap.ErrorOrNil()
+}
+
+func (br *BatchResult) String() string {
+	return fmt.Sprintf("BatchResult: objects=%d ok=%d failed=%d calls=%d", len(br.Results), len(br.Succeeded()), len(br.Failed()), br.Calls)
+}
+
+// Batch is a request-scoped staging buffer of KnowledgeItems. Create one per upload/ingestion with KnowledgeBase.NewBatch,
+// so concurrent uploads never see items of each other.
+type Batch struct {
+	kb       *KnowledgeBase
+	log      *slog.Record
+	size     int
+	maxBytes int
+
+	mu    sync.Mutex
+	items []*KnowledgeItem
+}
+
+// NewBatch creates empty batch bound to the knowledge base class.
+func (kb *KnowledgeBase) NewBatch(ctx context.Context) *Batch {
+	return &Batch{kb: kb, log: kb.log.RecWithCtx(ctx, ch), size: DefaultBatchSize, maxBytes: DefaultBatchMaxBytes}
+}
+
+func (b *Batch) WithSize(n int) *Batch {
+	b.size = lo.Ternary(n > 0, n, DefaultBatchSize)
+	return b
+}
+
+func (b *Batch) WithMaxBytes(n int) *Batch {
+	b.maxBytes = lo.Ternary(n > 0, n, DefaultBatchMaxBytes)
+	return b
+}
+
+// AddItem creates item and adds it to the batch, see Add.
+func (b *Batch) AddItem(title, content, url, category, summary, keyWords string) *Batch {
+	return b.Add(NewKI(title, content, url, category, summary, keyWords))
+}
+
+// Add splits items by the knowledge base chunker (see KnowledgeBase.WithChunker) and adds chunks to the batch.
+// Safe for concurrent use.
+func (b *Batch) Add(items ...*KnowledgeItem) *Batch {
+	var chunks []*KnowledgeItem
+	for _, item := range items {
+		b.log.Infof("Batch add: %s", item)
+		chunks = append(chunks, b.kb.SplitItem(b.log, item)...)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.items = append(b.items, chunks...)
+	return b
+}
+
+func (b *Batch) Items() []*KnowledgeItem {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*KnowledgeItem{}, b.items...)
+}
+
+func (b *Batch) Len() int { return len(b.Items()) }
+
+// take returns staged items and empties the batch.
+func (b *Batch) take() []*KnowledgeItem {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	items := b.items
+	b.items = nil
+	return items
+}
+
+// Objects converts staged items to Weaviate objects. Item with ID (_additional.id) keeps it, so the object is overwritten.
+func (b *Batch) Objects() []*models.Object {
+	return lo.Map(b.Items(), func(item *KnowledgeItem, _ int) *models.Object { return b.object(item) })
+}
+
+func (b *Batch) object(item *KnowledgeItem) *models.Object {
+	obj := item.ToWeaviate(b.kb.Class)
+	if item.ID() != "" {
+		obj.ID = strfmt.UUID(item.ID())
+	}
+	return obj
+}
+
+// parts divides items into groups limited by batch size and approximate payload size.
+func (b *Batch) parts(items []*KnowledgeItem) (res [][]*KnowledgeItem) {
+	var (
+		cur   []*KnowledgeItem
+		bytes int
+	)
+	for _, item := range items {
+		n := len(item.Title) + len(item.Content) + len(item.URL) + len(item.Summary) + len(item.KeyWords) + len(item.SectionPath)
+		if len(cur) > 0 && (len(cur) >= b.size || bytes+n > b.maxBytes) {
+			res, cur, bytes = append(res, cur), nil, 0
+		}
+		cur, bytes = append(cur, item), bytes+n
+	}
+	if len(cur) > 0 {
+		res = append(res, cur)
+	}
+	return
+}
+
+// Flush saves staged items via the batch API (split into several calls if needed) and empties the batch.
+// Returned error is not nil only if some objects were not saved, see BatchResult.Failed for details.
+func (b *Batch) Flush(ctx context.Context) (*BatchResult, error) {
+	items := b.take()
+	res := &BatchResult{}
+	for _, part := range b.parts(items) {
+		res.Calls++
+		vectors, err := b.kb.embedItems(ctx, part)
+		if err != nil {
+			b.log.Errorf("Batch call %d (objects=%d) embedding failed: %v", res.Calls, len(part), err)
+			for _, item := range part {
+				res.add(item, item.ID(), err)
+			}
+			continue
+		}
+		b.send(ctx, res, part, vectors)
+	}
+	b.log.Infof("Batch flushed: %s", res)
+	return res, res.Err()
+}
+
+// send saves part of items with vectors (nil - vectorized by Weaviate) in one batch call and adds results to res.
+func (b *Batch) send(ctx context.Context, res *BatchResult, part []*KnowledgeItem, vectors [][]float32) {
+	batcher := b.kb.Client.Batch().ObjectsBatcher()
+	for i, item := range part {
+		obj := b.object(item)
+		if vectors != nil {
+			obj.Vector = vectors[i]
+		}
+		batcher.WithObjects(obj)
+	}
+
+	resp, err := batcher.Do(ctx)
+	if err != nil {
+		b.log.Errorf("Batch call %d (objects=%d) failed: %v", res.Calls, len(part), err)
+		for _, item := range part {
+			res.add(item, item.ID(), err)
+		}
+		return
+	}
+	for i, item := range part {
+		if i >= len(resp) {
+			res.add(item, item.ID(), errorx.E("object is absent in batch response"))
+			continue
+		}
+		var e error
+		if r := resp[i].Result; r != nil && r.Errors != nil && len(r.Errors.Error) > 0 {
+			e = errorx.E(r.Errors.Error[0].Message)
+		}
+		res.add(item, resp[i].ID.String(), e)
+	}
+}
+
+// Update overwrites properties of staged items which already exist in Weaviate and empties the batch.
+// Item without ID is looked up by title.
+func (b *Batch) Update(ctx context.Context) (*BatchResult, error) {
+	res := &BatchResult{}
+	for _, item := range b.take() {
+		id := item.ID()
+		if id == "" {
+			if found := b.kb.GetObjByTitleEQ(ctx, item.Title, FieldAdditional1); len(found) > 0 {
+				id = found[0].ID()
+			}
+		}
+		if id == "" {
+			res.add(item, "", errorx.Ef("object with title[%s] not found", item.Title))
+			continue
+		}
+
+		res.Calls++
+		updater := b.kb.Client.Data().Updater().WithClassName(b.kb.Class).WithID(id).WithProperties(item.ToWeaviate(b.kb.Class).Properties)
+		vectors, err := b.kb.embedItems(ctx, []*KnowledgeItem{item})
+		if err == nil {
+			if vectors != nil {
+				updater = updater.WithVector(vectors[0])
+			}
+			err = updater.Do(ctx)
+		}
+		if err != nil {
+			b.log.Errorf("Weaviate update object with id=%s failed. Error: %v", id, err)
+		}
+		res.add(item, id, err)
+	}
+	b.log.Infof("Batch updated: %s", res)
+	return res, res.Err()
+}
@@ -0,0 +1,43 @@
+package wvservice
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch_Parts(t *testing.T) {
+	var items []*KnowledgeItem
+	for i := 0; i < 5; i++ {
+		items = append(items, &KnowledgeItem{Title: "t", ChunkNo: i, Content: strings.Repeat("x", 100)})
+	}
+
+	parts := (&Batch{size: 2, maxBytes: DefaultBatchMaxBytes}).parts(items)
+	assert.Equal(t, []int{2, 2, 1}, lenParts(parts))
+
+	parts = (&Batch{size: DefaultBatchSize, maxBytes: 250}).parts(items)
+	assert.Equal(t, []int{2, 2, 1}, lenParts(parts), "limited by payload size")
+
+	parts = (&Batch{size: DefaultBatchSize, maxBytes: 10}).parts(items)
+	assert.Equal(t, []int{1, 1, 1, 1, 1}, lenParts(parts), "too large item is sent alone")
+}
+
+func TestBatchResult(t *testing.T) {
+	res := &BatchResult{}
+	res.add(&KnowledgeItem{Title: "A"}, "id-a", nil)
+	res.add(&KnowledgeItem{Title: "B", ChunkNo: 2}, "id-b", errors.New("boom"))
+
+	assert.Equal(t, []string{"id-a"}, res.IDs())
+	assert.Len(t, res.Failed(), 1)
+	assert.ErrorContains(t, res.Err(), "boom")
+	assert.NoError(t, (&BatchResult{}).Err())
+}
+
+func lenParts(parts [][]*KnowledgeItem) (res []int) {
+	for _, p := range parts {
+		res = append(res, len(p))
+	}
+	return
+}
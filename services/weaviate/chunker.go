@@ -0,0 +1,392 @@
+package wvservice
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	tokenizer "github.com/samber/go-gpt-3-encoder"
+	"github.com/samber/lo"
+)
+
+const (
+	DefaultChunkTargetTokens  = 800
+	DefaultChunkOverlapTokens = 100
+	SectionPathSep            = " > "
+)
+
+var (
+	reHeading       = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*$`)
+	reTableBorder   = regexp.MustCompile(`^[\s\-+|:=]{3,}$`)
+	reSentenceSplit = regexp.MustCompile(`([.!?;])\s+`)
+
+	// gptEncoder loads BPE encodings once, encoder is safe for concurrent use
+	gptEncoder = sync.OnceValue(func() *tokenizer.Encoder { return lo.Must(tokenizer.NewEncoder()) })
+)
+
+// Chunk is a part of document content prepared for vectorization.
+type Chunk struct {
+	Content     string `json:"content"`
+	SectionPath string `json:"sectionPath,omitempty"`
+	Tokens      int    `json:"tokens"`
+}
+
+// Chunker splits document content into chunks.
+type Chunker interface {
+	Split(content string) []Chunk
+}
+
+// ChunkerFunc is an adapter to allow the use of ordinary functions as Chunker.
+type ChunkerFunc func(content string) []Chunk
+
+func (fn ChunkerFunc) Split(content string) []Chunk { return fn(content) }
+
+// ChunkOpts holds sizing parameters shared by chunkers.
+type ChunkOpts struct {
+	TargetTokens  int `json:"targetTokens"`
+	OverlapTokens int `json:"overlapTokens"`
+}
+
+func DefaultChunkOpts() ChunkOpts {
+	return ChunkOpts{TargetTokens: DefaultChunkTargetTokens, OverlapTokens: DefaultChunkOverlapTokens}
+}
+
+func (o ChunkOpts) normalize() ChunkOpts {
+	if o.TargetTokens <= 0 || o.TargetTokens > MAX_TOKEN_SUPPORT {
+		o.TargetTokens = lo.Ternary(o.TargetTokens <= 0, DefaultChunkTargetTokens, MAX_TOKEN_SUPPORT)
+	}
+	if o.OverlapTokens < 0 || o.OverlapTokens >= o.TargetTokens {
+		o.OverlapTokens = 0
+	}
+	return o
+}
+
+// ParagraphChunker packs paragraphs into chunks up to TargetTokens.
+// Tables are never split unless a single table exceeds MAX_TOKEN_SUPPORT,
+// in that case it is divided by rows with the header repeated in every part.
+type ParagraphChunker struct {
+	ChunkOpts
+}
+
+func NewParagraphChunker(target, overlap int) *ParagraphChunker {
+	return &ParagraphChunker{ChunkOpts: ChunkOpts{TargetTokens: target, OverlapTokens: overlap}}
+}
+
+func (pc *ParagraphChunker) Split(content string) []Chunk {
+	return newChunkBuilder(pc.ChunkOpts).pack(splitBlocks(content), "")
+}
+
+// HeadingChunker splits content by markdown headings ("# Title") and packs every section with ParagraphChunker rules.
+// Each chunk gets SectionPath built from the chain of parent headings, e.g.: "Tariffs > FMC > Settings".
+type HeadingChunker struct {
+	ChunkOpts
+}
+
+func NewHeadingChunker(target, overlap int) *HeadingChunker {
+	return &HeadingChunker{ChunkOpts: ChunkOpts{TargetTokens: target, OverlapTokens: overlap}}
+}
+
+func DefaultChunker() Chunker {
+	o := DefaultChunkOpts()
+	return NewHeadingChunker(o.TargetTokens, o.OverlapTokens)
+}
+
+func (hc *HeadingChunker) Split(content string) (chunks []Chunk) {
+	b := newChunkBuilder(hc.ChunkOpts)
+	for _, s := range splitSections(content) {
+		chunks = append(chunks, b.pack(splitBlocks(s.body), s.path)...)
+	}
+	return
+}
+
+type section struct {
+	path string
+	body string
+}
+
+// splitSections divides content by markdown headings. Heading line stays in body of its section.
+func splitSections(content string) (sections []section) {
+	var (
+		stack  []string
+		lvls   []int
+		buf    strings.Builder
+		path   string
+		inCode bool
+	)
+	flush := func() {
+		if strings.TrimSpace(buf.String()) != "" {
+			sections = append(sections, section{path: path, body: buf.String()})
+		}
+		buf.Reset()
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+		}
+		if m := reHeading.FindStringSubmatch(strings.TrimSpace(line)); m != nil && !inCode {
+			flush()
+			lvl := len(m[1])
+			for len(lvls) > 0 && lvls[len(lvls)-1] >= lvl {
+				stack, lvls = stack[:len(stack)-1], lvls[:len(lvls)-1]
+			}
+			stack, lvls = append(stack, strings.TrimSpace(m[2])), append(lvls, lvl)
+			path = strings.Join(stack, SectionPathSep)
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	flush()
+	return
+}
+
+type blockKind int
+
+const (
+	blockText blockKind = iota
+	blockTable
+	blockCode
+)
+
+type block struct {
+	kind blockKind
+	text string
+}
+
+// splitBlocks divides text into paragraphs, tables and fenced code blocks.
+func splitBlocks(content string) (blocks []block) {
+	var (
+		cur  []string
+		kind = blockText
+	)
+	flush := func() {
+		if txt := strings.TrimSpace(strings.Join(cur, "\n")); txt != "" {
+			blocks = append(blocks, block{kind: kind, text: txt})
+		}
+		cur, kind = nil, blockText
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case kind == blockCode:
+			cur = append(cur, line)
+			if strings.HasPrefix(trimmed, "```") {
+				flush()
+			}
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			kind, cur = blockCode, []string{line}
+			if len(trimmed) > 3 && strings.HasSuffix(trimmed, "```") {
+				flush()
+			}
+		case isTableLine(trimmed):
+			if kind != blockTable {
+				flush()
+				kind = blockTable
+			}
+			cur = append(cur, line)
+		case trimmed == "":
+			flush()
+		default:
+			if kind == blockTable {
+				flush()
+			}
+			cur = append(cur, line)
+		}
+	}
+	flush()
+	return
+}
+
+func isTableLine(s string) bool {
+	if s == "" {
+		return false
+	}
+	return strings.HasPrefix(s, "|") || strings.Contains(s, " | ") || reTableBorder.MatchString(s) && strings.ContainsAny(s, "+|")
+}
+
+type chunkBuilder struct {
+	ChunkOpts
+	enc *tokenizer.Encoder
+}
+
+func newChunkBuilder(o ChunkOpts) *chunkBuilder {
+	return &chunkBuilder{ChunkOpts: o.normalize(), enc: gptEncoder()}
+}
+
+func (b *chunkBuilder) count(s string) int {
+	return len(lo.Must(b.enc.Encode(s)))
+}
+
+// pack greedily joins blocks into chunks, adding overlap from the tail of the previous chunk.
+func (b *chunkBuilder) pack(blocks []block, path string) (chunks []Chunk) {
+	var (
+		parts  []string
+		tokens int
+		isNew  bool // parts contain something except overlap
+	)
+	emit := func(last block) {
+		txt := strings.Join(parts, "\n\n")
+		chunks = append(chunks, Chunk{Content: txt, SectionPath: path, Tokens: b.count(txt)})
+		parts, tokens, isNew = nil, 0, false
+		if ov := b.overlap(last); ov != "" {
+			parts, tokens = []string{ov}, b.count(ov)
+		}
+	}
+
+	var last block
+	for _, bl := range b.explode(blocks) {
+		t := b.count(bl.text)
+		if isNew && tokens+t > b.TargetTokens {
+			emit(last)
+			if tokens+t > b.TargetTokens {
+				parts, tokens = nil, 0
+			}
+		}
+		parts, tokens, isNew, last = append(parts, bl.text), tokens+t, true, bl
+	}
+	if isNew {
+		emit(block{})
+	}
+	return
+}
+
+// overlap returns trailing words of text block limited by OverlapTokens. Tables and code are never overlapped.
+func (b *chunkBuilder) overlap(bl block) string {
+	if b.OverlapTokens == 0 || bl.kind != blockText {
+		return ""
+	}
+	words := strings.Fields(bl.text)
+	i := len(words)
+	for i > 0 && b.count(strings.Join(words[i-1:], " ")) <= b.OverlapTokens {
+		i--
+	}
+	return strings.Join(words[i:], " ")
+}
+
+// explode splits blocks that exceed TargetTokens: text by sentences and words, tables by rows when they exceed MAX_TOKEN_SUPPORT.
+func (b *chunkBuilder) explode(blocks []block) (res []block) {
+	for _, bl := range blocks {
+		t := b.count(bl.text)
+		switch {
+		case bl.kind == blockText && t > b.TargetTokens:
+			for _, s := range b.splitText(bl.text) {
+				res = append(res, block{kind: blockText, text: s})
+			}
+		case bl.kind != blockText && t > MAX_TOKEN_SUPPORT:
+			for _, s := range b.splitRows(bl.text) {
+				res = append(res, block{kind: bl.kind, text: s})
+			}
+		default:
+			res = append(res, bl)
+		}
+	}
+	return
+}
+
+func (b *chunkBuilder) splitText(text string) (res []string) {
+	var cur []string
+	flush := func() {
+		if len(cur) > 0 {
+			res = append(res, strings.Join(cur, " "))
+			cur = nil
+		}
+	}
+	for _, sent := range strings.Split(reSentenceSplit.ReplaceAllString(text, "$1\n"), "\n") {
+		if sent = strings.TrimSpace(sent); sent == "" {
+			continue
+		}
+		for _, piece := range b.splitWords(sent) {
+			if len(cur) > 0 && b.count(strings.Join(append(cur, piece), " ")) > b.TargetTokens {
+				flush()
+			}
+			cur = append(cur, piece)
+		}
+	}
+	flush()
+	return
+}
+
+// splitWords divides single sentence which is larger than TargetTokens.
+func (b *chunkBuilder) splitWords(sent string) (res []string) {
+	if b.count(sent) <= b.TargetTokens {
+		return []string{sent}
+	}
+	var cur []string
+	for _, w := range strings.Fields(sent) {
+		if len(cur) > 0 && b.count(strings.Join(append(cur, w), " ")) > b.TargetTokens {
+			res, cur = append(res, strings.Join(cur, " ")), nil
+		}
+		cur = append(cur, w)
+	}
+	if len(cur) > 0 {
+		res = append(res, strings.Join(cur, " "))
+	}
+	return
+}
+
+// splitRows divides huge table into parts with repeated header (first line and border line if present).
+func (b *chunkBuilder) splitRows(table string) (res []string) {
+	lines := strings.Split(table, "\n")
+	hdrLen := 1
+	if len(lines) > 1 && reTableBorder.MatchString(strings.TrimSpace(lines[1])) {
+		hdrLen = 2
+	}
+	hdrLen = min(hdrLen, len(lines))
+	header := lines[:hdrLen]
+	limit := min(b.TargetTokens, MAX_TOKEN_SUPPORT)
+
+	cur := append([]string{}, header...)
+	for _, row := range lines[hdrLen:] {
+		if len(cur) > hdrLen && b.count(strings.Join(append(cur, row), "\n")) > limit {
+			res, cur = append(res, strings.Join(cur, "\n")), append([]string{}, header...)
+		}
+		cur = append(cur, row)
+	}
+	if len(cur) > hdrLen || len(res) == 0 {
+		res = append(res, strings.Join(cur, "\n"))
+	}
+	return
+}
+
+var rePageMarker = regexp.MustCompile(`\[\[page:(\d+)\]\]\s*`)
+
+// PageMarker returns marker of the page start, placed by processors (PDF) on a separate line before text of the page.
+// Markers are removed from chunks and converted to KnowledgeItem.Pages on split.
+func PageMarker(n int) string {
+	return "[[page:" + strconv.Itoa(n) + "]]"
+}
+
+// applyPages sets pages of chunks by page markers and removes markers from the content.
+// Text of the chunk before the first marker belongs to the last page of the previous chunk.
+func applyPages(items []*KnowledgeItem) {
+	cur := 0
+	for _, item := range items {
+		locs := rePageMarker.FindAllStringSubmatchIndex(item.Content, -1)
+		if len(locs) == 0 {
+			if cur > 0 {
+				item.Pages = []int{cur}
+			}
+			continue
+		}
+
+		var pages []int
+		if cur > 0 && strings.TrimSpace(item.Content[:locs[0][0]]) != "" {
+			pages = append(pages, cur)
+		}
+		for i, loc := range locs {
+			cur, _ = strconv.Atoi(item.Content[loc[2]:loc[3]])
+			end := len(item.Content)
+			if i+1 < len(locs) {
+				end = locs[i+1][0]
+			}
+			if strings.TrimSpace(item.Content[loc[1]:end]) != "" && !lo.Contains(pages, cur) {
+				pages = append(pages, cur)
+			}
+		}
+		item.Pages = pages
+		item.Content = strings.TrimSpace(rePageMarker.ReplaceAllString(item.Content, ""))
+	}
+}
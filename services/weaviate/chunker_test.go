@@ -0,0 +1,115 @@
+package wvservice
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gookit/slog"
+	"github.com/stretchr/testify/assert"
+)
+
+const mdDoc = `# Tariffs
+Common information about tariffs.
+
+## FMC
+Fixed mobile convergence tariffs.
+
+### Settings
+First paragraph about settings.
+
+Second paragraph about settings.
+
+  Name    | Price | Minutes
+----------+-------+----------
+  Basic   | 100   | 300
+  Premium | 250   | unlimited
+
+## Roaming
+Roaming tariffs description.`
+
+func TestHeadingChunker_SectionPath(t *testing.T) {
+	chunks := NewHeadingChunker(200, 0).Split(mdDoc)
+	paths := []string{}
+	for _, c := range chunks {
+		paths = append(paths, c.SectionPath)
+	}
+	assert.Equal(t, []string{"Tariffs", "Tariffs > FMC", "Tariffs > FMC > Settings", "Tariffs > Roaming"}, paths)
+	assert.Contains(t, chunks[2].Content, "Premium | 250")
+}
+
+func TestHeadingChunker_TableKeptWhole(t *testing.T) {
+	var rows []string
+	for i := 0; i < 40; i++ {
+		rows = append(rows, "  option  | value number "+strings.Repeat("x", i%5))
+	}
+	doc := "# Table\nIntro text.\n\n  Header | Value\n---------+------\n" + strings.Join(rows, "\n") + "\n\nTail text."
+
+	chunks := NewHeadingChunker(50, 10).Split(doc)
+	tables := 0
+	for _, c := range chunks {
+		if strings.Contains(c.Content, "Header | Value") {
+			tables++
+			assert.Equal(t, 40, strings.Count(c.Content, "option"), "table must not be split")
+		}
+	}
+	assert.Equal(t, 1, tables)
+}
+
+func TestParagraphChunker_SizeAndOverlap(t *testing.T) {
+	var paragraphs []string
+	for i := 0; i < 20; i++ {
+		paragraphs = append(paragraphs, strings.Repeat("Lifecell provides mobile services for customers. ", 5))
+	}
+	pc := NewParagraphChunker(120, 20)
+	chunks := pc.Split(strings.Join(paragraphs, "\n\n"))
+
+	assert.Greater(t, len(chunks), 1)
+	for i, c := range chunks {
+		assert.LessOrEqual(t, c.Tokens, 120+20, "chunk %d too large", i)
+		assert.Empty(t, c.SectionPath)
+	}
+	words := strings.Fields(chunks[0].Content)
+	assert.Contains(t, chunks[1].Content[:200], strings.Join(words[len(words)-3:], " "), "next chunk starts with overlap")
+}
+
+func TestSplitBlocks(t *testing.T) {
+	blocks := splitBlocks("text line\n  A | B\n----+---\n  1 | 2\nafter table\n\n```\ncode | not table\n\n```")
+	kinds := []blockKind{}
+	for _, b := range blocks {
+		kinds = append(kinds, b.kind)
+	}
+	assert.Equal(t, []blockKind{blockText, blockTable, blockText, blockCode}, kinds)
+}
+
+func TestApplyPages(t *testing.T) {
+	items := []*KnowledgeItem{
+		{Content: PageMarker(1) + "\n\nFirst page.\n\n" + PageMarker(2) + "\n\nSecond page start"},
+		{Content: "second page end\n\n" + PageMarker(3)},
+		{Content: PageMarker(4) + "\n\nFourth page."},
+		{Content: "still fourth"},
+	}
+	applyPages(items)
+	assert.Equal(t, []int{1, 2}, items[0].Pages)
+	assert.Equal(t, "First page.\n\nSecond page start", items[0].Content)
+	assert.Equal(t, []int{2}, items[1].Pages)
+	assert.Equal(t, "second page end", items[1].Content)
+	assert.Equal(t, []int{4}, items[2].Pages)
+	assert.Equal(t, []int{4}, items[3].Pages)
+}
+
+func TestSplitItem_Pages(t *testing.T) {
+	var pages []string
+	for i := 1; i <= 3; i++ {
+		pages = append(pages, PageMarker(i)+"\n\n"+strings.Repeat("Text of the page. ", 60))
+	}
+	item := &KnowledgeItem{Title: "doc.pdf", Content: strings.Join(pages, "\n\n")}
+	items := splitItem(NewParagraphChunker(200, 0), slog.Std().Record(), item)
+	if assert.Greater(t, len(items), 1) {
+		assert.Equal(t, []int{1}, items[0].Pages)
+		assert.Equal(t, []int{3}, items[len(items)-1].Pages)
+		for _, it := range items {
+			assert.NotContains(t, it.Content, "[[page:")
+			assert.NotEmpty(t, it.Pages)
+		}
+	}
+}
@@ -41,6 +41,12 @@ func (a AdditionalMap) LastUpdateTime() *time.Time {
 }
 
 func (a AdditionalMap) Score() float64 {
-	v, _ := strconv.ParseFloat(a["score"].(string), 64)
-	return v
+	switch v := a["score"].(type) {
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	case float64:
+		return v
+	}
+	return 0
 }
@@ -0,0 +1,314 @@
+package wvservice
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gookit/goutil/errorx"
+	"github.com/samber/lo"
+)
+
+const (
+	DefaultDumpPageSize = 200
+	maxDumpLineBytes    = 64 << 20 // object with vector and large content
+)
+
+// DumpRecord is a line of JSONL dump of the vector store, see Export and Import.
+type DumpRecord struct {
+	ID                 string         `json:"id"`
+	Properties         *KnowledgeItem `json:"properties"`
+	Vector             []float32      `json:"vector,omitempty"`
+	CreationTimeUnix   int64          `json:"creationTimeUnix,omitempty"`
+	LastUpdateTimeUnix int64          `json:"lastUpdateTimeUnix,omitempty"`
+}
+
+// Dumper is implemented by stores which can export objects with vectors and restore them with the same IDs.
+type Dumper interface {
+	// Dump returns up to limit records ordered by ID, starting after the cursor ID (empty - from the beginning).
+	Dump(ctx context.Context, limit int, after string, withVector bool) ([]*DumpRecord, error)
+	// Restore saves records with their IDs. Records without vector (all records if reEmbed) are vectorized by the store.
+	Restore(ctx context.Context, reEmbed bool, recs ...*DumpRecord) (*BatchResult, error)
+}
+
+var (
+	_ Dumper = (*KnowledgeBase)(nil)
+	_ Dumper = (*MemoryStore)(nil)
+)
+
+// DumpOptions of Export.
+type DumpOptions struct {
+	PageSize   int  `json:"pageSize,omitempty"`
+	WithVector bool `json:"withVector,omitempty"`
+}
+
+// ImportOptions of Import.
+type ImportOptions struct {
+	BatchSize int  `json:"batchSize,omitempty"`
+	ReEmbed   bool `json:"reEmbed,omitempty"` // ignore vectors of the dump, e.g. when target uses another embedding model
+}
+
+// Export writes all objects of the store to out as JSONL (one DumpRecord per line) page by page
+// and returns count of written records.
+func Export(ctx context.Context, d Dumper, out io.Writer, opts DumpOptions) (int, error) {
+	size := lo.Ternary(opts.PageSize > 0, opts.PageSize, DefaultDumpPageSize)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+
+	var after string
+	count := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		recs, err := d.Dump(ctx, size, after, opts.WithVector)
+		if err != nil {
+			return count, fmt.Errorf("dump after[%s]: %w", after, err)
+		}
+		for _, rec := range recs {
+			if err = enc.Encode(rec); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if len(recs) < size {
+			return count, nil
+		}
+		after = recs[len(recs)-1].ID
+	}
+}
+
+// Import reads JSONL dump (see Export) and restores records to the store by batches.
+// Invalid lines are reported as failed objects, returned error is not nil if some objects were not restored.
+func Import(ctx context.Context, d Dumper, in io.Reader, opts ImportOptions) (*BatchResult, error) {
+	size := lo.Ternary(opts.BatchSize > 0, opts.BatchSize, DefaultBatchSize)
+	res := &BatchResult{}
+	var recs []*DumpRecord
+	flush := func() error {
+		if len(recs) == 0 {
+			return nil
+		}
+		part, err := d.Restore(ctx, opts.ReEmbed, recs...)
+		if part == nil && err != nil {
+			return err
+		}
+		res.Merge(part)
+		recs = recs[:0]
+		return ctx.Err()
+	}
+
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 0, 1<<20), maxDumpLineBytes)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec DumpRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			res.add(&KnowledgeItem{Title: fmt.Sprintf("line %d", line)}, "", err)
+			continue
+		}
+		recs = append(recs, &rec)
+		if len(recs) >= size {
+			if err := flush(); err != nil {
+				return res, err
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return res, err
+	}
+	if err := flush(); err != nil {
+		return res, err
+	}
+	return res, res.Err()
+}
+
+// ImportTarget returns store to import into. If class is empty or equals class of the store, the store itself is returned.
+// For another class KnowledgeBase bound to the class is returned, class is created from the embedded schema if absent.
+func ImportTarget(ctx context.Context, store VectorStore, class string) (Dumper, error) {
+	kb, isKB := store.(*KnowledgeBase)
+	if class == "" || isKB && class == kb.Class {
+		d, ok := store.(Dumper)
+		if !ok {
+			return nil, errorx.Rawf("store %T does not support import", store)
+		}
+		return d, nil
+	}
+	if !isKB {
+		return nil, errorx.Rawf("import into class[%s] is supported by Weaviate store only", class)
+	}
+	target := kb.WithClass(class)
+	if err := target.CreateClassFromVar(ctx, kb_json_file); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// WithClass returns copy of the knowledge base bound to another class (client, chunker and embedder are shared).
+func (kb *KnowledgeBase) WithClass(class string) *KnowledgeBase {
+	c := *kb
+	c.Class = class
+	return &c
+}
+
+// Dump returns page of objects using REST list with after cursor, see Dumper.
+func (kb *KnowledgeBase) Dump(ctx context.Context, limit int, after string, withVector bool) ([]*DumpRecord, error) {
+	getter := kb.Client.Data().ObjectsGetter().WithClassName(kb.Class).WithLimit(limit)
+	if after != "" {
+		getter = getter.WithAfter(after)
+	}
+	if withVector {
+		getter = getter.WithVector()
+	}
+	objs, err := getter.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	recs := make([]*DumpRecord, 0, len(objs))
+	for _, obj := range objs {
+		item := ObjectToItem(obj)
+		item.Additional = nil
+		recs = append(recs, &DumpRecord{
+			ID:                 obj.ID.String(),
+			Properties:         item,
+			Vector:             obj.Vector,
+			CreationTimeUnix:   obj.CreationTimeUnix,
+			LastUpdateTimeUnix: obj.LastUpdateTimeUnix,
+		})
+	}
+	return recs, nil
+}
+
+// Restore saves records with batch API, see Dumper. Timestamps of records are assigned by Weaviate.
+func (kb *KnowledgeBase) Restore(ctx context.Context, reEmbed bool, recs ...*DumpRecord) (*BatchResult, error) {
+	b := kb.NewBatch(ctx)
+	res := &BatchResult{}
+	vectors := map[*KnowledgeItem][]float32{}
+	var items []*KnowledgeItem
+	for _, rec := range recs {
+		item, err := rec.item()
+		if err != nil {
+			res.add(lo.Ternary(rec.Properties != nil, rec.Properties, &KnowledgeItem{}), rec.ID, err)
+			continue
+		}
+		if !reEmbed && len(rec.Vector) > 0 {
+			vectors[item] = rec.Vector
+		}
+		items = append(items, item)
+	}
+
+	for _, part := range b.parts(items) {
+		res.Calls++
+		missing := lo.Filter(part, func(item *KnowledgeItem, _ int) bool { return vectors[item] == nil })
+		embedded, err := kb.embedItems(ctx, missing)
+		if err != nil {
+			b.log.Errorf("Restore call %d (objects=%d) embedding failed: %v", res.Calls, len(part), err)
+			for _, item := range part {
+				res.add(item, item.ID(), err)
+			}
+			continue
+		}
+		for i, v := range embedded {
+			vectors[missing[i]] = v
+		}
+		b.send(ctx, res, part, lo.Map(part, func(item *KnowledgeItem, _ int) []float32 { return vectors[item] }))
+	}
+	b.log.Infof("Restore class=%s: %s", kb.Class, res)
+	return res, res.Err()
+}
+
+// Dump returns page of documents ordered by ID, see Dumper.
+func (s *MemoryStore) Dump(_ context.Context, limit int, after string, withVector bool) ([]*DumpRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lo.Map(s.pageIDs(limit, after), func(id string, _ int) *DumpRecord {
+		d := s.docs[id]
+		item := d.Item.clone()
+		item.Additional = nil
+		rec := &DumpRecord{ID: id, Properties: item, CreationTimeUnix: d.Created, LastUpdateTimeUnix: d.Updated}
+		if withVector {
+			rec.Vector = slices.Clone(d.Vector)
+		}
+		return rec
+	}), nil
+}
+
+// Restore saves records keeping their timestamps, see Dumper.
+func (s *MemoryStore) Restore(ctx context.Context, reEmbed bool, recs ...*DumpRecord) (*BatchResult, error) {
+	res := &BatchResult{Calls: 1}
+	type restored struct {
+		rec  *DumpRecord
+		item *KnowledgeItem
+		vec  []float32
+	}
+	var valid []*restored
+	for _, rec := range recs {
+		item, err := rec.item()
+		if err == nil && item.ID() == "" {
+			item.Additional["id"] = uuid.NewString()
+		}
+		if err == nil {
+			_, err = uuid.Parse(item.ID())
+		}
+		if err != nil {
+			res.add(lo.Ternary(rec.Properties != nil, rec.Properties, &KnowledgeItem{}), rec.ID, err)
+			continue
+		}
+		valid = append(valid, &restored{rec: rec, item: item, vec: lo.Ternary(reEmbed, nil, rec.Vector)})
+	}
+
+	missing := lo.Filter(valid, func(r *restored, _ int) bool { return len(r.vec) == 0 })
+	if s.embed != nil && len(missing) > 0 {
+		vectors, err := s.embed.EmbedDocuments(ctx, lo.Map(missing, func(r *restored, _ int) string { return r.item.EmbedText() }))
+		if err == nil && len(vectors) != len(missing) {
+			err = fmt.Errorf("embedder returned %d vectors for %d items", len(vectors), len(missing))
+		}
+		if err != nil {
+			for _, r := range valid {
+				res.add(r.item, r.item.ID(), err)
+			}
+			return res, res.Err()
+		}
+		for i, r := range missing {
+			r.vec = vectors[i]
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	s.mu.Lock()
+	for _, r := range valid {
+		stored := r.item.clone()
+		stored.Additional = nil
+		d := &memDoc{
+			Item:    stored,
+			Vector:  r.vec,
+			Created: lo.Ternary(r.rec.CreationTimeUnix > 0, r.rec.CreationTimeUnix, now),
+			Updated: lo.Ternary(r.rec.LastUpdateTimeUnix > 0, r.rec.LastUpdateTimeUnix, now),
+		}
+		d.index()
+		s.docs[r.item.ID()] = d
+		s.dirty = true
+		res.add(r.item, r.item.ID(), nil)
+	}
+	s.mu.Unlock()
+	return res, res.Err()
+}
+
+// item returns copy of properties with ID of the record in Additional.
+func (rec *DumpRecord) item() (*KnowledgeItem, error) {
+	if rec.Properties == nil {
+		return nil, errorx.Rawf("record[%s] has no properties", rec.ID)
+	}
+	if rec.Properties.Title == "" && rec.Properties.Content == "" {
+		return nil, errorx.Rawf("record[%s] has neither title nor content", rec.ID)
+	}
+	item := rec.Properties.clone()
+	item.Additional = AdditionalMap{"id": rec.ID}
+	return item, nil
+}
@@ -0,0 +1,101 @@
+package wvservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport_MemoryStore(t *testing.T) {
+	ctx := context.Background()
+	embedCalls := 0
+	embed := EmbedFunc(func(_ context.Context, texts []string) ([][]float32, error) {
+		embedCalls += len(texts)
+		res := make([][]float32, len(texts))
+		for i := range texts {
+			res[i] = []float32{1, 0}
+		}
+		return res, nil
+	})
+	src := NewMemoryStore(logTestKB).WithEmbedder(embed)
+	_, err := src.Upsert(ctx,
+		NewKI("VoIP error 409", "Error 409 means the number is already registered", "https://wiki/voip/409", "voip", "", "voip,409"),
+		NewKI("Tariff change", "How to change tariff plan", "https://lifecell.ua/tariffs", "web", "", "tariff"),
+		NewKI("Tagged", "Content with tags", "https://wiki/tagged", "confluence", "", "").WithTags("voip"),
+	)
+	require.NoError(t, err)
+	total, _ := src.Count(ctx)
+
+	var buf bytes.Buffer
+	count, err := Export(ctx, src, &buf, DumpOptions{PageSize: 2, WithVector: true})
+	require.NoError(t, err)
+	assert.Equal(t, total, count)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, total)
+	var rec DumpRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &rec))
+	assert.NotEmpty(t, rec.ID)
+	assert.NotEmpty(t, rec.Vector)
+	assert.NotZero(t, rec.CreationTimeUnix)
+	assert.Nil(t, rec.Properties.Additional)
+
+	// vectors of the dump are restored as is, IDs and timestamps are kept
+	embedCalls = 0
+	dst := NewMemoryStore(logTestKB).WithEmbedder(embed)
+	res, err := Import(ctx, dst, bytes.NewReader(buf.Bytes()), ImportOptions{BatchSize: 2})
+	require.NoError(t, err)
+	assert.Len(t, res.Succeeded(), total)
+	assert.Equal(t, 2, res.Calls)
+	assert.Zero(t, embedCalls)
+
+	srcItems, _ := src.List(ctx, 100, "")
+	dstItems, _ := dst.List(ctx, 100, "")
+	assert.Equal(t, srcItems, dstItems)
+
+	so := DefaultSO()
+	require.NoError(t, so.WithFilter(&MetaFilter{Tags: []string{"voip"}}))
+	items, err := dst.Search(ctx, so)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Tagged"}, titles(items))
+
+	// re-embedding ignores vectors of the dump
+	_, err = Import(ctx, NewMemoryStore(logTestKB).WithEmbedder(embed), bytes.NewReader(buf.Bytes()), ImportOptions{ReEmbed: true})
+	require.NoError(t, err)
+	assert.Equal(t, total, embedCalls)
+}
+
+func TestImport_InvalidRecords(t *testing.T) {
+	dump := strings.Join([]string{
+		`{"id":"1f0b7d4e-2c43-4f8e-9a57-3d0b4cfc3a10","properties":{"title":"OK","content":"valid"}}`,
+		`not json`,
+		``,
+		`{"id":"2f0b7d4e-2c43-4f8e-9a57-3d0b4cfc3a10"}`,
+		`{"id":"bad-id","properties":{"title":"Bad ID"}}`,
+		`{"properties":{"title":"No ID","content":"id is generated"}}`,
+	}, "\n")
+	dst := NewMemoryStore(logTestKB)
+	res, err := Import(context.Background(), dst, strings.NewReader(dump), ImportOptions{})
+	require.Error(t, err)
+	assert.Len(t, res.Succeeded(), 2)
+	assert.Len(t, res.Failed(), 3)
+	assert.Equal(t, "line 2", res.Failed()[0].Title)
+
+	item, err := dst.GetByID(context.Background(), "1f0b7d4e-2c43-4f8e-9a57-3d0b4cfc3a10")
+	require.NoError(t, err)
+	assert.Equal(t, "OK", item.Title)
+}
+
+func TestImportTarget(t *testing.T) {
+	s := NewMemoryStore(logTestKB)
+	d, err := ImportTarget(context.Background(), s, "")
+	require.NoError(t, err)
+	assert.Same(t, s, d)
+
+	_, err = ImportTarget(context.Background(), s, "OtherClass")
+	assert.Error(t, err)
+}
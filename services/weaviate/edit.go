@@ -0,0 +1,150 @@
+package wvservice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gookit/goutil"
+)
+
+const (
+	maxTitleLen    = 512
+	maxCategoryLen = 64
+)
+
+// ItemPatch is an edit of the knowledge object: nil fields are kept as is, edited object is vectorized again.
+// LastUpdateTimeUnix is the version of the object read by the editor (optimistic concurrency):
+// edit is rejected with ErrVersionConflict if the object was updated after it.
+type ItemPatch struct {
+	Title              *string `json:"title,omitempty"`
+	Content            *string `json:"content,omitempty"`
+	Category           *string `json:"category,omitempty"`
+	KeyWords           *string `json:"keyWords,omitempty"`
+	Summary            *string `json:"summary,omitempty"`
+	LastUpdateTimeUnix int64   `json:"lastUpdateTimeUnix"`
+}
+
+// Validate checks the patch, errors wrap ErrInvalidItem.
+func (p *ItemPatch) Validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidItem, fmt.Sprintf(format, args...))
+	}
+	switch {
+	case p.LastUpdateTimeUnix <= 0:
+		return invalid("lastUpdateTimeUnix of the edited object is required")
+	case p.Title == nil && p.Content == nil && p.Category == nil && p.KeyWords == nil && p.Summary == nil:
+		return invalid("nothing to update")
+	case p.Title != nil && strings.TrimSpace(*p.Title) == "":
+		return invalid("title is empty")
+	case p.Title != nil && utf8.RuneCountInString(strings.TrimSpace(*p.Title)) > maxTitleLen:
+		return invalid("title is longer than %d characters", maxTitleLen)
+	case p.Content != nil && strings.TrimSpace(*p.Content) == "":
+		return invalid("content is empty")
+	case p.Category != nil && strings.TrimSpace(*p.Category) == "":
+		return invalid("category is empty")
+	case p.Category != nil && utf8.RuneCountInString(strings.TrimSpace(*p.Category)) > maxCategoryLen:
+		return invalid("category is longer than %d characters", maxCategoryLen)
+	}
+	return nil
+}
+
+// CheckVersion returns ErrVersionConflict if the object version (lastUpdateTimeUnix) differs from the patch one.
+func (p *ItemPatch) CheckVersion(item *KnowledgeItem) error {
+	if updated := goutil.Int64(item.Additional["lastUpdateTimeUnix"]); updated != p.LastUpdateTimeUnix {
+		return fmt.Errorf("%w: object[%s] version is %d, edited version is %d", ErrVersionConflict, item.ID(), updated, p.LastUpdateTimeUnix)
+	}
+	return nil
+}
+
+// Apply sets edited fields of the item. Keywords are normalized to a comma separated list without duplicates.
+func (p *ItemPatch) Apply(item *KnowledgeItem) {
+	set := func(dst *string, v *string) {
+		if v != nil {
+			*dst = strings.TrimSpace(*v)
+		}
+	}
+	set(&item.Title, p.Title)
+	set(&item.Content, p.Content)
+	set(&item.Category, p.Category)
+	set(&item.Summary, p.Summary)
+	if p.KeyWords != nil {
+		item.KeyWords = strings.Join(compactValues(strings.Split(*p.KeyWords, ",")), ",")
+	}
+}
+
+// Update edits the object and vectorizes it again, returns updated object with new timestamps.
+// Weaviate has no conditional update, so the version is checked right before the write.
+func (kb *KnowledgeBase) Update(ctx context.Context, id string, p *ItemPatch) (*KnowledgeItem, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	item, err := kb.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.CheckVersion(item); err != nil {
+		return nil, err
+	}
+	p.Apply(item)
+
+	updater := kb.Client.Data().Updater().WithClassName(kb.Class).WithID(id).WithProperties(item.ToWeaviate(kb.Class).Properties)
+	vectors, err := kb.embedItems(ctx, []*KnowledgeItem{item})
+	if err != nil {
+		return nil, err
+	}
+	if vectors != nil {
+		updater = updater.WithVector(vectors[0])
+	}
+	if err = updater.Do(ctx); err != nil {
+		kb.log.RecWithCtx(ctx, ch).Errorf("Weaviate update object with id=%s failed. Error: %v", id, err)
+		return nil, err
+	}
+	kb.log.RecWithCtx(ctx, ch).Infof("Object[%s] updated: title=%s", id, item.Title)
+	return kb.GetByID(ctx, id)
+}
+
+// Update edits the document atomically, see VectorStore.
+func (s *MemoryStore) Update(ctx context.Context, id string, p *ItemPatch) (*KnowledgeItem, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	item, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.CheckVersion(item); err != nil {
+		return nil, err
+	}
+	p.Apply(item)
+
+	var vector []float32
+	if s.embed != nil {
+		vectors, err := s.embed.EmbedDocuments(ctx, []string{item.EmbedText()})
+		if err != nil {
+			return nil, err
+		}
+		vector = vectors[0]
+	}
+
+	s.mu.Lock()
+	d, ok := s.docs[id]
+	switch {
+	case !ok:
+		s.mu.Unlock()
+		return nil, ErrObjectNotFound
+	case d.Updated != p.LastUpdateTimeUnix: // updated while the item was embedded
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: object[%s] version is %d, edited version is %d", ErrVersionConflict, id, d.Updated, p.LastUpdateTimeUnix)
+	}
+	stored := item.clone()
+	stored.Additional = nil
+	nd := &memDoc{Item: stored, Vector: vector, Created: d.Created, Updated: max(time.Now().UnixMilli(), d.Updated+1)}
+	nd.index()
+	s.docs[id], s.dirty = nd, true
+	res := nd.project(id, nil, nil)
+	s.mu.Unlock()
+	return res, nil
+}
@@ -0,0 +1,80 @@
+package wvservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gookit/goutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemPatch_Validate(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name    string
+		patch   ItemPatch
+		wantErr bool
+	}{
+		{"ok", ItemPatch{Title: str("Title"), LastUpdateTimeUnix: 1}, false},
+		{"summary can be cleared", ItemPatch{Summary: str(""), LastUpdateTimeUnix: 1}, false},
+		{"no version", ItemPatch{Title: str("Title")}, true},
+		{"nothing to update", ItemPatch{LastUpdateTimeUnix: 1}, true},
+		{"empty title", ItemPatch{Title: str("  "), LastUpdateTimeUnix: 1}, true},
+		{"empty content", ItemPatch{Content: str(""), LastUpdateTimeUnix: 1}, true},
+		{"long category", ItemPatch{Category: str(string(make([]byte, maxCategoryLen+1))), LastUpdateTimeUnix: 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.patch.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidItem)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Update(t *testing.T) {
+	ctx := context.Background()
+	var embedded []string
+	s := NewMemoryStore(logTestKB).WithEmbedder(EmbedFunc(func(_ context.Context, texts []string) ([][]float32, error) {
+		embedded = append(embedded, texts...)
+		res := make([][]float32, len(texts))
+		for i := range texts {
+			res[i] = []float32{1, 0}
+		}
+		return res, nil
+	}))
+	res, err := s.Upsert(ctx, NewKI("VoIP error 409", "Error 409 means wrong fact", "https://wiki/voip/409", "voip", "", "voip"))
+	require.NoError(t, err)
+	id := res.Succeeded()[0].ID
+	item, err := s.GetByID(ctx, id)
+	require.NoError(t, err)
+	version := goutil.Int64(item.Additional["lastUpdateTimeUnix"])
+
+	content, keywords := " Error 409 means the number is already registered ", "voip, 409,,voip"
+	embedded = nil
+	updated, err := s.Update(ctx, id, &ItemPatch{Content: &content, KeyWords: &keywords, LastUpdateTimeUnix: version})
+	require.NoError(t, err)
+	assert.Equal(t, "Error 409 means the number is already registered", updated.Content)
+	assert.Equal(t, "voip,409", updated.KeyWords)
+	assert.Equal(t, item.Title, updated.Title)
+	assert.Equal(t, item.Additional["creationTimeUnix"], updated.Additional["creationTimeUnix"])
+	assert.Greater(t, goutil.Int64(updated.Additional["lastUpdateTimeUnix"]), version)
+	assert.Equal(t, []string{updated.EmbedText()}, embedded)
+
+	items, err := s.Search(ctx, DefaultSO().Fields(FieldAdditional1).SearchTxt("registered"))
+	require.NoError(t, err)
+	require.NotEmpty(t, items)
+	assert.Equal(t, id, items[0].ID())
+
+	// stale version is rejected
+	title := "Stale edit"
+	_, err = s.Update(ctx, id, &ItemPatch{Title: &title, LastUpdateTimeUnix: version})
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	_, err = s.Update(ctx, "00000000-0000-0000-0000-000000000000", &ItemPatch{Title: &title, LastUpdateTimeUnix: version})
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
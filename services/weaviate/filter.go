@@ -0,0 +1,138 @@
+package wvservice
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+)
+
+const (
+	pathCreationTime   = "_creationTimeUnix"
+	pathLastUpdateTime = "_lastUpdateTimeUnix"
+)
+
+// MetaFilter restricts search by metadata of documents. Set fields are combined with AND, values of every list - with OR.
+// Nested filters: Any - at least one must match, All - every one must match. Dates are "2006-01-02" or RFC3339.
+//
+//	{"categories": ["FRD"], "any": [{"urlPrefixes": ["https://confluence."]}, {"tags": ["voip"]}]}
+type MetaFilter struct {
+	Categories    []string      `json:"categories,omitempty"`
+	URLPrefixes   []string      `json:"urlPrefixes,omitempty"` // Like "prefix*", needs 'field' tokenization of url
+	Tags          []string      `json:"tags,omitempty"`
+	CreatedAfter  string        `json:"createdAfter,omitempty"`
+	CreatedBefore string        `json:"createdBefore,omitempty"`
+	UpdatedAfter  string        `json:"updatedAfter,omitempty"`
+	UpdatedBefore string        `json:"updatedBefore,omitempty"`
+	Any           []*MetaFilter `json:"any,omitempty"`
+	All           []*MetaFilter `json:"all,omitempty"`
+}
+
+// IsEmpty reports whether filter has no conditions.
+func (mf *MetaFilter) IsEmpty() bool {
+	if mf == nil {
+		return true
+	}
+	return len(mf.Categories) == 0 && len(mf.URLPrefixes) == 0 && len(mf.Tags) == 0 &&
+		mf.CreatedAfter == "" && mf.CreatedBefore == "" && mf.UpdatedAfter == "" && mf.UpdatedBefore == "" &&
+		lo.EveryBy(mf.Any, (*MetaFilter).IsEmpty) && lo.EveryBy(mf.All, (*MetaFilter).IsEmpty)
+}
+
+// Build converts filter to where condition, nil if filter is empty.
+func (mf *MetaFilter) Build() (*filters.WhereBuilder, error) {
+	if mf.IsEmpty() {
+		return nil, nil
+	}
+	var conds []*filters.WhereBuilder
+	add := func(w *filters.WhereBuilder) {
+		if w != nil {
+			conds = append(conds, w)
+		}
+	}
+
+	values := compactValues(mf.Categories)
+	add(anyOf(lo.Map(values, func(c string, _ int) *filters.WhereBuilder { return FilterWhereCategory(c) })...))
+	values = compactValues(mf.URLPrefixes)
+	add(anyOf(lo.Map(values, func(p string, _ int) *filters.WhereBuilder {
+		return filterWhere(FieldUrl.String(), strings.TrimSuffix(p, "*")+"*", filters.Like)
+	})...))
+	if tags := compactValues(mf.Tags); len(tags) > 0 {
+		add(filters.Where().WithPath([]string{FieldTags.String()}).WithOperator(filters.ContainsAny).WithValueText(tags...))
+	}
+
+	for _, d := range []struct {
+		path, value string
+		op          filters.WhereOperator
+	}{
+		{pathCreationTime, mf.CreatedAfter, filters.GreaterThanEqual},
+		{pathCreationTime, mf.CreatedBefore, filters.LessThan},
+		{pathLastUpdateTime, mf.UpdatedAfter, filters.GreaterThanEqual},
+		{pathLastUpdateTime, mf.UpdatedBefore, filters.LessThan},
+	} {
+		if d.value == "" {
+			continue
+		}
+		t, err := ParseFilterDate(d.value)
+		if err != nil {
+			return nil, err
+		}
+		add(filters.Where().WithPath([]string{d.path}).WithOperator(d.op).WithValueDate(t))
+	}
+
+	for _, group := range []struct {
+		list []*MetaFilter
+		join func(...*filters.WhereBuilder) *filters.WhereBuilder
+	}{{mf.Any, anyOf}, {mf.All, allOf}} {
+		var nested []*filters.WhereBuilder
+		for _, sub := range group.list {
+			w, err := sub.Build()
+			if err != nil {
+				return nil, err
+			}
+			if w != nil {
+				nested = append(nested, w)
+			}
+		}
+		add(group.join(nested...))
+	}
+	return allOf(conds...), nil
+}
+
+// ParseFilterDate parses date of the filter: "2006-01-02" (UTC midnight) or RFC3339.
+func ParseFilterDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid filter date %q: expected YYYY-MM-DD or RFC3339", s)
+	}
+	return t, nil
+}
+
+func compactValues(values []string) []string {
+	return lo.Uniq(lo.Compact(lo.Map(values, func(v string, _ int) string { return strings.TrimSpace(v) })))
+}
+
+func anyOf(conds ...*filters.WhereBuilder) *filters.WhereBuilder {
+	return combine(filters.Or, conds)
+}
+
+func allOf(conds ...*filters.WhereBuilder) *filters.WhereBuilder {
+	return combine(filters.And, conds)
+}
+
+// combine joins non-nil conditions with the operator, single condition is returned as is.
+func combine(op filters.WhereOperator, conds []*filters.WhereBuilder) *filters.WhereBuilder {
+	conds = lo.Compact(conds)
+	switch len(conds) {
+	case 0:
+		return nil
+	case 1:
+		return conds[0]
+	}
+	return filters.Where().WithOperator(op).WithOperands(conds)
+}
@@ -0,0 +1,151 @@
+package wvservice
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+func testFilterStore(t *testing.T) *MemoryStore {
+	s := NewMemoryStore(logTestKB)
+	_, err := s.Upsert(context.Background(),
+		NewKI("FRD VoIP", "VoIP registration requirements", "https://confluence.lifecell.ua/frd/voip", "FRD", "", "voip").WithTags("voip", "frd"),
+		NewKI("Confluence VoIP", "VoIP troubleshooting guide", "https://confluence.lifecell.ua/support/voip", "confluence", "", "voip").WithTags("voip", "Support"),
+		NewKI("Web tariffs", "Tariffs for business", "https://www.lifecell.ua/uk/biznesu/tariffs", "WEB", "", "tariff"),
+	)
+	require.NoError(t, err)
+
+	// creation/update dates: FRD - 2024, confluence - 2025, web - now
+	for _, d := range s.docs {
+		switch d.Item.Category {
+		case "FRD":
+			d.Created, d.Updated = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).UnixMilli(), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+		case "confluence":
+			d.Created, d.Updated = time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC).UnixMilli(), time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+		}
+	}
+	return s
+}
+
+func TestMetaFilter_Build(t *testing.T) {
+	where, err := (&MetaFilter{}).Build()
+	require.NoError(t, err)
+	assert.Nil(t, where)
+
+	where, err = (&MetaFilter{Categories: []string{"FRD"}}).Build()
+	require.NoError(t, err)
+	wf := where.Build()
+	assert.Equal(t, []string{"category"}, wf.Path)
+	assert.Equal(t, "FRD", *wf.ValueText)
+
+	where, err = (&MetaFilter{Categories: []string{"FRD", "confluence"}, URLPrefixes: []string{"https://confluence."}, UpdatedAfter: "2025-01-01"}).Build()
+	require.NoError(t, err)
+	wf = where.Build()
+	assert.Equal(t, string(filters.And), wf.Operator)
+	require.Len(t, wf.Operands, 3)
+	assert.Equal(t, string(filters.Or), wf.Operands[0].Operator)
+	assert.Len(t, wf.Operands[0].Operands, 2)
+	assert.Equal(t, "https://confluence.*", *wf.Operands[1].ValueText)
+	assert.Equal(t, []string{pathLastUpdateTime}, wf.Operands[2].Path)
+	assert.Equal(t, "2025-01-01T00:00:00Z", *wf.Operands[2].ValueDate)
+
+	_, err = (&MetaFilter{All: []*MetaFilter{{CreatedBefore: "yesterday"}}}).Build()
+	assert.Error(t, err)
+}
+
+func TestMemoryStore_SearchMetaFilter(t *testing.T) {
+	s := testFilterStore(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		filter string
+		want   []string
+	}{
+		{"category", `{"categories":["FRD"]}`, []string{"FRD VoIP"}},
+		{"categories OR", `{"categories":["FRD","confluence"]}`, []string{"Confluence VoIP", "FRD VoIP"}},
+		{"url prefix", `{"urlPrefixes":["https://confluence.lifecell.ua/"]}`, []string{"Confluence VoIP", "FRD VoIP"}},
+		{"url prefix AND category", `{"urlPrefixes":["https://confluence.lifecell.ua/"],"categories":["confluence"]}`, []string{"Confluence VoIP"}},
+		{"tags", `{"tags":["support"]}`, []string{"Confluence VoIP"}},
+		{"tags any", `{"tags":["frd","support"]}`, []string{"Confluence VoIP", "FRD VoIP"}},
+		{"created range", `{"createdAfter":"2024-01-01","createdBefore":"2025-01-01"}`, []string{"FRD VoIP"}},
+		{"updated after", `{"updatedAfter":"2025-01-15T00:00:00Z"}`, []string{"Confluence VoIP", "Web tariffs"}},
+		{"nested any", `{"any":[{"categories":["WEB"]},{"tags":["frd"]}]}`, []string{"FRD VoIP", "Web tariffs"}},
+		{"nested all", `{"all":[{"tags":["voip"]},{"updatedBefore":"2025-01-01"}]}`, []string{"FRD VoIP"}},
+		{"no match", `{"categories":["FRD"],"tags":["support"]}`, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mf MetaFilter
+			require.NoError(t, json.Unmarshal([]byte(tt.filter), &mf))
+			so := DefaultSO().Limit(10).SortOrder(FieldTitle, false)
+			require.NoError(t, so.WithFilter(&mf))
+			items, err := s.Search(ctx, so)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, titles(items))
+		})
+	}
+
+	items, err := s.Search(ctx, DefaultSO().SetFields(FieldsAll...).SearchTxt("troubleshooting"))
+	require.NoError(t, err)
+	require.NotEmpty(t, items)
+	assert.Equal(t, "Confluence VoIP", items[0].Title)
+	assert.Equal(t, lo.Must(s.GetByID(ctx, items[0].ID())).Tags, items[0].Tags, "tags are returned by search")
+	assert.NotEmpty(t, items[0].Tags)
+}
+
+func TestSearchRequest_ToSearchOptions(t *testing.T) {
+	s := testFilterStore(t)
+
+	var req SearchRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"searchText":"voip","limit":10,
+		"where":{"field":"title","operator":"Like","value":"*VoIP"},
+		"filter":{"categories":["confluence","WEB"]}}`), &req))
+	so, err := req.ToSearchOptions()
+	require.NoError(t, err)
+	items, err := s.Search(context.Background(), so)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Confluence VoIP"}, titles(items))
+
+	req = SearchRequest{Filter: &MetaFilter{UpdatedAfter: "01.01.2025"}}
+	_, err = req.ToSearchOptions()
+	assert.Error(t, err)
+}
+
+func TestSearchOptions_GetFilterWhere(t *testing.T) {
+	so := NewSO().FilterWhere(WhereTitleLike("VoIP*")).FilterWhere(func(string) *filters.WhereBuilder { return FilterWhereCategory("FRD") })
+	wf := so.GetWhere().Build()
+	assert.Equal(t, string(filters.And), wf.Operator)
+	require.Len(t, wf.Operands, 2)
+	assert.Equal(t, "VoIP*", *wf.Operands[0].ValueText)
+
+	items, err := testFilterStore(t).Search(context.Background(), so)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FRD VoIP"}, titles(items))
+}
+
+func TestDiffClassConfig(t *testing.T) {
+	var schema, existing models.Class
+	require.NoError(t, json.Unmarshal([]byte(kb_json_file), &schema))
+	require.NoError(t, json.Unmarshal([]byte(kb_json_file), &existing))
+	assert.NoError(t, diffClassConfig(&schema, &existing))
+
+	// class created before filters: timestamps are not indexed, url is tokenized by words
+	existing.InvertedIndexConfig = nil
+	url, _ := lo.Find(existing.Properties, func(p *models.Property) bool { return p.Name == FieldUrl.String() })
+	url.Tokenization = models.PropertyTokenizationWord
+	existing.Properties = lo.Reject(existing.Properties, func(p *models.Property, _ int) bool { return p.Name == FieldSheet.String() })
+
+	err := diffClassConfig(&schema, &existing)
+	require.ErrorIs(t, err, ErrClassConfig)
+	assert.Contains(t, err.Error(), "indexTimestamps")
+	assert.Contains(t, err.Error(), `property url has tokenization "word" instead of "field"`)
+	assert.NotContains(t, err.Error(), "sheet", "missing properties are added with schema settings")
+}
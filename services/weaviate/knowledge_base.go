@@ -3,20 +3,24 @@ package wvservice
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-openapi/strfmt"
+	"github.com/google/uuid"
 	"github.com/gookit/goutil"
 	"github.com/gookit/goutil/errorx"
 	"github.com/gookit/goutil/mathutil"
 	"github.com/gookit/goutil/structs"
 	"github.com/gookit/slog"
-	"github.com/mitchellh/copystructure"
 	"github.com/samber/lo"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/fault"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
 	"github.com/weaviate/weaviate/entities/models"
 	gh "gitlab.dev.ict/golang/libs/gohttp"
@@ -89,6 +93,20 @@ type KnowledgeItem struct {
 	Category string `json:"category,omitempty"`
 	Summary  string `json:"summary,omitempty"`
 	KeyWords string `json:"keyWords,omitempty"`
+	// SectionPath is a chain of headings where chunk is located, e.g.: "Tariffs > FMC > Settings"
+	SectionPath string `json:"sectionPath,omitempty"`
+	// Tags are labels of the document (front matter tags, Confluence labels, etc.), used by filters
+	Tags []string `json:"tags,omitempty"`
+	// Language is ISO 639-1 code of the content language (uk, en), detected at ingestion
+	Language string `json:"language,omitempty"`
+	// Pages are numbers of the source document pages (PDF) covered by the chunk, see PageMarker
+	Pages []int `json:"pages,omitempty"`
+	// Sheet is the spreadsheet sheet name and RowRange are numbers of its rows ("2-51") covered by the chunk
+	Sheet    string `json:"sheet,omitempty"`
+	RowRange string `json:"rowRange,omitempty"`
+	// Owner is responsible for the document and ValidUntil is the date after which it is expired (runbook front matter)
+	Owner      string     `json:"owner,omitempty"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
 	// Additional map[string]interface{} `json:"_additional,omitempty"`
 	Additional AdditionalMap `json:"_additional,omitempty"`
 }
@@ -112,8 +130,43 @@ func (ki *KnowledgeItem) GenerateURL() string {
 	return ki.URL
 }
 
+// Citation is a reference to the knowledge item which was used to build the answer.
+type Citation struct {
+	ID          string  `json:"id,omitempty"`
+	Title       string  `json:"title"`
+	ChunkNo     int     `json:"chunkNo"`
+	SectionPath string  `json:"sectionPath,omitempty"`
+	URL         string  `json:"url,omitempty"`
+	Score       float64 `json:"score"`
+}
+
+func (ki *KnowledgeItem) Citation() Citation {
+	return Citation{
+		ID:          ki.ID(),
+		Title:       ki.Title,
+		ChunkNo:     ki.ChunkNo,
+		SectionPath: ki.SectionPath,
+		URL:         ki.GenerateURL(),
+		Score:       ki.Additional.Score(),
+	}
+}
+
 type KnowledgeItems []*KnowledgeItem
 
+// Citations returns unique citations of items in the same order.
+func (ki KnowledgeItems) Citations() (res []Citation) {
+	seen := map[string]bool{}
+	for _, item := range ki {
+		key := lo.Ternary(item.ID() != "", item.ID(), fmt.Sprintf("%s#%d", item.Title, item.ChunkNo))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		res = append(res, item.Citation())
+	}
+	return
+}
+
 func (ki KnowledgeItems) String() string {
 	return fmt.Sprintf("KnowledgeItems Total=%d Dupls=%d", ki.Len(), len(ki.FindDuplicates()))
 }
@@ -152,37 +205,94 @@ func NewKI(title, content, url, category, summary, keyWords string) *KnowledgeIt
 	}
 }
 
+func (k *KnowledgeItem) WithTags(tags ...string) *KnowledgeItem {
+	k.Tags = tags
+	return k
+}
+
+func (k *KnowledgeItem) WithLanguage(lang string) *KnowledgeItem {
+	k.Language = lang
+	return k
+}
+
+func (k *KnowledgeItem) WithPages(pages ...int) *KnowledgeItem {
+	k.Pages = pages
+	return k
+}
+
+func (k *KnowledgeItem) WithSheet(name, rowRange string) *KnowledgeItem {
+	k.Sheet, k.RowRange = name, rowRange
+	return k
+}
+
+func (k *KnowledgeItem) WithOwner(owner string) *KnowledgeItem {
+	k.Owner = owner
+	return k
+}
+
+// WithValidUntil sets the expiration date of the document, zero time - never expires.
+func (k *KnowledgeItem) WithValidUntil(t time.Time) *KnowledgeItem {
+	k.ValidUntil = nil
+	if !t.IsZero() {
+		k.ValidUntil = &t
+	}
+	return k
+}
+
+// IsExpired reports whether ValidUntil of the item is before now.
+func (k *KnowledgeItem) IsExpired(now time.Time) bool {
+	return k.ValidUntil != nil && k.ValidUntil.Before(now)
+}
+
 func (k *KnowledgeItem) String() string {
-	return fmt.Sprintf("KnowledgeItem=[title=%s; chunk=%d; url=%s; category=%s; additional=%v; content=%s; keyWords=%s; summary=%s]", k.Title, k.ChunkNo, k.URL, k.Category, k.Additional, strings.ReplaceAll(utils.StrCut(k.Content, 30), "\n", ""), k.KeyWords[:mathutil.Min(len(k.KeyWords), 50)], utils.StrCut(strconv.Quote(k.Summary), 30))
+	return fmt.Sprintf("KnowledgeItem=[title=%s; chunk=%d; section=%s; url=%s; category=%s; additional=%v; content=%s; keyWords=%s; summary=%s]", k.Title, k.ChunkNo, k.SectionPath, k.URL, k.Category, k.Additional, strings.ReplaceAll(utils.StrCut(k.Content, 30), "\n", ""), k.KeyWords[:mathutil.Min(len(k.KeyWords), 50)], utils.StrCut(strconv.Quote(k.Summary), 30))
 }
 
 // ToWeaviate converts KnowledgeItem to Weaviate Object.
 func (k *KnowledgeItem) ToWeaviate(clsName string) *models.Object {
-	return &models.Object{
-		Class: clsName,
-		Properties: map[string]interface{}{
-			"title":    k.Title,
-			"chunkNo":  k.ChunkNo,
-			"content":  k.Content,
-			"url":      k.URL,
-			"category": k.Category,
-			"summary":  k.Summary,
-			"keywords": k.KeyWords,
-		},
+	props := map[string]interface{}{
+		"title":       k.Title,
+		"chunkNo":     k.ChunkNo,
+		"content":     k.Content,
+		"url":         k.URL,
+		"category":    k.Category,
+		"summary":     k.Summary,
+		"keywords":    k.KeyWords,
+		"sectionPath": k.SectionPath,
+	}
+	if len(k.Tags) > 0 {
+		props["tags"] = k.Tags
+	}
+	if k.Language != "" {
+		props["language"] = k.Language
+	}
+	if len(k.Pages) > 0 {
+		props["pages"] = k.Pages
+	}
+	if k.Sheet != "" {
+		props["sheet"], props["rowRange"] = k.Sheet, k.RowRange
+	}
+	if k.Owner != "" {
+		props["owner"] = k.Owner
 	}
+	if k.ValidUntil != nil {
+		props["validUntil"] = k.ValidUntil.UTC().Format(time.RFC3339)
+	}
+	return &models.Object{Class: clsName, Properties: props}
 }
 func (k *KnowledgeItem) _ToWeaviate(clsName string) *models.Object {
 	// dataObjs := []models.PropertySchema{}
 	return nil
 }
 
-// KnowledgeBase is a collection of KnowledgeItem.
+// KnowledgeBase is a Weaviate class of KnowledgeItem. Items are staged for saving in request-scoped Batch (see NewBatch).
 type KnowledgeBase struct {
 	*weaviate.Client
-	Items   []*KnowledgeItem `json:"Items"`
-	Class   string           `json:"WV_ClassName"`
+	Class   string `json:"WV_ClassName"`
 	log     *gl.Logger
 	IsDebug bool
+	chunker Chunker
+	embed   Embedder
 }
 
 // NewKnowledgeBase creates a new knowledgebase
@@ -208,21 +318,63 @@ func NewKnowledgeBase(client *weaviate.Client, log *gl.Logger, className, classC
 		} else {
 			kb.CreateClassFromJson(context.Background(), classConfigFile)
 		}
+	} else if kb_json_file != "" {
+		kb.AddMissingProperties(context.Background(), kb_json_file)
 	}
 	return kb
 }
 
-func (kb *KnowledgeBase) Search(ctx context.Context, so *SearchOptions) (*models.GraphQLResponse, error) {
+// WithChunker sets the chunker used by Batch.Add to split content. Default is HeadingChunker.
+func (kb *KnowledgeBase) WithChunker(c Chunker) *KnowledgeBase {
+	kb.chunker = c
+	return kb
+}
+
+// WithEmbedder enables client-side embedding: objects are saved with explicit vectors and search queries
+// are vectorized before sending, so Weaviate vectorizer module is not used. Without embedder the class vectorizer is used.
+func (kb *KnowledgeBase) WithEmbedder(e Embedder) *KnowledgeBase {
+	kb.embed = e
+	return kb
+}
+
+func (kb *KnowledgeBase) Chunker() Chunker {
+	if kb.chunker == nil {
+		kb.chunker = DefaultChunker()
+	}
+	return kb.chunker
+}
+
+// SearchGQL returns raw GraphQL response of the search, see Search for converted items.
+func (kb *KnowledgeBase) SearchGQL(ctx context.Context, so *SearchOptions) (*models.GraphQLResponse, error) {
+	if kb.embed != nil && so != nil && so.SearchText != "" && len(so.Vector) == 0 && so.Mode != SearchBM25 {
+		v, err := kb.embed.EmbedQuery(ctx, so.SearchText)
+		if err != nil {
+			return nil, fmt.Errorf("embed query: %w", err)
+		}
+		so = lo.ToPtr(*so).WithVector(v)
+	}
 	return WeaviateSearch(kb.log.RecWithCtx(ctx), kb.Client, kb.Class, so)
 }
 
+// embedItems returns vectors of items or nil if embedder is not set.
+func (kb *KnowledgeBase) embedItems(ctx context.Context, items []*KnowledgeItem) ([][]float32, error) {
+	if kb.embed == nil || len(items) == 0 {
+		return nil, nil
+	}
+	vectors, err := kb.embed.EmbedDocuments(ctx, lo.Map(items, func(item *KnowledgeItem, _ int) string { return item.EmbedText() }))
+	if err == nil && len(vectors) != len(items) {
+		err = errorx.Ef("embedder returned %d vectors for %d items", len(vectors), len(items))
+	}
+	return vectors, err
+}
+
+// TotalItems returns count of objects in the class (Aggregate meta count), 0 on error.
 func (kb *KnowledgeBase) TotalItems() int {
-	r, e := WeaviateSearch(kb.log.RecWithCtx(context.Background()), kb.Client, kb.Class, NewSO().Limit(10000))
-	if e != nil {
-		kb.log.Errorf("Error in search: %v", e)
-		return 0
+	n, err := kb.Count(context.Background())
+	if err != nil {
+		kb.log.Errorf("Count objects of class=%s error: %v", kb.Class, err)
 	}
-	return len(GQLRespConvert[KnowledgeItem](r, kb.Class))
+	return n
 }
 
 func (kb *KnowledgeBase) IsClassExists(ctx context.Context, className ...string) bool {
@@ -240,6 +392,7 @@ func (kb *KnowledgeBase) CreateClassFromVar(ctx context.Context, contentJson str
 	log := kb.log.RecWithCtx(ctx, ch)
 	var class = &models.Class{}
 	utils.JsonToStruct([]byte(contentJson), class)
+	class.Class = kb.Class
 
 	if kb.IsClassExists(ctx) {
 		log.Warnf("Class=%s alredy exists... Exit creation...", class.Class)
@@ -254,6 +407,66 @@ func (kb *KnowledgeBase) CreateClassFromVar(ctx context.Context, contentJson str
 	return nil
 }
 
+// AddMissingProperties creates properties of the class schema (JSON) which are absent in the existing class,
+// e.g. 'tags' in the class created by previous version. Existing properties are not changed.
+func (kb *KnowledgeBase) AddMissingProperties(ctx context.Context, contentJson string) error {
+	log := kb.log.RecWithCtx(ctx, ch)
+	var class = &models.Class{}
+	utils.JsonToStruct([]byte(contentJson), class)
+
+	existing, err := kb.Client.Schema().ClassGetter().WithClassName(kb.Class).Do(ctx)
+	if err != nil {
+		log.Errorf("Class[%s] get failed, cause error: %v", kb.Class, err)
+		return err
+	}
+	names := lo.SliceToMap(existing.Properties, func(p *models.Property) (string, bool) { return p.Name, true })
+	for _, p := range class.Properties {
+		if names[p.Name] {
+			continue
+		}
+		if err = kb.Client.Schema().PropertyCreator().WithClassName(kb.Class).WithProperty(p).Do(ctx); err != nil {
+			log.Errorf("Class[%s] add property=%s failed, cause error: %v", kb.Class, p.Name, err)
+			return err
+		}
+		log.Infof("Class[%s] property added: %s", kb.Class, p.Name)
+	}
+	return nil
+}
+
+// ErrClassConfig is returned when the existing class differs from the class schema in settings which are not changed in place.
+var ErrClassConfig = errors.New("class config differs from schema")
+
+// CheckClassConfig compares the existing class with the class schema in settings which Weaviate applies only on class creation:
+// timestamps index (filters by creation/update date) and tokenization of properties (e.g. 'url' for filter by URL prefix).
+// Such class must be migrated: export it (kb-export), import into a new class (kb-import -class) and switch VectorClass.
+func (kb *KnowledgeBase) CheckClassConfig(ctx context.Context) error {
+	var class = &models.Class{}
+	utils.JsonToStruct([]byte(kb_json_file), class)
+	existing, err := kb.Client.Schema().ClassGetter().WithClassName(kb.Class).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("get class %s: %w", kb.Class, err)
+	}
+	return diffClassConfig(class, existing)
+}
+
+func diffClassConfig(schema, existing *models.Class) error {
+	var diffs []string
+	if schema.InvertedIndexConfig != nil && schema.InvertedIndexConfig.IndexTimestamps &&
+		(existing.InvertedIndexConfig == nil || !existing.InvertedIndexConfig.IndexTimestamps) {
+		diffs = append(diffs, "timestamps are not indexed (invertedIndexConfig.indexTimestamps)")
+	}
+	props := lo.SliceToMap(existing.Properties, func(p *models.Property) (string, *models.Property) { return p.Name, p })
+	for _, p := range schema.Properties {
+		if e, ok := props[p.Name]; ok && p.Tokenization != "" && e.Tokenization != p.Tokenization {
+			diffs = append(diffs, fmt.Sprintf("property %s has tokenization %q instead of %q", p.Name, e.Tokenization, p.Tokenization))
+		}
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("%w: class %s: %s", ErrClassConfig, existing.Class, strings.Join(diffs, "; "))
+	}
+	return nil
+}
+
 func (kb *KnowledgeBase) CreateClassFromJson(ctx context.Context, fileName string) error {
 	log := kb.log.RecWithCtx(ctx, ch)
 	var class = &models.Class{}
@@ -274,83 +487,25 @@ func (kb *KnowledgeBase) CreateClassFromJson(ctx context.Context, fileName strin
 }
 
 func (kb *KnowledgeBase) String() string {
-	return fmt.Sprintf("KnowledgeBase: wv_client=%v; is_log_not_null=%t; size_items=%d", lo.Must(kb.Client.Misc().MetaGetter().Do(context.Background())), kb.log != nil, len(kb.Items))
+	return fmt.Sprintf("KnowledgeBase: wv_client=%v; is_log_not_null=%t; class=%s", lo.Must(kb.Client.Misc().MetaGetter().Do(context.Background())), kb.log != nil, kb.Class)
 }
 
-func (kb *KnowledgeBase) Size() int     { return len(kb.Items) }
-func (kb *KnowledgeBase) IsEmpty() bool { return kb.Size() == 0 }
-func (kb *KnowledgeBase) ClearItems()   { kb.Items = []*KnowledgeItem{} }
-
-// AddItem adds a new knowledge item to the knowledge base.
-func (kb *KnowledgeBase) AddItem(ctx context.Context, title, content, url, category, summary, keyWords string) {
-	kb.AddItemSimple(ctx, NewKI(title, content, url, category, summary, keyWords))
+// SplitItem splits item into chunks with kb chunker. Item without content or with a single chunk is returned as is.
+func (kb *KnowledgeBase) SplitItem(log *slog.Record, item *KnowledgeItem) []*KnowledgeItem {
+	return splitItem(kb.Chunker(), log, item)
 }
 
-// AddItemSimple adds a knowledge item to the knowledge base.
-// If the content of the item exceeds the maximum token support, it will be divided into chunks.
-// Each chunk will be assigned a chunk number and added to the knowledge base.
-// Parameters:
-//   - item: The knowledge item to be added.
-func (kb *KnowledgeBase) AddItemSimple(ctx context.Context, item *KnowledgeItem) {
-	log := kb.log.RecWithCtx(ctx, ch)
-	log.Infof("AddItemSimple: %s", item)
-	if cntTokens := countTokens(item.Content); cntTokens > MAX_TOKEN_SUPPORT {
-		log.Warnf("Content size=%d is too large then %d. Divide into chunks", len(item.Content), MAX_TOKEN_SUPPORT)
-		chunks := (cntTokens / MAX_TOKEN_SUPPORT) + 1
-		chunkLen := (len(item.Content) / chunks) + chunks
-		log.Infof("chunks=%d; chunkLen=%d", chunks, chunkLen)
-		for i, v := range lo.ChunkString(item.Content, chunkLen) {
-			newItem, err := copystructure.Copy(item)
-			log.Infof("newItem_TYPE=%T", newItem)
-			if err != nil {
-				panic(err)
-			}
-			newItem.(*KnowledgeItem).Content = v
-			newItem.(*KnowledgeItem).ChunkNo = i + 1
-			log.Infof("Item chunk-%d: %s", (i + 1), newItem)
-			kb.Items = append(kb.Items, newItem.(*KnowledgeItem))
-		}
-		log.Infof("Items %v", kb.Items)
-	} else {
-		kb.Items = append(kb.Items, item)
-	}
+// UpdateItemInWeaviate overwrites properties of existing objects. Item without ID is looked up by title.
+func (kb *KnowledgeBase) UpdateItemInWeaviate(ctx context.Context, items ...*KnowledgeItem) (*BatchResult, error) {
+	b := kb.NewBatch(ctx)
+	b.items = items
+	return b.Update(ctx)
 }
 
-// ToWeaviate converts the KnowledgeBase to a list of Weaviate objects.
-func (kb *KnowledgeBase) ToWeaviate() []*models.Object {
-	var objs []*models.Object
-	for _, item := range kb.Items {
-		objs = append(objs, item.ToWeaviate(kb.Class))
-	}
-	return objs
-}
-
-// UpdateItemInWeaviate updates the items in the Weaviate knowledge base.
-func (kb *KnowledgeBase) UpdateItemInWeaviate(ctx context.Context) error {
-	for _, item := range kb.Items {
-		if item.ID() == "" {
-			item.Additional["id"] = kb.GetObjByTitleEQ(ctx, item.Title, FieldAdditional1)
-		}
-		err := kb.Client.Data().Updater().WithClassName(kb.Class).WithID(item.ID()).WithProperties(item.ToWeaviate(kb.Class).Properties).Do(ctx)
-		kb.log.WithCtx(ctx).Errorf("Weaviate update object with id=%s failed. Error: %v", item.ID(), err)
-	}
-	return nil
-}
-
-// AddItemsToWeaviate adds items to the Weaviate knowledge base.
-// It iterates over the items in the KnowledgeBase and sends them to the Weaviate server.
-// If an error occurs while adding an item, it is added to the error map.
-// Returns an error map containing any errors that occurred during the process.
-func (kb *KnowledgeBase) AddItemsToWeaviate(ctx context.Context) error {
-	r := kb.log.RecWithCtx(ctx, ch)
-	errMap := errorx.ErrMap{}
-	for _, item := range kb.Items {
-		_, err := item.AddItemToWeaviate(r, kb.Client, kb.Class)
-		if err != nil {
-			errMap[item.Title] = err
-		}
-	}
-	return errMap.ErrorOrNil()
+// AddItemsToWeaviate splits items into chunks and saves them with the batch API.
+// Returns per object results; error is not nil if any object failed.
+func (kb *KnowledgeBase) AddItemsToWeaviate(ctx context.Context, items ...*KnowledgeItem) (*BatchResult, error) {
+	return kb.NewBatch(ctx).Add(items...).Flush(ctx)
 }
 
 func (item *KnowledgeItem) AddItemToWeaviate(r *slog.Record, w *weaviate.Client, class string) (id string, e error) {
@@ -365,47 +520,38 @@ func (item *KnowledgeItem) AddItemToWeaviate(r *slog.Record, w *weaviate.Client,
 	return string(o.Object.ID), nil
 }
 
-func (kb *KnowledgeBase) AddToWeaviateBatchNew(ctx context.Context) ([]models.ObjectsGetResponse, error) {
-	batcher := kb.Client.Batch().ObjectsBatcher()
-
-	for _, item := range kb.Items {
-		batcher.WithObjects(item.ToWeaviate(kb.Class))
-	}
-
-	batchRes, err := batcher.Do(ctx)
+// ItemUUID generates deterministic object ID for the chunk of the source, so repeated ingestion overwrites the same objects.
+func ItemUUID(sourceURI string, chunkNo int) strfmt.UUID {
+	return strfmt.UUID(uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("%s#%d", sourceURI, chunkNo))).String())
+}
 
-	if err != nil {
-		kb.log.Infof("BATCH_RESP: %v", batchRes)
-		kb.log.Infof("BATCH_RESP_ERR: %w", err)
-		return batchRes, err
-	}
-	// Check the response for any errors.
-	for _, res := range batchRes {
-		if res.Result.Errors != nil {
-			return batchRes, errorx.E(res.Result.Errors.Error[0].Message)
-		}
-	}
-	return batchRes, err
+// UpsertItems saves chunks of the source with deterministic IDs (see ItemUUID) via the batch API.
+// Returns IDs of successfully saved objects.
+func (kb *KnowledgeBase) UpsertItems(ctx context.Context, sourceURI string, items ...*KnowledgeItem) ([]string, error) {
+	return upsertSource(ctx, kb, sourceURI, items...)
 }
 
-// AddToWeaviateBatch adds the knowledge base to weaviate using the batch API.
-func (kb *KnowledgeBase) AddToWeaviateBatch(ctx context.Context) ([]models.ObjectsGetResponse, error) {
-	batchRes, err := kb.Client.Batch().ObjectsBatcher().WithObjects(kb.ToWeaviate()...).Do(ctx)
-	if err != nil {
-		return batchRes, err
-	}
-	// Check the response for any errors.
-	for _, res := range batchRes {
-		if res.Result.Errors != nil {
-			return batchRes, errorx.E(res.Result.Errors.Error[0].Message)
+// DeleteObjects deletes objects by IDs. Not found objects are ignored.
+func (kb *KnowledgeBase) DeleteObjects(ctx context.Context, ids ...string) error {
+	errMap := errorx.ErrMap{}
+	for _, id := range ids {
+		if err := kb.DeleteItemFromWeaviate(ctx, id); err != nil {
+			if e, ok := err.(*fault.WeaviateClientError); ok && e.StatusCode == http.StatusNotFound {
+				continue
+			}
+			errMap[id] = err
 		}
 	}
-	return batchRes, err
+	return errMap.ErrorOrNil()
 }
 
-func (kb *KnowledgeBase) AddToWeaviateBatchWithAutoClear(ctx context.Context) ([]models.ObjectsGetResponse, error) {
-	defer kb.ClearItems()
-	return kb.AddToWeaviateBatch(ctx)
+// DeleteWhere deletes all objects of the class matched by filter. Returns count of deleted objects.
+func (kb *KnowledgeBase) DeleteWhere(ctx context.Context, where *filters.WhereBuilder) (int64, error) {
+	resp, err := kb.Client.Batch().ObjectsBatchDeleter().WithClassName(kb.Class).WithOutput("minimal").WithWhere(where).Do(ctx)
+	if err != nil || resp == nil || resp.Results == nil {
+		return 0, err
+	}
+	return resp.Results.Successful, nil
 }
 
 func (kb *KnowledgeBase) GetObjectsFromWeaviate(ctx context.Context, withVector bool) []*models.Object {
@@ -430,28 +576,38 @@ func (kb *KnowledgeBase) GetObjectsFromWeaviate(ctx context.Context, withVector
 	return res
 }
 
+// GetAllObjectsFromWeaviateGQL returns all objects of the class page by page: by cursor if sort is empty,
+// otherwise by offset (limited by QUERY_MAXIMUM_RESULTS of Weaviate). On error objects fetched so far are returned.
 func (kb *KnowledgeBase) GetAllObjectsFromWeaviateGQL(ctx context.Context, sort []graphql.Sort, fields ...Field) []*KnowledgeItem {
 	log := kb.log.RecWithCtx(ctx, ch)
-	fieldsDef := []graphql.Field{f("title"), f("category"), f("chunkNo"), f(Additioanl2)}
-	if len(fields) > 0 {
-		fieldsDef = fieldsList(fields...)
+	if len(fields) == 0 {
+		fields = []Field{FieldTitle, FieldCategory, FieldChunkNo, FieldAdditional2}
 	}
-	res, err := kb.GraphQL().Get().WithClassName(kb.Class).
-		// WithFields(f("title"), f("category"), f("chunkNo"), f("_additional {id}")).
-		WithFields(fieldsDef...).
-		WithLimit(1000).
-		// WithSort(graphql.Sort{Path: []string{"title"}, Order: graphql.Desc}).
-		// WithSort(SortBy(FieldTitle, false)).
-		WithSort(sort...).
-		// WithHybrid(kb.GraphQL().HybridArgumentBuilder().WithQuery("")).
-		Do(ctx)
-
-	log.Debugf("err: %v; res: %v", err, res)
+	if len(sort) == 0 && !lo.Contains(fields, FieldAdditional2) {
+		fields = append(fields, FieldAdditional2) // id is the cursor
+	}
+	so := NewSO().SetFields(fields...).Limit(MaxPageSize)
+	so.SortBy = sort
 
-	if err == nil && res.Errors == nil {
-		return kb.JqlRespToListKI(res)
+	var res []*KnowledgeItem
+	for {
+		q := lo.ToPtr(*so)
+		if len(sort) == 0 && len(res) > 0 {
+			q.WithAfter(res[len(res)-1].ID())
+		} else {
+			q.WithOffset(len(res))
+		}
+		items, err := kb.Search(ctx, q)
+		if err != nil {
+			log.Errorf("Get all objects of class=%s failed after %d objects: %v", kb.Class, len(res), err)
+			return res
+		}
+		res = append(res, items...)
+		if len(items) < so.LimitItems {
+			log.Debugf("Returned %d objects for class=%s", len(res), kb.Class)
+			return res
+		}
 	}
-	return nil
 }
 
 func (kb *KnowledgeBase) GetObjByID(ctx context.Context, id string) (*models.Object, error) {
@@ -460,6 +616,9 @@ func (kb *KnowledgeBase) GetObjByID(ctx context.Context, id string) (*models.Obj
 		return nil, err
 	}
 	kb.log.RecWithCtx(ctx, ch).Infof("Returned %d objects for class=%s", len(res), kb.Class)
+	if len(res) == 0 {
+		return nil, nil
+	}
 	return res[0], nil
 }
 
@@ -556,9 +715,6 @@ func (kb *KnowledgeBase) DeleteItemFromWeaviate(ctx context.Context, uuid string
 
 func (kb *KnowledgeBase) RemoveDuplicates(ctx context.Context, items []*KnowledgeItem, class ...string) (dupls []*KnowledgeItem) {
 	ki := KnowledgeItems(items)
-	if ki.Len() == 0 {
-		ki = KnowledgeItems(kb.Items)
-	}
 	dupls = ki.FindDuplicates()
 	kb.Class = utils.FirstOrDefault(kb.Class, class...)
 	kb.log.WithCtx(ctx).Infof("Found %d duplicates! Start removing dupls from class=%s", len(dupls), kb.Class)
@@ -62,28 +62,31 @@ func init() {
 
 func TestNewKnowledgeBase(t *testing.T) {
 	t.Run("upload-test-1", func(t *testing.T) {
-		// kb.AddItem(ctx, "TEST doc 1", "teeest first document", "", CATEGORY_FRD, "", "")
-		kb.AddItem(ctx, "TEST doc 2", "teeest first document 222222", "", CATEGORY_FRD, "", "")
-		kb.AddItem(ctx, "TEST doc 3", "teeest first document 333333", "", CATEGORY_FRD, "", "")
-		err := kb.AddItemsToWeaviate(ctx)
+		// NewKI("TEST doc 1", "teeest first document", "", CATEGORY_FRD, "", "")
+		_, err := kb.AddItemsToWeaviate(ctx,
+			NewKI("TEST doc 2", "teeest first document 222222", "", CATEGORY_FRD, "", ""),
+			NewKI("TEST doc 3", "teeest first document 333333", "", CATEGORY_FRD, "", ""))
 		assert.NoError(t, err)
 	})
 
 	t.Run("upload-batch-test-1", func(t *testing.T) {
-		// kb.AddItem(ctx, "TEST doc 1", "teeest first document", "", CATEGORY_FRD, "", "")
-		kb.AddItem(ctx, "TEST doc 2", "teeest first document 222222", "", CATEGORY_FRD, "", "")
-		kb.AddItem(ctx, "TEST doc 3", "teeest first document 333333", "", CATEGORY_FRD, "", "")
-		resp, err := kb.AddToWeaviateBatch(ctx)
+		// batch.AddItem("TEST doc 1", "teeest first document", "", CATEGORY_FRD, "", "")
+		batch := kb.NewBatch(ctx)
+		batch.AddItem("TEST doc 2", "teeest first document 222222", "", CATEGORY_FRD, "", "")
+		batch.AddItem("TEST doc 3", "teeest first document 333333", "", CATEGORY_FRD, "", "")
+		resp, err := batch.Flush(ctx)
 		assert.NoError(t, err)
 		t.Log(utils.JsonPrettyStr(resp))
 	})
 
 	t.Run("upload-batch-test-2", func(t *testing.T) {
-		// kb.AddItem(ctx, "TEST doc 1", "teeest first document", "", CATEGORY_FRD, "", "")
-		kb.AddItem(ctx, "TEST doc 22", "teeest first document 222222", "", CATEGORY_FRD, "", "")
-		kb.AddItem(ctx, "TEST doc 33", "teeest first document 333333", "", CATEGORY_FRD, "", "")
-		kb.AddItem(ctx, "TEST doc 44", "teeest first document 333333", "", CATEGORY_FRD, "", "")
-		resp, err := kb.AddToWeaviateBatchNew(ctx)
+		// batch.AddItem("TEST doc 1", "teeest first document", "", CATEGORY_FRD, "", "")
+		batch := kb.NewBatch(ctx).WithSize(2)
+		batch.AddItem("TEST doc 22", "teeest first document 222222", "", CATEGORY_FRD, "", "")
+		batch.AddItem("TEST doc 33", "teeest first document 333333", "", CATEGORY_FRD, "", "")
+		batch.AddItem("TEST doc 44", "teeest first document 333333", "", CATEGORY_FRD, "", "")
+		resp, err := batch.Flush(ctx)
+		assert.Equal(t, 2, resp.Calls)
 		assert.NoError(t, err)
 		t.Log(utils.JsonPrettyStr(resp))
 	})
@@ -104,7 +107,7 @@ func TestGetObjects(t *testing.T) {
 func TestGetObjects1(t *testing.T) {
 	so := DefaultSO().Limit(2).Fields(FieldContent, FieldAdditional2).SortOrder(FieldTitle, false).SearchTxt("error 409")
 	t.Log(utils.JsonPrettyStr(so))
-	gr, err := kb.Search(ctx, so)
+	gr, err := kb.SearchGQL(ctx, so)
 	assert.NoError(t, err)
 	t.Log(utils.JsonPrettyStr(gr))
 
@@ -118,7 +121,7 @@ func TestGetObjects1(t *testing.T) {
 func TestGetObjects3(t *testing.T) {
 	so := DefaultSO().Limit(5).Fields(FieldAdditional1).SearchTxt("error 409")
 	t.Log(utils.JsonPrettyStr(so))
-	gr, err := kb.Search(ctx, so)
+	gr, err := kb.SearchGQL(ctx, so)
 	assert.NoError(t, err)
 	t.Log("RESULT>>>>\n", utils.JsonPrettyStr(gr))
 
@@ -254,32 +257,33 @@ func TestKnowledgeBase_AddItem(t *testing.T) {
 	// help_deleteClass(t, DefaultClassKB)
 	kb = NewKnowledgeBase(client, logTestKB, DefaultClassKB, DefaultClassKB_json)
 	kb.DeleteFull(ctx)
+	batch := kb.NewBatch(ctx)
 
 	t.Run("0", func(t *testing.T) {
 		fn, content := parseFile(t, FILE1)
 		summary, keywords := aiSummarizeAndKeywords(t, content)
-		kb.AddItem(ctx, fn, content, "", CATEGORY_FRD, summary, keywords)
-		t.Log("ITEM[0]>>>", kb.Items[0])
+		batch.AddItem(fn, content, "", CATEGORY_FRD, summary, keywords)
+		t.Log("ITEM[0]>>>", batch.Items()[0])
 	})
-	assert.NotZero(t, kb.Items)
-	t.Log("#########################################################", kb.Size())
+	assert.NotZero(t, batch.Len())
+	t.Log("#########################################################", batch.Len())
 	t.Run("1", func(t *testing.T) {
 		fn, content := parseFile(t, FILE2)
 		summary, keywords := aiSummarizeAndKeywords(t, content)
-		kb.AddItem(ctx, fn, content, "", CATEGORY_FRD, summary, keywords)
-		t.Log("ITEM[1]>>>", kb.Items[1])
+		batch.AddItem(fn, content, "", CATEGORY_FRD, summary, keywords)
+		t.Log("ITEM[1]>>>", batch.Items()[1])
 	})
-	assert.NotZero(t, kb.Items)
-	t.Log("#########################################################", kb.Size())
+	assert.NotZero(t, batch.Len())
+	t.Log("#########################################################", batch.Len())
 	t.Run("2", func(t *testing.T) {
 		fn, content := parseFile(t, FILE3)
 		summary, keywords := aiSummarizeAndKeywords(t, content)
-		kb.AddItem(ctx, fn, content, "", CATEGORY_FRD, summary, keywords)
+		batch.AddItem(fn, content, "", CATEGORY_FRD, summary, keywords)
 	})
-	assert.NotZero(t, kb.Items)
-	t.Log("#########################################################", kb.Size())
+	assert.NotZero(t, batch.Len())
+	t.Log("#########################################################", batch.Len())
 
-	_, err := kb.AddToWeaviateBatch(ctx)
+	_, err := batch.Flush(ctx)
 	assert.NoError(t, err)
 	help_getObjects(t, DefaultClassKB)
 }
@@ -0,0 +1,752 @@
+package wvservice
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/gookit/goutil"
+	"github.com/gookit/slog"
+	"github.com/samber/lo"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+	"github.com/weaviate/weaviate/entities/models"
+	gl "gitlab.dev.ict/golang/libs/gologgers"
+)
+
+const (
+	chMem = "VectorMem"
+
+	DefaultHybridAlpha = 0.75
+	bm25K1             = 1.2
+	bm25B              = 0.75
+	rrfK               = 60
+
+	DefaultMemFlushInterval = 5 * time.Second
+)
+
+// textFields - properties indexed for BM25 search by default.
+var textFields = []string{FieldTitle.String(), FieldContent.String(), FieldSummary.String(), FieldKeywords.String(), FieldSectionPath.String()}
+
+type memDoc struct {
+	Item    *KnowledgeItem `json:"item"`
+	Vector  []float32      `json:"vector,omitempty"`
+	Created int64          `json:"created"`
+	Updated int64          `json:"updated"`
+
+	terms map[string]map[string]int // field => term => frequency
+}
+
+// MemoryStore is an in-process VectorStore: brute-force cosine similarity over vectors and BM25 over text fields.
+// If path is set, content is loaded from the JSON file, changes are saved to it by Flush (see AutoFlush).
+type MemoryStore struct {
+	log     *gl.Logger
+	path    string
+	chunker Chunker
+	embed   Embedder
+
+	mu     sync.RWMutex
+	saveMu sync.Mutex
+	docs   map[string]*memDoc
+	dirty  bool // changes are not flushed to the file yet
+}
+
+func NewMemoryStore(log *gl.Logger) *MemoryStore {
+	return &MemoryStore{log: log, docs: map[string]*memDoc{}}
+}
+
+// OpenMemoryStore creates store persisted to the file, existing content of the file is loaded.
+func OpenMemoryStore(log *gl.Logger, path string) (*MemoryStore, error) {
+	s := NewMemoryStore(log)
+	s.path = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &s.docs); err != nil {
+		return nil, fmt.Errorf("memory store[%s] load: %w", path, err)
+	}
+	for _, d := range s.docs {
+		d.index()
+	}
+	log.Infof("Memory store loaded from %s: objects=%d", path, len(s.docs))
+	return s, nil
+}
+
+func (s *MemoryStore) WithChunker(c Chunker) *MemoryStore {
+	s.chunker = c
+	return s
+}
+
+// WithEmbedder enables vector search. Without embedder only BM25 is used.
+func (s *MemoryStore) WithEmbedder(e Embedder) *MemoryStore {
+	s.embed = e
+	return s
+}
+
+func (s *MemoryStore) Chunker() Chunker {
+	if s.chunker == nil {
+		s.chunker = DefaultChunker()
+	}
+	return s.chunker
+}
+
+func (s *MemoryStore) SplitItem(log *slog.Record, item *KnowledgeItem) []*KnowledgeItem {
+	return splitItem(s.Chunker(), log, item)
+}
+
+func (s *MemoryStore) UpsertItems(ctx context.Context, sourceURI string, items ...*KnowledgeItem) ([]string, error) {
+	return upsertSource(ctx, s, sourceURI, items...)
+}
+
+func (s *MemoryStore) DeleteObjects(ctx context.Context, ids ...string) error {
+	return s.Delete(ctx, ids...)
+}
+
+func (s *MemoryStore) Upsert(ctx context.Context, items ...*KnowledgeItem) (*BatchResult, error) {
+	res := &BatchResult{Calls: 1}
+	vectors := make([][]float32, len(items))
+	if s.embed != nil && len(items) > 0 {
+		v, err := s.embed.EmbedDocuments(ctx, lo.Map(items, func(item *KnowledgeItem, _ int) string { return item.EmbedText() }))
+		if err != nil {
+			for _, item := range items {
+				res.add(item, item.ID(), err)
+			}
+			return res, res.Err()
+		}
+		copy(vectors, v)
+	}
+
+	s.mu.Lock()
+	now := time.Now().UnixMilli()
+	for i, item := range items {
+		id := lo.Ternary(item.ID() != "", item.ID(), uuid.NewString())
+		if _, err := uuid.Parse(id); err != nil {
+			res.add(item, id, fmt.Errorf("invalid id: %w", err))
+			continue
+		}
+		stored := item.clone()
+		stored.Additional = nil
+		d := &memDoc{Item: stored, Vector: vectors[i], Created: now, Updated: now}
+		if prev, ok := s.docs[id]; ok {
+			d.Created = prev.Created
+		}
+		d.index()
+		s.docs[id] = d
+		s.dirty = true
+		res.add(item, id, nil)
+	}
+	s.mu.Unlock()
+	return res, res.Err()
+}
+
+func (s *MemoryStore) Search(ctx context.Context, so *SearchOptions) ([]*KnowledgeItem, error) {
+	if so == nil {
+		so = DefaultSO()
+	}
+	limit := lo.Ternary(so.LimitItems > 0, so.LimitItems, 10)
+
+	var where *models.WhereFilter
+	if so.GetWhere() != nil {
+		where = so.GetWhere().Build()
+	}
+
+	var qVector []float32
+	if len(so.Vector) > 0 {
+		qVector = so.Vector
+	} else if so.SearchText != "" && so.Mode != SearchBM25 && s.embed != nil {
+		v, err := s.embed.EmbedQuery(ctx, so.SearchText)
+		if err != nil {
+			return nil, err
+		}
+		qVector = v
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := lo.Filter(lo.Keys(s.docs), func(id string, _ int) bool { return matchWhere(where, id, s.docs[id]) })
+	slices.Sort(ids)
+
+	var scores map[string]float64
+	switch {
+	case so.After != "":
+		// cursor listing is ordered by ID like in Weaviate
+		i, found := slices.BinarySearch(ids, so.After)
+		ids = ids[lo.Ternary(found, i+1, i):]
+	case so.SearchText == "" && len(qVector) == 0:
+		s.sortIDs(ids, so.SortBy)
+	case so.Mode == SearchBM25 || len(qVector) == 0:
+		scores = s.bm25(ids, so.SearchText, so.SearchFields)
+	case so.Mode == SearchVector:
+		scores = s.cosine(ids, qVector)
+	default:
+		alpha := float64(lo.Ternary(so.Alpha > 0, so.Alpha, DefaultHybridAlpha))
+		scores = fuseRanked(s.cosine(ids, qVector), s.bm25(ids, so.SearchText, so.SearchFields), alpha)
+	}
+	if scores != nil {
+		ids = lo.Filter(ids, func(id string, _ int) bool { return scores[id] > 0 })
+		slices.SortStableFunc(ids, func(a, b string) int { return cmp.Compare(scores[b], scores[a]) })
+	}
+
+	ids = ids[min(max(so.Offset, 0), len(ids)):]
+
+	items := make([]*KnowledgeItem, 0, min(limit, len(ids)))
+	for _, id := range ids[:min(limit, len(ids))] {
+		items = append(items, s.docs[id].project(id, so.FieldsReturn, scores))
+	}
+	s.log.RecWithCtx(ctx, chMem).Debugf("Search[%s] mode=%s found=%d returned=%d", so.SearchText, so.Mode, len(ids), len(items))
+	return items, nil
+}
+
+func (s *MemoryStore) GetByID(_ context.Context, id string) (*KnowledgeItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.docs[id]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	return d.project(id, nil, nil), nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := s.docs[id]; ok {
+			delete(s.docs, id)
+			s.dirty = true
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteWhere(_ context.Context, where *filters.WhereBuilder) (int64, error) {
+	if where == nil {
+		return 0, nil
+	}
+	wf := where.Build()
+	var cnt int64
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, d := range s.docs {
+		if matchWhere(wf, id, d) {
+			delete(s.docs, id)
+			cnt++
+		}
+	}
+	s.dirty = s.dirty || cnt > 0
+	return cnt, nil
+}
+
+func (s *MemoryStore) List(_ context.Context, limit int, after string) ([]*KnowledgeItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lo.Map(s.pageIDs(limit, after), func(id string, _ int) *KnowledgeItem { return s.docs[id].project(id, nil, nil) }), nil
+}
+
+// pageIDs returns up to limit IDs ordered after the cursor ID, caller holds the lock.
+func (s *MemoryStore) pageIDs(limit int, after string) []string {
+	ids := lo.Keys(s.docs)
+	slices.Sort(ids)
+	if after != "" {
+		i, _ := slices.BinarySearch(ids, after)
+		if i < len(ids) && ids[i] == after {
+			i++
+		}
+		ids = ids[i:]
+	}
+	return ids[:min(max(limit, 0), len(ids))]
+}
+
+func (s *MemoryStore) Count(context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.docs), nil
+}
+
+func (s *MemoryStore) CountWhere(_ context.Context, where *filters.WhereBuilder) (int, error) {
+	if where == nil {
+		return s.Count(context.Background())
+	}
+	wf := where.Build()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lo.CountBy(lo.Keys(s.docs), func(id string) bool { return matchWhere(wf, id, s.docs[id]) }), nil
+}
+
+// Flush saves content to the file if it was changed since the last flush and store is persisted.
+// File is replaced atomically, so it keeps either previous or new content on crash.
+func (s *MemoryStore) Flush(context.Context) error {
+	if s.path == "" {
+		return nil
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(s.docs)
+	s.dirty = err != nil
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err = writeFileAtomic(s.path, data); err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return fmt.Errorf("memory store[%s] flush: %w", s.path, err)
+	}
+	return nil
+}
+
+// AutoFlush flushes changes in background every interval until ctx is done, the rest of changes is flushed at the end.
+func (s *MemoryStore) AutoFlush(ctx context.Context, every time.Duration) *MemoryStore {
+	if s.path == "" {
+		return s
+	}
+	go func() {
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if err := s.Flush(context.WithoutCancel(ctx)); err != nil {
+					s.log.RecWithCtx(ctx, chMem).Errorf("Flush failed: %v", err)
+				}
+				return
+			case <-t.C:
+				if err := s.Flush(ctx); err != nil {
+					s.log.RecWithCtx(ctx, chMem).Errorf("Flush failed: %v", err)
+				}
+			}
+		}
+	}()
+	return s
+}
+
+// writeFileAtomic writes data to temp file in the dir of the path, syncs it and renames it to the path.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (s *MemoryStore) sortIDs(ids []string, sort []graphql.Sort) {
+	if len(sort) == 0 {
+		slices.SortStableFunc(ids, func(a, b string) int { return cmp.Compare(s.docs[a].Created, s.docs[b].Created) })
+		return
+	}
+	slices.SortStableFunc(ids, func(a, b string) int {
+		for _, srt := range sort {
+			if len(srt.Path) == 0 {
+				continue
+			}
+			c := compareValues(s.docs[a].value(a, srt.Path[0]), s.docs[b].value(b, srt.Path[0]))
+			if srt.Order == "desc" {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+}
+
+// bm25 scores documents by query terms over the fields (all text fields if empty).
+func (s *MemoryStore) bm25(ids []string, query string, fields []string) map[string]float64 {
+	qTerms := lo.Uniq(tokenize(query))
+	if len(qTerms) == 0 {
+		return map[string]float64{}
+	}
+	if len(fields) == 0 {
+		fields = textFields
+	}
+
+	tf := make(map[string]map[string]int, len(ids))
+	lens := make(map[string]int, len(ids))
+	df := map[string]int{}
+	total := 0
+	for _, id := range ids {
+		tf[id] = map[string]int{}
+		for _, f := range fields {
+			for t, n := range s.docs[id].terms[f] {
+				tf[id][t] += n
+				lens[id] += n
+			}
+		}
+		total += lens[id]
+		for _, t := range qTerms {
+			if tf[id][t] > 0 {
+				df[t]++
+			}
+		}
+	}
+	avgLen := math.Max(float64(total)/math.Max(float64(len(ids)), 1), 1)
+
+	scores := make(map[string]float64, len(ids))
+	n := float64(len(ids))
+	for _, id := range ids {
+		for _, t := range qTerms {
+			f := float64(tf[id][t])
+			if f == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(df[t])+0.5)/(float64(df[t])+0.5))
+			scores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(lens[id])/avgLen))
+		}
+	}
+	return scores
+}
+
+func (s *MemoryStore) cosine(ids []string, q []float32) map[string]float64 {
+	scores := make(map[string]float64, len(ids))
+	for _, id := range ids {
+		if v := s.docs[id].Vector; len(v) == len(q) {
+			scores[id] = cosineSimilarity(q, v)
+		}
+	}
+	return scores
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0 // vectors of different models
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// fuseRanked combines vector and keyword results by reciprocal rank, like Weaviate "rankedFusion".
+func fuseRanked(vector, keyword map[string]float64, alpha float64) map[string]float64 {
+	res := map[string]float64{}
+	for _, part := range []struct {
+		weight float64
+		scores map[string]float64
+	}{{alpha, vector}, {1 - alpha, keyword}} {
+		weight, scores := part.weight, part.scores
+		ids := lo.Filter(lo.Keys(scores), func(id string, _ int) bool { return scores[id] > 0 })
+		slices.SortFunc(ids, func(a, b string) int { return cmp.Or(cmp.Compare(scores[b], scores[a]), cmp.Compare(a, b)) })
+		for rank, id := range ids {
+			res[id] += weight / float64(rrfK+rank+1)
+		}
+	}
+	return res
+}
+
+func (d *memDoc) index() {
+	d.terms = map[string]map[string]int{}
+	for _, f := range textFields {
+		counts := map[string]int{}
+		for _, t := range tokenize(goutil.String(d.value("", f))) {
+			counts[t]++
+		}
+		d.terms[f] = counts
+	}
+}
+
+// value returns property of the document by Weaviate path name.
+func (d *memDoc) value(id, field string) any {
+	it := d.Item
+	switch field {
+	case "id", "_id":
+		return id
+	case FieldTitle.String():
+		return it.Title
+	case FieldChunkNo.String():
+		return int64(it.ChunkNo)
+	case FieldContent.String():
+		return it.Content
+	case FieldUrl.String():
+		return it.URL
+	case FieldCategory.String():
+		return it.Category
+	case FieldSummary.String():
+		return it.Summary
+	case FieldKeywords.String():
+		return it.KeyWords
+	case FieldSectionPath.String():
+		return it.SectionPath
+	case FieldTags.String():
+		return it.Tags
+	case FieldLanguage.String():
+		return it.Language
+	case FieldPages.String():
+		return lo.Map(it.Pages, func(p int, _ int) string { return strconv.Itoa(p) })
+	case FieldSheet.String():
+		return it.Sheet
+	case FieldRowRange.String():
+		return it.RowRange
+	case FieldOwner.String():
+		return it.Owner
+	case FieldValidUntil.String():
+		if it.ValidUntil == nil {
+			return nil
+		}
+		return it.ValidUntil.UnixMilli()
+	case "_creationTimeUnix":
+		return d.Created
+	case "_lastUpdateTimeUnix":
+		return d.Updated
+	}
+	return nil
+}
+
+// project returns copy of the item with requested fields only (all if fields is empty) and Additional map like Weaviate returns.
+func (d *memDoc) project(id string, fields []Field, scores map[string]float64) *KnowledgeItem {
+	item := d.Item.clone()
+	item.Additional = AdditionalMap{
+		"id":                 id,
+		"creationTimeUnix":   strconv.FormatInt(d.Created, 10),
+		"lastUpdateTimeUnix": strconv.FormatInt(d.Updated, 10),
+	}
+	if scores != nil {
+		item.Additional["score"] = strconv.FormatFloat(scores[id], 'f', -1, 64)
+	}
+	if len(fields) == 0 {
+		return item
+	}
+
+	names := lo.Map(fields, func(f Field, _ int) string { return f.String() })
+	keep := func(f Field) bool { return lo.Contains(names, f.String()) }
+	res := &KnowledgeItem{Additional: item.Additional}
+	if keep(FieldTitle) {
+		res.Title = item.Title
+	}
+	if keep(FieldChunkNo) {
+		res.ChunkNo = item.ChunkNo
+	}
+	if keep(FieldContent) {
+		res.Content = item.Content
+	}
+	if keep(FieldUrl) {
+		res.URL = item.URL
+	}
+	if keep(FieldCategory) {
+		res.Category = item.Category
+	}
+	if keep(FieldSummary) {
+		res.Summary = item.Summary
+	}
+	if keep(FieldKeywords) {
+		res.KeyWords = item.KeyWords
+	}
+	if keep(FieldSectionPath) {
+		res.SectionPath = item.SectionPath
+	}
+	if keep(FieldTags) {
+		res.Tags = item.Tags
+	}
+	if keep(FieldLanguage) {
+		res.Language = item.Language
+	}
+	if keep(FieldPages) {
+		res.Pages = item.Pages
+	}
+	if keep(FieldSheet) {
+		res.Sheet = item.Sheet
+	}
+	if keep(FieldRowRange) {
+		res.RowRange = item.RowRange
+	}
+	if keep(FieldOwner) {
+		res.Owner = item.Owner
+	}
+	if keep(FieldValidUntil) {
+		res.ValidUntil = item.ValidUntil
+	}
+	if !lo.SomeBy(names, func(n string) bool { return strings.HasPrefix(n, "_additional") }) {
+		res.Additional = nil
+	}
+	return res
+}
+
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+// matchWhere evaluates Weaviate where filter against the document. Nil or empty filter matches everything.
+func matchWhere(w *models.WhereFilter, id string, d *memDoc) bool {
+	if w == nil || w.Operator == "" && len(w.Path) == 0 {
+		return true
+	}
+	switch filters.WhereOperator(w.Operator) {
+	case filters.And:
+		return lo.EveryBy(w.Operands, func(o *models.WhereFilter) bool { return matchWhere(o, id, d) })
+	case filters.Or:
+		return lo.SomeBy(w.Operands, func(o *models.WhereFilter) bool { return matchWhere(o, id, d) })
+	case filters.Not:
+		return len(w.Operands) > 0 && !matchWhere(w.Operands[0], id, d)
+	}
+	if len(w.Path) == 0 {
+		return false
+	}
+
+	actual := d.value(id, w.Path[0])
+	switch filters.WhereOperator(w.Operator) {
+	case filters.IsNull:
+		arr, isArr := actual.([]string)
+		isNull := actual == nil || actual == "" || isArr && len(arr) == 0
+		return w.ValueBoolean == nil || *w.ValueBoolean == isNull
+	case filters.ContainsAny, filters.ContainsAll:
+		values := whereValues(w)
+		tokens := tokenize(goutil.String(actual))
+		if arr, ok := actual.([]string); ok {
+			// array property: every element is compared as a whole, like field tokenization
+			tokens = lo.Map(arr, func(v string, _ int) string { return strings.ToLower(v) })
+		}
+		has := func(v any) bool { return lo.Contains(tokens, strings.ToLower(goutil.String(v))) }
+		if filters.WhereOperator(w.Operator) == filters.ContainsAll {
+			return len(values) > 0 && lo.EveryBy(values, has)
+		}
+		return lo.SomeBy(values, has)
+	}
+
+	values := whereValues(w)
+	if len(values) == 0 {
+		return false
+	}
+	expected := values[0]
+	switch filters.WhereOperator(w.Operator) {
+	case filters.Equal:
+		return equalValues(actual, expected)
+	case filters.NotEqual:
+		return !equalValues(actual, expected)
+	case filters.GreaterThan:
+		return compareValues(actual, expected) > 0
+	case filters.GreaterThanEqual:
+		return compareValues(actual, expected) >= 0
+	case filters.LessThan:
+		return compareValues(actual, expected) < 0
+	case filters.LessThanEqual:
+		return compareValues(actual, expected) <= 0
+	case filters.Like:
+		re, str := likeRegexp(goutil.String(expected)), goutil.String(actual)
+		return re.MatchString(str) || lo.SomeBy(tokenize(str), re.MatchString)
+	}
+	return false
+}
+
+func whereValues(w *models.WhereFilter) (values []any) {
+	for _, p := range []*string{w.ValueText, w.ValueString} {
+		if p != nil {
+			values = append(values, *p)
+		}
+	}
+	if w.ValueInt != nil {
+		values = append(values, *w.ValueInt)
+	}
+	if w.ValueNumber != nil {
+		values = append(values, *w.ValueNumber)
+	}
+	if w.ValueBoolean != nil {
+		values = append(values, *w.ValueBoolean)
+	}
+	if w.ValueDate != nil {
+		if t, err := time.Parse(time.RFC3339Nano, *w.ValueDate); err == nil {
+			values = append(values, t.UnixMilli())
+		}
+	}
+	for _, v := range w.ValueTextArray {
+		values = append(values, v)
+	}
+	for _, v := range w.ValueStringArray {
+		values = append(values, v)
+	}
+	for _, v := range w.ValueIntArray {
+		values = append(values, v)
+	}
+	return
+}
+
+// equalValues compares strings case-insensitively (text properties are lowercased by Weaviate tokenization).
+func equalValues(a, b any) bool {
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			return strings.EqualFold(sa, sb)
+		}
+	}
+	return compareValues(a, b) == 0
+}
+
+// compareValues compares numbers numerically and everything else as strings.
+func compareValues(a, b any) int {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return cmp.Compare(fa, fb)
+		}
+	}
+	return cmp.Compare(goutil.String(a), goutil.String(b))
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// likeRegexp converts Weaviate Like pattern ("*" - any chars, "?" - one char) to case-insensitive regexp.
+// Like Weaviate, the pattern is matched against the whole value or any of its words.
+func likeRegexp(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return regexp.MustCompile("(?is)^" + sb.String() + "$")
+}
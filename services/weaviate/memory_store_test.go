@@ -0,0 +1,175 @@
+package wvservice
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+)
+
+func testMemoryStore(t *testing.T, path string) *MemoryStore {
+	s, err := OpenMemoryStore(logTestKB, path)
+	require.NoError(t, err)
+	_, err = s.Upsert(context.Background(),
+		NewKI("VoIP error 409", "Error 409 means the number is already registered in VoIP platform", "https://wiki/voip/409", "voip", "", "voip,409"),
+		NewKI("VoIP error 404", "Subscriber not found in VoIP platform", "https://wiki/voip/404", "voip", "", "voip,404"),
+		NewKI("Tariff change", "How to change tariff plan of the subscriber", "https://lifecell.ua/tariffs", "web", "", "tariff"),
+	)
+	require.NoError(t, err)
+	return s
+}
+
+func titles(items []*KnowledgeItem) []string {
+	return lo.Map(items, func(item *KnowledgeItem, _ int) string { return item.Title })
+}
+
+func TestMemoryStore_SearchBM25(t *testing.T) {
+	s := testMemoryStore(t, "")
+	ctx := context.Background()
+
+	items, err := s.Search(ctx, DefaultSO().Fields(FieldAdditional1).SearchTxt("error 409"))
+	require.NoError(t, err)
+	require.NotEmpty(t, items)
+	assert.Equal(t, "VoIP error 409", items[0].Title)
+	assert.NotEmpty(t, items[0].Additional["score"])
+
+	items, err = s.Search(ctx, DefaultSO().SearchTxt("tariff").SF(FieldContent.String()))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Tariff change"}, titles(items))
+
+	items, err = s.Search(ctx, DefaultSO().SearchTxt("nothing similar"))
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestMemoryStore_SearchWhere(t *testing.T) {
+	s := testMemoryStore(t, "")
+	ctx := context.Background()
+
+	items, err := s.Search(ctx, DefaultSO().Where("category", "voip", string(filters.Equal)).SortOrder(FieldTitle, false))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"VoIP error 404", "VoIP error 409"}, titles(items))
+
+	items, err = s.Search(ctx, DefaultSO().Where("url", "https://lifecell.ua*", string(filters.Like)))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Tariff change"}, titles(items))
+
+	and := filters.Where().WithOperator(filters.And).WithOperands([]*filters.WhereBuilder{
+		filters.Where().WithPath([]string{"category"}).WithOperator(filters.Equal).WithValueText("voip"),
+		filters.Where().WithPath([]string{"content"}).WithOperator(filters.Like).WithValueText("*registered*"),
+	})
+	items, err = s.Search(ctx, DefaultSO().WithWhere(and))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"VoIP error 409"}, titles(items))
+
+	or := filters.Where().WithOperator(filters.Or).WithOperands([]*filters.WhereBuilder{
+		filters.Where().WithPath([]string{"title"}).WithOperator(filters.Equal).WithValueText("Tariff change"),
+		filters.Where().WithPath([]string{"title"}).WithOperator(filters.Equal).WithValueText("VoIP error 404"),
+	})
+	items, err = s.Search(ctx, DefaultSO().WithWhere(or).SearchTxt("subscriber"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Tariff change", "VoIP error 404"}, titles(items))
+}
+
+func TestMemoryStore_SearchHybrid(t *testing.T) {
+	// fake embedder: vector is a count of "voip" and "tariff" words
+	embed := func(_ context.Context, texts []string) ([][]float32, error) {
+		return lo.Map(texts, func(s string, _ int) []float32 {
+			s = strings.ToLower(s)
+			return []float32{float32(strings.Count(s, "voip")), float32(strings.Count(s, "tariff")), 0.1}
+		}), nil
+	}
+	s := NewMemoryStore(logTestKB).WithEmbedder(EmbedFunc(embed))
+	ctx := context.Background()
+	_, err := s.Upsert(ctx,
+		NewKI("VoIP", "VoIP platform voip", "", "voip", "", ""),
+		NewKI("Tariff", "Tariff plan", "", "web", "", ""),
+	)
+	require.NoError(t, err)
+
+	items, err := s.Search(ctx, DefaultSO().SearchTxt("voip").WithMode(SearchVector))
+	require.NoError(t, err)
+	assert.Equal(t, "VoIP", items[0].Title)
+
+	items, err = s.Search(ctx, DefaultSO().SearchTxt("tariff plan").WithAlpha(0.5))
+	require.NoError(t, err)
+	assert.Equal(t, "Tariff", items[0].Title)
+}
+
+func TestMemoryStore_ListGetDelete(t *testing.T) {
+	s := testMemoryStore(t, "")
+	ctx := context.Background()
+
+	page1, err := s.List(ctx, 2, "")
+	require.NoError(t, err)
+	require.Len(t, page1, 2)
+	page2, err := s.List(ctx, 2, page1[1].ID())
+	require.NoError(t, err)
+	require.Len(t, page2, 1)
+	assert.Less(t, page1[1].ID(), page2[0].ID())
+
+	item, err := s.GetByID(ctx, page2[0].ID())
+	require.NoError(t, err)
+	assert.NotEmpty(t, item.Content)
+	assert.NotEmpty(t, item.Additional["creationTimeUnix"])
+
+	cnt, err := s.DeleteWhere(ctx, filters.Where().WithPath([]string{"category"}).WithOperator(filters.Equal).WithValueText("voip"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	require.NoError(t, s.Delete(ctx, page2[0].ID()))
+	_, err = s.GetByID(ctx, page2[0].ID())
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestMemoryStore_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.json")
+	s := testMemoryStore(t, path)
+	ctx := context.Background()
+
+	ids, err := s.UpsertItems(ctx, "file://a.docx", NewKI("Doc A", "content of document A", "a.docx", "docx", "", ""))
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
+	assert.NoFileExists(t, path, "changes are saved by flush")
+
+	require.NoError(t, s.Flush(ctx))
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*"))
+	assert.Equal(t, []string{path}, files, "temp file is renamed")
+	info, _ := os.Stat(path)
+	require.NoError(t, s.Flush(ctx))
+	info2, _ := os.Stat(path)
+	assert.Equal(t, info.ModTime(), info2.ModTime(), "unchanged store is not saved again")
+
+	loaded, err := OpenMemoryStore(logTestKB, path)
+	require.NoError(t, err)
+	total, _ := loaded.Count(ctx)
+	assert.Equal(t, 4, total)
+
+	item, err := loaded.GetByID(ctx, ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, "Doc A", item.Title)
+
+	items, err := loaded.Search(ctx, DefaultSO().SearchTxt("document"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Doc A"}, titles(items))
+
+	autoCtx, cancel := context.WithCancel(ctx)
+	loaded.AutoFlush(autoCtx, time.Hour)
+	require.NoError(t, loaded.Delete(ctx, ids[0]))
+	cancel()
+	assert.Eventually(t, func() bool {
+		reloaded, err := OpenMemoryStore(logTestKB, path)
+		if err != nil {
+			return false
+		}
+		total, _ := reloaded.Count(ctx)
+		return total == 3
+	}, time.Second, 10*time.Millisecond, "changes are flushed when auto flush is stopped")
+}
@@ -0,0 +1,105 @@
+package wvservice
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gookit/goutil/errorx"
+	"github.com/samber/lo"
+)
+
+// QueryRewriter generates variants of the search query: translations, keywords, hypothetical answer (HyDE), etc.
+type QueryRewriter interface {
+	Rewrite(ctx context.Context, query string) ([]string, error)
+}
+
+// WithRewriter enables multi-query retrieval: the store is searched with the original query and every variant,
+// results are fused by reciprocal rank (see FuseRRF) and reranked against the original query. Nil disables it.
+func (r *Retriever) WithRewriter(qr QueryRewriter) *Retriever {
+	r.rewriter = qr
+	return r
+}
+
+// search returns candidates for the query. With rewriter the store is searched with every query variant in parallel.
+func (r *Retriever) search(ctx context.Context, so *SearchOptions) ([]*KnowledgeItem, error) {
+	if r.rewriter == nil || so.SearchText == "" {
+		return r.store.Search(ctx, so)
+	}
+	log := r.log.RecWithCtx(ctx, chRetriever)
+
+	queries := []string{so.SearchText}
+	variants, err := r.rewriter.Rewrite(ctx, so.SearchText)
+	if err != nil {
+		log.Warnf("Query rewrite failed, original query is used: %v", err)
+	}
+	queries = lo.Uniq(append(queries, lo.Compact(lo.Map(variants, func(q string, _ int) string { return strings.TrimSpace(q) }))...))
+	log.Infof("Multi-query retrieval: queries=%d %q", len(queries), queries)
+
+	var (
+		wg    sync.WaitGroup
+		lists = make([][]*KnowledgeItem, len(queries))
+		errs  = make([]error, len(queries))
+	)
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			variant := lo.ToPtr(*so).SearchTxt(q)
+			if i > 0 {
+				variant.Vector = nil // explicit vector belongs to the original query
+			}
+			lists[i], errs[i] = r.store.Search(ctx, variant)
+		}(i, q)
+	}
+	wg.Wait()
+
+	errMap := errorx.ErrMap{}
+	for i, e := range errs {
+		if e != nil {
+			errMap[queries[i]] = e
+		}
+	}
+	if len(errMap) == len(queries) {
+		return nil, errMap.ErrorOrNil()
+	}
+	if len(errMap) > 0 {
+		log.Warnf("Search failed for some query variants: %v", errMap)
+	}
+	return FuseRRF(so.LimitItems, lists...), nil
+}
+
+// FuseRRF merges ranked lists by reciprocal rank fusion: score = sum(1 / (60 + rank)).
+// Items are identified by ID (title and chunk number if ID is absent). Score of the item is replaced by RRF score.
+func FuseRRF(limit int, lists ...[]*KnowledgeItem) []*KnowledgeItem {
+	scores := map[string]float64{}
+	items := map[string]*KnowledgeItem{}
+	var keys []string
+	for _, list := range lists {
+		for rank, item := range list {
+			key := lo.Ternary(item.ID() != "", item.ID(), fmt.Sprintf("%s#%d", item.Title, item.ChunkNo))
+			if _, ok := items[key]; !ok {
+				items[key] = item
+				keys = append(keys, key)
+			}
+			scores[key] += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	slices.SortStableFunc(keys, func(a, b string) int { return cmp.Compare(scores[b], scores[a]) })
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return lo.Map(keys, func(key string, _ int) *KnowledgeItem {
+		item := items[key]
+		if item.Additional == nil {
+			item.Additional = AdditionalMap{}
+		}
+		item.Additional["score"] = strconv.FormatFloat(scores[key], 'f', 6, 64)
+		return item
+	})
+}
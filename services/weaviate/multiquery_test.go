@@ -0,0 +1,54 @@
+package wvservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRewriter struct {
+	variants []string
+	err      error
+}
+
+func (f *fakeRewriter) Rewrite(context.Context, string) ([]string, error) { return f.variants, f.err }
+
+func TestFuseRRF(t *testing.T) {
+	a, b, c := &KnowledgeItem{Title: "A"}, &KnowledgeItem{Title: "B"}, &KnowledgeItem{Title: "C"}
+	res := FuseRRF(0,
+		[]*KnowledgeItem{a, b, c},
+		[]*KnowledgeItem{{Title: "C"}, {Title: "B"}},
+		[]*KnowledgeItem{{Title: "B"}},
+	)
+	assert.Equal(t, []string{"B", "C", "A"}, titles(res))
+	assert.Greater(t, res[0].Additional.Score(), res[1].Additional.Score())
+
+	assert.Len(t, FuseRRF(2, []*KnowledgeItem{a, b, c}), 2)
+}
+
+func TestRetriever_MultiQuery(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(logTestKB)
+	_, err := s.Upsert(ctx,
+		NewKI("Налаштування телефонії", "Налаштування телефонії: сервер, логін", "", "voip", "", ""),
+		NewKI("Telephony settings", "Telephony settings: SIP server, login", "", "voip", "", ""),
+		NewKI("Tariffs", "Tariff plans", "", "web", "", ""),
+	)
+	require.NoError(t, err)
+
+	r := NewRetriever(logTestKB, s).WithReranker(nil)
+	items, err := r.Retrieve(ctx, DefaultSO().SearchTxt("налаштування телефонії"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Налаштування телефонії"}, titles(items), "single query finds only Ukrainian document")
+
+	items, err = r.WithRewriter(&fakeRewriter{variants: []string{"Telephony settings", "SIP server"}}).Retrieve(ctx, DefaultSO().SearchTxt("налаштування телефонії"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Налаштування телефонії", "Telephony settings"}, titles(items), "English document is found by translated variant")
+
+	items, err = r.WithRewriter(&fakeRewriter{err: errors.New("llm is down")}).Retrieve(ctx, DefaultSO().SearchTxt("налаштування телефонії"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Налаштування телефонії"}, titles(items), "rewrite error - original query only")
+}
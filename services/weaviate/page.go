@@ -0,0 +1,88 @@
+package wvservice
+
+import (
+	"context"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// Page is a page of objects of the store.
+// Offset pagination (Number >= 1) supports search, filter and sort; cursor pagination (Next) - only listing ordered by ID.
+type Page struct {
+	Items  []*KnowledgeItem `json:"items"`
+	Total  int              `json:"total"`           // count of objects matched by filter
+	Number int              `json:"page,omitempty"`  // 1-based number of the page, 0 for cursor pagination
+	Size   int              `json:"size"`            // requested size of the page
+	Next   string           `json:"next,omitempty"`  // cursor of the next page (cursor pagination)
+	After  string           `json:"after,omitempty"` // cursor of the current page
+}
+
+// NewPage wraps items (e.g. search results) into single page.
+func NewPage(items []*KnowledgeItem) *Page {
+	return &Page{Items: items, Total: len(items), Number: 1, Size: len(items)}
+}
+
+// Pages returns count of pages.
+func (p *Page) Pages() int {
+	if p.Size <= 0 {
+		return 1
+	}
+	return max((p.Total+p.Size-1)/p.Size, 1)
+}
+
+// HasNext reports whether there are objects after the page.
+func (p *Page) HasNext() bool {
+	if p.Number == 0 {
+		return p.Next != ""
+	}
+	return p.Number < p.Pages()
+}
+
+func (p *Page) HasPrev() bool  { return p.Number > 1 }
+func (p *Page) NextPage() int  { return p.Number + 1 }
+func (p *Page) PrevPage() int  { return max(p.Number-1, 1) }
+func (p *Page) IsCursor() bool { return p.Number == 0 }
+
+// Paginate returns page of objects matched by so. If so.After is set, page is taken by cursor (sort and filter are not allowed),
+// otherwise the page number is used (1-based, offset + size is limited by QUERY_MAXIMUM_RESULTS of Weaviate, 10000 by default).
+// Total is counted by CountWhere with the filter of so.
+func Paginate(ctx context.Context, store VectorStore, so *SearchOptions, page int) (*Page, error) {
+	if so == nil {
+		so = DefaultSO()
+	}
+	q := *so
+	q.LimitItems = lo.Clamp(lo.Ternary(q.LimitItems > 0, q.LimitItems, DefaultPageSize), 1, MaxPageSize)
+	res := &Page{Size: q.LimitItems, After: q.After}
+	if q.After != "" {
+		if q.SearchText != "" || q.GetWhere() != nil || len(q.SortBy) > 0 {
+			return nil, ErrCursorWithQuery
+		}
+		q.Offset = 0
+		if !lo.ContainsBy(q.FieldsReturn, func(f Field) bool { return strings.HasPrefix(f.String(), "_additional") }) {
+			q.FieldsReturn = append(q.FieldsReturn, FieldAdditional2)
+		}
+	} else {
+		res.Number = max(page, 1)
+		q.Offset = (res.Number - 1) * q.LimitItems
+	}
+
+	total, err := store.CountWhere(ctx, q.GetWhere())
+	if err != nil {
+		return nil, err
+	}
+	items, err := store.Search(ctx, &q)
+	if err != nil {
+		return nil, err
+	}
+	res.Items, res.Total = items, total
+	if res.IsCursor() && len(items) == q.LimitItems {
+		res.Next = items[len(items)-1].ID()
+	}
+	return res, nil
+}
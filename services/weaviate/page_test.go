@@ -0,0 +1,65 @@
+package wvservice
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaginate_MemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(logTestKB)
+	for i := range 7 {
+		_, err := s.Upsert(ctx, NewKI(fmt.Sprintf("Doc %d", i), "content", "https://wiki/doc", "confluence", "", ""))
+		require.NoError(t, err)
+	}
+	_, err := s.Upsert(ctx, NewKI("Web", "content", "https://lifecell.ua", "WEB", "", ""))
+	require.NoError(t, err)
+
+	// offset pagination with sort
+	so := DefaultSO().Limit(3).SortOrder(FieldTitle, false)
+	page, err := Paginate(ctx, s, so, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Doc 0", "Doc 1", "Doc 2"}, titles(page.Items))
+	assert.Equal(t, 8, page.Total)
+	assert.Equal(t, 3, page.Pages())
+	assert.True(t, page.HasNext())
+	assert.False(t, page.HasPrev())
+
+	page, err = Paginate(ctx, s, so, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Doc 6", "Web"}, titles(page.Items))
+	assert.False(t, page.HasNext())
+	assert.Equal(t, 2, page.PrevPage())
+
+	// total is counted with the filter
+	fso := DefaultSO().Limit(2)
+	require.NoError(t, fso.WithFilter(&MetaFilter{Categories: []string{"WEB"}}))
+	page, err = Paginate(ctx, s, fso, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, page.Total)
+	assert.Equal(t, []string{"Web"}, titles(page.Items))
+
+	// cursor pagination continues after the ID of the last object
+	ids := s.pageIDs(100, "")
+	require.Len(t, ids, 8)
+	page, err = Paginate(ctx, s, DefaultSO().Limit(3).WithAfter(ids[2]), 0)
+	require.NoError(t, err)
+	assert.True(t, page.IsCursor())
+	assert.Equal(t, ids[3:6], lo.Map(page.Items, func(item *KnowledgeItem, _ int) string { return item.ID() }))
+	assert.Equal(t, ids[5], page.Next)
+	assert.Equal(t, 8, page.Total)
+
+	page, err = Paginate(ctx, s, DefaultSO().Limit(3).WithAfter(page.Next), 0)
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+	assert.Empty(t, page.Next)
+	assert.False(t, page.HasNext())
+
+	_, err = Paginate(ctx, s, DefaultSO().WithAfter(ids[0]).SortOrder(FieldTitle, false), 0)
+	assert.ErrorIs(t, err, ErrCursorWithQuery)
+}
@@ -0,0 +1,207 @@
+package wvservice
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	gl "gitlab.dev.ict/golang/libs/gologgers"
+)
+
+const (
+	chRetriever = "Retriever"
+
+	DefaultCandidates  = 20
+	DefaultTopK        = 4
+	DefaultMinScore    = 0.2
+	DefaultTokenBudget = 3000
+)
+
+// Reranker scores documents by relevance to the query. Returned scores are in range [0..1] and aligned with docs.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, docs []string) ([]float64, error)
+}
+
+// LexicalReranker scores documents by coverage of query terms and bigrams. It needs no LLM and is used as fallback.
+type LexicalReranker struct{}
+
+func (LexicalReranker) Rerank(_ context.Context, query string, docs []string) ([]float64, error) {
+	qTerms := lo.Uniq(lo.Filter(tokenize(query), func(t string, _ int) bool { return len([]rune(t)) > 1 }))
+	qBigrams := bigrams(qTerms)
+	scores := make([]float64, len(docs))
+	if len(qTerms) == 0 {
+		return scores, nil
+	}
+	for i, doc := range docs {
+		terms := tokenize(doc)
+		termSet := lo.SliceToMap(terms, func(t string) (string, bool) { return t, true })
+		bigramSet := lo.SliceToMap(bigrams(terms), func(t string) (string, bool) { return t, true })
+
+		covered := lo.CountBy(qTerms, func(t string) bool { return termSet[t] })
+		score := float64(covered) / float64(len(qTerms))
+		if len(qBigrams) > 0 {
+			// phrase matches weigh 30%
+			phrases := lo.CountBy(qBigrams, func(b string) bool { return bigramSet[b] })
+			score = 0.7*score + 0.3*float64(phrases)/float64(len(qBigrams))
+		}
+		scores[i] = score
+	}
+	return scores, nil
+}
+
+func bigrams(terms []string) []string {
+	if len(terms) < 2 {
+		return nil
+	}
+	res := make([]string, 0, len(terms)-1)
+	for i := 1; i < len(terms); i++ {
+		res = append(res, terms[i-1]+" "+terms[i])
+	}
+	return res
+}
+
+// Retriever is a retrieval pipeline: over-fetch candidates from the store, rerank them, drop items with low score
+// and keep the best items which fit into the token budget.
+type Retriever struct {
+	log         *gl.Logger
+	store       VectorStore
+	reranker    Reranker
+	fallback    Reranker
+	rewriter    QueryRewriter
+	candidates  int
+	topK        int
+	minScore    float64
+	tokenBudget int
+}
+
+// NewRetriever creates retriever with LexicalReranker, see WithReranker to use LLM.
+func NewRetriever(log *gl.Logger, store VectorStore) *Retriever {
+	return &Retriever{
+		log:         log,
+		store:       store,
+		reranker:    LexicalReranker{},
+		fallback:    LexicalReranker{},
+		candidates:  DefaultCandidates,
+		topK:        DefaultTopK,
+		minScore:    DefaultMinScore,
+		tokenBudget: DefaultTokenBudget,
+	}
+}
+
+// WithReranker sets reranker, on its error LexicalReranker is used. Nil disables reranking: order of the store is kept.
+func (r *Retriever) WithReranker(rr Reranker) *Retriever {
+	r.reranker = rr
+	return r
+}
+
+func (r *Retriever) WithCandidates(n int) *Retriever {
+	r.candidates = lo.Ternary(n > 0, n, DefaultCandidates)
+	return r
+}
+
+func (r *Retriever) WithTopK(n int) *Retriever {
+	r.topK = lo.Ternary(n > 0, n, DefaultTopK)
+	return r
+}
+
+// WithMinScore sets threshold of rerank score, items with lower score are dropped.
+func (r *Retriever) WithMinScore(score float64) *Retriever {
+	r.minScore = score
+	return r
+}
+
+// WithTokenBudget limits total tokens of contents of returned items (0 - unlimited).
+func (r *Retriever) WithTokenBudget(tokens int) *Retriever {
+	r.tokenBudget = tokens
+	return r
+}
+
+func (r *Retriever) Store() VectorStore {
+	return r.store
+}
+
+// Retrieve searches candidates by so (limit of so is replaced by candidates count) and returns up to topK reranked items.
+// Score of the item (_additional.score) is replaced by rerank score. Expired items (see KnowledgeItem.ValidUntil) are dropped.
+func (r *Retriever) Retrieve(ctx context.Context, so *SearchOptions) ([]*KnowledgeItem, error) {
+	log := r.log.RecWithCtx(ctx, chRetriever)
+	if so == nil {
+		so = DefaultSO()
+	}
+	query := *so
+	query.LimitItems = max(r.candidates, r.topK)
+	query.FieldsReturn = lo.Uniq(append(slices.Clone(so.FieldsReturn), FieldTitle, FieldSectionPath, FieldContent, FieldValidUntil, FieldAdditional1))
+
+	items, err := r.search(ctx, &query)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	items = lo.Reject(items, func(item *KnowledgeItem, _ int) bool {
+		if item.IsExpired(now) {
+			log.Debugf("Drop expired item: validUntil=%s %s", item.ValidUntil.Format(time.DateOnly), item)
+			return true
+		}
+		return false
+	})
+	if len(items) == 0 || r.reranker == nil || so.SearchText == "" {
+		return r.budget(items), nil
+	}
+
+	docs := lo.Map(items, func(item *KnowledgeItem, _ int) string { return item.EmbedText() })
+	scores, err := r.reranker.Rerank(ctx, so.SearchText, docs)
+	if err == nil && len(scores) != len(items) {
+		err = fmt.Errorf("reranker returned %d scores for %d items", len(scores), len(items))
+	}
+	if err != nil {
+		log.Warnf("Rerank failed, lexical fallback is used: %v", err)
+		if scores, err = r.fallback.Rerank(ctx, so.SearchText, docs); err != nil {
+			return nil, err
+		}
+	}
+
+	order := lo.Range(len(items))
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(scores[b], scores[a]) })
+	var res []*KnowledgeItem
+	for _, i := range order {
+		if scores[i] < r.minScore {
+			log.Debugf("Rerank drop: score=%.3f < %.3f %s", scores[i], r.minScore, items[i])
+			continue
+		}
+		if items[i].Additional == nil {
+			items[i].Additional = AdditionalMap{}
+		}
+		items[i].Additional["score"] = strconv.FormatFloat(scores[i], 'f', 4, 64)
+		res = append(res, items[i])
+	}
+	res = r.budget(res)
+	log.Infof("Retrieve[%s]: candidates=%d returned=%d", so.SearchText, len(items), len(res))
+	return res, nil
+}
+
+// budget keeps first topK items which fit into the token budget. The first item is kept even if it exceeds the budget.
+func (r *Retriever) budget(items []*KnowledgeItem) []*KnowledgeItem {
+	items = items[:min(r.topK, len(items))]
+	if r.tokenBudget <= 0 {
+		return items
+	}
+	total := 0
+	for i, item := range items {
+		total += countTokens(item.Content)
+		if total > r.tokenBudget && i > 0 {
+			return items[:i]
+		}
+	}
+	return items
+}
+
+// JoinContents joins contents of items as a context for LLM.
+func JoinContents(items []*KnowledgeItem) string {
+	return strings.Join(lo.Map(items, func(item *KnowledgeItem, _ int) string {
+		return lo.Ternary(item.SectionPath != "", fmt.Sprintf("# %s (%s)\n%s", item.Title, item.SectionPath, item.Content), fmt.Sprintf("# %s\n%s", item.Title, item.Content))
+	}), "\n\n")
+}
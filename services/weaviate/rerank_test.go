@@ -0,0 +1,98 @@
+package wvservice
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReranker struct {
+	scores func(doc string) float64
+	err    error
+}
+
+func (f *fakeReranker) Rerank(_ context.Context, _ string, docs []string) ([]float64, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	res := make([]float64, len(docs))
+	for i, d := range docs {
+		res[i] = f.scores(d)
+	}
+	return res, nil
+}
+
+func TestLexicalReranker(t *testing.T) {
+	scores, err := LexicalReranker{}.Rerank(context.Background(), "FMC VoIP settings", []string{
+		"Tariff plans for subscribers",
+		"VoIP settings of FMC: SIP server, login",
+		"FMC VoIP settings",
+	})
+	require.NoError(t, err)
+	assert.Zero(t, scores[0])
+	assert.Greater(t, scores[2], scores[1], "phrase match is scored higher")
+	assert.InDelta(t, 1, scores[2], 1e-9)
+}
+
+func TestRetriever_Retrieve(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(logTestKB)
+	_, err := s.Upsert(ctx,
+		NewKI("VoIP errors", "VoIP VoIP VoIP registration errors", "", "voip", "", ""),
+		NewKI("VoIP tariffs", "VoIP VoIP tariffs", "", "voip", "", ""),
+		NewKI("FMC settings", "FMC VoIP settings: SIP server and login", "", "voip", "", ""),
+		NewKI("Other", "nothing", "", "web", "", ""),
+	)
+	require.NoError(t, err)
+
+	fmcFirst := &fakeReranker{scores: func(doc string) float64 {
+		switch {
+		case strings.HasPrefix(doc, "FMC settings"):
+			return 0.9
+		case strings.HasPrefix(doc, "VoIP errors"):
+			return 0.5
+		}
+		return 0.1
+	}}
+	r := NewRetriever(logTestKB, s).WithReranker(fmcFirst).WithMinScore(0.3)
+	items, err := r.Retrieve(ctx, DefaultSO().SearchTxt("voip settings"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FMC settings", "VoIP errors"}, titles(items), "reranked and filtered by threshold")
+	assert.Equal(t, 0.9, items[0].Additional.Score())
+
+	items, err = r.WithTopK(1).Retrieve(ctx, DefaultSO().SearchTxt("voip settings"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FMC settings"}, titles(items))
+
+	// reranker error - lexical fallback
+	items, err = NewRetriever(logTestKB, s).WithReranker(&fakeReranker{err: errors.New("llm is down")}).Retrieve(ctx, DefaultSO().SearchTxt("FMC settings"))
+	require.NoError(t, err)
+	assert.Equal(t, "FMC settings", items[0].Title)
+
+	// expired runbook is not served
+	_, err = s.Upsert(ctx, NewKI("FMC runbook", "FMC VoIP settings runbook", "", "runbook", "", "").WithValidUntil(time.Now().AddDate(0, 0, -1)))
+	require.NoError(t, err)
+	items, err = NewRetriever(logTestKB, s).Retrieve(ctx, DefaultSO().SearchTxt("FMC settings runbook"))
+	require.NoError(t, err)
+	assert.NotContains(t, titles(items), "FMC runbook")
+}
+
+func TestRetriever_Budget(t *testing.T) {
+	r := NewRetriever(logTestKB, nil).WithTopK(10).WithTokenBudget(25)
+	items := []*KnowledgeItem{
+		{Title: "A", Content: strings.Repeat("word ", 20)},
+		{Title: "B", Content: strings.Repeat("word ", 20)},
+	}
+	assert.Equal(t, []string{"A"}, titles(r.budget(items)))
+
+	r.WithTokenBudget(10)
+	assert.Equal(t, []string{"A"}, titles(r.budget(items)), "first item is kept even if exceeds budget")
+
+	r.WithTokenBudget(0)
+	assert.Len(t, r.budget(items), 2)
+}
@@ -1,17 +1,32 @@
 package wvservice
 
 import (
+	"github.com/samber/lo"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
 )
 
+// SearchMode defines how SearchText is matched: hybrid (default), vector only or keyword (BM25) only.
+type SearchMode string
+
+const (
+	SearchHybrid SearchMode = "hybrid"
+	SearchVector SearchMode = "vector"
+	SearchBM25   SearchMode = "bm25"
+)
+
 type SearchOptions struct {
 	FieldsReturn   []Field           `json:"fields"`       // The fields to return
 	SearchText     string            `json:"searchText"`   // The text to search
 	SearchFields   []string          `json:"searchFields"` // The fields to search in
 	SortBy         []graphql.Sort    `json:"sort"`         // The sort options
 	filterWhere    []FilterWhereFunc // The filter where functions
-	LimitItems     int               `json:"limit"` // The number of records to return
+	LimitItems     int               `json:"limit"`            // The number of records to return
+	Offset         int               `json:"offset,omitempty"` // count of skipped records (offset + limit is limited by QUERY_MAXIMUM_RESULTS of Weaviate)
+	After          string            `json:"after,omitempty"`  // cursor: ID of the last object of previous page, can't be combined with search, where and sort
+	Mode           SearchMode        `json:"mode,omitempty"`
+	Alpha          float32           `json:"alpha,omitempty"` // weight of vector search in hybrid mode (0 - BM25 only, 1 - vector only)
+	Vector         []float32         `json:"-"`               // query vector, if empty vector is built from SearchText by the store
 	whereCondition *filters.WhereBuilder
 }
 
@@ -31,6 +46,7 @@ SearchRequest
   - > Filter:Field - this is the field that the user wants to filter on.
   - > Filter:Operator - this is the operator that the user wants to use to filter the data. This can be `Equals`, `NotEquals`, `GreaterThan`, `LessThan`, `GreaterThanOrEquals`, `LessThanOrEquals`, `Contains`, `NotContains`, `StartsWith`, `EndsWith`.
   - > Filter:Value - this is the value that the user wants to filter on.
+  - `MetaFilter` this is a composable filter by category, URL prefix, tags and creation/update dates, see MetaFilter.
 */
 type SearchRequest struct {
 	Fields       []Field  `json:"fields"`       // The fields to return
@@ -46,19 +62,26 @@ type SearchRequest struct {
 		Operator string `json:"operator"`
 		Values   string `json:"value"`
 	} `json:"where"` // The filter where functions
+	Filter *MetaFilter `json:"filter,omitempty"` // combined with Where by AND
 }
 
-func (sr *SearchRequest) ToSearchOptions() *SearchOptions {
+// ToSearchOptions converts request to search options, error is returned for invalid filter.
+func (sr *SearchRequest) ToSearchOptions() (*SearchOptions, error) {
 	so := NewSO().
 		SetFields(sr.Fields...).
 		SearchTxt(sr.SearchText).
 		SF(sr.SearchFields...).
-		Limit(sr.Limit).
-		Where(sr.Where.Field, sr.Where.Values, sr.Where.Operator)
+		Limit(sr.Limit)
+	if sr.Where.Field != "" {
+		so.Where(sr.Where.Field, sr.Where.Values, sr.Where.Operator)
+	}
 	if sr.Sort.Field != "" {
 		so.Sort(sr.Sort.Field, sr.Sort.SortOrder)
 	}
-	return so
+	if err := so.WithFilter(sr.Filter); err != nil {
+		return nil, err
+	}
+	return so, nil
 }
 
 func sortT() graphql.Sort {
@@ -115,11 +138,64 @@ func (so *SearchOptions) Where(f, value, op string) *SearchOptions {
 	return so
 }
 
+// WithWhere sets filter condition, it is applied by every VectorStore implementation.
+func (so *SearchOptions) WithWhere(where *filters.WhereBuilder) *SearchOptions {
+	so.whereCondition = where
+	return so
+}
+
+// AndWhere adds filter condition to the existing one by AND.
+func (so *SearchOptions) AndWhere(where *filters.WhereBuilder) *SearchOptions {
+	so.whereCondition = allOf(so.whereCondition, where)
+	return so
+}
+
+// WithFilter adds metadata filter to the where condition by AND. Empty filter is ignored.
+func (so *SearchOptions) WithFilter(mf *MetaFilter) error {
+	where, err := mf.Build()
+	if err != nil {
+		return err
+	}
+	so.AndWhere(where)
+	return nil
+}
+
+func (so *SearchOptions) WithMode(m SearchMode) *SearchOptions {
+	so.Mode = m
+	return so
+}
+
+func (so *SearchOptions) WithAlpha(alpha float32) *SearchOptions {
+	so.Alpha = alpha
+	return so
+}
+
+func (so *SearchOptions) WithVector(v []float32) *SearchOptions {
+	so.Vector = v
+	return so
+}
+
+// GetWhere returns filter condition set by Where/WithWhere/WithFilter combined with FilterWhere functions by AND.
+func (so *SearchOptions) GetWhere() *filters.WhereBuilder {
+	return allOf(so.whereCondition, so.GetFilterWhere())
+}
+
 func (so *SearchOptions) Limit(limit int) *SearchOptions {
 	so.LimitItems = limit
 	return so
 }
 
+func (so *SearchOptions) WithOffset(offset int) *SearchOptions {
+	so.Offset = offset
+	return so
+}
+
+// WithAfter sets cursor, objects are listed ordered by ID after the object with the id.
+func (so *SearchOptions) WithAfter(id string) *SearchOptions {
+	so.After = id
+	return so
+}
+
 func (so *SearchOptions) GetFields() []graphql.Field {
 	return fieldsList(so.FieldsReturn...)
 }
@@ -128,10 +204,7 @@ func (so *SearchOptions) GetSort() []graphql.Sort {
 	return so.SortBy
 }
 
+// GetFilterWhere returns conditions of all FilterWhere functions combined by AND.
 func (so *SearchOptions) GetFilterWhere() *filters.WhereBuilder {
-	var where *filters.WhereBuilder
-	for _, f := range so.filterWhere {
-		return f("")
-	}
-	return where
+	return allOf(lo.Map(so.filterWhere, func(f FilterWhereFunc, _ int) *filters.WhereBuilder { return f("") })...)
 }
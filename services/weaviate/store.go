@@ -0,0 +1,219 @@
+package wvservice
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gookit/goutil"
+	"github.com/gookit/goutil/errorx"
+	"github.com/gookit/slog"
+	"github.com/mitchellh/copystructure"
+	"github.com/samber/lo"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/fault"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+	"github.com/weaviate/weaviate/entities/models"
+	"gitlab.dev.ict/golang/libs/utils"
+)
+
+var (
+	ErrObjectNotFound  = errors.New("object not found")
+	ErrCursorWithQuery = errors.New("cursor pagination can't be combined with search, filter or sort")
+	ErrInvalidItem     = errors.New("invalid object")
+	ErrVersionConflict = errors.New("object was modified after it was read")
+)
+
+// FieldsAll - all properties of KnowledgeItem with id and timestamps.
+var FieldsAll = []Field{FieldTitle, FieldChunkNo, FieldContent, FieldUrl, FieldCategory, FieldSummary, FieldKeywords, FieldSectionPath, FieldTags, FieldLanguage, FieldPages, FieldSheet, FieldRowRange, FieldOwner, FieldValidUntil, FieldAdditional2}
+
+// VectorStore is a storage of knowledge items with hybrid/vector/BM25 search.
+// Implementations: KnowledgeBase (Weaviate) and MemoryStore (in-process, optionally persisted to file).
+type VectorStore interface {
+	// Upsert saves items as is (without chunking). Items with ID (_additional.id) overwrite existing objects.
+	Upsert(ctx context.Context, items ...*KnowledgeItem) (*BatchResult, error)
+	// Search returns items matched by SearchOptions: text/vector query, where filter, sort and limit.
+	Search(ctx context.Context, so *SearchOptions) ([]*KnowledgeItem, error)
+	// GetByID returns item with all fields or ErrObjectNotFound.
+	GetByID(ctx context.Context, id string) (*KnowledgeItem, error)
+	// Update edits properties of the object (see ItemPatch) and vectorizes it again, returns the updated object.
+	Update(ctx context.Context, id string, p *ItemPatch) (*KnowledgeItem, error)
+	// Delete removes objects by IDs, absent objects are ignored.
+	Delete(ctx context.Context, ids ...string) error
+	// DeleteWhere removes objects matched by filter and returns their count.
+	DeleteWhere(ctx context.Context, where *filters.WhereBuilder) (int64, error)
+	// List returns up to limit items ordered by ID, starting after the cursor ID (empty - from the beginning).
+	List(ctx context.Context, limit int, after string) ([]*KnowledgeItem, error)
+	// Count returns total count of objects.
+	Count(ctx context.Context) (int, error)
+	// CountWhere returns count of objects matched by filter (all objects if where is nil).
+	CountWhere(ctx context.Context, where *filters.WhereBuilder) (int, error)
+}
+
+var (
+	_ VectorStore = (*KnowledgeBase)(nil)
+	_ VectorStore = (*MemoryStore)(nil)
+)
+
+// Embedder creates vectors for documents and search queries on the client side (see llm.Embedder).
+type Embedder interface {
+	EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error)
+	EmbedQuery(ctx context.Context, text string) ([]float32, error)
+}
+
+// EmbedFunc is an adapter to use ordinary function as Embedder.
+type EmbedFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+func (fn EmbedFunc) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	return fn(ctx, texts)
+}
+
+func (fn EmbedFunc) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	v, err := fn(ctx, []string{text})
+	if err != nil || len(v) == 0 {
+		return nil, err
+	}
+	return v[0], nil
+}
+
+// EmbedText returns text of the item which is vectorized: title, section path and content.
+func (k *KnowledgeItem) EmbedText() string {
+	return strings.Join(lo.Compact([]string{k.Title, k.SectionPath, k.Content}), "\n")
+}
+
+// upsertSource assigns deterministic IDs (see ItemUUID) to chunks of the source and upserts them to the store.
+func upsertSource(ctx context.Context, store VectorStore, sourceURI string, items ...*KnowledgeItem) ([]string, error) {
+	for _, item := range items {
+		if item.Additional == nil {
+			item.Additional = AdditionalMap{}
+		}
+		item.Additional["id"] = ItemUUID(sourceURI, item.ChunkNo).String()
+	}
+	res, err := store.Upsert(ctx, items...)
+	return res.IDs(), err
+}
+
+// splitItem splits item into chunks with chunker. Item without content or with a single chunk is returned as is.
+// Page markers (see PageMarker) are converted to pages of the chunks.
+func splitItem(c Chunker, log *slog.Record, item *KnowledgeItem) (items []*KnowledgeItem) {
+	chunks := c.Split(item.Content)
+	if len(chunks) <= 1 {
+		if len(chunks) == 1 && item.SectionPath == "" {
+			item.SectionPath = chunks[0].SectionPath
+		}
+		items = []*KnowledgeItem{item}
+		applyPages(items)
+		return
+	}
+
+	log.Infof("Content tokens=%d divided into chunks=%d", countTokens(item.Content), len(chunks))
+	for i, c := range chunks {
+		ki := item.clone()
+		ki.Content, ki.ChunkNo, ki.SectionPath = c.Content, i+1, c.SectionPath
+		items = append(items, ki)
+	}
+	applyPages(items)
+	items = lo.Filter(items, func(ki *KnowledgeItem, _ int) bool { return ki.Content != "" }) // chunks of page markers only
+	for i, ki := range items {
+		log.Debugf("Item chunk-%d: tokens=%d %s", i+1, chunks[ki.ChunkNo-1].Tokens, ki)
+		ki.ChunkNo = i + 1
+	}
+	return
+}
+
+func (k *KnowledgeItem) clone() *KnowledgeItem {
+	return lo.Must(copystructure.Copy(k)).(*KnowledgeItem)
+}
+
+// Upsert saves items with the batch API, see VectorStore.
+func (kb *KnowledgeBase) Upsert(ctx context.Context, items ...*KnowledgeItem) (*BatchResult, error) {
+	b := kb.NewBatch(ctx)
+	b.items = items
+	return b.Flush(ctx)
+}
+
+// Search runs GraphQL Get query and converts response to items, see VectorStore.
+func (kb *KnowledgeBase) Search(ctx context.Context, so *SearchOptions) ([]*KnowledgeItem, error) {
+	gr, err := kb.SearchGQL(ctx, so)
+	if err != nil {
+		return nil, err
+	}
+	return GQLRespConvert[KnowledgeItem](gr, kb.Class), nil
+}
+
+func (kb *KnowledgeBase) GetByID(ctx context.Context, id string) (*KnowledgeItem, error) {
+	obj, err := kb.GetObjByID(ctx, id)
+	if e, ok := err.(*fault.WeaviateClientError); ok && e.StatusCode == http.StatusNotFound || err == nil && obj == nil {
+		return nil, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ObjectToItem(obj), nil
+}
+
+func (kb *KnowledgeBase) Delete(ctx context.Context, ids ...string) error {
+	return kb.DeleteObjects(ctx, ids...)
+}
+
+func (kb *KnowledgeBase) List(ctx context.Context, limit int, after string) ([]*KnowledgeItem, error) {
+	builder := kb.GraphQL().Get().WithClassName(kb.Class).WithFields(fieldsList(FieldsAll...)...).WithLimit(limit)
+	if after != "" {
+		builder = builder.WithAfter(after)
+	}
+	res, err := builder.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if res.Errors != nil {
+		return nil, errorx.Rawf("WW errors: %v", res.Errors)
+	}
+	return GQLRespConvert[KnowledgeItem](res, kb.Class), nil
+}
+
+// Count returns count of objects in the class using Aggregate meta count.
+func (kb *KnowledgeBase) Count(ctx context.Context) (int, error) {
+	return kb.CountWhere(ctx, nil)
+}
+
+// CountWhere returns count of objects matched by filter using Aggregate meta count.
+func (kb *KnowledgeBase) CountWhere(ctx context.Context, where *filters.WhereBuilder) (int, error) {
+	builder := kb.GraphQL().Aggregate().WithClassName(kb.Class).
+		WithFields(graphql.Field{Name: "meta", Fields: []graphql.Field{{Name: "count"}}})
+	if where != nil {
+		builder = builder.WithWhere(where)
+	}
+	res, err := builder.Do(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if res.Errors != nil {
+		return 0, errorx.Rawf("WW errors: %v", res.Errors)
+	}
+	var agg map[string][]struct {
+		Meta struct {
+			Count int `json:"count"`
+		} `json:"meta"`
+	}
+	utils.JsonToStruct(utils.Json(res.Data["Aggregate"]), &agg)
+	if len(agg[kb.Class]) == 0 {
+		return 0, nil
+	}
+	return agg[kb.Class][0].Meta.Count, nil
+}
+
+// ObjectToItem converts Weaviate object to KnowledgeItem with id and timestamps in Additional.
+func ObjectToItem(obj *models.Object) *KnowledgeItem {
+	var item KnowledgeItem
+	utils.JsonToStruct(utils.Json(obj.Properties), &item)
+	if props, ok := obj.Properties.(map[string]interface{}); ok && props["keywords"] != nil {
+		item.KeyWords = goutil.String(props["keywords"])
+	}
+	item.Additional = AdditionalMap{
+		"id":                 obj.ID.String(),
+		"creationTimeUnix":   goutil.String(obj.CreationTimeUnix),
+		"lastUpdateTimeUnix": goutil.String(obj.LastUpdateTimeUnix),
+	}
+	return &item
+}
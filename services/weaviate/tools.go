@@ -9,7 +9,6 @@ import (
 	"github.com/gookit/goutil/strutil"
 	"github.com/gookit/slog"
 	"github.com/mitchellh/mapstructure"
-	tokenizer "github.com/samber/go-gpt-3-encoder"
 	"github.com/samber/lo"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
@@ -32,6 +31,14 @@ const (
 	FieldUrl         Field = "url"
 	FieldKeywords    Field = "keywords"
 	FieldSummary     Field = "summary"
+	FieldSectionPath Field = "sectionPath"
+	FieldTags        Field = "tags"
+	FieldLanguage    Field = "language"
+	FieldPages       Field = "pages"
+	FieldSheet       Field = "sheet"
+	FieldRowRange    Field = "rowRange"
+	FieldOwner       Field = "owner"
+	FieldValidUntil  Field = "validUntil"
 	FieldAdditional1 Field = Additioanl1
 	FieldAdditional2 Field = Additioanl2
 	FieldAdditional3 Field = Additioanl3
@@ -46,11 +53,12 @@ func f(fieldName string) graphql.Field { return graphql.Field{Name: fieldName} }
 type FilterWhereFunc func(s string) *filters.WhereBuilder
 
 func WhereTitleLike(s string) FilterWhereFunc {
-	return func(s string) *filters.WhereBuilder { return FilterWhereTitleLike(s) }
+	return func(string) *filters.WhereBuilder { return FilterWhereTitleLike(s) }
 }
 
 var FilterWhereCategory = func(s string) *filters.WhereBuilder { return filterWhere(FieldCategory.String(), s, filters.Equal) }
 var FilterWhereTitleLike = func(s string) *filters.WhereBuilder { return filterWhere(FieldTitle.String(), s, filters.Like) }
+var FilterWhereUrlEQ = func(s string) *filters.WhereBuilder { return filterWhere(FieldUrl.String(), s, filters.Equal) }
 var FilterWhereTitleEQ = func(s string) *filters.WhereBuilder { return filterWhere(FieldTitle.String(), s, filters.Equal) }
 var filterWhere = func(f, value string, op filters.WhereOperator) *filters.WhereBuilder {
 	return filters.Where().WithPath([]string{f}).WithOperator(op).WithValueText(value)
@@ -132,7 +140,7 @@ func help_object_to_str(obj *models.Object) string {
 func time2str(timeUnix int64) string { return time.UnixMilli(timeUnix).Format(time.DateTime) }
 
 func countTokens(content string) int {
-	return len(lo.Must(lo.Must(tokenizer.NewEncoder()).Encode(content)))
+	return len(lo.Must(gptEncoder().Encode(content)))
 }
 
 func FindDuplicates(objarrr []*models.Object) (dupls []*models.Object) {
@@ -164,21 +172,44 @@ func WeaviateSearch(log *slog.Record, w *weaviate.Client, className string, so *
 	builder := w.GraphQL().Get().WithClassName(className).
 		WithFields(so.GetFields()...).
 		WithLimit(so.LimitItems)
-
-	if so.SearchText != "" {
-		h := w.GraphQL().HybridArgumentBuilder().
-			WithQuery(so.SearchText).
-			WithFusionType(graphql.Ranked).
-			WithProperties(so.SearchFields)
-		builder = builder.WithHybrid(h)
+	if so.Offset > 0 {
+		builder = builder.WithOffset(so.Offset)
+	}
+	if so.After != "" {
+		builder = builder.WithAfter(so.After)
+	}
+
+	if so.SearchText != "" || len(so.Vector) > 0 {
+		switch so.Mode {
+		case SearchBM25:
+			builder = builder.WithBM25(w.GraphQL().Bm25ArgBuilder().WithQuery(so.SearchText).WithProperties(so.SearchFields...))
+		case SearchVector:
+			if len(so.Vector) > 0 {
+				builder = builder.WithNearVector(w.GraphQL().NearVectorArgBuilder().WithVector(so.Vector))
+			} else {
+				builder = builder.WithNearText(w.GraphQL().NearTextArgBuilder().WithConcepts([]string{so.SearchText}))
+			}
+		default:
+			h := w.GraphQL().HybridArgumentBuilder().
+				WithQuery(so.SearchText).
+				WithFusionType(graphql.Ranked).
+				WithProperties(so.SearchFields)
+			if so.Alpha > 0 {
+				h = h.WithAlpha(so.Alpha)
+			}
+			if len(so.Vector) > 0 {
+				h = h.WithVector(so.Vector)
+			}
+			builder = builder.WithHybrid(h)
+		}
 	}
 
 	if len(so.SortBy) > 0 {
 		builder = builder.WithSort(so.GetSort()...)
 	}
 
-	if so.whereCondition != nil {
-		builder = builder.WithWhere(so.whereCondition)
+	if where := so.GetWhere(); where != nil {
+		builder = builder.WithWhere(where)
 	}
 
 	r, e := builder.Do(log.Ctx)
@@ -141,7 +141,8 @@ func Test_ToSearchOptions(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Log(utils.JsonPrettyStr(tt.sr))
-			so := tt.sr.ToSearchOptions()
+			so, err := tt.sr.ToSearchOptions()
+			assert.NoError(t, err)
 			t.Logf("so: %#v", so)
 
 			resp, err := WeaviateSearch(record, client, DefaultClassKB, so)
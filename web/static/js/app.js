@@ -325,6 +325,26 @@ function processSSE(url, messageCallback, errorCallback) {
     messageCallback(event);
   });
 
+  es.addEventListener("chatgpt_delta", function (event) {
+    console.debug("Custom SSE event [chatgpt_delta] received:", event);
+    handleEventChatDelta(event);
+  });
+
+  es.addEventListener("citations", function (event) {
+    console.debug("Custom SSE event [citations] received:", event);
+    handleEventCitations(event);
+  });
+
+  es.addEventListener("ingest_job", function (event) {
+    console.debug("Custom SSE event [ingest_job] received:", event);
+    handleEventIngestJob(event);
+  });
+
+  es.addEventListener("quota", function (event) {
+    console.debug("Custom SSE event [quota] received:", event);
+    handleEventQuota(event);
+  });
+
   sseLogStatus();
   localStorage.setItem('sse-active', 'true')
 
@@ -385,6 +405,59 @@ function handleEventChatGPT(e) {
   }
 }
 
+// append streamed part of the answer (JSON string) to the last bubble, markdown is rendered on "######"
+function handleEventChatDelta(e) {
+  if (!isValidJSON(e.data)) {
+    console.warn("<<handleEventChatDelta>> data is not JSON:", e.data);
+    return;
+  }
+  const text = JSON.parse(e.data);
+  textChunks += text;
+  $(".chat-end > .chat-bubble > span.loading").last().before(document.createTextNode(text));
+  const chatMsg = document.getElementById("chat-msg");
+  chatMsg.scrollTo(0, chatMsg.scrollHeight, { behavior: "smooth" });
+}
+
+// add list of sources(citations) under the last answer of AI
+function handleEventCitations(e) {
+  if (!isValidJSON(e.data)) {
+    console.warn("<<handleEventCitations>> data is not JSON:", e.data);
+    return;
+  }
+  const links = JSON.parse(e.data).map((c) => {
+    const title = c.sectionPath ? `${c.title} > ${c.sectionPath}` : c.title;
+    const text = `${title}${c.chunkNo ? ` [#${c.chunkNo}]` : ""}`;
+    const link = c.url ? `<a class="link link-hover" href="${c.url}" target="_blank">${text}</a>` : text;
+    return `<li title="score=${c.score}">${link}</li>`;
+  });
+  $(".chat.chat-end:last > .chat-bubble").after(`<div class="chat-footer citations text-xs opacity-70"><ol class="list-decimal list-inside">${links.join("")}</ol></div>`);
+}
+
+// show progress of background ingestion job on vector db admin page
+function handleEventIngestJob(e) {
+  if (!isValidJSON(e.data)) {
+    console.warn("<<handleEventIngestJob>> data is not JSON:", e.data);
+    return;
+  }
+  const p = JSON.parse(e.data);
+  const item = p.item ? ` | ${p.item.source}: ${p.item.status}${p.item.error ? ` (${p.item.error})` : ""}` : "";
+  $("#response").removeClass("hidden").text(`Job ${p.jobId}: ${p.status} [${p.finished}/${p.total}]${item}`);
+}
+
+// show notice in chat when AI quota of the user is nearly used or used up
+function handleEventQuota(e) {
+  if (!isValidJSON(e.data)) {
+    console.warn("<<handleEventQuota>> data is not JSON:", e.data);
+    return;
+  }
+  const q = JSON.parse(e.data);
+  const color = q.exceeded ? "bg-red-100 text-red-700 border-red-700" : "bg-yellow-100 text-yellow-800 border-yellow-700";
+  const notice = $(`<div class="flex justify-center"><div class="${color} border rounded px-4 py-3 leading-normal mb-4"></div></div>`);
+  notice.children().text(q.message);
+  $("#chat-msg").append(notice);
+  setTimeout(() => notice.remove(), q.exceeded ? 10000 : 5000);
+}
+
 function chatBeautifullLast() {
   $('.chat.chat-end>div.chat-bubble:last').each((i, v) => {
     console.log(`parseMdToHTML: INPUT ELEMENT[idx=${i}]; Text=>[${$(this).text()}]`);
@@ -149,8 +149,11 @@ func (h *VectorDBHandler) WeaviateDocumentUpload(c *fiber.Ctx) error {
 	}
 	r.Infof("File[%s] save to[%s] - OK!", file.Filename, filePath)
 
-	dp.WithFilePaths(filePath).Process(r.Ctx, m.ContentSaveToVectorDB(kb))
-	kb.AddToWeaviateBatch(r.Ctx)
+	batch := kb.NewBatch(r.Ctx)
+	dp.WithFilePaths(filePath).Process(r.Ctx, m.ContentSaveToVectorDB(batch))
+	if res, err := batch.Flush(r.Ctx); err != nil {
+		r.Errorf("Save to weaviate - FAIL! %s err=%v", res, err)
+	}
 
 	return c.SendStatus(200)
 }
@@ -180,7 +183,12 @@ func (h *VectorDBHandler) WeaviateDocumntsHandler(c *fiber.Ctx) error {
 			log.Error("Error parsing request body: ", err)
 			return err
 		}
-		ki, err = h.fetchDataFromWeaviate(req.ToSearchOptions())
+		var so *wvservice.SearchOptions
+		if so, err = req.ToSearchOptions(); err != nil {
+			log.Error("Invalid search request: ", err)
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+		ki, err = h.fetchDataFromWeaviate(so)
 	} else {
 		ki, err = h.fetchDataFromWeaviate()
 	}